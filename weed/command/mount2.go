@@ -28,6 +28,27 @@ type Mount2Options struct {
 	readOnly           *bool
 	debug              *bool
 	debugPort          *int
+	maxNameLength      *int
+	aliasLongNames     *bool
+	nameNormalization  *string
+	warmFileSizeKB     *int64
+	dirSortBy          *string
+	dirSortLimit       *int
+	dirListNoCache     *bool
+	dirListGlob        *string
+	listXAttrs         *string
+	dirPrefetch        *int
+	dirPrefetchDepth   *int
+	entryGeneration    *bool
+	unionDirs          *string
+	dirPlusWorkers     *int
+	readCacheSizeMB    *int64
+	dirListDirsOnly    *bool
+	dirListFullPaths   *bool
+	readQoS            *string
+	localSockets       *string
+	dirListCacheTTL    *time.Duration
+	dirListCacheLimit  *int
 }
 
 var (
@@ -58,6 +79,27 @@ func init() {
 	mount2Options.readOnly = cmdMount2.Flag.Bool("readOnly", false, "read only")
 	mount2Options.debug = cmdMount2.Flag.Bool("debug", false, "serves runtime profiling data, e.g., http://localhost:<debug.port>/debug/pprof/goroutine?debug=2")
 	mount2Options.debugPort = cmdMount2.Flag.Int("debug.port", 6061, "http port for debugging")
+	mount2Options.maxNameLength = cmdMount2.Flag.Int("maxNameLength", 0, "if not 0, skip entries with longer names when listing directories")
+	mount2Options.aliasLongNames = cmdMount2.Flag.Bool("aliasLongNames", false, "list entries longer than maxNameLength under a shortened alias instead of skipping them")
+	mount2Options.nameNormalization = cmdMount2.Flag.String("nameNormalization", "", "[nfc|nfd] list and look up the entry names in this unicode form, for clients mixing macOS and Linux names")
+	mount2Options.warmFileSizeKB = cmdMount2.Flag.Int64("warmFileSizeKB", 0, "if not 0, prefetch the first chunk of listed files up to this size into the chunk cache")
+	mount2Options.dirSortBy = cmdMount2.Flag.String("dirSortBy", "name", "[name|name-desc|mtime|size] order of directory listings, newest, largest or last named first")
+	mount2Options.dirSortLimit = cmdMount2.Flag.Int("dirSortLimit", 100000, "directories with more entries are listed by name, with -dirSortBy mtime or size")
+	mount2Options.listXAttrs = cmdMount2.Flag.String("listXAttrs", "", "comma separated extended attribute names to keep when listing directories, to answer getxattr right after a listing")
+	mount2Options.dirPrefetch = cmdMount2.Flag.Int("dirPrefetch", 0, "if not 0, the number of workers listing the subdirectories of a directory in the background, to speed up recursive walks")
+	mount2Options.dirPrefetchDepth = cmdMount2.Flag.Int("dirPrefetchDepth", 1, "how many levels of subdirectories to list in the background, with -dirPrefetch")
+	mount2Options.entryGeneration = cmdMount2.Flag.Bool("entryGeneration", false, "give a reused inode a new generation, for clients caching files by inode and generation")
+	mount2Options.unionDirs = cmdMount2.Flag.String("unionDirs", "", "comma separated <dir>:<lowerDir> filer paths, to list each dir merged with the read only entries of its lowerDir")
+	mount2Options.dirPlusWorkers = cmdMount2.Flag.Int("dirPlusWorkers", 0, "if more than 1, the number of workers filling in the attributes of large directory listings")
+	mount2Options.dirListNoCache = cmdMount2.Flag.Bool("dirListNoCache", false, "list directories from the filer each time, to see changes made by other clients at once")
+	mount2Options.dirListGlob = cmdMount2.Flag.String("dirListGlob", "", "if not empty, list only the directory entries matching this pattern, e.g. \"*.parquet\"")
+	mount2Options.dirListDirsOnly = cmdMount2.Flag.Bool("dirListDirsOnly", false, "list only the subdirectories of directories, for tree walkers such as \"find -type d\"")
+	mount2Options.dirListFullPaths = cmdMount2.Flag.Bool("dirListFullPaths", false, "give each entry its filer path in the user.seaweedfs.path extended attribute, e.g. for NFS re-exports and indexers")
+	mount2Options.dirListCacheTTL = cmdMount2.Flag.Duration("dirListCacheTTL", 0, "if not 0, share the listing of a directory among the processes reading it within this long, e.g. 2s")
+	mount2Options.dirListCacheLimit = cmdMount2.Flag.Int("dirListCacheLimit", 10000, "directories with more entries are listed by each process, with -dirListCacheTTL")
+	mount2Options.readQoS = cmdMount2.Flag.String("readQoS", "", "comma separated <class>:<MB per second> read bandwidth limits, for the files tagged with the class in their user.qos extended attribute")
+	mount2Options.localSockets = cmdMount2.Flag.String("localSockets", "", "comma separated <port>:<unix socket path>, to read from the volume servers of this machine on the ports over their unix sockets")
+	mount2Options.readCacheSizeMB = cmdMount2.Flag.Int64("readCacheSizeMB", 0, "if not 0, keep up to this many MB of recently read file data in memory, for repeated reads of the same ranges")
 
 	mountCpuProfile = cmdMount2.Flag.String("cpuprofile", "", "cpu profile output file")
 	mountMemProfile = cmdMount2.Flag.String("memprofile", "", "memory profile output file")
@@ -18,6 +18,7 @@ import (
 	"net/http"
 	"os"
 	"os/user"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -136,6 +137,35 @@ func RunMount2(option *Mount2Options, umask os.FileMode) bool {
 		return false
 	}
 
+	unionDirs, err := mount.ParseUnionDirs(*option.unionDirs)
+	if err != nil {
+		fmt.Printf("failed to parse %s: %v\n", *option.unionDirs, err)
+		return false
+	}
+	readQoSClasses, err := mount.ParseReadQoSClasses(*option.readQoS)
+	if err != nil {
+		fmt.Printf("failed to parse %s: %v\n", *option.readQoS, err)
+		return false
+	}
+	localSockets, err := util.ParseLocalSockets(*option.localSockets)
+	if err != nil {
+		fmt.Printf("failed to parse %s: %v\n", *option.localSockets, err)
+		return false
+	}
+	util.SetLocalSockets(localSockets)
+	if _, err := filepath.Match(*option.dirListGlob, ""); err != nil {
+		fmt.Printf("failed to parse %s: %v\n", *option.dirListGlob, err)
+		return false
+	}
+	if err := mount.CheckNameNormalization(*option.nameNormalization); err != nil {
+		fmt.Printf("failed to parse %s: %v\n", *option.nameNormalization, err)
+		return false
+	}
+	if err := mount.CheckDirSortMode(*option.dirSortBy); err != nil {
+		fmt.Printf("failed to parse %s: %v\n", *option.dirSortBy, err)
+		return false
+	}
+
 	// Ensure target mount point availability
 	if isValid := checkMountPointAvailable(dir); !isValid {
 		glog.Fatalf("Expected mount to still be active, target mount point: %s, please check!", dir)
@@ -171,30 +201,54 @@ func RunMount2(option *Mount2Options, umask os.FileMode) bool {
 	}
 
 	seaweedFileSystem := mount.NewSeaweedFileSystem(&mount.Option{
-		MountDirectory:     dir,
-		FilerAddresses:     filerAddresses,
-		GrpcDialOption:     grpcDialOption,
-		FilerMountRootPath: mountRoot,
-		Collection:         *option.collection,
-		Replication:        *option.replication,
-		TtlSec:             int32(*option.ttlSec),
-		DiskType:           types.ToDiskType(*option.diskType),
-		ChunkSizeLimit:     int64(chunkSizeLimitMB) * 1024 * 1024,
-		ConcurrentWriters:  *option.concurrentWriters,
-		CacheDir:           *option.cacheDir,
-		CacheSizeMB:        *option.cacheSizeMB,
-		DataCenter:         *option.dataCenter,
-		MountUid:           uid,
-		MountGid:           gid,
-		MountMode:          mountMode,
-		MountCtime:         fileInfo.ModTime(),
-		MountMtime:         time.Now(),
-		Umask:              umask,
-		VolumeServerAccess: *mountOptions.volumeServerAccess,
-		Cipher:             cipher,
-		UidGidMapper:       uidGidMapper,
+		MountDirectory:         dir,
+		FilerAddresses:         filerAddresses,
+		GrpcDialOption:         grpcDialOption,
+		FilerMountRootPath:     mountRoot,
+		Collection:             *option.collection,
+		Replication:            *option.replication,
+		TtlSec:                 int32(*option.ttlSec),
+		DiskType:               types.ToDiskType(*option.diskType),
+		ChunkSizeLimit:         int64(chunkSizeLimitMB) * 1024 * 1024,
+		ConcurrentWriters:      *option.concurrentWriters,
+		CacheDir:               *option.cacheDir,
+		CacheSizeMB:            *option.cacheSizeMB,
+		DataCenter:             *option.dataCenter,
+		MountUid:               uid,
+		MountGid:               gid,
+		MountMode:              mountMode,
+		MountCtime:             fileInfo.ModTime(),
+		MountMtime:             time.Now(),
+		Umask:                  umask,
+		VolumeServerAccess:     *mountOptions.volumeServerAccess,
+		Cipher:                 cipher,
+		UidGidMapper:           uidGidMapper,
+		MaxNameLength:          *option.maxNameLength,
+		AliasLongNames:         *option.aliasLongNames,
+		NameNormalization:      *option.nameNormalization,
+		WarmFileSizeLimit:      *option.warmFileSizeKB * 1024,
+		DirSortMode:            *option.dirSortBy,
+		DirSortLimit:           *option.dirSortLimit,
+		DirListNoCache:         *option.dirListNoCache,
+		DirListGlob:            *option.dirListGlob,
+		DirListDirsOnly:        *option.dirListDirsOnly,
+		DirListFullPaths:       *option.dirListFullPaths,
+		ListXAttrNames:         strings.Split(*option.listXAttrs, ","),
+		DirPrefetchConcurrency: *option.dirPrefetch,
+		DirPrefetchDepth:       *option.dirPrefetchDepth,
+		EntryGeneration:        *option.entryGeneration,
+		UnionDirs:              unionDirs,
+		DirPlusWorkers:         *option.dirPlusWorkers,
+		ReadCacheSizeMB:        *option.readCacheSizeMB,
+		ReadQoSClasses:         readQoSClasses,
+		DirListingCacheTTL:     *option.dirListCacheTTL,
+		DirListingCacheLimit:   *option.dirListCacheLimit,
 	})
 
+	if *mountOptions.debug {
+		http.HandleFunc("/debug/mount/dirs", seaweedFileSystem.ServeDirectoryReadStats)
+	}
+
 	server, err := fuse.NewServer(seaweedFileSystem, dir, fuseMountOptions)
 	if err != nil {
 		glog.Fatalf("Mount fail: %v", err)
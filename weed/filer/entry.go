@@ -136,3 +136,10 @@ func maxUint64(x, y uint64) uint64 {
 	}
 	return y
 }
+
+func minUint64(x, y uint64) uint64 {
+	if x < y {
+		return x
+	}
+	return y
+}
@@ -2,6 +2,8 @@ package filer
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/chrislusf/seaweedfs/weed/wdclient"
 	"io"
@@ -93,55 +95,138 @@ func ResolveOneChunkManifest(lookupFileIdFn wdclient.LookupFileIdFunctionType, c
 
 // TODO fetch from cache for weed mount?
 func fetchChunk(lookupFileIdFn wdclient.LookupFileIdFunctionType, fileId string, cipherKey []byte, isGzipped bool) ([]byte, error) {
+	return fetchChunkOfSize(context.Background(), lookupFileIdFn, fileId, cipherKey, isGzipped, 0)
+}
+
+// fetchChunkOfSize fetches the whole chunk, taking a body shorter than chunkSize, the size recorded for the chunk
+// in its entry, as truncated by the transport. 0 accepts any size.
+func fetchChunkOfSize(ctx context.Context, lookupFileIdFn wdclient.LookupFileIdFunctionType, fileId string, cipherKey []byte, isGzipped bool, chunkSize uint64) ([]byte, error) {
 	urlStrings, err := lookupFileIdFn(fileId)
 	if err != nil {
-		glog.Errorf("operation LookupFileId %s failed, err: %v", fileId, err)
-		return nil, err
+		readErrorLog.Logf("lookup "+VolumeId(fileId), "operation LookupFileId %s failed, err: %v", fileId, err)
+		return nil, lookupError(fileId, err)
 	}
-	return retriedFetchChunkData(urlStrings, cipherKey, isGzipped, true, 0, 0)
+	return retriedFetchChunkDataWithAttempts(ctx, urlStrings, cipherKey, isGzipped, true, 0, int(chunkSize), nil)
 }
 
 func fetchChunkRange(lookupFileIdFn wdclient.LookupFileIdFunctionType, fileId string, cipherKey []byte, isGzipped bool, offset int64, size int) ([]byte, error) {
+	return fetchChunkRangeWithContext(context.Background(), lookupFileIdFn, fileId, cipherKey, isGzipped, offset, size)
+}
+
+func fetchChunkRangeWithContext(ctx context.Context, lookupFileIdFn wdclient.LookupFileIdFunctionType, fileId string, cipherKey []byte, isGzipped bool, offset int64, size int) ([]byte, error) {
 	urlStrings, err := lookupFileIdFn(fileId)
 	if err != nil {
-		glog.Errorf("operation LookupFileId %s failed, err: %v", fileId, err)
-		return nil, err
+		readErrorLog.Logf("lookup "+VolumeId(fileId), "operation LookupFileId %s failed, err: %v", fileId, err)
+		return nil, lookupError(fileId, err)
 	}
-	return retriedFetchChunkData(urlStrings, cipherKey, isGzipped, false, offset, size)
+	return retriedFetchChunkDataWithAttempts(ctx, urlStrings, cipherKey, isGzipped, false, offset, size, nil)
 }
 
 func retriedFetchChunkData(urlStrings []string, cipherKey []byte, isGzipped bool, isFullChunk bool, offset int64, size int) ([]byte, error) {
+	return retriedFetchChunkDataWithAttempts(context.Background(), urlStrings, cipherKey, isGzipped, isFullChunk, offset, size, nil)
+}
+
+// checkReceivedSize fails a response shorter than the expected size, e.g. a proxy ending it early without failing it,
+// so the bytes received are not trusted either. A longer response is cut at the size, e.g. a needle
+// rewritten larger than the chunk its entry refers to. A size of 0 is not checked.
+func checkReceivedSize(urlString string, received []byte, size int, offset int64) ([]byte, error) {
+	if size > 0 && len(received) < size {
+		return received, fmt.Errorf("%s: %w", urlString, &TruncatedChunkError{Size: int64(len(received)), Expected: int64(size), Offset: offset})
+	}
+	if size > 0 && len(received) > size {
+		return received[:size], nil
+	}
+	return received, nil
+}
+
+// retriedFetchChunkDataWithAttempts calls onAttempt, if not nil, with the outcome of each request to a replica.
+// A body ending cleanly before size bytes, the whole chunk size for a full chunk if positive, is retried as truncated.
+// Once ctx is done, the request in flight is abandoned and no more are made.
+func retriedFetchChunkDataWithAttempts(ctx context.Context, urlStrings []string, cipherKey []byte, isGzipped bool, isFullChunk bool, offset int64, size int, onAttempt func(urlString string, err error)) ([]byte, error) {
 
 	var err error
 	var shouldRetry bool
 	receivedData := make([]byte, 0, size)
 
+	// plain chunks can continue from the last received byte after an interrupted read,
+	// while encrypted or compressed ones have to be fetched again from the start
+	resumable := cipherKey == nil && !isGzipped
+
 	for waitTime := time.Second; waitTime < util.RetryWaitTime; waitTime += waitTime / 2 {
-		for _, urlString := range urlStrings {
-			receivedData = receivedData[:0]
+		// a replica failing the authentication of its encrypted content has been tampered with,
+		// which is not fixed by retrying it, while the other replicas may still be intact
+		tampered := 0
+		for _, replicaUrl := range urlStrings {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				err, shouldRetry = ctxErr, false
+				break
+			}
+			urlString := replicaUrl
 			if strings.Contains(urlString, "%") {
 				urlString = url.PathEscape(urlString)
 			}
-			shouldRetry, err = util.ReadUrlAsStream(urlString+"?readDeleted=true", cipherKey, isGzipped, isFullChunk, offset, size, func(data []byte) {
-				receivedData = append(receivedData, data...)
-			})
+			received := len(receivedData)
+			if resumable && received > 0 {
+				remaining := -1
+				if !isFullChunk {
+					remaining = size - received
+				}
+				glog.V(1).Infof("resume reading %s from byte %d", urlString, received)
+				shouldRetry, err = resumeUrlAsStream(ctx, readDeletedUrl(urlString), offset+int64(received), remaining, func(data []byte) {
+					receivedData = append(receivedData, data...)
+				})
+				if errors.Is(err, util.ErrRangeIgnored) {
+					// e.g. a proxy answering with the whole chunk, so the bytes received are dropped and read again
+					glog.V(1).Infof("resume reading %s: %v, read from the start", urlString, err)
+					received = 0
+				}
+			}
+			if !resumable || received == 0 {
+				receivedData = receivedData[:0]
+				shouldRetry, err = readUrlAsStream(ctx, readDeletedUrl(urlString), cipherKey, isGzipped, isFullChunk, offset, size, func(data []byte) {
+					receivedData = append(receivedData, data...)
+				})
+			}
+			if err == nil {
+				if receivedData, err = checkReceivedSize(urlString, receivedData, size, offset); err != nil {
+					shouldRetry = true
+					receivedData = receivedData[:0]
+				}
+			}
+			if onAttempt != nil {
+				onAttempt(replicaUrl, err)
+			}
+			if errors.Is(err, util.ErrAuthentication) {
+				tampered++
+			}
 			if !shouldRetry {
 				break
 			}
 			if err != nil {
-				glog.V(0).Infof("read %s failed, err: %v", urlString, err)
+				readFailureLog.Logf("read "+replicaServer(urlString), "read %s failed, err: %v", urlString, err)
 			} else {
 				break
 			}
 		}
+		if tampered == len(urlStrings) {
+			break
+		}
 		if err != nil && shouldRetry {
-			glog.V(0).Infof("retry reading in %v", waitTime)
-			time.Sleep(waitTime)
+			readFailureLog.Logf("retry", "retry reading in %v", waitTime)
+			select {
+			case <-time.After(waitTime):
+			case <-ctx.Done():
+			}
 		} else {
 			break
 		}
 	}
 
+	// content that can not be decoded fails the same on every replica, and so does a chunk shorter than its entry says
+	if err != nil && !errors.Is(err, ErrDecryption) && !errors.Is(err, ErrDecompression) && !errors.Is(err, ErrTruncatedChunk) {
+		err = &ReplicasFailedError{Urls: urlStrings, Err: err}
+	}
+
 	return receivedData, err
 
 }
@@ -154,7 +239,7 @@ func retriedStreamFetchChunkData(writer io.Writer, urlStrings []string, cipherKe
 	for waitTime := time.Second; waitTime < util.RetryWaitTime; waitTime += waitTime / 2 {
 		for _, urlString := range urlStrings {
 			var localProcesed int
-			shouldRetry, err = util.ReadUrlAsStream(urlString+"?readDeleted=true", cipherKey, isGzipped, isFullChunk, offset, size, func(data []byte) {
+			shouldRetry, err = readUrlAsStream(context.Background(), readDeletedUrl(urlString), cipherKey, isGzipped, isFullChunk, offset, size, func(data []byte) {
 				if totalWritten > localProcesed {
 					toBeSkipped := totalWritten - localProcesed
 					if len(data) <= toBeSkipped {
@@ -172,13 +257,13 @@ func retriedStreamFetchChunkData(writer io.Writer, urlStrings []string, cipherKe
 				break
 			}
 			if err != nil {
-				glog.V(0).Infof("read %s failed, err: %v", urlString, err)
+				readFailureLog.Logf("read "+replicaServer(urlString), "read %s failed, err: %v", urlString, err)
 			} else {
 				break
 			}
 		}
 		if err != nil && shouldRetry {
-			glog.V(0).Infof("retry reading in %v", waitTime)
+			readFailureLog.Logf("retry", "retry reading in %v", waitTime)
 			time.Sleep(waitTime)
 		} else {
 			break
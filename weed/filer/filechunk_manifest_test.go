@@ -2,8 +2,15 @@ package filer
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -111,3 +118,136 @@ func mockMerge(saveFunc SaveDataAsChunkFunctionType, dataChunks []*filer_pb.File
 
 	return
 }
+
+func TestRetriedFetchChunkDataResumes(t *testing.T) {
+
+	data := make([]byte, 1024*1024)
+	rand.Read(data)
+	cutOff := 300 * 1024
+
+	var rangeHeaders []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+			rangeHeaders = append(rangeHeaders, rangeHeader)
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+			return
+		}
+		// announce the whole chunk, but drop the connection after cutOff bytes
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(data[:cutOff])
+		w.(http.Flusher).Flush()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	// the same location twice, so the retry does not wait
+	urlStrings := []string{server.URL + "/1,0a0b0c0d", server.URL + "/1,0a0b0c0d"}
+	received, err := retriedFetchChunkData(urlStrings, nil, false, true, 0, 0)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if !bytes.Equal(received, data) {
+		t.Errorf("received %d bytes differ from the %d bytes sent", len(received), len(data))
+	}
+	if len(rangeHeaders) != 1 || rangeHeaders[0] != fmt.Sprintf("bytes=%d-", cutOff) {
+		t.Errorf("unexpected range requests %v", rangeHeaders)
+	}
+
+}
+
+func TestRetriedFetchChunkDataRestartsWhenRangeIgnored(t *testing.T) {
+
+	data := make([]byte, 1024*1024)
+	rand.Read(data)
+	cutOff := 300 * 1024
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 1 {
+			// the range is ignored, and the whole chunk sent
+			w.Write(data)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(data[:cutOff])
+		w.(http.Flusher).Flush()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("hijack: %v", err)
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	urlStrings := []string{server.URL + "/1,0a0b0c0d", server.URL + "/1,0a0b0c0d"}
+	received, err := retriedFetchChunkData(urlStrings, nil, false, true, 0, 0)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if !bytes.Equal(received, data) {
+		t.Errorf("received %d bytes differ from the %d bytes sent", len(received), len(data))
+	}
+
+}
+
+func TestRetriedFetchChunkDataRetriesTruncatedBody(t *testing.T) {
+
+	data := make([]byte, 64*1024)
+	rand.Read(data)
+
+	requests, truncate := 0, true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if truncate {
+			truncate = false
+			// a well formed response, just shorter than the chunk
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)/2))
+			w.Write(data[:len(data)/2])
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		// the same location twice, so the retry does not wait
+		return []string{server.URL + "/" + fileId, server.URL + "/" + fileId}, nil
+	}
+
+	received, err := fetchChunkOfSize(context.Background(), lookupFn, "1,0a0b0c0e", nil, false, uint64(len(data)))
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if !bytes.Equal(received, data) || requests != 2 {
+		t.Errorf("received %d bytes in %d requests, expect %d bytes in 2", len(received), requests, len(data))
+	}
+
+	// a chunk as short as its entry says is taken as is
+	requests = 0
+	received, err = fetchChunkOfSize(context.Background(), lookupFn, "1,0a0b0c0e", nil, false, uint64(len(data)/2))
+	if err != nil || len(received) != len(data)/2 || requests != 1 {
+		t.Errorf("received %d bytes in %d requests: %v", len(received), requests, err)
+	}
+
+	// the fetches reporting the replicas check the chunk size as well
+	requests, truncate = 0, true
+	received, err = fetchChunkReportingReplicas(lookupFn, "1,0a0b0c0e", nil, false, nil, nil, nil, retriedReplicaFetch(context.Background(), nil, false, uint64(len(data))))
+	if err != nil || !bytes.Equal(received, data) || requests != 2 {
+		t.Errorf("reporting fetch received %d bytes in %d requests: %v", len(received), requests, err)
+	}
+
+	requests = 0
+	received, err = fetchChunkRange(lookupFn, "1,0a0b0c0e", nil, false, 1000, 2000)
+	if err != nil || !bytes.Equal(received, data[1000:3000]) || requests != 1 {
+		t.Errorf("range read %d bytes in %d requests: %v", len(received), requests, err)
+	}
+
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"github.com/chrislusf/seaweedfs/weed/util"
 	"io"
+	"time"
 )
 
 var (
@@ -12,6 +13,8 @@ var (
 	ErrUnsupportedSuperLargeDirectoryListing = errors.New("unsupported super large directory listing")
 	ErrKvNotImplemented                      = errors.New("kv not implemented yet")
 	ErrKvNotFound                            = errors.New("kv: not found")
+	ErrUnsupportedDescendingListing          = errors.New("unsupported descending directory listing")
+	ErrUnsupportedModifiedSinceListing       = errors.New("unsupported modified since directory listing")
 )
 
 type ListEachEntryFunc func(entry *Entry) bool
@@ -41,6 +44,18 @@ type FilerStore interface {
 	Shutdown()
 }
 
+// DescendingLister is a FilerStore that can list a directory in descending name order natively.
+type DescendingLister interface {
+	// ListDirectoryEntriesDescending lists the entries before startFileName, or from the last one if startFileName is empty
+	ListDirectoryEntriesDescending(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc ListEachEntryFunc) (lastFileName string, err error)
+}
+
+// ModifiedSinceLister is a FilerStore that can skip the entries not modified after a time while listing a directory.
+type ModifiedSinceLister interface {
+	// ListDirectoryEntriesModifiedSince lists the entries with Mtime after since, the limit counting only them
+	ListDirectoryEntriesModifiedSince(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, since time.Time, eachEntryFunc ListEachEntryFunc) (lastFileName string, err error)
+}
+
 type BucketAware interface {
 	OnBucketCreation(bucket string)
 	OnBucketDeletion(bucket string)
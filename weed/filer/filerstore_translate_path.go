@@ -5,6 +5,7 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/util"
 	"math"
 	"strings"
+	"time"
 )
 
 var (
@@ -117,6 +118,36 @@ func (t *FilerStorePathTranlator) ListDirectoryEntries(ctx context.Context, dirP
 	})
 }
 
+func (t *FilerStorePathTranlator) ListDirectoryEntriesDescending(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc ListEachEntryFunc) (string, error) {
+
+	descendingLister, ok := t.actualStore.(DescendingLister)
+	if !ok {
+		return "", ErrUnsupportedDescendingListing
+	}
+
+	newFullPath := t.translatePath(dirPath)
+
+	return descendingLister.ListDirectoryEntriesDescending(ctx, newFullPath, startFileName, includeStartFile, limit, func(entry *Entry) bool {
+		entry.FullPath = dirPath[:len(t.storeRoot)-1] + entry.FullPath
+		return eachEntryFunc(entry)
+	})
+}
+
+func (t *FilerStorePathTranlator) ListDirectoryEntriesModifiedSince(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, since time.Time, eachEntryFunc ListEachEntryFunc) (string, error) {
+
+	modifiedSinceLister, ok := t.actualStore.(ModifiedSinceLister)
+	if !ok {
+		return "", ErrUnsupportedModifiedSinceListing
+	}
+
+	newFullPath := t.translatePath(dirPath)
+
+	return modifiedSinceLister.ListDirectoryEntriesModifiedSince(ctx, newFullPath, startFileName, includeStartFile, limit, since, func(entry *Entry) bool {
+		entry.FullPath = dirPath[:len(t.storeRoot)-1] + entry.FullPath
+		return eachEntryFunc(entry)
+	})
+}
+
 func (t *FilerStorePathTranlator) ListDirectoryPrefixedEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc) (string, error) {
 
 	newFullPath := t.translatePath(dirPath)
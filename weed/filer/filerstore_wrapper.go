@@ -21,6 +21,8 @@ var (
 
 type VirtualFilerStore interface {
 	FilerStore
+	DescendingLister
+	ModifiedSinceLister
 	DeleteHardLink(ctx context.Context, hardLinkId HardLinkId) error
 	DeleteOneEntry(ctx context.Context, entry *Entry) error
 	AddPathSpecificStore(path string, storeId string, store FilerStore)
@@ -244,6 +246,48 @@ func (fsw *FilerStoreWrapper) ListDirectoryEntries(ctx context.Context, dirPath
 	})
 }
 
+// ListDirectoryEntriesDescending returns ErrUnsupportedDescendingListing if the store of the directory can not list in descending order.
+func (fsw *FilerStoreWrapper) ListDirectoryEntriesDescending(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc ListEachEntryFunc) (string, error) {
+	actualStore := fsw.getActualStore(dirPath + "/")
+	descendingLister, ok := actualStore.(DescendingLister)
+	if !ok {
+		return "", ErrUnsupportedDescendingListing
+	}
+	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "descendingList").Inc()
+	start := time.Now()
+	defer func() {
+		stats.FilerStoreHistogram.WithLabelValues(actualStore.GetName(), "descendingList").Observe(time.Since(start).Seconds())
+	}()
+
+	glog.V(4).Infof("ListDirectoryEntriesDescending %s from %s limit %d", dirPath, startFileName, limit)
+	return descendingLister.ListDirectoryEntriesDescending(ctx, dirPath, startFileName, includeStartFile, limit, func(entry *Entry) bool {
+		fsw.maybeReadHardLink(ctx, entry)
+		filer_pb.AfterEntryDeserialization(entry.Chunks)
+		return eachEntryFunc(entry)
+	})
+}
+
+// ListDirectoryEntriesModifiedSince returns ErrUnsupportedModifiedSinceListing if the store of the directory can not filter by modification time.
+func (fsw *FilerStoreWrapper) ListDirectoryEntriesModifiedSince(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, since time.Time, eachEntryFunc ListEachEntryFunc) (string, error) {
+	actualStore := fsw.getActualStore(dirPath + "/")
+	modifiedSinceLister, ok := actualStore.(ModifiedSinceLister)
+	if !ok {
+		return "", ErrUnsupportedModifiedSinceListing
+	}
+	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "modifiedSinceList").Inc()
+	start := time.Now()
+	defer func() {
+		stats.FilerStoreHistogram.WithLabelValues(actualStore.GetName(), "modifiedSinceList").Observe(time.Since(start).Seconds())
+	}()
+
+	glog.V(4).Infof("ListDirectoryEntriesModifiedSince %s from %s since %v limit %d", dirPath, startFileName, since, limit)
+	return modifiedSinceLister.ListDirectoryEntriesModifiedSince(ctx, dirPath, startFileName, includeStartFile, limit, since, func(entry *Entry) bool {
+		fsw.maybeReadHardLink(ctx, entry)
+		filer_pb.AfterEntryDeserialization(entry.Chunks)
+		return eachEntryFunc(entry)
+	})
+}
+
 func (fsw *FilerStoreWrapper) ListDirectoryPrefixedEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc ListEachEntryFunc) (lastFileName string, err error) {
 	actualStore := fsw.getActualStore(dirPath + "/")
 	stats.FilerStoreCounter.WithLabelValues(actualStore.GetName(), "prefixList").Inc()
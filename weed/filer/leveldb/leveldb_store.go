@@ -11,6 +11,7 @@ import (
 	leveldb_util "github.com/syndtr/goleveldb/leveldb/util"
 	"io"
 	"os"
+	"time"
 
 	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
@@ -24,6 +25,8 @@ const (
 
 var (
 	_ = filer.Debuggable(&LevelDBStore{})
+	_ = filer.DescendingLister(&LevelDBStore{})
+	_ = filer.ModifiedSinceLister(&LevelDBStore{})
 )
 
 func init() {
@@ -175,6 +178,17 @@ func (store *LevelDBStore) ListDirectoryEntries(ctx context.Context, dirPath wee
 }
 
 func (store *LevelDBStore) ListDirectoryPrefixedEntries(ctx context.Context, dirPath weed_util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
+	return store.listDirectoryEntries(ctx, dirPath, startFileName, includeStartFile, limit, prefix, time.Time{}, eachEntryFunc)
+}
+
+// ListDirectoryEntriesModifiedSince skips the older entries while scanning the directory, so the limit counts only
+// the listed ones. The entries are not indexed by time, so every entry of the directory is still read and decoded.
+func (store *LevelDBStore) ListDirectoryEntriesModifiedSince(ctx context.Context, dirPath weed_util.FullPath, startFileName string, includeStartFile bool, limit int64, since time.Time, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
+	return store.listDirectoryEntries(ctx, dirPath, startFileName, includeStartFile, limit, "", since, eachEntryFunc)
+}
+
+// listDirectoryEntries lists the entries with the prefix, modified after since if it is not zero
+func (store *LevelDBStore) listDirectoryEntries(ctx context.Context, dirPath weed_util.FullPath, startFileName string, includeStartFile bool, limit int64, prefix string, since time.Time, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
 
 	directoryPrefix := genDirectoryKeyPrefix(dirPath, prefix)
 	lastFileStart := directoryPrefix
@@ -195,6 +209,49 @@ func (store *LevelDBStore) ListDirectoryPrefixedEntries(ctx context.Context, dir
 		if fileName == startFileName && !includeStartFile {
 			continue
 		}
+		if limit <= 0 {
+			break
+		}
+		entry := &filer.Entry{
+			FullPath: weed_util.NewFullPath(string(dirPath), fileName),
+		}
+		if decodeErr := entry.DecodeAttributesAndChunks(weed_util.MaybeDecompressData(iter.Value())); decodeErr != nil {
+			err = decodeErr
+			glog.V(0).Infof("list %s : %v", entry.FullPath, err)
+			break
+		}
+		if !since.IsZero() && !entry.Mtime.After(since) {
+			continue
+		}
+		limit--
+		lastFileName = fileName
+		if !eachEntryFunc(entry) {
+			break
+		}
+	}
+	iter.Release()
+
+	return lastFileName, err
+}
+
+func (store *LevelDBStore) ListDirectoryEntriesDescending(ctx context.Context, dirPath weed_util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc filer.ListEachEntryFunc) (lastFileName string, err error) {
+
+	directoryPrefix := genDirectoryKeyPrefix(dirPath, "")
+	keyRange := leveldb_util.BytesPrefix(directoryPrefix)
+	if startFileName != "" {
+		// the range limit is exclusive
+		keyRange.Limit = genDirectoryKeyPrefix(dirPath, startFileName)
+		if includeStartFile {
+			keyRange.Limit = append(keyRange.Limit, 0)
+		}
+	}
+
+	iter := store.db.NewIterator(keyRange, nil)
+	for ok := iter.Last(); ok; ok = iter.Prev() {
+		fileName := getNameFromKey(iter.Key())
+		if fileName == "" {
+			continue
+		}
 		limit--
 		if limit < 0 {
 			break
@@ -86,6 +86,67 @@ func TestEmptyRoot(t *testing.T) {
 
 }
 
+func TestListDirectoryEntriesByPages(t *testing.T) {
+	dir := t.TempDir()
+	store := &LevelDBStore{}
+	store.initialize(dir)
+	defer store.Shutdown()
+
+	ctx := context.Background()
+
+	// names prefixed by others, and entries of a subdirectory and of a sibling directory with a longer name
+	names := []string{"a", "b", "ba", "bb", "c", "d", "e", "f", "g"}
+	for _, fullpath := range []string{"/dir/b/x", "/dirx/a", "/dirx/z"} {
+		store.InsertEntry(ctx, &filer.Entry{FullPath: util.FullPath(fullpath)})
+	}
+	for _, name := range names {
+		store.InsertEntry(ctx, &filer.Entry{FullPath: util.FullPath("/dir").Child(name)})
+	}
+
+	listByPages := func(descending bool) (listed []string) {
+		listFn := store.ListDirectoryEntries
+		if descending {
+			listFn = store.ListDirectoryEntriesDescending
+		}
+		for lastFileName := ""; ; {
+			count := 0
+			var err error
+			lastFileName, err = listFn(ctx, "/dir", lastFileName, false, 2, func(entry *filer.Entry) bool {
+				listed = append(listed, entry.Name())
+				count++
+				return true
+			})
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if count == 0 {
+				return
+			}
+		}
+	}
+
+	if listed := listByPages(false); fmt.Sprint(listed) != fmt.Sprint(names) {
+		t.Errorf("listed %v, expect %v", listed, names)
+	}
+	var reversed []string
+	for i := len(names) - 1; i >= 0; i-- {
+		reversed = append(reversed, names[i])
+	}
+	if listed := listByPages(true); fmt.Sprint(listed) != fmt.Sprint(reversed) {
+		t.Errorf("listed %v in descending order, expect %v", listed, reversed)
+	}
+
+	var listed []string
+	store.ListDirectoryEntriesDescending(ctx, "/dir", "ba", true, 100, func(entry *filer.Entry) bool {
+		listed = append(listed, entry.Name())
+		return true
+	})
+	if fmt.Sprint(listed) != "[ba b a]" {
+		t.Errorf("listed %v from ba inclusive, expect [ba b a]", listed)
+	}
+
+}
+
 func BenchmarkInsertEntry(b *testing.B) {
 	testFiler := filer.NewFiler(nil, nil, "", "", "", "", nil)
 	dir := b.TempDir()
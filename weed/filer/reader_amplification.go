@@ -0,0 +1,133 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// ErrReadAmplification is matched by errors.Is for the tiny reads refused by a ReadAmplificationGuard.
+var ErrReadAmplification = errors.New("read amplification")
+
+// ReadAmplificationError tells how much the tiny reads fetched before the guard engaged.
+type ReadAmplificationError struct {
+	Reads        int
+	ReadBytes    int64
+	Fetches      int64
+	FetchedBytes int64
+}
+
+func (e *ReadAmplificationError) Error() string {
+	return fmt.Sprintf("%d reads of %d bytes took %d chunk fetches of %d bytes, read through a buffer instead",
+		e.Reads, e.ReadBytes, e.Fetches, e.FetchedBytes)
+}
+
+func (e *ReadAmplificationError) Is(target error) bool {
+	return target == ErrReadAmplification
+}
+
+// ReadAmplificationGuard spots tiny reads that keep going to the volume servers, e.g. 1 byte reads
+// all over 16MB chunks with the chunk cache disabled or evicting. The tiny reads are judged in windows,
+// and once a window fetched too often or too much, the guard engages until the next read larger than tiny.
+type ReadAmplificationGuard struct {
+	TinyReadSize      int64   // reads up to this size are judged, 4KB if 0
+	Window            int     // the number of tiny reads judged together, 64 if 0
+	MaxFetchesPerRead float64 // the chunk fetches per tiny read, 0.5 if 0
+	MaxAmplification  float64 // the bytes fetched per byte read, 1024 if 0
+
+	// an engaged guard refuses the tiny reads with a ReadAmplificationError,
+	// instead of keeping the whole chunks they touch in memory and in the chunk cache
+	Refuse bool
+}
+
+type readAmplification struct {
+	guard   ReadAmplificationGuard
+	window  ReadAmplificationError
+	engaged *ReadAmplificationError
+}
+
+// fetchTally counts the fetches made for one read, and not for the prefetches running along
+type fetchTally struct {
+	fetches      int64
+	fetchedBytes int64
+}
+
+type fetchTallyKey struct{}
+
+// SetReadAmplificationGuard judges the tiny reads by the guard. A nil guard disables it.
+func (c *ChunkReadAt) SetReadAmplificationGuard(guard *ReadAmplificationGuard) {
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+	if guard == nil {
+		c.amplification = nil
+		return
+	}
+	a := &readAmplification{guard: *guard}
+	if a.guard.TinyReadSize <= 0 {
+		a.guard.TinyReadSize = 4 * 1024
+	}
+	if a.guard.Window <= 0 {
+		a.guard.Window = 64
+	}
+	if a.guard.MaxFetchesPerRead <= 0 {
+		a.guard.MaxFetchesPerRead = 0.5
+	}
+	if a.guard.MaxAmplification <= 0 {
+		a.guard.MaxAmplification = 1024
+	}
+	c.amplification = a
+}
+
+// guardedReadAt is doReadAt judged by the read amplification guard, if any
+func (c *ChunkReadAt) guardedReadAt(ctx context.Context, p []byte, offset int64) (n int, err error) {
+
+	a := c.amplification
+	if a == nil {
+		return c.doReadAt(ctx, p, offset)
+	}
+	if int64(len(p)) > a.guard.TinyReadSize {
+		// the caller reads through a buffer now
+		a.window, a.engaged = ReadAmplificationError{}, nil
+		return c.doReadAt(ctx, p, offset)
+	}
+	if a.engaged != nil && a.guard.Refuse {
+		return 0, a.engaged
+	}
+
+	tally := &fetchTally{}
+	n, err = c.doReadAt(context.WithValue(ctx, fetchTallyKey{}, tally), p, offset)
+
+	if a.engaged != nil {
+		return
+	}
+	a.window.Reads++
+	a.window.ReadBytes += int64(n)
+	a.window.Fetches += atomic.LoadInt64(&tally.fetches)
+	a.window.FetchedBytes += atomic.LoadInt64(&tally.fetchedBytes)
+	if a.window.Reads < a.guard.Window {
+		return
+	}
+	if float64(a.window.Fetches) > a.guard.MaxFetchesPerRead*float64(a.window.Reads) ||
+		float64(a.window.FetchedBytes) > a.guard.MaxAmplification*float64(a.window.ReadBytes) {
+		engaged := a.window
+		a.engaged = &engaged
+		glog.V(0).Infof("tiny reads: %v", a.engaged)
+	}
+	a.window = ReadAmplificationError{}
+	return
+}
+
+// keepsChunks tells whether to read and cache whole chunks for the tiny reads
+func (a *readAmplification) keepsChunks() bool {
+	return a != nil && a.engaged != nil && !a.guard.Refuse
+}
+
+func tallyFetch(ctx context.Context, data []byte) {
+	if tally, ok := ctx.Value(fetchTallyKey{}).(*fetchTally); ok {
+		atomic.AddInt64(&tally.fetches, 1)
+		atomic.AddInt64(&tally.fetchedBytes, int64(len(data)))
+	}
+}
@@ -0,0 +1,87 @@
+package filer
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+// noChunkCache is a chunk cache evicting everything at once
+type noChunkCache struct{}
+
+func (noChunkCache) GetChunk(fileId string, minSize uint64) []byte { return nil }
+func (noChunkCache) GetChunkSlice(fileId string, offset, length uint64) []byte {
+	return nil
+}
+func (noChunkCache) SetChunk(fileId string, data []byte) {}
+
+func TestReadAmplificationGuard(t *testing.T) {
+
+	const chunkSize = 1024 * 1024
+	chunks := map[string][]byte{
+		"51,01": randomBytes(chunkSize),
+		"51,02": randomBytes(chunkSize),
+	}
+	content := append(append([]byte{}, chunks["51,01"]...), chunks["51,02"]...)
+	chunkViews := []*ChunkView{
+		{FileId: "51,01", Size: chunkSize, ChunkSize: chunkSize, LogicOffset: 0},
+		{FileId: "51,02", Size: chunkSize, ChunkSize: chunkSize, LogicOffset: chunkSize},
+	}
+
+	for _, refuse := range []bool{false, true} {
+		server := newTestVolumeServer(chunks)
+		readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, noChunkCache{}, int64(len(content)))
+		readerAt.SetReadAmplificationGuard(&ReadAmplificationGuard{Window: 8, Refuse: refuse})
+
+		// 1 byte reads hopping over the second chunk
+		readByte := func(i int) error {
+			offset := int64(chunkSize + (i*7919)%chunkSize)
+			buf := make([]byte, 1)
+			n, err := readerAt.ReadAt(buf, offset)
+			if err != nil {
+				return err
+			}
+			if n != 1 || buf[0] != content[offset] {
+				t.Fatalf("refuse=%v: read at %d: n=%d unexpected content", refuse, offset, n)
+			}
+			return nil
+		}
+		for i := 0; i < 8; i++ {
+			if err := readByte(i); err != nil {
+				t.Fatalf("refuse=%v: read %d: %v", refuse, i, err)
+			}
+		}
+
+		if !refuse {
+			// the engaged guard keeps the whole chunk instead of fetching again
+			requests := atomic.LoadInt32(&server.requests)
+			for i := 8; i < 100; i++ {
+				if err := readByte(i); err != nil {
+					t.Fatalf("read %d: %v", i, err)
+				}
+			}
+			if more := atomic.LoadInt32(&server.requests) - requests; more > 1 {
+				t.Errorf("%d more requests with the guard engaged, expect at most 1", more)
+			}
+			server.Close()
+			continue
+		}
+
+		err := readByte(8)
+		var amplification *ReadAmplificationError
+		if !errors.Is(err, ErrReadAmplification) || !errors.As(err, &amplification) || amplification.Reads != 8 {
+			t.Fatalf("tiny read with the guard engaged: %v, expect a ReadAmplificationError", err)
+		}
+
+		// reading through a buffer resets the guard
+		buf := make([]byte, 64*1024)
+		if n, err := readerAt.ReadAt(buf, chunkSize); n != len(buf) || err != nil {
+			t.Fatalf("buffered read: n=%d err=%v", n, err)
+		}
+		if err := readByte(9); err != nil {
+			t.Errorf("tiny read after a buffered read: %v", err)
+		}
+		server.Close()
+	}
+
+}
@@ -6,6 +6,8 @@ import (
 	"io"
 	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
@@ -16,78 +18,308 @@ import (
 )
 
 type ChunkReadAt struct {
+	cachedBytes  int64 // accessed atomically, so first for its 64 bit alignment on 32 bit platforms
 	masterClient *wdclient.MasterClient
 	chunkViews   []*ChunkView
 	lookupFileId wdclient.LookupFileIdFunctionType
 	readerLock   sync.Mutex
 	fileSize     int64
 
-	fetchGroup      singleflight.Group
+	fetchGroup      *singleflight.Group
 	chunkCache      chunk_cache.ChunkCache
 	lastChunkFileId string
 	lastChunkData   []byte
+	lastChunkView   *ChunkView // the chunk view read last, if its data is lastChunkData
+	tailChunkFileId string     // the final chunk, kept for readers following a growing file
+	tailChunkData   []byte
+	followsTail     bool // appended to, so reads keep coming back to the final chunk
 	readerPattern   *ReaderPattern
+	readHedger      *ReadHedger
+
+	underReplicatedFn UnderReplicatedFn
+	readRepairFn      ReadRepairFn
+	localShortcut     *localShortcut
+	fetchBudget       *fetchBudget
+	cacheKeyFn        func(fileId string) string
+	progress          *readProgress
+	priority          ReadPriority
+	prefetchDepth     int
+	syncPrefetch      bool
+	prefetchedChunks  map[string][]byte // the chunks ahead fetched by a synchronous prefetch
+	prefetchCtx       context.Context   // done once closed, dropping the prefetches still queued
+	prefetchCancel    context.CancelFunc
+	adaptivePrefetch  *adaptivePrefetch
+	eventSink         *readEventSink
+	clock             util.Clock
+	volumeLookup      *VolumeLookup
+	pinnedLookup      *pinnedLookup
+	amplification     *readAmplification
+	readBudget        time.Duration
+	cacheBlockSize    int64
+	eofPolicy         EOFPolicy
+	edgeTemplate      *edgeTemplate
+	fetchTimeout      fetchTimeout
+	checksums         chunkChecksums
+	serveStale        bool
+	staleReadFn       StaleReadFn
+	transportSelector *TransportSelector
 }
 
 var _ = io.ReaderAt(&ChunkReadAt{})
 var _ = io.Closer(&ChunkReadAt{})
 
+// chunkFetchGroups coalesce the fetches of the same chunk by the readers sharing a chunk cache,
+// which share the chunks fetched already, and so the same volume servers.
+// Readers of other caches, e.g. of another filer, do not join their fetches, nor their failures.
+var (
+	chunkFetchGroupsLock sync.Mutex
+	chunkFetchGroups     = make(map[chunk_cache.ChunkCache]*singleflight.Group)
+)
+
+type LookupOptions struct {
+	// keep the replicas in the order returned by the filer, e.g. to pin reads to one replica
+	NoShuffle bool
+	// if set, used to shuffle the replicas instead of the global source, e.g. seeded for reproducible reads
+	Rand *rand.Rand
+	// spaces out the retries of a failing volume lookup, a jittered exponential backoff up to 10 seconds if nil
+	Backoff *util.Backoff
+	// if not 0, the cached locations of a volume are looked up again after this long
+	CacheTTL time.Duration
+	// if set, used instead of the system clock for the cache expiry and the default backoff, e.g. a util.TestClock
+	Clock util.Clock
+	// for a FederatedLookup, ask first the filer picked by the volume id modulo the number of filers
+	ShardByVolumeId bool
+	// tells which replicas are read only, e.g. a *FrozenReplicas kept up to date from the master,
+	// as the volume lookup of the filer does not tell
+	IsFrozen IsFrozenFunc
+	// how to read the replicas IsFrozen tells are read only, as any other replica if not set
+	FrozenVolumes FrozenVolumePolicy
+	// builds the url of a file id on each of its volume servers, e.g. for https, a path prefixed reverse proxy,
+	// or an auth query parameter. DefaultVolumeUrl if nil.
+	VolumeUrl VolumeUrlFunc
+}
+
 func LookupFn(filerClient filer_pb.FilerClient) wdclient.LookupFileIdFunctionType {
+	return LookupFnWithOptions(filerClient, nil)
+}
 
-	vidCache := make(map[string]*filer_pb.Locations)
-	var vicCacheLock sync.RWMutex
-	return func(fileId string) (targetUrls []string, err error) {
-		vid := VolumeId(fileId)
-		vicCacheLock.RLock()
-		locations, found := vidCache[vid]
-		vicCacheLock.RUnlock()
-
-		if !found {
-			util.Retry("lookup volume "+vid, func() error {
-				err = filerClient.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
-					resp, err := client.LookupVolume(context.Background(), &filer_pb.LookupVolumeRequest{
-						VolumeIds: []string{vid},
-					})
-					if err != nil {
-						return err
-					}
-
-					locations = resp.LocationsMap[vid]
-					if locations == nil || len(locations.Locations) == 0 {
-						glog.V(0).Infof("failed to locate %s", fileId)
-						return fmt.Errorf("failed to locate %s", fileId)
-					}
-					vicCacheLock.Lock()
-					vidCache[vid] = locations
-					vicCacheLock.Unlock()
-
-					return nil
-				})
-				return err
-			})
+func LookupFnWithOptions(filerClient filer_pb.FilerClient, opts *LookupOptions) wdclient.LookupFileIdFunctionType {
+	return NewVolumeLookup(filerClient, opts).LookupFileId
+}
+
+// VolumeLookup finds the volume servers of file ids through the filer, caching the volume locations.
+type VolumeLookup struct {
+	filerClient  filer_pb.FilerClient
+	opts         *LookupOptions
+	backoff      *util.Backoff
+	randLock     sync.Mutex
+	clock        util.Clock
+	vidCache     map[string]*cachedVolume
+	vidCacheLock sync.RWMutex
+}
+
+type cachedVolume struct {
+	locations *filer_pb.Locations
+	cachedAt  time.Time
+}
+
+func NewVolumeLookup(filerClient filer_pb.FilerClient, opts *LookupOptions) *VolumeLookup {
+	if opts == nil {
+		opts = &LookupOptions{}
+	}
+	clock := opts.Clock
+	if clock == nil {
+		clock = util.RealClock
+	}
+	backoff := opts.Backoff
+	if backoff == nil {
+		backoff = util.NewBackoff(time.Second, 10*time.Second)
+		backoff.Clock = clock
+	}
+	return &VolumeLookup{
+		filerClient: filerClient,
+		opts:        opts,
+		backoff:     backoff,
+		clock:       clock,
+		vidCache:    make(map[string]*cachedVolume),
+	}
+}
+
+func (vl *VolumeLookup) LookupFileId(fileId string) (targetUrls []string, err error) {
+
+	vid := VolumeId(fileId)
+	locations, found := vl.cachedLocations(vid)
+
+	if !found {
+		err = util.RetryWithBackoff("lookup volume "+vid, vl.backoff, func() error {
+			missing, lookupErr := vl.lookupVolumes(context.Background(), []string{vid})
+			if lookupErr == nil && len(missing) > 0 {
+				glog.V(0).Infof("failed to locate %s", fileId)
+				lookupErr = fmt.Errorf("failed to locate %s", fileId)
+			}
+			return lookupErr
+		})
+		locations, _ = vl.cachedLocations(vid)
+	}
+
+	if err != nil {
+		return nil, &LookupError{FileId: fileId, Err: err}
+	}
+
+	return vl.targetUrls(fileId, locations)
+}
+
+// targetUrls returns the urls of the file id on the volume servers, shuffled unless NoShuffle is set,
+// and then placed by the FrozenVolumes policy
+func (vl *VolumeLookup) targetUrls(fileId string, locations *filer_pb.Locations) (targetUrls []string, err error) {
+
+	locs := append([]*filer_pb.Location(nil), locations.Locations...)
+	if !vl.opts.NoShuffle {
+		vl.shuffle(locs)
+	}
+	if locs, err = vl.placeFrozen(VolumeId(fileId), locs); err != nil {
+		return nil, &LookupError{FileId: fileId, Err: err}
+	}
+
+	for _, loc := range locs {
+		volumeServerAddress := vl.filerClient.AdjustedUrl(loc)
+		targetUrls = append(targetUrls, vl.volumeUrl(volumeServerAddress, fileId))
+	}
+
+	return
+}
+
+func (vl *VolumeLookup) shuffle(locs []*filer_pb.Location) {
+	if vl.opts.Rand != nil {
+		vl.randLock.Lock()
+		defer vl.randLock.Unlock()
+	}
+	for i := len(locs) - 1; i > 0; i-- {
+		var j int
+		if vl.opts.Rand != nil {
+			j = vl.opts.Rand.Intn(i + 1)
+		} else {
+			j = rand.Intn(i + 1)
 		}
+		locs[i], locs[j] = locs[j], locs[i]
+	}
+}
 
-		if err != nil {
-			return nil, err
+// ResolveVolumes looks up all the volumes not cached yet in one request to the filer.
+func (vl *VolumeLookup) ResolveVolumes(ctx context.Context, vids []string) error {
+
+	var unresolved []string
+	seen := make(map[string]struct{})
+	for _, vid := range vids {
+		if _, found := seen[vid]; found {
+			continue
+		}
+		seen[vid] = struct{}{}
+		if _, found := vl.cachedLocations(vid); !found {
+			unresolved = append(unresolved, vid)
 		}
+	}
+	if len(unresolved) == 0 {
+		return nil
+	}
 
-		for _, loc := range locations.Locations {
-			volumeServerAddress := filerClient.AdjustedUrl(loc)
-			targetUrl := fmt.Sprintf("http://%s/%s", volumeServerAddress, fileId)
-			targetUrls = append(targetUrls, targetUrl)
+	return util.RetryWithBackoff(fmt.Sprintf("lookup %d volumes", len(unresolved)), vl.backoff, func() error {
+		missing, err := vl.lookupVolumes(ctx, unresolved)
+		if err == nil && len(missing) > 0 {
+			glog.V(0).Infof("failed to locate volumes %v", missing)
+			err = fmt.Errorf("failed to locate volumes %v", missing)
 		}
+		return err
+	})
+}
+
+// LookupFileIds finds the volume servers of several file ids, looking up their uncached volumes in one request.
+func (vl *VolumeLookup) LookupFileIds(ctx context.Context, fileIds []string) (targetUrls map[string][]string, err error) {
+
+	vids := make([]string, 0, len(fileIds))
+	for _, fileId := range fileIds {
+		vids = append(vids, VolumeId(fileId))
+	}
+	if err = vl.ResolveVolumes(ctx, vids); err != nil {
+		return nil, err
+	}
 
-		for i := len(targetUrls) - 1; i > 0; i-- {
-			j := rand.Intn(i + 1)
-			targetUrls[i], targetUrls[j] = targetUrls[j], targetUrls[i]
+	targetUrls = make(map[string][]string, len(fileIds))
+	for _, fileId := range fileIds {
+		urls, lookupErr := vl.LookupFileId(fileId)
+		if lookupErr != nil {
+			return nil, lookupErr
 		}
+		targetUrls[fileId] = urls
+	}
+	return targetUrls, nil
+}
 
-		return
+// snapshotLocations returns the cached locations of the volumes
+func (vl *VolumeLookup) snapshotLocations(vids []string) map[string]*filer_pb.Locations {
+	snapshot := make(map[string]*filer_pb.Locations, len(vids))
+	for _, vid := range vids {
+		if locations, found := vl.cachedLocations(vid); found {
+			snapshot[vid] = locations
+		}
 	}
+	return snapshot
 }
 
+// forgetLocations drops the cached locations of the volumes, to look them up again
+func (vl *VolumeLookup) forgetLocations(vids []string) {
+	vl.vidCacheLock.Lock()
+	defer vl.vidCacheLock.Unlock()
+	for _, vid := range vids {
+		delete(vl.vidCache, vid)
+	}
+}
+
+func (vl *VolumeLookup) cachedLocations(vid string) (*filer_pb.Locations, bool) {
+	vl.vidCacheLock.RLock()
+	defer vl.vidCacheLock.RUnlock()
+	cached, found := vl.vidCache[vid]
+	if !found || vl.opts.CacheTTL > 0 && vl.clock.Now().Sub(cached.cachedAt) >= vl.opts.CacheTTL {
+		return nil, false
+	}
+	return cached.locations, true
+}
+
+// lookupVolumes caches the locations of the volumes found, and returns the ones not found.
+func (vl *VolumeLookup) lookupVolumes(ctx context.Context, vids []string) (missing []string, err error) {
+	err = vl.filerClient.WithFilerClient(false, func(client filer_pb.SeaweedFilerClient) error {
+		resp, err := client.LookupVolume(ctx, &filer_pb.LookupVolumeRequest{
+			VolumeIds: vids,
+		})
+		if err != nil {
+			return err
+		}
+
+		missing = nil
+		vl.vidCacheLock.Lock()
+		defer vl.vidCacheLock.Unlock()
+		for _, vid := range vids {
+			locations := resp.LocationsMap[vid]
+			if locations == nil || len(locations.Locations) == 0 {
+				missing = append(missing, vid)
+				continue
+			}
+			vl.vidCache[vid] = &cachedVolume{locations: locations, cachedAt: vl.clock.Now()}
+		}
+		return nil
+	})
+	return
+}
+
+// NewChunkReaderAtFromClient reads the chunk views in the order of their file offsets, sorting them if needed.
+// Overlapping chunk views are only logged, see NewStrictChunkReaderAt.
 func NewChunkReaderAtFromClient(lookupFn wdclient.LookupFileIdFunctionType, chunkViews []*ChunkView, chunkCache chunk_cache.ChunkCache, fileSize int64) *ChunkReadAt {
 
+	chunkViews, err := checkChunkViews(chunkViews)
+	if err != nil {
+		glog.Errorf("read chunk views: %v", err)
+	}
+
 	return &ChunkReadAt{
 		chunkViews:    chunkViews,
 		lookupFileId:  lookupFn,
@@ -97,41 +329,174 @@ func NewChunkReaderAtFromClient(lookupFn wdclient.LookupFileIdFunctionType, chun
 	}
 }
 
+// SetFetchGroup makes the reader coalesce its chunk fetches within the group,
+// instead of with all other readers of its chunk cache in this process.
+func (c *ChunkReadAt) SetFetchGroup(fetchGroup *singleflight.Group) {
+	c.fetchGroup = fetchGroup
+}
+
+// SetCacheKeyFn maps the file ids to the keys of the chunk cache and of the coalesced fetches,
+// e.g. CacheKeyInNamespace for readers of different filers sharing one chunk cache. A nil fn keeps the file ids.
+func (c *ChunkReadAt) SetCacheKeyFn(fn func(fileId string) string) {
+	c.cacheKeyFn = fn
+}
+
+// SetClock times the fetches reported to the event sink, e.g. by a util.TestClock. A nil clock is the system clock.
+func (c *ChunkReadAt) SetClock(clock util.Clock) {
+	c.clock = clock
+}
+
+func (c *ChunkReadAt) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
+
+// CacheKeyInNamespace keeps the chunks of the namespace apart from those of the same file ids in other namespaces.
+func CacheKeyInNamespace(namespace string) func(fileId string) string {
+	return func(fileId string) string {
+		return chunk_cache.NamespacedKey(namespace, fileId)
+	}
+}
+
+func (c *ChunkReadAt) cacheKey(fileId string) string {
+	if c.cacheKeyFn != nil {
+		return c.cacheKeyFn(fileId)
+	}
+	return fileId
+}
+
+func (c *ChunkReadAt) getFetchGroup() *singleflight.Group {
+	if c.fetchGroup != nil {
+		return c.fetchGroup
+	}
+	chunkFetchGroupsLock.Lock()
+	defer chunkFetchGroupsLock.Unlock()
+	fetchGroup, found := chunkFetchGroups[c.chunkCache]
+	if !found {
+		fetchGroup = &singleflight.Group{}
+		chunkFetchGroups[c.chunkCache] = fetchGroup
+	}
+	return fetchGroup
+}
+
 func (c *ChunkReadAt) Close() error {
 	c.lastChunkData = nil
 	c.lastChunkFileId = ""
+	c.lastChunkView = nil
+	c.tailChunkData = nil
+	c.tailChunkFileId = ""
+	c.prefetchedChunks = nil
+	if c.prefetchCancel != nil {
+		c.prefetchCancel()
+		c.prefetchCtx, c.prefetchCancel = nil, nil
+	}
+	c.recountCachedBytes()
 	return nil
 }
 
-func (c *ChunkReadAt) ReadAt(p []byte, offset int64) (n int, err error) {
+// Reset makes the reader read another file, keeping its chunk cache, lookup function and other settings,
+// e.g. for readers kept in a pool. It waits for the reads in flight, and forgets the chunks kept from the previous file.
+func (c *ChunkReadAt) Reset(chunkViews []*ChunkView, fileSize int64) {
 
-	c.readerPattern.MonitorReadAt(offset, len(p))
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+
+	c.Close()
+	chunkViews, err := checkChunkViews(chunkViews)
+	if err != nil {
+		glog.Errorf("read chunk views: %v", err)
+	}
+	c.chunkViews = chunkViews
+	c.fileSize = fileSize
+	c.followsTail = false
+	*c.readerPattern = *NewReaderPattern()
+	if c.progress != nil {
+		c.progress = &readProgress{fn: c.progress.fn, interval: c.progress.interval}
+	}
+}
+
+// AppendChunkViews extends the reader to a grown file without dropping the chunks read so far.
+// From then on, the final chunk is kept by the reader, as the reads following the file keep coming back to it.
+// The appended chunk views must not start before the end of the existing ones.
+func (c *ChunkReadAt) AppendChunkViews(fileSize int64, chunkViews ...*ChunkView) error {
 
 	c.readerLock.Lock()
 	defer c.readerLock.Unlock()
 
+	var stop int64
+	if len(c.chunkViews) > 0 {
+		last := c.chunkViews[len(c.chunkViews)-1]
+		stop = last.LogicOffset + int64(last.Size)
+	}
+	for _, chunkView := range chunkViews {
+		if chunkView.LogicOffset < stop {
+			return fmt.Errorf("append chunk %s at %d before the end %d", chunkView.FileId, chunkView.LogicOffset, stop)
+		}
+		stop = chunkView.LogicOffset + int64(chunkView.Size)
+	}
+	if fileSize < c.fileSize {
+		return fmt.Errorf("append shrinks file size from %d to %d", c.fileSize, fileSize)
+	}
+
+	c.chunkViews = append(c.chunkViews, chunkViews...)
+	c.fileSize = fileSize
+	c.followsTail = true
+	return nil
+}
+
+func (c *ChunkReadAt) ReadAt(p []byte, offset int64) (n int, err error) {
+	return c.ReadAtWithContext(context.Background(), p, offset)
+}
+
+// ReadAtWithContext gives up waiting for chunk fetches once the context is done.
+func (c *ChunkReadAt) ReadAtWithContext(ctx context.Context, p []byte, offset int64) (n int, err error) {
+
+	c.readerPattern.MonitorReadAt(offset, len(p))
+
+	c.readerLock.Lock()
 	// glog.V(4).Infof("ReadAt [%d,%d) of total file size %d bytes %d chunk views", offset, offset+int64(len(p)), c.fileSize, len(c.chunkViews))
-	return c.doReadAt(p, offset)
+	n, err = c.guardedReadAt(c.withReadDeadline(ctx), p, offset)
+	report := c.trackProgress(offset, n)
+	c.readerLock.Unlock()
+
+	if report != nil {
+		report()
+	}
+	return
 }
 
-func (c *ChunkReadAt) doReadAt(p []byte, offset int64) (n int, err error) {
+func (c *ChunkReadAt) doReadAt(ctx context.Context, p []byte, offset int64) (n int, err error) {
+
+	if offset >= c.fileSize {
+		return 0, io.EOF
+	}
+	readStop := offset + int64(len(p))
+	if readStop > c.fileSize {
+		// chunk views beyond the file size are not visible
+		p = p[:c.fileSize-offset]
+	}
+
+	if copied, ok := c.readLastChunkView(p, offset); ok {
+		return copied, nil
+	}
 
 	startOffset, remaining := offset, int64(len(p))
-	var nextChunk *ChunkView
 	for i, chunk := range c.chunkViews {
 		if remaining <= 0 {
 			break
 		}
-		if i+1 < len(c.chunkViews) {
-			nextChunk = c.chunkViews[i+1]
-		} else {
-			nextChunk = nil
+		if chunk.Size == 0 {
+			continue
 		}
 		if startOffset < chunk.LogicOffset {
-			gap := int(chunk.LogicOffset - startOffset)
-			glog.V(4).Infof("zero [%d,%d)", startOffset, chunk.LogicOffset)
-			n += int(min(int64(gap), remaining))
-			startOffset, remaining = chunk.LogicOffset, remaining-int64(gap)
+			// the gap may be larger than an int on 32 bit platforms, and larger than the rest of the read
+			zeroed := min(chunk.LogicOffset-startOffset, remaining)
+			glog.V(4).Infof("zero [%d,%d)", startOffset, startOffset+zeroed)
+			zero(p[startOffset-offset : startOffset-offset+zeroed])
+			n += int(zeroed)
+			startOffset, remaining = startOffset+zeroed, remaining-zeroed
 			if remaining <= 0 {
 				break
 			}
@@ -141,30 +506,48 @@ func (c *ChunkReadAt) doReadAt(p []byte, offset int64) (n int, err error) {
 		if chunkStart >= chunkStop {
 			continue
 		}
+		if err = c.checkReadDeadline(ctx, chunk); err != nil {
+			return
+		}
+		nextChunks := c.nextNonEmptyChunkViews(i, c.PrefetchDepth())
 		// glog.V(4).Infof("read [%d,%d), %d/%d chunk %s [%d,%d)", chunkStart, chunkStop, i, len(c.chunkViews), chunk.FileId, chunk.LogicOffset-chunk.Offset, chunk.LogicOffset-chunk.Offset+int64(chunk.Size))
 		var buffer []byte
 		bufferOffset := chunkStart - chunk.LogicOffset + chunk.Offset
 		bufferLength := chunkStop - chunkStart
-		buffer, err = c.readChunkSlice(chunk, nextChunk, uint64(bufferOffset), uint64(bufferLength))
+		buffer, err = c.readChunkSlice(ctx, chunk, nextChunks, uint64(bufferOffset), uint64(bufferLength))
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+			return
+		}
 		if err != nil {
-			glog.Errorf("fetching chunk %+v: %v\n", chunk, err)
+			readErrorLog.Logf("fetch "+VolumeId(chunk.FileId), "fetching chunk %+v: %v", chunk, err)
+			err = newChunkFetchError(chunk, bufferOffset, err)
 			return
 		}
 
 		copied := copy(p[startOffset-offset:chunkStop-chunkStart+startOffset-offset], buffer)
+		if c.lookupFileId != nil && int64(copied) < bufferLength {
+			err = newChunkFetchError(chunk, bufferOffset, &TruncatedChunkError{Size: int64(copied), Expected: bufferLength, Offset: bufferOffset})
+			n += copied
+			return
+		}
 		n += copied
 		startOffset, remaining = startOffset+int64(copied), remaining-int64(copied)
+		if chunk.FileId == c.lastChunkFileId {
+			c.lastChunkView = chunk
+		}
 	}
 
 	// glog.V(4).Infof("doReadAt [%d,%d), n:%v, err:%v", offset, offset+int64(len(p)), n, err)
 
-	if err == nil && remaining > 0 && c.fileSize > startOffset {
-		delta := int(min(remaining, c.fileSize-startOffset))
-		glog.V(4).Infof("zero2 [%d,%d) of file size %d bytes", startOffset, startOffset+int64(delta), c.fileSize)
-		n += delta
+	// the rest after the last chunk view is a hole up to the file size, since p was cut at the file size
+	if err == nil && remaining > 0 {
+		glog.V(4).Infof("zero2 [%d,%d) of file size %d bytes", startOffset, startOffset+remaining, c.fileSize)
+		zero(p[startOffset-offset:])
+		n += int(remaining)
 	}
 
-	if err == nil && offset+int64(len(p)) >= c.fileSize {
+	if err == nil && (readStop > c.fileSize || (readStop == c.fileSize && c.eofPolicy == EOFAtEnd)) {
 		err = io.EOF
 	}
 	// fmt.Printf("~~~ filled %d, err: %v\n\n", n, err)
@@ -173,22 +556,81 @@ func (c *ChunkReadAt) doReadAt(p []byte, offset int64) (n int, err error) {
 
 }
 
-func (c *ChunkReadAt) readChunkSlice(chunkView *ChunkView, nextChunkViews *ChunkView, offset, length uint64) ([]byte, error) {
+// readLastChunkView copies the range if it lies within the chunk view read last,
+// sparing many small reads of one hot chunk the walk over all chunk views.
+func (c *ChunkReadAt) readLastChunkView(p []byte, offset int64) (n int, ok bool) {
+	chunkView := c.lastChunkView
+	if chunkView == nil || chunkView.FileId != c.lastChunkFileId {
+		return 0, false
+	}
+	stop := offset + int64(len(p))
+	// a read up to the file end is left to the general path, which decides on io.EOF
+	if offset < chunkView.LogicOffset || stop > chunkView.LogicOffset+int64(chunkView.Size) || stop >= c.fileSize {
+		return 0, false
+	}
+	dataOffset := offset - chunkView.LogicOffset + chunkView.Offset
+	if dataOffset+int64(len(p)) > int64(len(c.lastChunkData)) {
+		return 0, false
+	}
+	return copy(p, c.lastChunkData[dataOffset:]), true
+}
+
+// nextNonEmptyChunkViews returns up to depth non empty chunk views after the i-th one, at least one if any.
+func (c *ChunkReadAt) nextNonEmptyChunkViews(i int, depth int) (chunkViews []*ChunkView) {
+	if depth < 1 {
+		depth = 1
+	}
+	for _, chunkView := range c.chunkViews[i+1:] {
+		if len(chunkViews) >= depth {
+			break
+		}
+		if chunkView.Size > 0 {
+			chunkViews = append(chunkViews, chunkView)
+		}
+	}
+	return
+}
+
+func zero(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
+
+func (c *ChunkReadAt) readChunkSlice(ctx context.Context, chunkView *ChunkView, nextChunkViews []*ChunkView, offset, length uint64) ([]byte, error) {
 
-	var chunkSlice []byte
-	if chunkView.LogicOffset == 0 {
-		chunkSlice = c.chunkCache.GetChunkSlice(chunkView.FileId, offset, length)
+	defer c.pinChunk(chunkView)()
+
+	// a chunk with a checksum is only checked when fetched whole
+	checksummed := c.checksums.has(chunkView.FileId)
+	if c.readsByBlocks(chunkView) && !checksummed {
+		return c.readChunkBlocks(ctx, chunkView, offset, length)
 	}
+
+	// any chunk may be cached, e.g. by a CachePrewarmer, though only some are cached by the reads
+	chunkSlice := c.chunkCache.GetChunkSlice(c.cacheKey(chunkView.FileId), offset, length)
 	if len(chunkSlice) > 0 {
+		c.eventSink.emit(ReadEvent{Type: ReadEventCacheHit, FileId: chunkView.FileId, Offset: int64(offset), Size: int64(length)})
 		return chunkSlice, nil
 	}
 	if c.lookupFileId == nil {
 		return nil, nil
 	}
-	if c.readerPattern.IsRandomMode() {
-		return c.doFetchRangeChunkData(chunkView, offset, length)
+	var chunkData []byte
+	var err error
+	if c.tailChunkFileId == chunkView.FileId || (c.followsTail && len(nextChunkViews) == 0) {
+		// reads near the end of a growing file keep coming back to the final chunk
+		chunkData, err = c.readTailChunk(ctx, chunkView)
+	} else if c.lastChunkFileId == chunkView.FileId {
+		chunkData = c.lastChunkData
+	} else if c.amplification.keepsChunks() {
+		// tiny reads all over the chunk, better fetched once
+		chunkData, err = c.readFromWholeChunkData(ctx, chunkView)
+	} else if c.readerPattern.IsRandomMode() && !checksummed {
+		return c.doFetchRangeChunkData(ctx, chunkView, offset, length)
+	} else {
+		chunkData, err = c.readFromWholeChunkData(ctx, chunkView, nextChunkViews...)
 	}
-	chunkData, err := c.readFromWholeChunkData(chunkView, nextChunkViews)
 	if err != nil {
 		return nil, err
 	}
@@ -196,66 +638,211 @@ func (c *ChunkReadAt) readChunkSlice(chunkView *ChunkView, nextChunkViews *Chunk
 	return chunkData[offset : int64(offset)+wanted], nil
 }
 
-func (c *ChunkReadAt) readFromWholeChunkData(chunkView *ChunkView, nextChunkViews ...*ChunkView) (chunkData []byte, err error) {
+func (c *ChunkReadAt) readFromWholeChunkData(ctx context.Context, chunkView *ChunkView, nextChunkViews ...*ChunkView) (chunkData []byte, err error) {
 
 	if c.lastChunkFileId == chunkView.FileId {
 		return c.lastChunkData, nil
 	}
 
-	v, doErr := c.readOneWholeChunk(chunkView)
-
-	if doErr != nil {
-		return nil, doErr
+	if data, found := c.prefetchedChunks[chunkView.FileId]; found {
+		chunkData = data
+		delete(c.prefetchedChunks, chunkView.FileId)
+	} else {
+		v, doErr := c.readOneWholeChunk(ctx, chunkView)
+		if doErr != nil {
+			return nil, doErr
+		}
+		chunkData = v.([]byte)
 	}
 
-	chunkData = v.([]byte)
-
 	c.lastChunkData = chunkData
 	c.lastChunkFileId = chunkView.FileId
+	c.lastChunkView = chunkView
+	c.recountCachedBytes()
+	c.adaptivePrefetch.observeConsume(c.now())
 
-	for _, nextChunkView := range nextChunkViews {
+	// no chunks ahead are fetched while the prefetches are paused, not even synchronously
+	if prefetchScheduler.Paused() {
+		return
+	}
+	if c.syncPrefetch {
+		c.prefetchSynchronously(ctx, nextChunkViews)
+		return
+	}
+	prefetchCtx := c.prefetchContext()
+	for i, nextChunkView := range nextChunkViews {
 		if c.chunkCache != nil && nextChunkView != nil {
-			go c.readOneWholeChunk(nextChunkView)
+			nextChunkView := nextChunkView
+			prefetchScheduler.GoAheadWithContext(prefetchCtx, c.priority, i+1, func() {
+				c.readOneWholeChunk(prefetchCtx, nextChunkView)
+			})
 		}
 	}
 
 	return
 }
 
-func (c *ChunkReadAt) readOneWholeChunk(chunkView *ChunkView) (interface{}, error) {
+func (c *ChunkReadAt) readTailChunk(ctx context.Context, chunkView *ChunkView) ([]byte, error) {
 
-	var err error
+	if c.tailChunkFileId == chunkView.FileId {
+		return c.tailChunkData, nil
+	}
 
-	return c.fetchGroup.Do(chunkView.FileId, func() (interface{}, error) {
+	data, found := c.prefetchedChunks[chunkView.FileId]
+	delete(c.prefetchedChunks, chunkView.FileId)
+	if !found {
+		v, err := c.readOneWholeChunk(ctx, chunkView)
+		if err != nil {
+			return nil, err
+		}
+		data = v.([]byte)
+	}
 
-		glog.V(4).Infof("readFromWholeChunkData %s offset %d [%d,%d) size at least %d", chunkView.FileId, chunkView.Offset, chunkView.LogicOffset, chunkView.LogicOffset+int64(chunkView.Size), chunkView.ChunkSize)
+	// the previous final chunk is likely still read together with the appended one
+	if c.tailChunkFileId != "" {
+		// its chunk view is picked up when the chunk is read again
+		c.lastChunkData, c.lastChunkFileId, c.lastChunkView = c.tailChunkData, c.tailChunkFileId, nil
+	}
+	c.tailChunkData = data
+	c.tailChunkFileId = chunkView.FileId
+	c.recountCachedBytes()
 
-		var data []byte
-		if chunkView.LogicOffset == 0 {
-			data = c.chunkCache.GetChunk(chunkView.FileId, chunkView.ChunkSize)
+	return c.tailChunkData, nil
+}
+
+func (c *ChunkReadAt) readOneWholeChunk(ctx context.Context, chunkView *ChunkView) (interface{}, error) {
+
+	glog.V(4).Infof("readFromWholeChunkData %s offset %d [%d,%d) size at least %d", chunkView.FileId, chunkView.Offset, chunkView.LogicOffset, chunkView.LogicOffset+int64(chunkView.Size), chunkView.ChunkSize)
+
+	// only cache the first chunk, unless tiny reads keep coming back to the chunks
+	keepsChunk := chunkView.LogicOffset == 0 || c.amplification.keepsChunks()
+
+	if data := c.chunkCache.GetChunk(c.cacheKey(chunkView.FileId), chunkView.ChunkSize); data != nil {
+		glog.V(4).Infof("cache hit %s [%d,%d)", chunkView.FileId, chunkView.LogicOffset-chunkView.Offset, chunkView.LogicOffset-chunkView.Offset+int64(len(data)))
+		c.eventSink.emit(ReadEvent{Type: ReadEventCacheHit, FileId: chunkView.FileId, Size: int64(len(data))})
+		return data, nil
+	}
+	c.eventSink.emit(ReadEvent{Type: ReadEventCacheMiss, FileId: chunkView.FileId, Size: int64(chunkView.ChunkSize)})
+
+	fetched, err := c.doFetchFullChunkData(ctx, chunkView)
+	if err != nil {
+		if stale, found := c.staleChunk(chunkView, 0, 0, err); found {
+			return stale, nil
 		}
-		if data != nil {
-			glog.V(4).Infof("cache hit %s [%d,%d)", chunkView.FileId, chunkView.LogicOffset-chunkView.Offset, chunkView.LogicOffset-chunkView.Offset+int64(len(data)))
-		} else {
-			var err error
-			data, err = c.doFetchFullChunkData(chunkView)
+		return nil, err
+	}
+	// the readers sharing the fetch cache the chunk once, whichever checks it first
+	if keepsChunk && atomic.CompareAndSwapInt32(&fetched.cached, 0, 1) {
+		c.chunkCache.SetChunk(c.cacheKey(chunkView.FileId), fetched.data)
+	}
+	return fetched.data, nil
+}
+
+// sharedChunk is a whole chunk fetched once for all the readers waiting on it
+type sharedChunk struct {
+	data   []byte
+	cached int32 // accessed atomically, set by the reader caching the chunk
+}
+
+// doFetchFullChunkData waits for the shared fetch of the whole chunk within the budget of this reader,
+// and checks and accounts the chunk for this reader.
+func (c *ChunkReadAt) doFetchFullChunkData(ctx context.Context, chunkView *ChunkView) (*sharedChunk, error) {
+
+	glog.V(4).Infof("+ doFetchFullChunkData %s", chunkView.FileId)
+
+	releaseBytes, err := c.fetchBudget.acquire(ctx, int64(maxUint64(chunkView.ChunkSize, chunkView.Size)))
+	if err != nil {
+		return nil, err
+	}
+	defer releaseBytes()
+
+	c.eventSink.emit(ReadEvent{Type: ReadEventFetchStarted, FileId: chunkView.FileId, Size: int64(chunkView.ChunkSize)})
+	start := c.now()
+	fetched, err := c.fetchSharedChunk(ctx, chunkView)
+	var data []byte
+	if err == nil {
+		data = fetched.data
+		err = c.checksums.check(chunkView.FileId, data)
+	}
+	c.eventSink.emitFetch(chunkView.FileId, 0, start, data, err)
+	if err != nil {
+		return nil, err
+	}
+	c.adaptivePrefetch.observeFetch(c.now().Sub(start))
+	tallyFetch(ctx, data)
+
+	return fetched, nil
+
+}
+
+// fetchSharedChunk coalesces the fetches of the chunk by the readers of the fetch group.
+// The fetch runs detached from the context of any of them, bounded by the fetch timeout alone,
+// and each reader stops waiting for it once its own context is done.
+func (c *ChunkReadAt) fetchSharedChunk(ctx context.Context, chunkView *ChunkView) (*sharedChunk, error) {
+
+	var v interface{}
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, err = c.getFetchGroup().Do(c.cacheKey(chunkView.FileId), func() (interface{}, error) {
+			// detached like the fetch, as the readers joining it stop waiting on their own
+			release, err := acquireFetchSlot(context.Background(), c.priority)
 			if err != nil {
-				return data, err
+				return nil, err
 			}
-			if chunkView.LogicOffset == 0 {
-				// only cache the first chunk
-				c.chunkCache.SetChunk(chunkView.FileId, data)
+			defer release()
+			data, err := c.doFetchFullChunk(chunkView)
+			if err != nil {
+				return nil, err
 			}
-		}
-		return data, err
-	})
+			return &sharedChunk{data: data}, nil
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v.(*sharedChunk), nil
 }
 
-func (c *ChunkReadAt) doFetchFullChunkData(chunkView *ChunkView) ([]byte, error) {
+func (c *ChunkReadAt) doFetchFullChunk(chunkView *ChunkView) (data []byte, err error) {
 
-	glog.V(4).Infof("+ doFetchFullChunkData %s", chunkView.FileId)
+	if data, found := c.fetchLocalChunk(chunkView); found {
+		glog.V(4).Infof("- doFetchFullChunkData %s locally", chunkView.FileId)
+		return data, nil
+	}
+	if data, found := c.fetchEdgeChunk(context.Background(), chunkView, true, 0, 0); found {
+		glog.V(4).Infof("- doFetchFullChunkData %s from the edge", chunkView.FileId)
+		return data, nil
+	}
+
+	// the fetch may be shared with other reads, so it is bounded by the fetch timeout alone
+	ctx, cancel, timeout := c.withFetchTimeout(context.Background(), int64(chunkView.ChunkSize))
+	defer cancel()
 
-	data, err := fetchChunk(c.lookupFileId, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped)
+	if c.readHedger != nil {
+		data, err = c.readHedger.fetchChunk(ctx, c.lookupFileId, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, chunkView.ChunkSize, func(urlString string, attemptErr error) {
+			if attemptErr != nil {
+				c.eventSink.emit(ReadEvent{Type: ReadEventFailover, FileId: chunkView.FileId, Server: replicaServer(urlString), Err: attemptErr})
+			}
+		})
+	} else if c.transportSelector != nil || c.underReplicatedFn != nil || c.readRepairFn != nil || c.eventSink != nil {
+		// the replicas failing are reported whichever transport they are read over
+		fetchFn := retriedReplicaFetch(ctx, chunkView.CipherKey, chunkView.IsGzipped, chunkView.ChunkSize)
+		if c.transportSelector != nil {
+			fetchFn = c.transportSelector.replicaFetch(ctx, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, chunkView.ChunkSize)
+		}
+		data, err = fetchChunkReportingReplicas(c.lookupFileId, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, c.underReplicatedFn, c.readRepairFn, c.eventSink, fetchFn)
+	} else {
+		data, err = fetchChunkOfSize(ctx, c.lookupFileId, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, chunkView.ChunkSize)
+	}
+	err = c.fetchTimedOut(ctx, err, chunkView.FileId, int64(chunkView.ChunkSize), timeout)
 
 	glog.V(4).Infof("- doFetchFullChunkData %s", chunkView.FileId)
 
@@ -263,14 +850,80 @@ func (c *ChunkReadAt) doFetchFullChunkData(chunkView *ChunkView) ([]byte, error)
 
 }
 
-func (c *ChunkReadAt) doFetchRangeChunkData(chunkView *ChunkView, offset, length uint64) ([]byte, error) {
+func (c *ChunkReadAt) doFetchRangeChunkData(ctx context.Context, chunkView *ChunkView, offset, length uint64) ([]byte, error) {
 
 	glog.V(4).Infof("+ doFetchFullChunkData %s", chunkView.FileId)
 
-	data, err := fetchChunkRange(c.lookupFileId, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, int64(offset), int(length))
+	releaseBytes, err := c.fetchBudget.acquire(ctx, int64(length))
+	if err != nil {
+		return nil, err
+	}
+	defer releaseBytes()
+
+	release, err := acquireFetchSlot(ctx, c.priority)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if data, found := c.fetchLocalChunk(chunkView); found && uint64(len(data)) >= offset+length {
+		glog.V(4).Infof("- doFetchFullChunkData %s locally", chunkView.FileId)
+		return data[offset : offset+length], nil
+	}
+
+	c.eventSink.emit(ReadEvent{Type: ReadEventFetchStarted, FileId: chunkView.FileId, Offset: int64(offset), Size: int64(length)})
+	start := c.now()
+	data, found := c.fetchEdgeChunk(ctx, chunkView, false, int64(offset), int(length))
+	if !found {
+		fetchCtx, cancel, timeout := c.withFetchTimeout(ctx, int64(length))
+		data, err = fetchChunkRangeWithContext(fetchCtx, c.lookupFileId, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, int64(offset), int(length))
+		err = c.fetchTimedOut(fetchCtx, err, chunkView.FileId, int64(length), timeout)
+		cancel()
+	}
+	c.eventSink.emitFetch(chunkView.FileId, int64(offset), start, data, err)
+	if err == nil {
+		tallyFetch(ctx, data)
+	} else if stale, found := c.staleChunk(chunkView, offset, length, err); found {
+		return stale, nil
+	}
 
 	glog.V(4).Infof("- doFetchFullChunkData %s", chunkView.FileId)
 
 	return data, err
 
 }
+
+// PeekChunkHeader returns up to n leading bytes of the chunk behind chunkView,
+// e.g. for format sniffing. Only the requested range is fetched when possible,
+// and the whole-chunk cache is left untouched.
+func (c *ChunkReadAt) PeekChunkHeader(chunkView *ChunkView, n int) ([]byte, error) {
+
+	if n <= 0 {
+		return nil, nil
+	}
+	if chunkView.ChunkSize > 0 && uint64(n) > chunkView.ChunkSize {
+		n = int(chunkView.ChunkSize)
+	}
+
+	if data := c.chunkCache.GetChunkSlice(c.cacheKey(chunkView.FileId), 0, uint64(n)); len(data) >= n {
+		return data[:n], nil
+	}
+
+	if c.lookupFileId == nil {
+		return nil, nil
+	}
+
+	// encrypted or compressed chunks can only be decoded as a whole
+	if chunkView.CipherKey != nil || chunkView.IsGzipped {
+		data, err := fetchChunk(c.lookupFileId, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > n {
+			data = data[:n]
+		}
+		return data, nil
+	}
+
+	return fetchChunkRange(c.lookupFileId, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, 0, n)
+}
@@ -1,12 +1,26 @@
 package filer
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/golang/groupcache/singleflight"
+	"google.golang.org/grpc"
 )
 
 type mockChunkCache struct {
@@ -72,9 +86,9 @@ func TestReaderAt(t *testing.T) {
 		readerPattern: NewReaderPattern(),
 	}
 
-	testReadAt(t, readerAt, 0, 10, 10, io.EOF)
+	testReadAt(t, readerAt, 0, 10, 10, nil)
 	testReadAt(t, readerAt, 0, 12, 10, io.EOF)
-	testReadAt(t, readerAt, 2, 8, 8, io.EOF)
+	testReadAt(t, readerAt, 2, 8, 8, nil)
 	testReadAt(t, readerAt, 3, 6, 6, nil)
 
 }
@@ -123,7 +137,7 @@ func TestReaderAt0(t *testing.T) {
 		readerPattern: NewReaderPattern(),
 	}
 
-	testReadAt(t, readerAt, 0, 10, 10, io.EOF)
+	testReadAt(t, readerAt, 0, 10, 10, nil)
 	testReadAt(t, readerAt, 3, 16, 7, io.EOF)
 	testReadAt(t, readerAt, 3, 5, 5, nil)
 
@@ -152,7 +166,7 @@ func TestReaderAt1(t *testing.T) {
 		readerPattern: NewReaderPattern(),
 	}
 
-	testReadAt(t, readerAt, 0, 20, 20, io.EOF)
+	testReadAt(t, readerAt, 0, 20, 20, nil)
 	testReadAt(t, readerAt, 1, 7, 7, nil)
 	testReadAt(t, readerAt, 0, 1, 1, nil)
 	testReadAt(t, readerAt, 18, 4, 2, io.EOF)
@@ -162,3 +176,988 @@ func TestReaderAt1(t *testing.T) {
 	testReadAt(t, readerAt, 1, 10, 10, nil)
 
 }
+
+type mapChunkCache struct {
+	sync.Mutex
+	chunks map[string][]byte
+}
+
+func newMapChunkCache() *mapChunkCache {
+	return &mapChunkCache{
+		chunks: make(map[string][]byte),
+	}
+}
+
+func (m *mapChunkCache) GetChunk(fileId string, minSize uint64) (data []byte) {
+	m.Lock()
+	defer m.Unlock()
+	data = m.chunks[fileId]
+	if uint64(len(data)) < minSize {
+		return nil
+	}
+	return data
+}
+
+func (m *mapChunkCache) GetChunkSlice(fileId string, offset, length uint64) []byte {
+	m.Lock()
+	defer m.Unlock()
+	data := m.chunks[fileId]
+	if uint64(len(data)) < offset+length {
+		return nil
+	}
+	return data[offset : offset+length]
+}
+
+func (m *mapChunkCache) SetChunk(fileId string, data []byte) {
+	m.Lock()
+	defer m.Unlock()
+	m.chunks[fileId] = data
+}
+
+type testVolumeServer struct {
+	*httptest.Server
+	chunks        map[string][]byte
+	requests      int32
+	rangeRequests int32
+}
+
+func newTestVolumeServer(chunks map[string][]byte) *testVolumeServer {
+	s := &testVolumeServer{
+		chunks: chunks,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.requests, 1)
+		if r.Header.Get("Range") != "" {
+			atomic.AddInt32(&s.rangeRequests, 1)
+		}
+		data, found := s.chunks[strings.TrimPrefix(r.URL.Path, "/")]
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	return s
+}
+
+func (s *testVolumeServer) lookupFn(fileId string) (targetUrls []string, err error) {
+	return []string{s.URL + "/" + fileId}, nil
+}
+
+func randomBytes(size int) []byte {
+	data := make([]byte, size)
+	rand.Read(data)
+	return data
+}
+
+func TestPeekChunkHeader(t *testing.T) {
+
+	fileId := "1,0a0b0c0d"
+	data := randomBytes(64 * 1024)
+	server := newTestVolumeServer(map[string][]byte{fileId: data})
+	defer server.Close()
+
+	cache := newMapChunkCache()
+	chunkView := &ChunkView{
+		FileId:    fileId,
+		Size:      uint64(len(data)),
+		ChunkSize: uint64(len(data)),
+	}
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{chunkView}, cache, int64(len(data)))
+
+	peeked, err := readerAt.PeekChunkHeader(chunkView, 512)
+	if err != nil {
+		t.Fatalf("peek: %v", err)
+	}
+	full, err := fetchChunk(server.lookupFn, fileId, nil, false)
+	if err != nil {
+		t.Fatalf("full fetch: %v", err)
+	}
+	if !bytes.Equal(peeked, full[:512]) {
+		t.Errorf("peeked bytes differ from the full fetch")
+	}
+	if server.rangeRequests != 1 {
+		t.Errorf("expected 1 range request, got %d", server.rangeRequests)
+	}
+	if len(cache.chunks) != 0 {
+		t.Errorf("peek should not populate the chunk cache")
+	}
+
+}
+
+func TestReaderAtFetchErrorIsNotEOF(t *testing.T) {
+
+	data := randomBytes(1024)
+	server := newTestVolumeServer(map[string][]byte{"1,0b01": data})
+	defer server.Close()
+
+	chunkViews := []*ChunkView{
+		{FileId: "1,0b01", Size: 1024, ChunkSize: 1024, LogicOffset: 0},
+		{FileId: "1,0b02", Size: 1024, ChunkSize: 1024, LogicOffset: 1024},
+	}
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, newMapChunkCache(), 2048)
+
+	buf := make([]byte, 1024)
+	n, err := readerAt.ReadAt(buf, 0)
+	if n != 1024 || err != nil {
+		t.Errorf("read first chunk: n=%d err=%v", n, err)
+	}
+
+	// the second chunk is missing on the volume server
+	n, err = readerAt.ReadAt(buf, 1024)
+	if err == io.EOF || !errors.Is(err, ErrChunkFetch) {
+		t.Errorf("expected chunk fetch error, got n=%d err=%v", n, err)
+	}
+
+	n, err = readerAt.ReadAt(buf, 2048)
+	if n != 0 || err != io.EOF {
+		t.Errorf("expected io.EOF at the end of file, got n=%d err=%v", n, err)
+	}
+
+}
+
+type fakeFilerClient struct {
+	filer_pb.SeaweedFilerClient
+	locations      map[string][]string
+	lookupRequests int32
+
+	// the lookups to fail as if the filer were unreachable, and when all lookups were made
+	lookupFailures int32
+	lookupTimes    []time.Time
+
+	lookupVolumeIds [][]string // the volume ids of each lookup
+}
+
+func (f *fakeFilerClient) WithFilerClient(streamingMode bool, fn func(filer_pb.SeaweedFilerClient) error) error {
+	return fn(f)
+}
+
+func (f *fakeFilerClient) AdjustedUrl(location *filer_pb.Location) string {
+	return location.Url
+}
+
+func (f *fakeFilerClient) LookupVolume(ctx context.Context, in *filer_pb.LookupVolumeRequest, opts ...grpc.CallOption) (*filer_pb.LookupVolumeResponse, error) {
+	atomic.AddInt32(&f.lookupRequests, 1)
+	f.lookupTimes = append(f.lookupTimes, time.Now())
+	f.lookupVolumeIds = append(f.lookupVolumeIds, in.VolumeIds)
+	if f.lookupFailures > 0 {
+		f.lookupFailures--
+		return nil, errors.New("connection error: desc = \"transport: error while dialing\"")
+	}
+	resp := &filer_pb.LookupVolumeResponse{
+		LocationsMap: make(map[string]*filer_pb.Locations),
+	}
+	for _, vid := range in.VolumeIds {
+		urls, found := f.locations[vid]
+		if !found {
+			continue
+		}
+		locations := &filer_pb.Locations{}
+		for _, url := range urls {
+			locations.Locations = append(locations.Locations, &filer_pb.Location{Url: url})
+		}
+		resp.LocationsMap[vid] = locations
+	}
+	return resp, nil
+}
+
+func TestLookupFnNoShuffle(t *testing.T) {
+
+	filerClient := &fakeFilerClient{
+		locations: map[string][]string{
+			"3": {"server1:8080", "server2:8080", "server3:8080", "server4:8080"},
+		},
+	}
+	lookupFn := LookupFnWithOptions(filerClient, &LookupOptions{NoShuffle: true})
+
+	for i := 0; i < 10; i++ {
+		urls, err := lookupFn("3,01637037d6")
+		if err != nil {
+			t.Fatalf("lookup: %v", err)
+		}
+		for j, url := range urls {
+			if expected := fmt.Sprintf("http://server%d:8080/3,01637037d6", j+1); url != expected {
+				t.Fatalf("unexpected url %s at %d, expect %s", url, j, expected)
+			}
+		}
+	}
+
+	// the same seed gives the same order
+	first, _ := LookupFnWithOptions(filerClient, &LookupOptions{Rand: rand.New(rand.NewSource(1))})("3,01637037d6")
+	second, _ := LookupFnWithOptions(filerClient, &LookupOptions{Rand: rand.New(rand.NewSource(1))})("3,01637037d6")
+	if strings.Join(first, " ") != strings.Join(second, " ") {
+		t.Errorf("seeded shuffles differ: %v %v", first, second)
+	}
+
+}
+
+func TestLookupFnBacksOffWithJitter(t *testing.T) {
+
+	filerClient := &fakeFilerClient{
+		locations:      map[string][]string{"3": {"server1:8080"}},
+		lookupFailures: 2,
+	}
+	newBackoff := func() *util.Backoff {
+		backoff := util.NewBackoff(40*time.Millisecond, time.Second)
+		backoff.Rand = rand.New(rand.NewSource(7))
+		return backoff
+	}
+	lookupFn := LookupFnWithOptions(filerClient, &LookupOptions{Backoff: newBackoff()})
+
+	urls, err := lookupFn("3,01637037d6")
+	if err != nil || len(urls) != 1 {
+		t.Fatalf("lookup: %v %v", urls, err)
+	}
+	if len(filerClient.lookupTimes) != 3 {
+		t.Fatalf("expect 3 lookups, got %d", len(filerClient.lookupTimes))
+	}
+
+	// the same seeded backoff tells the jittered waits
+	expected := newBackoff()
+	for i := 1; i < len(filerClient.lookupTimes); i++ {
+		wait, nominal := expected.Wait(i), 40*time.Millisecond<<(i-1)
+		if wait >= nominal || wait < nominal/2 {
+			t.Errorf("wait %v after %d failures not jittered below %v", wait, i, nominal)
+		}
+		if gap := filerClient.lookupTimes[i].Sub(filerClient.lookupTimes[i-1]); gap < wait {
+			t.Errorf("retried after %v, expect at least %v", gap, wait)
+		}
+	}
+
+	// a filer down for longer than the total wait
+	filerClient = &fakeFilerClient{lookupFailures: math.MaxInt32}
+	lookupFn = LookupFnWithOptions(filerClient, &LookupOptions{Backoff: util.NewBackoff(10*time.Millisecond, 100*time.Millisecond)})
+	if _, err = lookupFn("3,01637037d6"); err == nil || !strings.Contains(err.Error(), "giving up") {
+		t.Errorf("expect giving up, got %v", err)
+	}
+
+}
+
+func TestReadersCoalesceChunkFetches(t *testing.T) {
+
+	fileId := "1,0e0f1011"
+	data := randomBytes(64 * 1024)
+	var requests int32
+	received, release := make(chan struct{}, 1), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		received <- struct{}{}
+		<-release
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+	chunkViews := []*ChunkView{{FileId: fileId, Size: uint64(len(data)), ChunkSize: uint64(len(data))}}
+
+	// readers sharing a chunk cache share the fetch, until one of them caches the chunk
+	chunkCache := newMapChunkCache()
+	var wg sync.WaitGroup
+	read := func(chunkCache *mapChunkCache) {
+		defer wg.Done()
+		reader := NewChunkReaderAtFromClient(lookupFn, chunkViews, chunkCache, int64(len(data)))
+		buf := make([]byte, len(data))
+		if n, err := reader.ReadAt(buf, 0); n != len(data) || !bytes.Equal(buf, data) {
+			t.Errorf("read %d bytes: %v", n, err)
+		}
+	}
+
+	wg.Add(2)
+	go read(chunkCache)
+	<-received
+	go read(chunkCache)
+	// let the second reader join the pending fetch
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if fetches := atomic.LoadInt32(&requests); fetches != 1 {
+		t.Errorf("%d fetches for the same chunk, expect 1", fetches)
+	}
+
+	// a reader of another chunk cache, e.g. of another filer, fetches on its own
+	wg.Add(1)
+	read(newMapChunkCache())
+	if fetches := atomic.LoadInt32(&requests); fetches != 2 {
+		t.Errorf("%d fetches for the same chunk by readers of two caches, expect 2", fetches)
+	}
+
+}
+
+func TestReaderAtFollowsAppendedTail(t *testing.T) {
+
+	chunks := map[string][]byte{
+		"1,0c01": randomBytes(4096),
+		"1,0c02": randomBytes(4096),
+		"1,0c03": randomBytes(4096),
+	}
+	content := append(append(append([]byte{}, chunks["1,0c01"]...), chunks["1,0c02"]...), chunks["1,0c03"]...)
+	server := newTestVolumeServer(chunks)
+	defer server.Close()
+
+	// following the file since it grew to the second chunk
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{
+		{FileId: "1,0c01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+	}, newMapChunkCache(), 4096)
+	if err := readerAt.AppendChunkViews(8192, &ChunkView{FileId: "1,0c02", Size: 4096, ChunkSize: 4096, LogicOffset: 4096}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	readTail := func(offset int64, size int) {
+		buf := make([]byte, size)
+		n, err := readerAt.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			t.Fatalf("read [%d,%d): %v", offset, offset+int64(size), err)
+		}
+		if !bytes.Equal(buf[:n], content[offset:offset+int64(n)]) {
+			t.Fatalf("read [%d,%d): unexpected content", offset, offset+int64(n))
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		readTail(8192-100, 100)
+	}
+	if server.requests != 1 {
+		t.Errorf("%d fetches for repeated tail reads, expect 1", server.requests)
+	}
+
+	if err := readerAt.AppendChunkViews(12288, &ChunkView{FileId: "1,0c03", Size: 4096, ChunkSize: 4096, LogicOffset: 8192}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		readTail(8192-100, 200)
+		readTail(12288-100, 100)
+	}
+	if server.requests != 2 {
+		t.Errorf("%d fetches after the file grows, expect 2", server.requests)
+	}
+
+	if err := readerAt.AppendChunkViews(12288, &ChunkView{FileId: "1,0c04", Size: 4096, ChunkSize: 4096, LogicOffset: 4096}); err == nil {
+		t.Errorf("expect error appending a chunk view before the end")
+	}
+
+}
+
+func TestReaderAtFollowsConcurrentAppends(t *testing.T) {
+
+	server, chunkViews, content := newTestSequentialFile(16, 1024)
+	defer server.Close()
+
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews[:1], newMapChunkCache(), 1024)
+
+	appended := make(chan error, 1)
+	go func() {
+		for i := 1; i < len(chunkViews); i++ {
+			if err := readerAt.AppendChunkViews(int64((i+1)*1024), chunkViews[i]); err != nil {
+				appended <- err
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+		appended <- nil
+	}()
+
+	// a follower keeps reading from where it stopped, past each end of file it has seen
+	followed := make([]byte, 0, len(content))
+	buf := make([]byte, 700)
+	for start := time.Now(); len(followed) < len(content); {
+		n, err := readerAt.ReadAt(buf, int64(len(followed)))
+		if err != nil && err != io.EOF {
+			t.Fatalf("read at %d: %v", len(followed), err)
+		}
+		followed = append(followed, buf[:n]...)
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("timeout following the appends, read %d bytes", len(followed))
+		}
+	}
+	if err := <-appended; err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if !bytes.Equal(followed, content) {
+		t.Errorf("followed content mismatch")
+	}
+
+}
+
+func TestReaderAtEmptyFiles(t *testing.T) {
+
+	server := newTestVolumeServer(map[string][]byte{"1,0d01": randomBytes(1024)})
+	defer server.Close()
+
+	dirtyBuffer := func(size int) []byte {
+		buf := make([]byte, size)
+		for i := range buf {
+			buf[i] = 0xff
+		}
+		return buf
+	}
+
+	// a truly empty file
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, nil, newMapChunkCache(), 0)
+	if n, err := readerAt.ReadAt(dirtyBuffer(10), 0); n != 0 || err != io.EOF {
+		t.Errorf("empty file: n=%d err=%v", n, err)
+	}
+
+	// a file with a single zero size chunk view, padded to its file size
+	readerAt = NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{
+		{FileId: "1,0d01", Size: 0, ChunkSize: 1024, LogicOffset: 0},
+	}, newMapChunkCache(), 10)
+	buf := dirtyBuffer(16)
+	if n, err := readerAt.ReadAt(buf, 0); n != 10 || err != io.EOF {
+		t.Errorf("zero size chunk view: n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(buf[:10], make([]byte, 10)) {
+		t.Errorf("zero size chunk view: expect zeros, got %x", buf[:10])
+	}
+	if n, err := readerAt.ReadAt(dirtyBuffer(10), 10); n != 0 || err != io.EOF {
+		t.Errorf("read at file size: n=%d err=%v", n, err)
+	}
+
+	// zero size chunk views between and after the real ones
+	data := randomBytes(1024)
+	server.chunks["1,0d02"] = data
+	readerAt = NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{
+		{FileId: "1,0d01", Size: 0, ChunkSize: 1024, LogicOffset: 0},
+		{FileId: "1,0d02", Size: 1024, ChunkSize: 1024, LogicOffset: 0},
+		{FileId: "1,0d03", Size: 0, ChunkSize: 1024, LogicOffset: 1024},
+	}, newMapChunkCache(), 1024)
+	buf = dirtyBuffer(1024)
+	if n, err := readerAt.ReadAt(buf, 0); n != 1024 || err != nil || !bytes.Equal(buf, data) {
+		t.Errorf("zero size chunk views around a chunk: n=%d err=%v", n, err)
+	}
+	if n, err := readerAt.ReadAt(dirtyBuffer(1), 1024); n != 0 || err != io.EOF {
+		t.Errorf("read at file size: n=%d err=%v", n, err)
+	}
+
+	if server.requests != 1 {
+		t.Errorf("%d fetches, expect 1 for the only chunk with content", server.requests)
+	}
+
+}
+
+func TestReaderAtReset(t *testing.T) {
+
+	first, second := randomBytes(3000), randomBytes(2000)
+	server := newTestVolumeServer(map[string][]byte{
+		"1,1a01": first[:2000], "1,1a02": first[2000:],
+		"1,1b01": second[:1000], "1,1b02": second[1000:],
+	})
+	defer server.Close()
+
+	readAll := func(readerAt *ChunkReadAt, size int) []byte {
+		buf := make([]byte, size)
+		for offset := 0; offset < size; offset += 500 {
+			if n, err := readerAt.ReadAt(buf[offset:offset+500], int64(offset)); n != 500 || (err != nil && err != io.EOF) {
+				t.Fatalf("read at %d: n=%d err=%v", offset, n, err)
+			}
+		}
+		return buf
+	}
+
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{
+		{FileId: "1,1a01", Size: 2000, ChunkSize: 2000, LogicOffset: 0},
+		{FileId: "1,1a02", Size: 1000, ChunkSize: 1000, LogicOffset: 2000},
+	}, newMapChunkCache(), 3000)
+	if !bytes.Equal(readAll(readerAt, 3000), first) {
+		t.Fatalf("first file content mismatch")
+	}
+
+	readerAt.Reset([]*ChunkView{
+		{FileId: "1,1b01", Size: 1000, ChunkSize: 1000, LogicOffset: 0},
+		{FileId: "1,1b02", Size: 1000, ChunkSize: 1000, LogicOffset: 1000},
+	}, 2000)
+	if !bytes.Equal(readAll(readerAt, 2000), second) {
+		t.Errorf("second file content mismatch")
+	}
+	if n, err := readerAt.ReadAt(make([]byte, 500), 2000); n != 0 || err != io.EOF {
+		t.Errorf("read past the second file: n=%d err=%v", n, err)
+	}
+
+}
+
+func TestReaderAtCacheKeyNamespaces(t *testing.T) {
+
+	fileId := "1,2a01"
+	first, second := randomBytes(1024), randomBytes(1024)
+	firstServer := newTestVolumeServer(map[string][]byte{fileId: first})
+	defer firstServer.Close()
+	secondServer := newTestVolumeServer(map[string][]byte{fileId: second})
+	defer secondServer.Close()
+
+	cache := newMapChunkCache()
+	read := func(server *testVolumeServer, namespace string) []byte {
+		readerAt := NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{
+			{FileId: fileId, Size: 1024, ChunkSize: 1024, LogicOffset: 0},
+		}, cache, 1024)
+		readerAt.SetCacheKeyFn(CacheKeyInNamespace(namespace))
+		buf := make([]byte, 1024)
+		if n, err := readerAt.ReadAt(buf, 0); n != 1024 || (err != nil && err != io.EOF) {
+			t.Fatalf("read %s: n=%d err=%v", namespace, n, err)
+		}
+		return buf
+	}
+
+	for i := 0; i < 2; i++ {
+		if !bytes.Equal(read(firstServer, "tenant1"), first) {
+			t.Errorf("tenant1 read the data of another tenant")
+		}
+		if !bytes.Equal(read(secondServer, "tenant2"), second) {
+			t.Errorf("tenant2 read the data of another tenant")
+		}
+	}
+	if len(cache.chunks) != 2 || cache.chunks["tenant1/"+fileId] == nil || cache.chunks["tenant2/"+fileId] == nil {
+		t.Errorf("unexpected cache keys")
+	}
+	if firstServer.requests != 1 || secondServer.requests != 1 {
+		t.Errorf("expect one fetch per tenant, got %d and %d", firstServer.requests, secondServer.requests)
+	}
+
+}
+
+func TestResolveLocationsInOneLookup(t *testing.T) {
+
+	chunks := make(map[string][]byte)
+	var chunkViews []*ChunkView
+	for i := 0; i < 3; i++ {
+		fileId := fmt.Sprintf("%d,7a01", 11+i)
+		chunks[fileId] = randomBytes(1024)
+		chunkViews = append(chunkViews, &ChunkView{FileId: fileId, Size: 1024, ChunkSize: 1024, LogicOffset: int64(i * 1024)})
+	}
+	server := newTestVolumeServer(chunks)
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	filerClient := &fakeFilerClient{
+		locations: map[string][]string{"11": {host}, "12": {host}, "13": {host}},
+	}
+	readerAt := NewChunkReaderAtFromClient(nil, chunkViews, newMapChunkCache(), 3072)
+	readerAt.SetVolumeLookup(NewVolumeLookup(filerClient, nil))
+
+	if err := readerAt.ResolveLocations(context.Background()); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	buf := make([]byte, 3072)
+	if n, err := readerAt.ReadAt(buf, 0); n != 3072 || (err != nil && err != io.EOF) {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+	if filerClient.lookupRequests != 1 {
+		t.Errorf("expect one batched lookup, got %d", filerClient.lookupRequests)
+	}
+
+}
+
+func TestLookupFileIdsInOneRequest(t *testing.T) {
+
+	filerClient := &fakeFilerClient{
+		locations: map[string][]string{
+			"21": {"server1:8080"},
+			"22": {"server2:8080"},
+			"23": {"server3:8080"},
+		},
+	}
+	volumeLookup := NewVolumeLookup(filerClient, &LookupOptions{NoShuffle: true})
+
+	// a lazy miss still looks up its volume alone
+	if _, err := volumeLookup.LookupFileId("21,01"); err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+
+	fileIds := []string{"21,02", "22,01", "22,02", "23,01", "23,02"}
+	targetUrls, err := volumeLookup.LookupFileIds(context.Background(), fileIds)
+	if err != nil {
+		t.Fatalf("lookup file ids: %v", err)
+	}
+	for _, fileId := range fileIds {
+		expected := fmt.Sprintf("http://server%s:8080/%s", fileId[1:2], fileId)
+		if urls := targetUrls[fileId]; len(urls) != 1 || urls[0] != expected {
+			t.Errorf("%s located at %v, expect %s", fileId, urls, expected)
+		}
+	}
+
+	if len(filerClient.lookupVolumeIds) != 2 {
+		t.Fatalf("expect 2 lookups, got %v", filerClient.lookupVolumeIds)
+	}
+	if batched := filerClient.lookupVolumeIds[1]; strings.Join(batched, " ") != "22 23" {
+		t.Errorf("batched lookup of %v, expect the uncached volumes 22 23", batched)
+	}
+
+}
+
+func TestReaderAtLastChunkViewFastPath(t *testing.T) {
+
+	chunks := map[string][]byte{"1,1c01": randomBytes(1000), "1,1c02": randomBytes(1000), "1,1c03": randomBytes(500)}
+	server := newTestVolumeServer(chunks)
+	defer server.Close()
+
+	// a partial first chunk view, a hole before the second one, and zeros after the last one
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{
+		{FileId: "1,1c01", Offset: 100, Size: 900, ChunkSize: 1000, LogicOffset: 0},
+		{FileId: "1,1c02", Offset: 0, Size: 1000, ChunkSize: 1000, LogicOffset: 950},
+		{FileId: "1,1c03", Offset: 0, Size: 500, ChunkSize: 500, LogicOffset: 1950},
+	}, newMapChunkCache(), 2600)
+
+	// a streaming pass keeps the whole final chunk, before the random reads fetch only the ranges
+	for offset := int64(0); offset < 2600; offset += 100 {
+		readerAt.ReadAt(make([]byte, 100), offset)
+	}
+	if readerAt.lastChunkView == nil || readerAt.lastChunkView.FileId != "1,1c03" {
+		t.Fatalf("last chunk view %+v after the streaming pass, expect 1,1c03", readerAt.lastChunkView)
+	}
+
+	random := rand.New(rand.NewSource(1))
+	fastReads := 0
+	for i := 0; i < 2000; i++ {
+		offset, size := random.Int63n(2700), 1+random.Intn(200)
+		if random.Intn(4) > 0 {
+			size = 1 + random.Intn(16)
+		}
+		if _, ok := readerAt.readLastChunkView(make([]byte, size), offset); ok {
+			fastReads++
+		}
+		p := make([]byte, size)
+		n, err := readerAt.ReadAt(p, offset)
+
+		// read again through the general path
+		lastChunkView := readerAt.lastChunkView
+		readerAt.lastChunkView = nil
+		expected := make([]byte, size)
+		expectedN, expectedErr := readerAt.ReadAt(expected, offset)
+		readerAt.lastChunkView = lastChunkView
+
+		if n != expectedN || err != expectedErr || !bytes.Equal(p[:n], expected[:expectedN]) {
+			t.Fatalf("read [%d,%d): n=%d err=%v, general path n=%d err=%v", offset, offset+int64(size), n, err, expectedN, expectedErr)
+		}
+	}
+	if fastReads == 0 {
+		t.Errorf("no read took the fast path")
+	}
+
+}
+
+func BenchmarkSmallReadsInHotChunk(b *testing.B) {
+
+	server, chunkViews, content := newTestSequentialFile(64, 256*1024)
+	defer server.Close()
+
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, newMapChunkCache(), int64(len(content)))
+	hotChunk := chunkViews[32]
+	p := make([]byte, 512)
+	readerAt.ReadAt(p, hotChunk.LogicOffset)
+
+	b.SetBytes(int64(len(p)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		offset := hotChunk.LogicOffset + int64(i*len(p))%int64(hotChunk.Size-uint64(len(p)))
+		readerAt.ReadAt(p, offset)
+	}
+
+}
+
+func TestReaderAtTrailingHole(t *testing.T) {
+
+	chunks := map[string][]byte{
+		"1,0d01": randomBytes(4096),
+		"1,0d02": randomBytes(4096),
+	}
+	// a hole between the chunks, and a larger one from the end of the last chunk up to the file size
+	content := make([]byte, 64*1024)
+	copy(content, chunks["1,0d01"])
+	copy(content[8192:], chunks["1,0d02"])
+	server := newTestVolumeServer(chunks)
+	defer server.Close()
+
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{
+		{FileId: "1,0d01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+		{FileId: "1,0d02", Size: 4096, ChunkSize: 4096, LogicOffset: 8192},
+	}, newMapChunkCache(), int64(len(content)))
+
+	for _, tc := range []struct {
+		offset      int64
+		size        int
+		expected    int
+		expectedErr error
+	}{
+		{offset: 20000, size: 100, expected: 100},                                        // inside the trailing hole
+		{offset: 12288, size: 1000, expected: 1000},                                      // at the start of the trailing hole
+		{offset: 12000, size: 1000, expected: 1000},                                      // straddling the end of the last chunk
+		{offset: 6000, size: 10000, expected: 10000},                                     // across both holes
+		{offset: 12000, size: 64 * 1024, expected: 64*1024 - 12000, expectedErr: io.EOF}, // up to the file end
+		{offset: 64*1024 - 100, size: 100, expected: 100},
+		{offset: 64*1024 - 100, size: 1000, expected: 100, expectedErr: io.EOF},
+		{offset: 64 * 1024, size: 100, expected: 0, expectedErr: io.EOF},
+		{offset: 0, size: 128 * 1024, expected: 64 * 1024, expectedErr: io.EOF},
+	} {
+		buf := bytes.Repeat([]byte{0xff}, tc.size)
+		n, err := readerAt.ReadAt(buf, tc.offset)
+		if n != tc.expected || err != tc.expectedErr {
+			t.Errorf("read [%d,%d): %d %v, expect %d %v", tc.offset, tc.offset+int64(tc.size), n, err, tc.expected, tc.expectedErr)
+			continue
+		}
+		if !bytes.Equal(buf[:n], content[tc.offset:tc.offset+int64(n)]) {
+			t.Errorf("read [%d,%d): unexpected content", tc.offset, tc.offset+int64(n))
+		}
+		if n < tc.size && !bytes.Equal(buf[n:], bytes.Repeat([]byte{0xff}, tc.size-n)) {
+			t.Errorf("read [%d,%d): written beyond the file size", tc.offset, tc.offset+int64(tc.size))
+		}
+	}
+
+}
+
+func TestVolumeLookupCacheTTL(t *testing.T) {
+
+	filerClient := &fakeFilerClient{
+		locations: map[string][]string{"31": {"server1:8080"}},
+	}
+	clock := util.NewTestClock(time.Unix(1600000000, 0))
+	volumeLookup := NewVolumeLookup(filerClient, &LookupOptions{CacheTTL: time.Minute, Clock: clock})
+
+	lookup := func(expected string) {
+		t.Helper()
+		urls, err := volumeLookup.LookupFileId("31,01")
+		if err != nil || len(urls) != 1 || urls[0] != "http://"+expected+"/31,01" {
+			t.Fatalf("lookup: %v %v, expect %s", urls, err, expected)
+		}
+	}
+
+	lookup("server1:8080")
+	filerClient.locations["31"] = []string{"server2:8080"}
+	clock.Advance(time.Minute - time.Second)
+	lookup("server1:8080")
+	if filerClient.lookupRequests != 1 {
+		t.Errorf("%d lookups before the cached locations expire, expect 1", filerClient.lookupRequests)
+	}
+
+	clock.Advance(time.Second)
+	lookup("server2:8080")
+	if filerClient.lookupRequests != 2 {
+		t.Errorf("%d lookups after the cached locations expire, expect 2", filerClient.lookupRequests)
+	}
+
+	// the retries of a failing lookup wait on the clock too
+	filerClient.lookupFailures = 1
+	clock.Advance(time.Minute)
+	done := make(chan error)
+	go func() {
+		_, err := volumeLookup.LookupFileId("31,01")
+		done <- err
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("lookup retried after the backoff: %v", err)
+	}
+	if requests := atomic.LoadInt32(&filerClient.lookupRequests); requests != 4 {
+		t.Errorf("%d lookups, expect 4", requests)
+	}
+
+}
+
+func TestReaderAtPinsLocations(t *testing.T) {
+
+	chunks := map[string][]byte{
+		"41,01": randomBytes(4096),
+		"41,02": randomBytes(4096),
+		"42,01": randomBytes(4096),
+	}
+	content := append(append(append([]byte{}, chunks["41,01"]...), chunks["41,02"]...), chunks["42,01"]...)
+	oldServer, newServer := newTestVolumeServer(chunks), newTestVolumeServer(chunks)
+	defer oldServer.Close()
+	defer newServer.Close()
+	oldHost, newHost := strings.TrimPrefix(oldServer.URL, "http://"), strings.TrimPrefix(newServer.URL, "http://")
+
+	filerClient := &fakeFilerClient{
+		locations: map[string][]string{"41": {oldHost}, "42": {oldHost}},
+	}
+	clock := util.NewTestClock(time.Unix(1600000000, 0))
+	volumeLookup := NewVolumeLookup(filerClient, &LookupOptions{CacheTTL: time.Minute, Clock: clock})
+	readerAt := NewChunkReaderAtFromClient(nil, []*ChunkView{
+		{FileId: "41,01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+		{FileId: "41,02", Size: 4096, ChunkSize: 4096, LogicOffset: 4096},
+		{FileId: "42,01", Size: 4096, ChunkSize: 4096, LogicOffset: 8192},
+	}, newMapChunkCache(), int64(len(content)))
+	readerAt.SetVolumeLookup(volumeLookup)
+
+	if err := readerAt.PinLocations(context.Background()); err != nil {
+		t.Fatalf("pin: %v", err)
+	}
+	read := func(offset int64) {
+		t.Helper()
+		buf := make([]byte, 4096)
+		if n, err := readerAt.ReadAt(buf, offset); n != len(buf) || (err != nil && err != io.EOF) {
+			t.Fatalf("read at %d: n=%d err=%v", offset, n, err)
+		}
+		if !bytes.Equal(buf, content[offset:offset+4096]) {
+			t.Fatalf("read at %d: unexpected content", offset)
+		}
+	}
+	read(0)
+
+	// the volumes move mid read, and the cached locations expire
+	filerClient.locations = map[string][]string{"41": {newHost}, "42": {newHost}}
+	clock.Advance(time.Minute)
+	if urls, err := volumeLookup.LookupFileId("41,02"); err != nil || len(urls) != 1 || !strings.Contains(urls[0], newHost) {
+		t.Fatalf("unpinned lookup: %v %v, expect the new server", urls, err)
+	}
+	read(4096)
+	read(8192)
+	if requests := atomic.LoadInt32(&newServer.requests); requests != 0 {
+		t.Errorf("%d requests to the new server before the refresh, expect 0", requests)
+	}
+
+	// the old server goes away
+	if err := readerAt.RefreshPinnedLocations(context.Background()); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	for _, fileId := range []string{"41,01", "42,01"} {
+		if urls, err := readerAt.lookupFileId(fileId); err != nil || len(urls) != 1 || !strings.Contains(urls[0], newHost) {
+			t.Errorf("pinned lookup of %s after the refresh: %v %v, expect the new server", fileId, urls, err)
+		}
+	}
+
+}
+
+func TestReadMixedCompressionChunks(t *testing.T) {
+
+	plain := [][]byte{
+		bytes.Repeat([]byte("gzipped, but served as stored "), 100),
+		randomBytes(3000),
+		bytes.Repeat([]byte("gzipped, served with its encoding "), 100),
+	}
+	stored := make([][]byte, len(plain))
+	for i, data := range plain {
+		stored[i] = data
+		if i != 1 {
+			gzipped, err := util.GzipData(data)
+			if err != nil {
+				t.Fatalf("gzip: %v", err)
+			}
+			stored[i] = gzipped
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/1,8a0"))
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			// decompressed for range reads
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(plain[i]))
+			return
+		}
+		// the first chunk passes through a proxy dropping the Content-Encoding header
+		if i == 2 {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		w.Write(stored[i])
+	}))
+	defer server.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+
+	var content []byte
+	var chunkViews []*ChunkView
+	for i, data := range plain {
+		chunkViews = append(chunkViews, &ChunkView{
+			FileId:      fmt.Sprintf("1,8a0%d", i),
+			Size:        uint64(len(data)),
+			ChunkSize:   uint64(len(data)),
+			LogicOffset: int64(len(content)),
+			IsGzipped:   i != 1,
+		})
+		content = append(content, data...)
+	}
+
+	cache := newMapChunkCache()
+	readerAt := NewChunkReaderAtFromClient(lookupFn, chunkViews, cache, int64(len(content)))
+	buf := make([]byte, len(content))
+	if n, err := readerAt.ReadAt(buf, 0); n != len(buf) || (err != nil && err != io.EOF) {
+		t.Fatalf("read %d bytes: %v", n, err)
+	}
+	if !bytes.Equal(buf, content) {
+		t.Errorf("read mismatch")
+	}
+	for i, chunkView := range chunkViews {
+		chunk := make([]byte, chunkView.Size)
+		readerAt := NewChunkReaderAtFromClient(lookupFn, chunkViews, cache, int64(len(content)))
+		if n, err := readerAt.ReadAt(chunk, chunkView.LogicOffset); n != len(chunk) || (err != nil && err != io.EOF) {
+			t.Fatalf("read chunk %d: %d bytes, %v", i, n, err)
+		}
+		if !bytes.Equal(chunk, plain[i]) {
+			t.Errorf("chunk %d read mismatch", i)
+		}
+	}
+
+	// the cache keeps the content, whatever the chunk is stored as
+	if cached := cache.GetChunk("1,8a00", 0); !bytes.Equal(cached, plain[0]) {
+		t.Errorf("cached %d bytes of the first chunk, expect its %d content bytes", len(cached), len(plain[0]))
+	}
+
+}
+
+func TestSharedFetchIsCheckedAndCancelledPerReader(t *testing.T) {
+
+	fileId := "1,0f101112"
+	data := randomBytes(64 * 1024)
+	var requests int32
+	received, release := make(chan struct{}, 1), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		received <- struct{}{}
+		<-release
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+	chunkViews := []*ChunkView{{FileId: fileId, Size: uint64(len(data)), ChunkSize: uint64(len(data))}}
+	cache := newMapChunkCache()
+	var group singleflight.Group
+	newReader := func(checksum string) *ChunkReadAt {
+		reader := NewChunkReaderAtFromClient(lookupFn, chunkViews, cache, int64(len(data)))
+		reader.SetFetchGroup(&group)
+		reader.SetChunkChecksums(ChecksumCRC32, map[string]string{fileId: checksum})
+		return reader
+	}
+	read := func(ctx context.Context, reader *ChunkReadAt) chan error {
+		result := make(chan error, 1)
+		go func() {
+			buf := make([]byte, len(data))
+			n, err := reader.ReadAtWithContext(ctx, buf, 0)
+			if err == nil && (n != len(data) || !bytes.Equal(buf, data)) {
+				err = fmt.Errorf("read %d bytes not matching", n)
+			}
+			result <- err
+		}()
+		return result
+	}
+
+	// the first reader starts the fetch, and gives up on it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := read(ctx, newReader(ChunkChecksum(ChecksumCRC32, data)))
+	<-received
+	mismatched := read(context.Background(), newReader("crc32:00000000"))
+	matched := read(context.Background(), newReader(ChunkChecksum(ChecksumCRC32, data)))
+	// let the other readers join the pending fetch
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled read: %v", err)
+	}
+
+	close(release)
+	if err := <-mismatched; !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("read with another checksum: %v", err)
+	}
+	if err := <-matched; err != nil {
+		t.Errorf("read joining a cancelled fetch: %v", err)
+	}
+	if fetches := atomic.LoadInt32(&requests); fetches != 1 {
+		t.Errorf("%d fetches for the same chunk, expect 1", fetches)
+	}
+	if !bytes.Equal(cache.GetChunk(fileId, uint64(len(data))), data) {
+		t.Errorf("checked chunk not cached")
+	}
+
+}
@@ -0,0 +1,99 @@
+package filer
+
+import (
+	"context"
+	"sync"
+)
+
+// fetchBudget bounds the bytes of the chunk fetches in flight, handing the freed bytes to the waiters in order.
+type fetchBudget struct {
+	sync.Mutex
+	limit   int64
+	inUse   int64
+	waiters []*budgetWaiter
+}
+
+type budgetWaiter struct {
+	size    int64
+	granted chan struct{}
+}
+
+// SetMaxInFlightBytes bounds the total size of the chunks this reader fetches at the same time,
+// e.g. for many concurrent reads of large chunks. Fetches over the limit wait for the earlier ones,
+// and a chunk larger than the limit is fetched alone. 0, the default, leaves the fetches unbounded.
+// This is on top of the fetch and prefetch limits shared by all readers.
+func (c *ChunkReadAt) SetMaxInFlightBytes(limit int64) {
+	if limit <= 0 {
+		c.fetchBudget = nil
+		return
+	}
+	c.fetchBudget = &fetchBudget{
+		limit: limit,
+	}
+}
+
+// acquire waits till the size fits in the budget, or the context is done.
+func (b *fetchBudget) acquire(ctx context.Context, size int64) (release func(), err error) {
+	if b == nil {
+		return func() {}, nil
+	}
+	if size > b.limit {
+		size = b.limit
+	}
+	release = func() {
+		b.release(size)
+	}
+
+	b.Lock()
+	if len(b.waiters) == 0 && b.inUse+size <= b.limit {
+		b.inUse += size
+		b.Unlock()
+		return release, nil
+	}
+	waiter := &budgetWaiter{
+		size:    size,
+		granted: make(chan struct{}),
+	}
+	b.waiters = append(b.waiters, waiter)
+	b.Unlock()
+
+	select {
+	case <-waiter.granted:
+		return release, nil
+	case <-ctx.Done():
+	}
+
+	b.Lock()
+	defer b.Unlock()
+	select {
+	case <-waiter.granted:
+		// granted while giving up
+		b.inUse -= size
+	default:
+		for i, w := range b.waiters {
+			if w == waiter {
+				b.waiters = append(b.waiters[:i], b.waiters[i+1:]...)
+				break
+			}
+		}
+	}
+	// the waiters behind may fit now
+	b.grant()
+	return nil, ctx.Err()
+}
+
+func (b *fetchBudget) release(size int64) {
+	b.Lock()
+	defer b.Unlock()
+	b.inUse -= size
+	b.grant()
+}
+
+func (b *fetchBudget) grant() {
+	for len(b.waiters) > 0 && b.inUse+b.waiters[0].size <= b.limit {
+		waiter := b.waiters[0]
+		b.waiters = b.waiters[1:]
+		b.inUse += waiter.size
+		close(waiter.granted)
+	}
+}
@@ -0,0 +1,113 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReaderAtBoundsInFlightBytes(t *testing.T) {
+
+	const chunkSize = 64 * 1024
+	server, chunkViews, content := newTestSequentialFile(16, chunkSize)
+	defer server.Close()
+
+	// count the chunks being served at the same time
+	var inFlight, maxInFlight int32
+	counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			seen := atomic.LoadInt32(&maxInFlight)
+			if current <= seen || atomic.CompareAndSwapInt32(&maxInFlight, seen, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		server.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer counting.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{counting.URL + "/" + fileId}, nil
+	}
+
+	readerAt := NewChunkReaderAtFromClient(lookupFn, chunkViews, newMapChunkCache(), int64(len(content)))
+	readerAt.SetMaxInFlightBytes(2 * chunkSize)
+
+	// a streaming reader fetching far ahead, and random reads at the same time
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		reader := readerAt.NewSequentialReader(8)
+		defer reader.Close()
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, reader); err != nil {
+			t.Errorf("copy: %v", err)
+		}
+		if !bytes.Equal(buf.Bytes(), content) {
+			t.Errorf("streamed data differs")
+		}
+	}()
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			offset := int64((15 - i) * chunkSize)
+			buf := make([]byte, 1024)
+			if _, err := readerAt.ReadAt(buf, offset); err != nil && err != io.EOF {
+				t.Errorf("read at %d: %v", offset, err)
+			}
+			if !bytes.Equal(buf, content[offset:offset+1024]) {
+				t.Errorf("read at %d: data differs", offset)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if maxInFlight > 2 {
+		t.Errorf("%d chunks fetched at the same time, expect at most 2 within the budget", maxInFlight)
+	}
+
+}
+
+func TestReadAtWithContextGivesUpWaitingForBudget(t *testing.T) {
+
+	server, chunkViews, content := newTestSequentialFile(2, 4096)
+	defer server.Close()
+
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, newMapChunkCache(), int64(len(content)))
+	readerAt.SetMaxInFlightBytes(4096)
+
+	// another fetch holds the whole budget
+	release, err := readerAt.fetchBudget.acquire(context.Background(), 4096)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := readerAt.ReadAtWithContext(ctx, make([]byte, 1024), 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("read waiting for the budget: %v, expect the deadline exceeded", err)
+	}
+	if requests := atomic.LoadInt32(&server.requests); requests != 0 {
+		t.Errorf("%d requests while the budget is used up", requests)
+	}
+
+	release()
+	buf := make([]byte, 1024)
+	if _, err := readerAt.ReadAtWithContext(context.Background(), buf, 0); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(buf, content[:1024]) {
+		t.Errorf("read data differs")
+	}
+
+}
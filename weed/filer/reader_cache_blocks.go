@@ -0,0 +1,79 @@
+package filer
+
+import (
+	"context"
+
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
+)
+
+// SetCacheBlockSize caches the plain chunks larger than the block size by blocks, fetched by range
+// on first access, instead of whole. Only the hot parts of huge chunks take up the cache then, e.g. for
+// random reads of large media or database files. The blocks are kept in memory, not in the on disk cache.
+// Encrypted and compressed chunks can only be read whole, and still are. 0, the default, caches whole chunks.
+func (c *ChunkReadAt) SetCacheBlockSize(blockSize int64) {
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+	if blockSize < 0 {
+		blockSize = 0
+	}
+	c.cacheBlockSize = blockSize
+}
+
+func (c *ChunkReadAt) readsByBlocks(chunkView *ChunkView) bool {
+	return c.cacheBlockSize > 0 && c.lookupFileId != nil &&
+		chunkView.CipherKey == nil && !chunkView.IsGzipped && chunkView.ChunkSize > uint64(c.cacheBlockSize)
+}
+
+// readChunkBlocks reads [offset, offset+length) of the chunk from its cached blocks, fetching the missing ones
+func (c *ChunkReadAt) readChunkBlocks(ctx context.Context, chunkView *ChunkView, offset, length uint64) ([]byte, error) {
+
+	blockSize := uint64(c.cacheBlockSize)
+	data := make([]byte, 0, length)
+	for stop := offset + length; offset < stop; {
+		index := offset / blockSize
+		blockStart := index * blockSize
+		if blockStart >= chunkView.ChunkSize {
+			break
+		}
+		expected := minUint64(blockSize, chunkView.ChunkSize-blockStart)
+		block, err := c.readChunkBlock(ctx, chunkView, int64(index), blockStart, expected)
+		if err != nil {
+			return nil, err
+		}
+		// a chunk shorter than expected is reported by the caller
+		from, to := offset-blockStart, minUint64(uint64(len(block)), stop-blockStart)
+		if from >= to {
+			break
+		}
+		data = append(data, block[from:to]...)
+		offset = blockStart + to
+		if uint64(len(block)) < expected {
+			break
+		}
+	}
+	return data, nil
+}
+
+func (c *ChunkReadAt) readChunkBlock(ctx context.Context, chunkView *ChunkView, index int64, blockStart, size uint64) ([]byte, error) {
+
+	key := chunk_cache.BlockKey(c.cacheKey(chunkView.FileId), index)
+	v, err := c.getFetchGroup().Do(key, func() (interface{}, error) {
+		if data := c.chunkCache.GetChunk(key, size); data != nil {
+			c.eventSink.emit(ReadEvent{Type: ReadEventCacheHit, FileId: chunkView.FileId, Offset: int64(blockStart), Size: int64(len(data))})
+			return data, nil
+		}
+		c.eventSink.emit(ReadEvent{Type: ReadEventCacheMiss, FileId: chunkView.FileId, Offset: int64(blockStart), Size: int64(size)})
+		data, err := c.doFetchRangeChunkData(ctx, chunkView, blockStart, size)
+		if err != nil {
+			return nil, err
+		}
+		if uint64(len(data)) == size {
+			c.chunkCache.SetChunk(key, data)
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]byte), nil
+}
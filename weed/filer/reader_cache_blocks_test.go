@@ -0,0 +1,64 @@
+package filer
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
+)
+
+func TestReaderAtCachesBlocks(t *testing.T) {
+
+	const chunkSize, blockSize = 4096, 1024
+	server, chunkViews, content := newTestSequentialFile(2, chunkSize)
+	defer server.Close()
+
+	cache := newMapChunkCache()
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, cache, int64(len(content)))
+	readerAt.SetCacheBlockSize(blockSize)
+
+	read := func(offset, size int64, expectedFetches int32) {
+		t.Helper()
+		fetches := atomic.LoadInt32(&server.rangeRequests)
+		buf := make([]byte, size)
+		if n, err := readerAt.ReadAt(buf, offset); n != len(buf) || err != nil {
+			t.Fatalf("read [%d,%d): n=%d err=%v", offset, offset+size, n, err)
+		}
+		if !bytes.Equal(buf, content[offset:offset+size]) {
+			t.Fatalf("read [%d,%d): content mismatch", offset, offset+size)
+		}
+		if fetched := atomic.LoadInt32(&server.rangeRequests) - fetches; fetched != expectedFetches {
+			t.Errorf("read [%d,%d) fetched %d blocks, expect %d", offset, offset+size, fetched, expectedFetches)
+		}
+	}
+
+	// across the first two blocks
+	read(1000, 100, 2)
+	// within a cached block
+	read(1050, 10, 0)
+	// the last two blocks of the first chunk
+	read(3000, 100, 2)
+	// across the chunks, from a cached block to the first block of the second chunk
+	read(4000, 200, 1)
+	// a read covering cached and missing blocks fetches the missing ones only
+	read(0, 4096+2048, 1)
+
+	if requests, rangeRequests := atomic.LoadInt32(&server.requests), atomic.LoadInt32(&server.rangeRequests); requests != rangeRequests {
+		t.Errorf("%d whole chunk fetches, expect only blocks fetched", requests-rangeRequests)
+	}
+	cache.Lock()
+	defer cache.Unlock()
+	if _, found := cache.chunks[chunkViews[0].FileId]; found {
+		t.Errorf("whole chunk cached")
+	}
+	for i, fileId := range []string{chunkViews[0].FileId, chunkViews[1].FileId} {
+		for index := int64(0); index < 4; index++ {
+			_, found := cache.chunks[chunk_cache.BlockKey(fileId, index)]
+			if expected := i == 0 || index < 2; found != expected {
+				t.Errorf("block %d of chunk %d cached: %v", index, i, found)
+			}
+		}
+	}
+
+}
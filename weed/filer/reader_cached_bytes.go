@@ -0,0 +1,25 @@
+package filer
+
+import (
+	"sync/atomic"
+)
+
+// CachedBytes returns the bytes of chunk data held by this reader itself, the last chunk read, the final one
+// of a growing file and the chunks prefetched synchronously, e.g. for a pool of readers to close the idle ones
+// holding the most.
+// The chunk cache is shared by the readers, and not counted. Safe to call while reading.
+func (c *ChunkReadAt) CachedBytes() int64 {
+	return atomic.LoadInt64(&c.cachedBytes)
+}
+
+// recountCachedBytes updates CachedBytes after the held chunks change, counting a chunk held twice once
+func (c *ChunkReadAt) recountCachedBytes() {
+	cached := len(c.lastChunkData) + len(c.tailChunkData)
+	if len(c.lastChunkData) > 0 && len(c.tailChunkData) > 0 && &c.lastChunkData[0] == &c.tailChunkData[0] {
+		cached -= len(c.tailChunkData)
+	}
+	for _, data := range c.prefetchedChunks {
+		cached += len(data)
+	}
+	atomic.StoreInt64(&c.cachedBytes, int64(cached))
+}
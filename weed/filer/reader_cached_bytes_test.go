@@ -0,0 +1,46 @@
+package filer
+
+import (
+	"testing"
+)
+
+func TestReaderAtCachedBytes(t *testing.T) {
+
+	server, chunkViews, _ := newTestSequentialFile(4, 4096)
+	defer server.Close()
+	// a reader following the file as it grows
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews[:3], newMapChunkCache(), 3*4096)
+	if err := readerAt.AppendChunkViews(4*4096, chunkViews[3]); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	if cached := readerAt.CachedBytes(); cached != 0 {
+		t.Fatalf("new reader holds %d bytes", cached)
+	}
+
+	for _, step := range []struct {
+		offset int64
+		cached int64
+	}{
+		{offset: 0, cached: 4096},        // the first chunk, kept as the last one read
+		{offset: 4096, cached: 4096},     // replaced by the second one
+		{offset: 3 * 4096, cached: 8192}, // the final chunk, kept along with the last one
+		{offset: 3 * 4096, cached: 8192},
+	} {
+		if _, err := readerAt.ReadAt(make([]byte, 4096), step.offset); err != nil {
+			t.Fatalf("read at %d: %v", step.offset, err)
+		}
+		if cached := readerAt.CachedBytes(); cached != step.cached {
+			t.Errorf("after reading at %d, the reader holds %d bytes, expect %d", step.offset, cached, step.cached)
+		}
+		if held := int64(len(readerAt.lastChunkData) + len(readerAt.tailChunkData)); held != step.cached {
+			t.Errorf("after reading at %d, the reader reports %d bytes, but holds %d", step.offset, step.cached, held)
+		}
+	}
+
+	readerAt.Close()
+	if cached := readerAt.CachedBytes(); cached != 0 {
+		t.Errorf("closed reader holds %d bytes", cached)
+	}
+
+}
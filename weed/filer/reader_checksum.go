@@ -0,0 +1,91 @@
+package filer
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	"github.com/cespare/xxhash"
+)
+
+// ErrChecksumMismatch is matched by errors.Is for chunk data not matching its checksum.
+var ErrChecksumMismatch = errors.New("chunk checksum mismatch")
+
+// ChecksumAlgorithm names how the chunk data is checksummed, see ChunkChecksum.
+type ChecksumAlgorithm string
+
+const (
+	ChecksumCRC32    ChecksumAlgorithm = "crc32" // Castagnoli, as the volume servers use
+	ChecksumXXHash64 ChecksumAlgorithm = "xxh64" // stronger than crc32, and faster on large chunks
+)
+
+var crc32Table = crc32.MakeTable(crc32.Castagnoli)
+
+// ParseChecksumAlgorithm parses the algorithm name of a command line option.
+func ParseChecksumAlgorithm(name string) (ChecksumAlgorithm, error) {
+	switch algorithm := ChecksumAlgorithm(strings.ToLower(name)); algorithm {
+	case ChecksumCRC32, ChecksumXXHash64:
+		return algorithm, nil
+	}
+	return "", fmt.Errorf("unknown checksum algorithm %q, expecting %s or %s", name, ChecksumCRC32, ChecksumXXHash64)
+}
+
+// ChunkChecksum returns the checksum of the chunk data as "<algorithm>:<hex>", e.g. "xxh64:9a3f0c21d7e4b856".
+// The sum is printed as a big endian number, so the same data gives the same string on any host,
+// and checksums recorded by one client compare equal when verified by another.
+func ChunkChecksum(algorithm ChecksumAlgorithm, data []byte) string {
+	switch algorithm {
+	case ChecksumXXHash64:
+		return fmt.Sprintf("%s:%016x", algorithm, xxhash.Sum64(data))
+	default:
+		return fmt.Sprintf("%s:%08x", ChecksumCRC32, crc32.Checksum(data, crc32Table))
+	}
+}
+
+// ChecksumMismatchError is a chunk whose data does not match its recorded checksum.
+type ChecksumMismatchError struct {
+	FileId   string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("chunk %s checksum %s, expecting %s", e.FileId, e.Actual, e.Expected)
+}
+
+func (e *ChecksumMismatchError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
+
+type chunkChecksums struct {
+	algorithm ChecksumAlgorithm
+	checksums map[string]string
+}
+
+// SetChunkChecksums makes the reader check the chunks it fetches whole against their checksums
+// by file id, as returned by ChunkChecksum with the algorithm. The chunks with a checksum are then
+// always fetched whole, and fail to read with a *ChecksumMismatchError if they do not match, also when
+// their checksum was recorded with another algorithm. Verify checks them too, trying the other replicas.
+// The chunks found in the chunk cache were checked when fetched, and are not checked again.
+func (c *ChunkReadAt) SetChunkChecksums(algorithm ChecksumAlgorithm, checksums map[string]string) {
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+	c.checksums = chunkChecksums{algorithm: algorithm, checksums: checksums}
+}
+
+func (cc chunkChecksums) has(fileId string) bool {
+	_, found := cc.checksums[fileId]
+	return found
+}
+
+func (cc chunkChecksums) check(fileId string, data []byte) error {
+	expected, found := cc.checksums[fileId]
+	if !found {
+		return nil
+	}
+	if actual := ChunkChecksum(cc.algorithm, data); actual != expected {
+		return &ChecksumMismatchError{FileId: fileId, Expected: expected, Actual: actual}
+	}
+	return nil
+}
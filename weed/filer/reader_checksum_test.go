@@ -0,0 +1,102 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestChunkChecksumIsByteOrderIndependent(t *testing.T) {
+	if got := ChunkChecksum(ChecksumXXHash64, nil); got != "xxh64:ef46db3751d8e999" {
+		t.Errorf("xxh64 of nothing: %s", got)
+	}
+	if got := ChunkChecksum(ChecksumCRC32, []byte("123456789")); got != "crc32:e3069283" {
+		t.Errorf("crc32 of the check string: %s", got)
+	}
+	if _, err := ParseChecksumAlgorithm("XXH64"); err != nil {
+		t.Errorf("parse xxh64: %v", err)
+	}
+	if _, err := ParseChecksumAlgorithm("md5"); err == nil {
+		t.Errorf("md5 should not be a checksum algorithm")
+	}
+}
+
+func TestReaderAtDetectsCorruptionWithXXHash(t *testing.T) {
+
+	data := randomBytes(4096)
+	checksum := ChunkChecksum(ChecksumXXHash64, data)
+
+	corruptions := map[string]func([]byte){
+		"intact": func(b []byte) {},
+		"single bit": func(b []byte) {
+			b[1234] ^= 0x08
+		},
+		"multiple bytes": func(b []byte) {
+			copy(b[2000:], []byte{0xde, 0xad, 0xbe, 0xef, 0x00, 0x00, 0xff})
+			b[4095]++
+		},
+	}
+	for name, corrupt := range corruptions {
+		t.Run(name, func(t *testing.T) {
+			served := append([]byte(nil), data...)
+			corrupt(served)
+			server := newTestVolumeServer(map[string][]byte{"1,4b01": served})
+			defer server.Close()
+
+			cache := newMapChunkCache()
+			chunkViews := []*ChunkView{{FileId: "1,4b01", Size: 4096, ChunkSize: 4096, LogicOffset: 0}}
+			readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, cache, 4096)
+			readerAt.SetChunkChecksums(ChecksumXXHash64, map[string]string{"1,4b01": checksum})
+
+			buf := make([]byte, 100)
+			_, err := readerAt.ReadAt(buf, 1200)
+			verifyErr := readerAt.Verify(context.Background())
+			if name == "intact" {
+				if err != nil || verifyErr != nil {
+					t.Fatalf("read intact chunk: %v, verify: %v", err, verifyErr)
+				}
+				return
+			}
+			if !errors.Is(err, ErrChecksumMismatch) || !errors.Is(err, ErrChunkFetch) {
+				t.Errorf("expected a checksum mismatch, got %v", err)
+			}
+			if !errors.Is(verifyErr, ErrChecksumMismatch) {
+				t.Errorf("expected verify to find the checksum mismatch, got %v", verifyErr)
+			}
+			if len(cache.chunks) != 0 {
+				t.Errorf("the corrupted chunk should not be cached")
+			}
+		})
+	}
+}
+
+func TestReaderAtComparesChecksumsOfTheSameAlgorithm(t *testing.T) {
+
+	data := randomBytes(1024)
+	server := newTestVolumeServer(map[string][]byte{"1,4c01": data})
+	defer server.Close()
+
+	chunkViews := []*ChunkView{{FileId: "1,4c01", Size: 1024, ChunkSize: 1024, LogicOffset: 0}}
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, newMapChunkCache(), 1024)
+	readerAt.SetChunkChecksums(ChecksumXXHash64, map[string]string{"1,4c01": ChunkChecksum(ChecksumCRC32, data)})
+
+	var mismatch *ChecksumMismatchError
+	if _, err := readerAt.ReadAt(make([]byte, 1024), 0); !errors.As(err, &mismatch) {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	if mismatch.Expected != ChunkChecksum(ChecksumCRC32, data) || mismatch.Actual != ChunkChecksum(ChecksumXXHash64, data) {
+		t.Errorf("unexpected mismatch %v", mismatch)
+	}
+}
+
+func BenchmarkChunkChecksum(b *testing.B) {
+	data := randomBytes(4 * 1024 * 1024)
+	for _, algorithm := range []ChecksumAlgorithm{ChecksumCRC32, ChecksumXXHash64} {
+		b.Run(string(algorithm), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				ChunkChecksum(algorithm, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package filer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
+)
+
+// ErrOverlappingChunkViews is matched by errors.Is for chunk views covering the same file offsets.
+var ErrOverlappingChunkViews = errors.New("overlapping chunk views")
+
+// NewStrictChunkReaderAt is NewChunkReaderAtFromClient failing with ErrOverlappingChunkViews
+// instead of reading overlapping chunk views, where the earlier one hides the rest.
+func NewStrictChunkReaderAt(lookupFn wdclient.LookupFileIdFunctionType, chunkViews []*ChunkView, chunkCache chunk_cache.ChunkCache, fileSize int64) (*ChunkReadAt, error) {
+	chunkViews, err := checkChunkViews(chunkViews)
+	if err != nil {
+		return nil, err
+	}
+	c := NewChunkReaderAtFromClient(lookupFn, nil, chunkCache, fileSize)
+	c.chunkViews = chunkViews
+	return c, nil
+}
+
+// checkChunkViews returns the chunk views sorted by their file offsets, as doReadAt expects,
+// and an error for the first overlapping ones. The given slice is left unchanged.
+func checkChunkViews(chunkViews []*ChunkView) ([]*ChunkView, error) {
+
+	sorted := sort.SliceIsSorted(chunkViews, func(i, j int) bool {
+		return chunkViews[i].LogicOffset < chunkViews[j].LogicOffset
+	})
+	if !sorted {
+		glog.Warningf("sort %d chunk views by file offset", len(chunkViews))
+		chunkViews = append([]*ChunkView(nil), chunkViews...)
+		sort.SliceStable(chunkViews, func(i, j int) bool {
+			return chunkViews[i].LogicOffset < chunkViews[j].LogicOffset
+		})
+	}
+
+	var last *ChunkView
+	for _, chunkView := range chunkViews {
+		if chunkView.Size == 0 {
+			continue
+		}
+		if last != nil && chunkView.LogicOffset < last.LogicOffset+int64(last.Size) {
+			return chunkViews, fmt.Errorf("%w: %s [%d,%d) and %s [%d,%d)", ErrOverlappingChunkViews,
+				last.FileId, last.LogicOffset, last.LogicOffset+int64(last.Size),
+				chunkView.FileId, chunkView.LogicOffset, chunkView.LogicOffset+int64(chunkView.Size))
+		}
+		last = chunkView
+	}
+
+	return chunkViews, nil
+}
+
+// ChunkFileIds returns the file ids of the chunk views in file offset order, e.g. to authorize
+// the chunks, or their volumes, before any read.
+func (c *ChunkReadAt) ChunkFileIds() []string {
+
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+
+	fileIds := make([]string, 0, len(c.chunkViews))
+	for _, chunkView := range c.chunkViews {
+		fileIds = append(fileIds, chunkView.FileId)
+	}
+	return fileIds
+}
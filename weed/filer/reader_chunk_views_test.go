@@ -0,0 +1,86 @@
+package filer
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestReaderAtSortsChunkViews(t *testing.T) {
+
+	server, chunkViews, content := newTestSequentialFile(4, 1024)
+	defer server.Close()
+
+	unsorted := []*ChunkView{chunkViews[2], chunkViews[0], chunkViews[3], chunkViews[1]}
+	readerAt, err := NewStrictChunkReaderAt(server.lookupFn, unsorted, newMapChunkCache(), int64(len(content)))
+	if err != nil {
+		t.Fatalf("new reader: %v", err)
+	}
+	data := make([]byte, len(content))
+	if n, err := readerAt.ReadAt(data, 0); n != len(content) || err != nil {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Errorf("read unsorted chunk views: content mismatch")
+	}
+	if unsorted[0] != chunkViews[2] {
+		t.Errorf("the given chunk views are reordered")
+	}
+
+}
+
+func TestReaderAtOverlappingChunkViews(t *testing.T) {
+
+	server, chunkViews, content := newTestSequentialFile(3, 1024)
+	defer server.Close()
+
+	// the third chunk view starts inside the second one
+	chunkViews[2].LogicOffset -= 100
+
+	_, err := NewStrictChunkReaderAt(server.lookupFn, chunkViews, newMapChunkCache(), int64(len(content)))
+	if !errors.Is(err, ErrOverlappingChunkViews) {
+		t.Fatalf("strict reader of overlapping chunk views: %v, expect ErrOverlappingChunkViews", err)
+	}
+
+	// without the strict check, the earlier chunk view wins the overlapping part
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, newMapChunkCache(), int64(len(content))-100)
+	data := make([]byte, len(content)-100)
+	if n, err := readerAt.ReadAt(data, 0); n != len(data) || err != nil {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(data[:2048], content[:2048]) || !bytes.Equal(data[2048:], content[2048+100:]) {
+		t.Errorf("read overlapping chunk views: content mismatch")
+	}
+
+	// empty chunk views cover nothing
+	if _, err := checkChunkViews([]*ChunkView{{FileId: "1,01", Size: 1024}, {FileId: "1,02", LogicOffset: 512}, {FileId: "1,03", Size: 10, LogicOffset: 1024}}); err != nil {
+		t.Errorf("check chunk views with an empty one: %v", err)
+	}
+
+}
+
+func TestReaderAtChunkFileIds(t *testing.T) {
+
+	readerAt := NewChunkReaderAtFromClient(nil, []*ChunkView{
+		{FileId: "3,0301", Size: 1024, ChunkSize: 1024, LogicOffset: 0},
+		{FileId: "5,0502", Size: 1024, ChunkSize: 1024, LogicOffset: 1024},
+		{FileId: "3,0303", Size: 1024, ChunkSize: 1024, LogicOffset: 2048},
+	}, newMapChunkCache(), 3072)
+
+	fileIds := readerAt.ChunkFileIds()
+	expected := []string{"3,0301", "5,0502", "3,0303"}
+	if len(fileIds) != len(expected) {
+		t.Fatalf("chunk file ids %v, expect %v", fileIds, expected)
+	}
+	for i := range expected {
+		if fileIds[i] != expected[i] {
+			t.Errorf("chunk file ids %v, expect %v", fileIds, expected)
+			break
+		}
+	}
+
+	if fileIds := NewChunkReaderAtFromClient(nil, nil, newMapChunkCache(), 0).ChunkFileIds(); len(fileIds) != 0 {
+		t.Errorf("chunk file ids of an empty file %v", fileIds)
+	}
+
+}
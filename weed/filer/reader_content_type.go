@@ -0,0 +1,35 @@
+package filer
+
+import (
+	"net/http"
+)
+
+// the most bytes http.DetectContentType looks at
+const sniffLen = 512
+
+// DetectContentType sniffs the content type of the file from its leading bytes, for files stored without one.
+// Only the head of the first chunk is fetched, or the whole first chunk if it is encrypted or compressed.
+func (c *ChunkReadAt) DetectContentType() (string, error) {
+
+	head := make([]byte, min(c.fileSize, sniffLen))
+	for _, chunkView := range c.chunkViews {
+		if chunkView.Size == 0 {
+			continue
+		}
+		if chunkView.LogicOffset >= int64(len(head)) {
+			// the head of the file is a hole
+			break
+		}
+		n := int(min(int64(chunkView.Size), int64(len(head))-chunkView.LogicOffset))
+		data, err := c.PeekChunkHeader(chunkView, int(chunkView.Offset)+n)
+		if err != nil {
+			return "", err
+		}
+		if len(data) > int(chunkView.Offset) {
+			copy(head[chunkView.LogicOffset:], data[chunkView.Offset:])
+		}
+		break
+	}
+
+	return http.DetectContentType(head), nil
+}
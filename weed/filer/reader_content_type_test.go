@@ -0,0 +1,75 @@
+package filer
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestDetectContentType(t *testing.T) {
+
+	pngData := append([]byte("\x89PNG\x0D\x0A\x1A\x0A"), randomBytes(64*1024)...)
+	pdfData := append([]byte("%PDF-1.7\n"), bytes.Repeat([]byte("x"), 64*1024)...)
+	gifData := append([]byte("GIF89a"), randomBytes(1024)...)
+	htmlData := []byte("<!DOCTYPE html><html><body>hello</body></html>")
+
+	gzipped, err := util.GzipData(gifData)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	cipherKey := util.GenCipherKey()
+	encrypted, err := util.Encrypt(pdfData, cipherKey)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	chunks := map[string][]byte{
+		"1,01": pngData,
+		"1,02": encrypted,
+		"1,03": gzipped,
+		"1,04": htmlData,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fileId := strings.TrimPrefix(r.URL.Path, "/")
+		if fileId == "1,03" {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(chunks[fileId])
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(chunks[fileId]))
+	}))
+	defer server.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+
+	tests := []struct {
+		name      string
+		chunkView *ChunkView
+		fileSize  int64
+		expected  string
+	}{
+		{"png", &ChunkView{FileId: "1,01", Size: uint64(len(pngData)), ChunkSize: uint64(len(pngData))}, int64(len(pngData)), "image/png"},
+		{"encrypted pdf", &ChunkView{FileId: "1,02", Size: uint64(len(pdfData)), ChunkSize: uint64(len(pdfData)), CipherKey: cipherKey}, int64(len(pdfData)), "application/pdf"},
+		{"gzipped gif", &ChunkView{FileId: "1,03", Size: uint64(len(gifData)), ChunkSize: uint64(len(gifData)), IsGzipped: true}, int64(len(gifData)), "image/gif"},
+		{"small html", &ChunkView{FileId: "1,04", Size: uint64(len(htmlData)), ChunkSize: uint64(len(htmlData))}, int64(len(htmlData)), "text/html; charset=utf-8"},
+		{"hole", &ChunkView{FileId: "1,01", Size: 1024, ChunkSize: uint64(len(pngData)), LogicOffset: 4096}, 5120, "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{tt.chunkView}, newMapChunkCache(), tt.fileSize)
+		contentType, err := readerAt.DetectContentType()
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if contentType != tt.expected {
+			t.Errorf("%s: detected %q, expect %q", tt.name, contentType, tt.expected)
+		}
+	}
+
+}
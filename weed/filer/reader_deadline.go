@@ -0,0 +1,66 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ReadDeadlineError reports a read running out of its total budget, with the chunks before read already.
+// It matches context.DeadlineExceeded, for the serving layers to handle like their own deadlines.
+type ReadDeadlineError struct {
+	Budget  time.Duration
+	Elapsed time.Duration
+	FileId  string // the first chunk not read
+}
+
+func (e *ReadDeadlineError) Error() string {
+	return fmt.Sprintf("read budget %v exhausted after %v, before chunk %s", e.Budget, e.Elapsed, e.FileId)
+}
+
+func (e *ReadDeadlineError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+type readDeadlineKey struct{}
+
+type readDeadline struct {
+	start    time.Time
+	deadline time.Time
+}
+
+// SetReadBudget bounds each ReadAtWithContext call, over all the chunks it fetches. Once the budget is spent,
+// the remaining chunks fail with a ReadDeadlineError instead of each being fetched in turn, and the bytes
+// read so far are returned. A fetch in flight is not interrupted. 0, the default, leaves the reads unbounded.
+func (c *ChunkReadAt) SetReadBudget(budget time.Duration) {
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+	if budget < 0 {
+		budget = 0
+	}
+	c.readBudget = budget
+}
+
+// withReadDeadline starts the budget of one read
+func (c *ChunkReadAt) withReadDeadline(ctx context.Context) context.Context {
+	if c.readBudget <= 0 {
+		return ctx
+	}
+	start := c.now()
+	return context.WithValue(ctx, readDeadlineKey{}, &readDeadline{start: start, deadline: start.Add(c.readBudget)})
+}
+
+// checkReadDeadline fails fast before fetching the chunk, once the read budget or the context is done
+func (c *ChunkReadAt) checkReadDeadline(ctx context.Context, chunkView *ChunkView) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d, ok := ctx.Value(readDeadlineKey{}).(*readDeadline)
+	if !ok {
+		return nil
+	}
+	if now := c.now(); !now.Before(d.deadline) {
+		return &ReadDeadlineError{Budget: d.deadline.Sub(d.start), Elapsed: now.Sub(d.start), FileId: chunkView.FileId}
+	}
+	return nil
+}
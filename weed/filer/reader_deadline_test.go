@@ -0,0 +1,54 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestReaderAtReadBudget(t *testing.T) {
+
+	const chunkSize = 1024
+	server, chunkViews, content := newTestSequentialFile(3, chunkSize)
+	defer server.Close()
+
+	// the first chunk takes longer than the whole read budget
+	clock := util.NewTestClock(time.Unix(1600000000, 0))
+	var slowFetch sync.Once
+	lookupFn := func(fileId string) ([]string, error) {
+		if fileId == chunkViews[0].FileId {
+			slowFetch.Do(func() {
+				clock.Advance(2 * time.Second)
+			})
+		}
+		return server.lookupFn(fileId)
+	}
+	readerAt := NewChunkReaderAtFromClient(lookupFn, chunkViews, newMapChunkCache(), int64(len(content)))
+	readerAt.SetClock(clock)
+	readerAt.SetReadBudget(time.Second)
+
+	buf := make([]byte, len(content))
+	n, err := readerAt.ReadAtWithContext(context.Background(), buf, 0)
+	var deadlineErr *ReadDeadlineError
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &deadlineErr) {
+		t.Fatalf("read over budget: %v, expect a ReadDeadlineError", err)
+	}
+	if deadlineErr.FileId != chunkViews[1].FileId || deadlineErr.Elapsed != 2*time.Second {
+		t.Errorf("deadline error %v, expect the second chunk after 2s", deadlineErr)
+	}
+	if n != chunkSize || !bytes.Equal(buf[:n], content[:chunkSize]) {
+		t.Errorf("read over budget returned %d bytes, expect the first chunk", n)
+	}
+
+	// the next read gets a fresh budget
+	n, err = readerAt.ReadAtWithContext(context.Background(), buf, 0)
+	if n != len(content) || !bytes.Equal(buf, content) {
+		t.Errorf("read within budget: n=%d err=%v", n, err)
+	}
+
+}
@@ -0,0 +1,76 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// edgeTemplate rewrites the replica urls into the urls of an edge cache, e.g. a CDN, in front of the volume servers.
+type edgeTemplate struct {
+	template string
+}
+
+// SetEdgeUrlTemplate fetches the chunks through an edge cache first, e.g. "https://cdn.example.com{path}",
+// where {path} is the path of the chunk on the volume server, "/3,01637037d6", and {host} the volume server
+// address as in the replica urls. The replicas sharing an edge url are tried once. The chunks the edge fails
+// to serve, e.g. missing or erroring, are fetched from the volume servers as usual.
+// An empty template disables the edge.
+func (c *ChunkReadAt) SetEdgeUrlTemplate(template string) error {
+	if template == "" {
+		c.edgeTemplate = nil
+		return nil
+	}
+	if !strings.Contains(template, "{path}") {
+		return fmt.Errorf("edge url template %q without {path}", template)
+	}
+	if _, err := url.Parse(strings.NewReplacer("{path}", "/", "{host}", "localhost").Replace(template)); err != nil {
+		return fmt.Errorf("edge url template %q: %v", template, err)
+	}
+	c.edgeTemplate = &edgeTemplate{
+		template: template,
+	}
+	return nil
+}
+
+// edgeUrls returns the distinct edge urls of the replica urls
+func (t *edgeTemplate) edgeUrls(urlStrings []string) (edgeUrls []string) {
+	seen := make(map[string]bool)
+	for _, urlString := range urlStrings {
+		u, err := url.Parse(urlString)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		edgeUrl := strings.NewReplacer("{host}", u.Host, "{path}", u.EscapedPath()).Replace(t.template)
+		if !seen[edgeUrl] {
+			seen[edgeUrl] = true
+			edgeUrls = append(edgeUrls, edgeUrl)
+		}
+	}
+	return
+}
+
+// fetchEdgeChunk returns the chunk, or the range of it, if the edge serves it.
+func (c *ChunkReadAt) fetchEdgeChunk(ctx context.Context, chunkView *ChunkView, isFullChunk bool, offset int64, size int) ([]byte, bool) {
+	if c.edgeTemplate == nil {
+		return nil, false
+	}
+	urlStrings, err := c.lookupFileId(chunkView.FileId)
+	if err != nil {
+		return nil, false
+	}
+	for _, edgeUrl := range c.edgeTemplate.edgeUrls(urlStrings) {
+		var data []byte
+		_, err := readUrlAsStream(ctx, readDeletedUrl(edgeUrl), chunkView.CipherKey, chunkView.IsGzipped, isFullChunk, offset, size, func(received []byte) {
+			data = append(data, received...)
+		})
+		if err == nil {
+			return data, true
+		}
+		glog.V(1).Infof("read %s from the edge %s: %v", chunkView.FileId, edgeUrl, err)
+	}
+	return nil, false
+}
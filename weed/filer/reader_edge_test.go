@@ -0,0 +1,46 @@
+package filer
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReaderAtEdgeUrlTemplate(t *testing.T) {
+
+	origin, chunkViews, content := newTestSequentialFile(3, 1024)
+	defer origin.Close()
+	// the edge has yet to cache the last chunk
+	edgeChunks := make(map[string][]byte)
+	for _, chunkView := range chunkViews[:2] {
+		edgeChunks[chunkView.FileId] = origin.chunks[chunkView.FileId]
+	}
+	edge := newTestVolumeServer(edgeChunks)
+	defer edge.Close()
+
+	readerAt := NewChunkReaderAtFromClient(origin.lookupFn, chunkViews, newMapChunkCache(), int64(len(content)))
+	if err := readerAt.SetEdgeUrlTemplate("http://edge.example.com"); err == nil {
+		t.Errorf("expect an error for a template without {path}")
+	}
+	if err := readerAt.SetEdgeUrlTemplate(edge.URL + "{path}"); err != nil {
+		t.Fatalf("set edge url template: %v", err)
+	}
+
+	// the read of the first chunk prefetches the second one, both on the edge
+	buf := make([]byte, 1024)
+	if n, err := readerAt.ReadAt(buf, 0); n != len(buf) || err != nil || !bytes.Equal(buf, content[:1024]) {
+		t.Fatalf("read through the edge: n=%d err=%v", n, err)
+	}
+	if requests := atomic.LoadInt32(&origin.requests); requests != 0 {
+		t.Errorf("%d requests to the origin for chunks on the edge", requests)
+	}
+
+	buf = make([]byte, len(content))
+	if n, err := readerAt.ReadAt(buf, 0); n != len(buf) || err != nil || !bytes.Equal(buf, content) {
+		t.Fatalf("read with an edge miss: n=%d err=%v", n, err)
+	}
+	if requests := atomic.LoadInt32(&origin.requests); requests == 0 {
+		t.Errorf("the chunk missing on the edge is not fetched from the origin")
+	}
+
+}
@@ -0,0 +1,21 @@
+package filer
+
+// EOFPolicy tells whether a read ending exactly at the end of the file returns io.EOF along with its bytes.
+// Reads cut short by the end of the file, or starting at or beyond it, return io.EOF either way.
+type EOFPolicy int
+
+const (
+	// EOFOnShortRead returns io.EOF only with fewer bytes than asked for, like os.File. The default,
+	// for callers treating any error with a full read as a failure.
+	EOFOnShortRead EOFPolicy = iota
+	// EOFAtEnd also returns io.EOF with a full read up to the end of the file, as io.ReaderAt allows,
+	// sparing the callers reading to the end the extra read that returns nothing.
+	EOFAtEnd
+)
+
+// SetEOFPolicy sets when the reads reaching the end of the file return io.EOF.
+func (c *ChunkReadAt) SetEOFPolicy(policy EOFPolicy) {
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+	c.eofPolicy = policy
+}
@@ -0,0 +1,42 @@
+package filer
+
+import (
+	"io"
+	"testing"
+)
+
+func TestReaderAtEOFPolicy(t *testing.T) {
+
+	server, chunkViews, content := newTestSequentialFile(2, 1024)
+	defer server.Close()
+	fileSize := int64(len(content))
+
+	for _, tc := range []struct {
+		policy   EOFPolicy
+		offset   int64
+		size     int
+		expected int
+		err      error
+	}{
+		{EOFOnShortRead, fileSize - 100, 100, 100, nil},
+		{EOFAtEnd, fileSize - 100, 100, 100, io.EOF},
+		{EOFOnShortRead, 0, int(fileSize), int(fileSize), nil},
+		{EOFAtEnd, 0, int(fileSize), int(fileSize), io.EOF},
+		// short reads, and reads from the end, end with io.EOF in both
+		{EOFOnShortRead, fileSize - 100, 200, 100, io.EOF},
+		{EOFAtEnd, fileSize - 100, 200, 100, io.EOF},
+		{EOFOnShortRead, fileSize, 100, 0, io.EOF},
+		{EOFAtEnd, fileSize, 100, 0, io.EOF},
+		// reads before the end never do
+		{EOFOnShortRead, fileSize - 200, 100, 100, nil},
+		{EOFAtEnd, fileSize - 200, 100, 100, nil},
+	} {
+		readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, newMapChunkCache(), fileSize)
+		readerAt.SetEOFPolicy(tc.policy)
+		n, err := readerAt.ReadAt(make([]byte, tc.size), tc.offset)
+		if n != tc.expected || err != tc.err {
+			t.Errorf("policy %d, read [%d,%d): %d %v, expect %d %v", tc.policy, tc.offset, tc.offset+int64(tc.size), n, err, tc.expected, tc.err)
+		}
+	}
+
+}
@@ -0,0 +1,116 @@
+package filer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// ErrChunkFetch is matched by errors.Is for any failure to fetch or decode chunk data while reading,
+// so that callers can tell it apart from io.EOF.
+var ErrChunkFetch = errors.New("chunk fetch failed")
+
+// The causes of a ChunkFetchError, also matched by errors.Is.
+var (
+	ErrVolumeLookup   = errors.New("volume lookup failed")
+	ErrReplicasFailed = errors.New("all replicas failed")
+	ErrDecryption     = util.ErrDecryption
+	ErrAuthentication = util.ErrAuthentication // an ErrDecryption of content tampered with
+	ErrDecompression  = util.ErrDecompression
+	ErrTruncatedChunk = errors.New("truncated chunk")
+)
+
+// ChunkFetchError tells the chunk view a read failed on, if known from its Size, and where the failed fetch started.
+type ChunkFetchError struct {
+	FileId      string
+	LogicOffset int64  // the file offset of the chunk view
+	Size        uint64 // the size of the chunk view, 0 if the view is not known
+	Offset      int64  // where the failed fetch started in the chunk
+	Err         error
+}
+
+func newChunkFetchError(chunkView *ChunkView, offset int64, err error) *ChunkFetchError {
+	return &ChunkFetchError{
+		FileId:      chunkView.FileId,
+		LogicOffset: chunkView.LogicOffset,
+		Size:        chunkView.Size,
+		Offset:      offset,
+		Err:         err,
+	}
+}
+
+func (e *ChunkFetchError) Error() string {
+	if e.Size == 0 {
+		return fmt.Sprintf("fetch chunk %s: %v", e.FileId, e.Err)
+	}
+	return fmt.Sprintf("fetch chunk %s at %d of the view [%d,%d): %v", e.FileId, e.Offset, e.LogicOffset, e.LogicOffset+int64(e.Size), e.Err)
+}
+
+func (e *ChunkFetchError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ChunkFetchError) Is(target error) bool {
+	return target == ErrChunkFetch
+}
+
+// LookupError is a failure to find the volume servers of a chunk.
+type LookupError struct {
+	FileId string
+	Err    error
+}
+
+func (e *LookupError) Error() string {
+	return fmt.Sprintf("lookup volume %s: %v", VolumeId(e.FileId), e.Err)
+}
+
+func (e *LookupError) Unwrap() error {
+	return e.Err
+}
+
+func (e *LookupError) Is(target error) bool {
+	return target == ErrVolumeLookup
+}
+
+func lookupError(fileId string, err error) error {
+	var lookupErr *LookupError
+	if errors.As(err, &lookupErr) {
+		return err
+	}
+	return &LookupError{FileId: fileId, Err: err}
+}
+
+// ReplicasFailedError is a failure to read a chunk from its replicas, with the error of the last one tried.
+// A replica answering that it has no such chunk is not retried on the others.
+type ReplicasFailedError struct {
+	Urls []string
+	Err  error
+}
+
+func (e *ReplicasFailedError) Error() string {
+	return fmt.Sprintf("read from %d replicas: %v", len(e.Urls), e.Err)
+}
+
+func (e *ReplicasFailedError) Unwrap() error {
+	return e.Err
+}
+
+func (e *ReplicasFailedError) Is(target error) bool {
+	return target == ErrReplicasFailed
+}
+
+// TruncatedChunkError is a chunk read shorter than its chunk views need.
+type TruncatedChunkError struct {
+	Size     int64 // the bytes read
+	Expected int64
+	Offset   int64 // where the read starts in the chunk
+}
+
+func (e *TruncatedChunkError) Error() string {
+	return fmt.Sprintf("short read %d of %d bytes at %d", e.Size, e.Expected, e.Offset)
+}
+
+func (e *TruncatedChunkError) Is(target error) bool {
+	return target == ErrTruncatedChunk
+}
@@ -0,0 +1,184 @@
+package filer
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestReadErrorTypes(t *testing.T) {
+
+	cipherKey := util.GenCipherKey()
+	encryptedGarbage, err := util.Encrypt([]byte{31, 139, 1, 2, 3}, cipherKey)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		lookupErr error
+		cipherKey []byte
+		isGzipped bool
+		expect    error
+	}{
+		{
+			name:      "lookup",
+			lookupErr: errors.New("volume 7 not found"),
+			expect:    ErrVolumeLookup,
+		},
+		{
+			name: "replicas",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			expect: ErrReplicasFailed,
+		},
+		{
+			name: "decryption",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write(randomBytes(1024))
+			},
+			cipherKey: cipherKey,
+			expect:    ErrDecryption,
+		},
+		{
+			name: "decompression",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Write([]byte("not gzipped"))
+			},
+			expect: ErrDecompression,
+		},
+		{
+			name: "encrypted decompression",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write(encryptedGarbage)
+			},
+			cipherKey: cipherKey,
+			isGzipped: true,
+			expect:    ErrDecompression,
+		},
+		{
+			name: "truncated",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write(randomBytes(100))
+			},
+			expect: ErrTruncatedChunk,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+			lookupFn := func(fileId string) ([]string, error) {
+				if tt.lookupErr != nil {
+					return nil, tt.lookupErr
+				}
+				return []string{server.URL + "/" + fileId}, nil
+			}
+			readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+				{FileId: "7,0e01", Size: 1024, ChunkSize: 1024, CipherKey: tt.cipherKey, IsGzipped: tt.isGzipped},
+			}, newMapChunkCache(), 1024)
+
+			_, err := readerAt.ReadAt(make([]byte, 1024), 0)
+			if !errors.Is(err, tt.expect) {
+				t.Fatalf("read error %v, expect %v", err, tt.expect)
+			}
+			if !errors.Is(err, ErrChunkFetch) {
+				t.Errorf("read error %v, expect a chunk fetch error", err)
+			}
+			for _, other := range []error{ErrVolumeLookup, ErrReplicasFailed, ErrDecryption, ErrDecompression, ErrTruncatedChunk} {
+				if other != tt.expect && errors.Is(err, other) {
+					t.Errorf("read error %v also matches %v", err, other)
+				}
+			}
+		})
+	}
+
+	var lookupErr *LookupError
+	_, err = fetchChunk(func(fileId string) ([]string, error) {
+		return nil, errors.New("no filer")
+	}, "7,0e02", nil, false)
+	if !errors.As(err, &lookupErr) || lookupErr.FileId != "7,0e02" {
+		t.Errorf("fetch error %v, expect a lookup error of 7,0e02", err)
+	}
+
+}
+
+func TestReaderAtErrorTellsTheChunkView(t *testing.T) {
+
+	server := newTestVolumeServer(map[string][]byte{
+		"7,0f01": randomBytes(1024),
+		"7,0f03": randomBytes(1024),
+	})
+	defer server.Close()
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{
+		{FileId: "7,0f01", Size: 1024, ChunkSize: 1024, LogicOffset: 0},
+		{FileId: "7,0f02", Offset: 100, Size: 900, ChunkSize: 1000, LogicOffset: 1024},
+		{FileId: "7,0f03", Size: 1024, ChunkSize: 1024, LogicOffset: 1924},
+	}, newMapChunkCache(), 2948)
+
+	// the read reaches the missing chunk 476 bytes into its view, past the 100 bytes skipped in the chunk
+	n, err := readerAt.ReadAt(make([]byte, 1000), 1500)
+	var fetchErr *ChunkFetchError
+	if !errors.As(err, &fetchErr) {
+		t.Fatalf("read error %v, expect a chunk fetch error", err)
+	}
+	if n != 0 || fetchErr.FileId != "7,0f02" || fetchErr.LogicOffset != 1024 || fetchErr.Size != 900 || fetchErr.Offset != 576 {
+		t.Errorf("read %d bytes, error %+v, expect the view of 7,0f02 at chunk offset 576", n, fetchErr)
+	}
+
+}
+
+func TestTamperedReplicaFailsOver(t *testing.T) {
+
+	cipherKey := util.GenCipherKey()
+	data := randomBytes(1024)
+	encrypted, err := util.Encrypt(data, cipherKey)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	tampered := append([]byte(nil), encrypted...)
+	tampered[len(tampered)/2] ^= 0x01
+
+	intactServer := newTestVolumeServer(map[string][]byte{"7,1001": encrypted})
+	defer intactServer.Close()
+	tamperedServer := newTestVolumeServer(map[string][]byte{"7,1001": tampered})
+	defer tamperedServer.Close()
+
+	read := func(servers ...*testVolumeServer) ([]byte, error) {
+		lookupFn := func(fileId string) (urls []string, err error) {
+			for _, server := range servers {
+				urls = append(urls, server.URL+"/"+fileId)
+			}
+			return urls, nil
+		}
+		readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+			{FileId: "7,1001", Size: 1024, ChunkSize: 1024, CipherKey: cipherKey},
+		}, newMapChunkCache(), 1024)
+		buf := make([]byte, 1024)
+		n, err := readerAt.ReadAt(buf, 0)
+		return buf[:n], err
+	}
+
+	if got, err := read(tamperedServer, intactServer); err != nil || !bytes.Equal(got, data) {
+		t.Errorf("read from the intact replica: %v", err)
+	}
+
+	// with every replica tampered with, the read fails at once, without retrying them
+	tamperedServer.requests = 0
+	_, err = read(tamperedServer, tamperedServer)
+	if !errors.Is(err, ErrAuthentication) || !errors.Is(err, ErrDecryption) || !errors.Is(err, ErrChunkFetch) {
+		t.Errorf("read error %v, expect an authentication failure", err)
+	}
+	if tamperedServer.requests != 2 {
+		t.Errorf("%d requests to the tampered replicas, expect 2", tamperedServer.requests)
+	}
+
+}
@@ -0,0 +1,33 @@
+package filer
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+)
+
+// ETag fingerprints the chunk composition of the file, so that conditional requests,
+// e.g. with If-None-Match, can be answered without reading the data.
+// Chunks are never changed in place, so the same chunk views at the same offsets mean the same content.
+func (c *ChunkReadAt) ETag() string {
+
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+
+	h := md5.New()
+	buf := make([]byte, 8)
+	writeInt := func(x int64) {
+		binary.BigEndian.PutUint64(buf, uint64(x))
+		h.Write(buf)
+	}
+	writeInt(c.fileSize)
+	for _, chunkView := range c.chunkViews {
+		writeInt(chunkView.LogicOffset)
+		writeInt(int64(chunkView.Size))
+		writeInt(chunkView.Offset)
+		writeInt(int64(len(chunkView.FileId)))
+		h.Write([]byte(chunkView.FileId))
+	}
+
+	return fmt.Sprintf("%x-%d", h.Sum(nil), len(c.chunkViews))
+}
@@ -0,0 +1,42 @@
+package filer
+
+import (
+	"testing"
+)
+
+func TestReaderAtETag(t *testing.T) {
+
+	newViews := func() []*ChunkView {
+		return []*ChunkView{
+			{FileId: "1,5a01", Offset: 0, Size: 1000, ChunkSize: 1000, LogicOffset: 0},
+			{FileId: "1,5a02", Offset: 24, Size: 1000, ChunkSize: 2000, LogicOffset: 1000},
+		}
+	}
+	etag := func(views []*ChunkView, fileSize int64) string {
+		return NewChunkReaderAtFromClient(nil, views, newMapChunkCache(), fileSize).ETag()
+	}
+
+	base := etag(newViews(), 2000)
+	if same := etag(newViews(), 2000); same != base {
+		t.Errorf("identical chunks give different etags %s %s", base, same)
+	}
+
+	changes := map[string]func(views []*ChunkView) ([]*ChunkView, int64){
+		"file id":      func(views []*ChunkView) ([]*ChunkView, int64) { views[1].FileId = "1,5a03"; return views, 2000 },
+		"size":         func(views []*ChunkView) ([]*ChunkView, int64) { views[1].Size = 999; return views, 2000 },
+		"chunk offset": func(views []*ChunkView) ([]*ChunkView, int64) { views[1].Offset = 25; return views, 2000 },
+		"file offset":  func(views []*ChunkView) ([]*ChunkView, int64) { views[1].LogicOffset = 1001; return views, 2000 },
+		"file size":    func(views []*ChunkView) ([]*ChunkView, int64) { return views, 3000 },
+		"fewer chunks": func(views []*ChunkView) ([]*ChunkView, int64) { return views[:1], 2000 },
+		"swapped ids": func(views []*ChunkView) ([]*ChunkView, int64) {
+			views[0].FileId, views[1].FileId = views[1].FileId, views[0].FileId
+			return views, 2000
+		},
+	}
+	for name, change := range changes {
+		if changed := etag(change(newViews())); changed == base {
+			t.Errorf("%s change keeps the etag %s", name, base)
+		}
+	}
+
+}
@@ -0,0 +1,96 @@
+package filer
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+type ReadEventType int
+
+const (
+	ReadEventFetchStarted ReadEventType = iota
+	ReadEventFetchCompleted
+	ReadEventFetchFailed
+	ReadEventCacheHit
+	ReadEventCacheMiss
+	ReadEventFailover // a replica failed to serve the chunk, and the fetch moves on to the next one
+)
+
+func (t ReadEventType) String() string {
+	switch t {
+	case ReadEventFetchStarted:
+		return "fetch started"
+	case ReadEventFetchCompleted:
+		return "fetch completed"
+	case ReadEventFetchFailed:
+		return "fetch failed"
+	case ReadEventCacheHit:
+		return "cache hit"
+	case ReadEventCacheMiss:
+		return "cache miss"
+	case ReadEventFailover:
+		return "failover"
+	}
+	return "unknown"
+}
+
+// ReadEvent is one step of reading a chunk, for tracing reads or building dashboards.
+type ReadEvent struct {
+	Type     ReadEventType
+	Time     time.Time
+	FileId   string
+	Offset   int64         // the offset in the chunk
+	Size     int64         // the bytes requested, or fetched by a completed fetch
+	Duration time.Duration // of a completed or failed fetch
+	Server   string        // the replica failed over from
+	Err      error
+}
+
+type readEventSink struct {
+	dropped int64 // first for the 64 bit alignment of atomic operations on 32 bit platforms
+	events  chan<- ReadEvent
+	now     func() time.Time
+}
+
+// SetEventSink sends the read events to the channel without ever blocking the reads:
+// the events the channel has no room for are dropped, see DroppedEvents. A nil channel stops the events.
+// Cache misses are only reported for whole chunks looked up in the chunk cache.
+func (c *ChunkReadAt) SetEventSink(events chan<- ReadEvent) {
+	if events == nil {
+		c.eventSink = nil
+		return
+	}
+	c.eventSink = &readEventSink{events: events, now: c.now}
+}
+
+// DroppedEvents returns the number of events dropped since the event sink was set.
+func (c *ChunkReadAt) DroppedEvents() int64 {
+	if c.eventSink == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&c.eventSink.dropped)
+}
+
+func (s *readEventSink) emit(event ReadEvent) {
+	if s == nil {
+		return
+	}
+	event.Time = s.now()
+	select {
+	case s.events <- event:
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+	}
+}
+
+// emitFetch reports the end of a fetch started at the given time
+func (s *readEventSink) emitFetch(fileId string, offset int64, start time.Time, data []byte, err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.emit(ReadEvent{Type: ReadEventFetchFailed, FileId: fileId, Offset: offset, Duration: s.now().Sub(start), Err: err})
+		return
+	}
+	s.emit(ReadEvent{Type: ReadEventFetchCompleted, FileId: fileId, Offset: offset, Size: int64(len(data)), Duration: s.now().Sub(start)})
+}
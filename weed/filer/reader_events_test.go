@@ -0,0 +1,84 @@
+package filer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestReadEvents(t *testing.T) {
+
+	data := randomBytes(4096)
+	healthy := newTestVolumeServer(map[string][]byte{"1,3a01": data})
+	defer healthy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{failing.URL + "/" + fileId, healthy.URL + "/" + fileId}, nil
+	}
+
+	chunkCache := newMapChunkCache()
+	newReader := func(events chan ReadEvent) *ChunkReadAt {
+		readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+			{FileId: "1,3a01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+		}, chunkCache, 8192)
+		readerAt.SetEventSink(events)
+		return readerAt
+	}
+	receive := func(events chan ReadEvent) (types []ReadEventType, received []ReadEvent) {
+		for {
+			select {
+			case event := <-events:
+				types = append(types, event.Type)
+				received = append(received, event)
+			default:
+				return
+			}
+		}
+	}
+
+	events := make(chan ReadEvent, 16)
+	if _, err := newReader(events).ReadAt(make([]byte, 100), 0); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	types, received := receive(events)
+	expected := []ReadEventType{ReadEventCacheMiss, ReadEventFetchStarted, ReadEventFailover, ReadEventFetchCompleted}
+	if len(types) != len(expected) {
+		t.Fatalf("events %v, expect %v", types, expected)
+	}
+	for i := range expected {
+		if types[i] != expected[i] || received[i].FileId != "1,3a01" || received[i].Time.IsZero() {
+			t.Fatalf("events %v, expect %v of 1,3a01", types, expected)
+		}
+	}
+	failingHost, _ := url.Parse(failing.URL)
+	if failover := received[2]; failover.Server != failingHost.Host || failover.Err == nil {
+		t.Errorf("failover from %s: %v, expect from %s", failover.Server, failover.Err, failingHost.Host)
+	}
+	if completed := received[3]; completed.Size != 4096 {
+		t.Errorf("fetch completed with %d bytes, expect 4096", completed.Size)
+	}
+
+	// the chunk is cached by the first read
+	if _, err := newReader(events).ReadAt(make([]byte, 100), 0); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if types, _ := receive(events); len(types) != 1 || types[0] != ReadEventCacheHit {
+		t.Errorf("events %v of a cached chunk, expect a cache hit", types)
+	}
+
+	// a full event channel drops the events instead of blocking the read
+	full := make(chan ReadEvent)
+	readerAt := newReader(full)
+	chunkCache.chunks = make(map[string][]byte)
+	if _, err := readerAt.ReadAt(make([]byte, 100), 0); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if dropped := readerAt.DroppedEvents(); dropped != 4 {
+		t.Errorf("dropped %d events, expect 4", dropped)
+	}
+
+}
@@ -0,0 +1,126 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
+)
+
+// FederatedLookup finds the volume servers of file ids through several filers, for files with chunks
+// in the volumes of different clusters. It remembers which filer resolved each volume.
+type FederatedLookup struct {
+	members      []*VolumeLookup
+	opts         *LookupOptions
+	backoff      *util.Backoff
+	resolvedBy   map[string]int
+	resolvedLock sync.RWMutex
+}
+
+func FederatedLookupFn(filerClients []filer_pb.FilerClient, opts *LookupOptions) wdclient.LookupFileIdFunctionType {
+	return NewFederatedLookup(filerClients, opts).LookupFileId
+}
+
+// NewFederatedLookup asks the filers in order, or starting with the one picked by the volume id if opts.ShardByVolumeId.
+func NewFederatedLookup(filerClients []filer_pb.FilerClient, opts *LookupOptions) *FederatedLookup {
+	if opts == nil {
+		opts = &LookupOptions{}
+	}
+	fl := &FederatedLookup{
+		opts:       opts,
+		resolvedBy: make(map[string]int),
+	}
+	for _, filerClient := range filerClients {
+		fl.members = append(fl.members, NewVolumeLookup(filerClient, opts))
+	}
+	fl.backoff = opts.Backoff
+	if fl.backoff == nil {
+		fl.backoff = util.NewBackoff(time.Second, 10*time.Second)
+		if opts.Clock != nil {
+			fl.backoff.Clock = opts.Clock
+		}
+	}
+	return fl
+}
+
+func (fl *FederatedLookup) LookupFileId(fileId string) (targetUrls []string, err error) {
+
+	vid := VolumeId(fileId)
+	if member, found := fl.resolver(vid); found {
+		if locations, found := member.cachedLocations(vid); found {
+			return member.targetUrls(fileId, locations)
+		}
+	}
+
+	var member *VolumeLookup
+	err = util.RetryWithBackoff("lookup volume "+vid, fl.backoff, func() (resolveErr error) {
+		member, resolveErr = fl.resolve(vid)
+		return
+	})
+	if err != nil {
+		return nil, &LookupError{FileId: fileId, Err: err}
+	}
+	locations, _ := member.cachedLocations(vid)
+	return member.targetUrls(fileId, locations)
+}
+
+// ResolvedBy returns the index of the filer that resolved the volume, if any did.
+func (fl *FederatedLookup) ResolvedBy(vid string) (index int, found bool) {
+	fl.resolvedLock.RLock()
+	defer fl.resolvedLock.RUnlock()
+	index, found = fl.resolvedBy[vid]
+	return
+}
+
+func (fl *FederatedLookup) resolver(vid string) (*VolumeLookup, bool) {
+	if index, found := fl.ResolvedBy(vid); found {
+		return fl.members[index], true
+	}
+	return nil, false
+}
+
+// resolve asks each filer once, and fails with the last unreachable filer's error, if any, for the backoff to retry
+func (fl *FederatedLookup) resolve(vid string) (*VolumeLookup, error) {
+	var lastErr error
+	for _, index := range fl.lookupOrder(vid) {
+		member := fl.members[index]
+		missing, err := member.lookupVolumes(context.Background(), []string{vid})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(missing) == 0 {
+			fl.resolvedLock.Lock()
+			fl.resolvedBy[vid] = index
+			fl.resolvedLock.Unlock()
+			return member, nil
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	glog.V(0).Infof("failed to locate volume %s on %d filers", vid, len(fl.members))
+	return nil, fmt.Errorf("failed to locate volume %s on %d filers", vid, len(fl.members))
+}
+
+// lookupOrder starts with the filer that resolved the volume before, e.g. if its cached locations expired
+func (fl *FederatedLookup) lookupOrder(vid string) (order []int) {
+	first := 0
+	if index, found := fl.ResolvedBy(vid); found {
+		first = index
+	} else if fl.opts.ShardByVolumeId && len(fl.members) > 0 {
+		if id, err := strconv.ParseUint(vid, 10, 32); err == nil {
+			first = int(id % uint64(len(fl.members)))
+		}
+	}
+	for i := range fl.members {
+		order = append(order, (first+i)%len(fl.members))
+	}
+	return
+}
@@ -0,0 +1,83 @@
+package filer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestFederatedLookupReadAcrossFilers(t *testing.T) {
+
+	dataA, dataB := randomBytes(1024), randomBytes(1024)
+	serverA := newTestVolumeServer(map[string][]byte{"61,01": dataA})
+	defer serverA.Close()
+	serverB := newTestVolumeServer(map[string][]byte{"62,01": dataB})
+	defer serverB.Close()
+
+	filerA := &fakeFilerClient{locations: map[string][]string{"61": {strings.TrimPrefix(serverA.URL, "http://")}}}
+	filerB := &fakeFilerClient{locations: map[string][]string{"62": {strings.TrimPrefix(serverB.URL, "http://")}}}
+	federatedLookup := NewFederatedLookup([]filer_pb.FilerClient{filerA, filerB}, nil)
+
+	readerAt := NewChunkReaderAtFromClient(federatedLookup.LookupFileId, []*ChunkView{
+		{FileId: "61,01", Size: 1024, ChunkSize: 1024, LogicOffset: 0},
+		{FileId: "62,01", Size: 1024, ChunkSize: 1024, LogicOffset: 1024},
+	}, newMapChunkCache(), 2048)
+
+	// the second chunk first, as a prefetch of it would look up its volume concurrently
+	if n, err := readerAt.ReadAt(make([]byte, 1024), 1024); n != 1024 || err != nil {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+	for i := 0; i < 2; i++ {
+		buf := make([]byte, 2048)
+		if n, err := readerAt.ReadAt(buf, 0); n != 2048 || err != nil {
+			t.Fatalf("read: n=%d err=%v", n, err)
+		}
+		if !bytes.Equal(buf[:1024], dataA) || !bytes.Equal(buf[1024:], dataB) {
+			t.Fatalf("read data differs from the chunks on both clusters")
+		}
+	}
+
+	// the first filer is asked for both volumes, the second one only for its own, and the filers resolving them are cached
+	if lookups := fmt.Sprint(filerA.lookupVolumeIds); lookups != "[[62] [61]]" {
+		t.Errorf("first filer looked up %s", lookups)
+	}
+	if lookups := fmt.Sprint(filerB.lookupVolumeIds); lookups != "[[62]]" {
+		t.Errorf("second filer looked up %s", lookups)
+	}
+	if index, found := federatedLookup.ResolvedBy("62"); !found || index != 1 {
+		t.Errorf("volume 62 resolved by %d %v, expect the second filer", index, found)
+	}
+
+	if _, err := federatedLookup.LookupFileId("63,01"); err == nil {
+		t.Errorf("expect an error for a volume on no filer")
+	}
+
+}
+
+func TestFederatedLookupShardByVolumeId(t *testing.T) {
+
+	filers := []*fakeFilerClient{
+		{locations: map[string][]string{"70": {"server0:8080"}}},
+		{locations: map[string][]string{"71": {"server1:8080"}}},
+	}
+	federatedLookup := NewFederatedLookup([]filer_pb.FilerClient{filers[0], filers[1]}, &LookupOptions{ShardByVolumeId: true})
+
+	for _, fileId := range []string{"70,01", "71,01"} {
+		urls, err := federatedLookup.LookupFileId(fileId)
+		if err != nil {
+			t.Fatalf("lookup %s: %v", fileId, err)
+		}
+		if expected := fmt.Sprintf("http://server%s:8080/%s", fileId[1:2], fileId); len(urls) != 1 || urls[0] != expected {
+			t.Errorf("lookup %s: %v, expect %s", fileId, urls, expected)
+		}
+	}
+	for i, filer := range filers {
+		if filer.lookupRequests != 1 {
+			t.Errorf("filer %d looked up %v, expect only its own volume", i, filer.lookupVolumeIds)
+		}
+	}
+
+}
@@ -0,0 +1,86 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FetchTimeoutError reports a chunk fetch, retries included, not done within the timeout for its size and priority.
+// It matches context.DeadlineExceeded.
+type FetchTimeoutError struct {
+	FileId   string
+	Size     int64 // the bytes to fetch
+	Timeout  time.Duration
+	Priority ReadPriority
+}
+
+func (e *FetchTimeoutError) Error() string {
+	return fmt.Sprintf("fetch %d bytes of chunk %s: not done within %v", e.Size, e.FileId, e.Timeout)
+}
+
+func (e *FetchTimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+type fetchTimeout struct {
+	base              time.Duration
+	minBytesPerSecond int64
+}
+
+// SetFetchTimeout bounds each chunk fetch, retries included, by the base timeout plus the time to transfer
+// the bytes at minBytesPerSecond, scaled by the priority of the reader. Interactive reads wait longer on a slow
+// server, while background ones give up early to free the fetch slots for others:
+//
+//	priority                  timeout to fetch n bytes
+//	ReadPriorityInteractive   (base + n/minBytesPerSecond) * 2
+//	ReadPriorityBackground    (base + n/minBytesPerSecond) / 2
+//
+// e.g. a 4MB chunk at a base of 1s and 1MB/s gets 10s when read interactively, and 2.5s in the background.
+// A minBytesPerSecond of 0 leaves the timeout to the base alone. A base of 0, the default, leaves the fetches unbounded.
+// The hedged fetches have their own delays, and are not bounded.
+func (c *ChunkReadAt) SetFetchTimeout(base time.Duration, minBytesPerSecond int64) {
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+	if base < 0 {
+		base = 0
+	}
+	if minBytesPerSecond < 0 {
+		minBytesPerSecond = 0
+	}
+	c.fetchTimeout = fetchTimeout{base: base, minBytesPerSecond: minBytesPerSecond}
+}
+
+// timeout returns the fetch timeout of size bytes at the priority, 0 if unbounded
+func (t fetchTimeout) timeout(size int64, priority ReadPriority) time.Duration {
+	if t.base <= 0 {
+		return 0
+	}
+	timeout := t.base
+	if t.minBytesPerSecond > 0 {
+		timeout += time.Duration(float64(size) / float64(t.minBytesPerSecond) * float64(time.Second))
+	}
+	if priority == ReadPriorityBackground {
+		return timeout / 2
+	}
+	return timeout * 2
+}
+
+// withFetchTimeout bounds the fetch of size bytes, and returns the timeout, 0 if unbounded
+func (c *ChunkReadAt) withFetchTimeout(ctx context.Context, size int64) (context.Context, context.CancelFunc, time.Duration) {
+	timeout := c.fetchTimeout.timeout(size, c.priority)
+	if timeout <= 0 {
+		return ctx, func() {}, 0
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, timeout)
+	return fetchCtx, cancel, timeout
+}
+
+// fetchTimedOut reports the failure of a fetch bounded by withFetchTimeout as a FetchTimeoutError, once its timeout passed
+func (c *ChunkReadAt) fetchTimedOut(ctx context.Context, err error, fileId string, size int64, timeout time.Duration) error {
+	if err == nil || timeout <= 0 || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return &FetchTimeoutError{FileId: fileId, Size: size, Timeout: timeout, Priority: c.priority}
+}
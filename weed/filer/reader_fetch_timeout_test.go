@@ -0,0 +1,65 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReaderAtFetchTimeoutByPriority(t *testing.T) {
+
+	data := randomBytes(1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.Write(data)
+	}))
+	defer server.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+
+	read := func(fileId string, priority ReadPriority) (time.Duration, error) {
+		readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+			{FileId: fileId, Size: 1024, ChunkSize: 1024},
+		}, newMapChunkCache(), 1024)
+		readerAt.SetPriority(priority)
+		// 400ms for interactive reads, 100ms for background ones
+		readerAt.SetFetchTimeout(200*time.Millisecond, 0)
+		buf := make([]byte, 1024)
+		start := time.Now()
+		_, err := readerAt.ReadAt(buf, 0)
+		if err == nil && !bytes.Equal(buf, data) {
+			t.Errorf("read data differs")
+		}
+		return time.Since(start), err
+	}
+
+	elapsed, err := read("1,7a01", ReadPriorityBackground)
+	var timeoutErr *FetchTimeoutError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("background read error %v, expect a fetch timeout", err)
+	}
+	if timeoutErr.Timeout != 100*time.Millisecond || timeoutErr.Priority != ReadPriorityBackground || timeoutErr.FileId != "1,7a01" {
+		t.Errorf("unexpected timeout %+v", timeoutErr)
+	}
+	if elapsed >= 300*time.Millisecond {
+		t.Errorf("background read gave up after %v, expect before the server answers", elapsed)
+	}
+
+	if _, err := read("1,7a02", ReadPriorityInteractive); err != nil {
+		t.Errorf("interactive read: %v, expect it to wait for the slow server", err)
+	}
+
+	timeouts := fetchTimeout{base: time.Second, minBytesPerSecond: 1024 * 1024}
+	if timeout := timeouts.timeout(4*1024*1024, ReadPriorityInteractive); timeout != 10*time.Second {
+		t.Errorf("interactive timeout %v, expect 10s", timeout)
+	}
+	if timeout := timeouts.timeout(4*1024*1024, ReadPriorityBackground); timeout != 2500*time.Millisecond {
+		t.Errorf("background timeout %v, expect 2.5s", timeout)
+	}
+
+}
@@ -0,0 +1,82 @@
+package filer
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+// ErrVolumeFrozen is wrapped by the LookupError of a volume with only frozen replicas, with the FrozenVolumesFail policy.
+var ErrVolumeFrozen = errors.New("volume frozen")
+
+// FrozenVolumePolicy tells how to read the replicas of the volumes marked read only, e.g. during maintenance.
+// Frozen replicas still serve reads, but may be slower, or about to go away.
+type FrozenVolumePolicy int
+
+const (
+	// read the frozen replicas as any other
+	FrozenVolumesRead FrozenVolumePolicy = iota
+	// try the frozen replicas only after the others
+	FrozenVolumesLast
+	// never read the frozen replicas, failing fast with ErrVolumeFrozen if all replicas of a volume are frozen
+	FrozenVolumesFail
+)
+
+// IsFrozenFunc tells whether the replica of the volume at the location is read only.
+type IsFrozenFunc func(vid string, location *filer_pb.Location) bool
+
+// placeFrozen orders or drops the frozen replicas by the FrozenVolumes policy, keeping the order of the others.
+func (vl *VolumeLookup) placeFrozen(vid string, locs []*filer_pb.Location) ([]*filer_pb.Location, error) {
+	if vl.opts.IsFrozen == nil || vl.opts.FrozenVolumes == FrozenVolumesRead {
+		return locs, nil
+	}
+	var thawed, frozen []*filer_pb.Location
+	for _, loc := range locs {
+		if vl.opts.IsFrozen(vid, loc) {
+			frozen = append(frozen, loc)
+		} else {
+			thawed = append(thawed, loc)
+		}
+	}
+	if vl.opts.FrozenVolumes == FrozenVolumesLast {
+		return append(thawed, frozen...), nil
+	}
+	if len(thawed) == 0 && len(frozen) > 0 {
+		return nil, ErrVolumeFrozen
+	}
+	return thawed, nil
+}
+
+// FrozenReplicas keeps the read only replicas by volume id and volume server url, for LookupOptions.IsFrozen.
+type FrozenReplicas struct {
+	sync.RWMutex
+	urls map[string]map[string]bool
+}
+
+func NewFrozenReplicas() *FrozenReplicas {
+	return &FrozenReplicas{urls: make(map[string]map[string]bool)}
+}
+
+// SetFrozen marks the replica of the volume on the volume server as read only, or as writable again.
+func (f *FrozenReplicas) SetFrozen(vid string, url string, frozen bool) {
+	f.Lock()
+	defer f.Unlock()
+	if !frozen {
+		delete(f.urls[vid], url)
+		if len(f.urls[vid]) == 0 {
+			delete(f.urls, vid)
+		}
+		return
+	}
+	if f.urls[vid] == nil {
+		f.urls[vid] = make(map[string]bool)
+	}
+	f.urls[vid][url] = true
+}
+
+func (f *FrozenReplicas) IsFrozen(vid string, location *filer_pb.Location) bool {
+	f.RLock()
+	defer f.RUnlock()
+	return f.urls[vid][location.Url]
+}
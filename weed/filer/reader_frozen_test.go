@@ -0,0 +1,82 @@
+package filer
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestFrozenVolumesAreDeprioritized(t *testing.T) {
+
+	filerClient := &fakeFilerClient{
+		locations: map[string][]string{
+			"31": {"server1:8080", "server2:8080", "server3:8080"},
+			"32": {"server1:8080", "server2:8080"},
+		},
+	}
+	frozen := NewFrozenReplicas()
+	frozen.SetFrozen("31", "server1:8080", true)
+	frozen.SetFrozen("31", "server3:8080", true)
+	frozen.SetFrozen("32", "server1:8080", true)
+	frozen.SetFrozen("32", "server2:8080", true)
+
+	for i := int64(0); i < 20; i++ {
+		volumeLookup := NewVolumeLookup(filerClient, &LookupOptions{
+			Rand:          rand.New(rand.NewSource(i)),
+			IsFrozen:      frozen.IsFrozen,
+			FrozenVolumes: FrozenVolumesLast,
+		})
+		urls, err := volumeLookup.LookupFileId("31,01")
+		if err != nil {
+			t.Fatalf("lookup: %v", err)
+		}
+		if len(urls) != 3 || urls[0] != "http://server2:8080/31,01" {
+			t.Errorf("seed %d: located at %v, expect the writable replica first", i, urls)
+		}
+		// all frozen replicas are still read
+		if urls, err := volumeLookup.LookupFileId("32,01"); err != nil || len(urls) != 2 {
+			t.Errorf("seed %d: frozen volume located at %v, %v", i, urls, err)
+		}
+	}
+
+	// without the option, the frozen replicas come first as often as not
+	first := make(map[string]int)
+	for i := int64(0); i < 20; i++ {
+		urls, _ := NewVolumeLookup(filerClient, &LookupOptions{Rand: rand.New(rand.NewSource(i)), IsFrozen: frozen.IsFrozen}).LookupFileId("31,01")
+		first[urls[0]]++
+	}
+	if first["http://server2:8080/31,01"] == 20 {
+		t.Errorf("the writable replica is always first without the option")
+	}
+
+}
+
+func TestFrozenVolumesFailFast(t *testing.T) {
+
+	filerClient := &fakeFilerClient{
+		locations: map[string][]string{
+			"41": {"server1:8080", "server2:8080"},
+			"42": {"server1:8080"},
+		},
+	}
+	frozen := NewFrozenReplicas()
+	frozen.SetFrozen("41", "server1:8080", true)
+	frozen.SetFrozen("42", "server1:8080", true)
+
+	volumeLookup := NewVolumeLookup(filerClient, &LookupOptions{IsFrozen: frozen.IsFrozen, FrozenVolumes: FrozenVolumesFail})
+	if urls, err := volumeLookup.LookupFileId("41,01"); err != nil || fmt.Sprint(urls) != "[http://server2:8080/41,01]" {
+		t.Errorf("located at %v, %v, expect only the writable replica", urls, err)
+	}
+	_, err := volumeLookup.LookupFileId("42,01")
+	if !errors.Is(err, ErrVolumeFrozen) || !errors.Is(err, ErrVolumeLookup) {
+		t.Errorf("expected a frozen volume lookup error, got %v", err)
+	}
+
+	// thawed after the maintenance
+	frozen.SetFrozen("42", "server1:8080", false)
+	if urls, err := volumeLookup.LookupFileId("42,01"); err != nil || len(urls) != 1 {
+		t.Errorf("thawed volume located at %v, %v", urls, err)
+	}
+
+}
@@ -0,0 +1,90 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNothingToProbe is the health of a reader without any chunk, e.g. of an empty file.
+var ErrNothingToProbe = errors.New("no chunk to probe")
+
+// healthProbeSize is the size read from the probed chunk, enough to go through the volume server's read path
+const healthProbeSize = 512
+
+// HealthReport is the outcome of a Healthcheck.
+type HealthReport struct {
+	FileId  string        // the probed chunk
+	Url     string        // the replica that answered, or was tried last
+	Latency time.Duration // from the lookup to the end of the read
+	Err     error
+}
+
+func (r HealthReport) Healthy() bool {
+	return r.Err == nil
+}
+
+func (r HealthReport) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("unhealthy after %v: %v", r.Latency, r.Err)
+	}
+	return fmt.Sprintf("healthy, read %s from %s in %v", r.FileId, r.Url, r.Latency)
+}
+
+// Healthcheck reads the start of the first chunk from the volume servers, bypassing the chunk cache,
+// to tell liveness probes whether the lookup and read path works. Each replica is tried once, till one answers,
+// and a probe is cheap enough to run often. The rest of the file is not checked, see Verify for that.
+func (c *ChunkReadAt) Healthcheck(ctx context.Context) (report HealthReport) {
+
+	c.readerLock.Lock()
+	var chunkView *ChunkView
+	for _, view := range c.chunkViews {
+		if view.Size > 0 {
+			chunkView = view
+			break
+		}
+	}
+	lookupFileId := c.lookupFileId
+	c.readerLock.Unlock()
+
+	if chunkView == nil || lookupFileId == nil {
+		report.Err = ErrNothingToProbe
+		return
+	}
+	report.FileId = chunkView.FileId
+
+	start := c.now()
+	defer func() {
+		report.Latency = c.now().Sub(start)
+	}()
+
+	urlStrings, err := lookupFileId(chunkView.FileId)
+	if err != nil {
+		report.Err = lookupError(chunkView.FileId, err)
+		return
+	}
+	size := int(minUint64(chunkView.Size, healthProbeSize))
+	for _, urlString := range urlStrings {
+		report.Url = urlString
+		if strings.Contains(urlString, "%") {
+			urlString = url.PathEscape(urlString)
+		}
+		received := 0
+		_, err = readUrlAsStream(ctx, readDeletedUrl(urlString), chunkView.CipherKey, chunkView.IsGzipped, false, chunkView.Offset, size, func(data []byte) {
+			received += len(data)
+		})
+		if err == nil && received == 0 {
+			err = fmt.Errorf("read nothing of %d bytes", size)
+		}
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if err != nil {
+		report.Err = &ChunkFetchError{FileId: chunkView.FileId, Err: &ReplicasFailedError{Urls: urlStrings, Err: err}}
+	}
+	return
+}
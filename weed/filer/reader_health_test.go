@@ -0,0 +1,38 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReaderAtHealthcheck(t *testing.T) {
+
+	server, chunkViews, content := newTestSequentialFile(2, 4096)
+	cache := newMapChunkCache()
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, cache, int64(len(content)))
+
+	// a cached chunk is probed all the same
+	cache.SetChunk(chunkViews[0].FileId, content[:4096])
+	report := readerAt.Healthcheck(context.Background())
+	if !report.Healthy() || report.FileId != chunkViews[0].FileId {
+		t.Errorf("working server: %v", report)
+	}
+	if requests := atomic.LoadInt32(&server.rangeRequests); requests != 1 {
+		t.Errorf("%d range requests, expect 1", requests)
+	}
+
+	server.Close()
+	report = readerAt.Healthcheck(context.Background())
+	var fetchErr *ChunkFetchError
+	if report.Healthy() || !errors.As(report.Err, &fetchErr) {
+		t.Errorf("server down: %v, expect a ChunkFetchError", report)
+	}
+
+	empty := NewChunkReaderAtFromClient(server.lookupFn, nil, cache, 0)
+	if report := empty.Healthcheck(context.Background()); !errors.Is(report.Err, ErrNothingToProbe) {
+		t.Errorf("empty file: %v", report)
+	}
+
+}
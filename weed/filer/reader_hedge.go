@@ -0,0 +1,130 @@
+package filer
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
+)
+
+// ReadHedger cuts the tail latency of slow volume servers. If the first replica has not answered
+// a whole chunk fetch after the delay, the next replica is asked too, the first complete response wins,
+// and the other request is cancelled. The hedged requests in flight are bounded across all readers sharing the hedger.
+type ReadHedger struct {
+	delay  time.Duration
+	tokens chan struct{}
+}
+
+func NewReadHedger(delay time.Duration, maxInFlight int) *ReadHedger {
+	return &ReadHedger{
+		delay:  delay,
+		tokens: make(chan struct{}, maxInFlight),
+	}
+}
+
+// SetReadHedger hedges the whole chunk fetches of this reader. A nil hedger disables hedging.
+func (c *ChunkReadAt) SetReadHedger(readHedger *ReadHedger) {
+	c.readHedger = readHedger
+}
+
+type hedgedResult struct {
+	urlString string
+	data      []byte
+	err       error
+}
+
+// fetchChunk fetches the whole chunk within ctx, checking its size like the other whole chunk fetches.
+// onAttempt, if not nil, is called with the outcome of each request to a replica, except the one cancelled after the other won.
+func (h *ReadHedger) fetchChunk(ctx context.Context, lookupFileIdFn wdclient.LookupFileIdFunctionType, fileId string, cipherKey []byte, isGzipped bool, chunkSize uint64, onAttempt func(urlString string, err error)) ([]byte, error) {
+
+	urlStrings, err := lookupFileIdFn(fileId)
+	if err != nil {
+		readErrorLog.Logf("lookup "+VolumeId(fileId), "operation LookupFileId %s failed, err: %v", fileId, err)
+		return nil, lookupError(fileId, err)
+	}
+	if len(urlStrings) < 2 {
+		return retriedFetchChunkDataWithAttempts(ctx, urlStrings, cipherKey, isGzipped, true, 0, int(chunkSize), onAttempt)
+	}
+
+	hedgeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgedResult, 2)
+	fetch := func(urlString string) {
+		data, err := fetchWholeChunkWithContext(hedgeCtx, urlString, cipherKey, isGzipped)
+		if err == nil {
+			data, err = checkReceivedSize(urlString, data, int(chunkSize), 0)
+		}
+		results <- hedgedResult{urlString, data, err}
+	}
+	failed := func(r hedgedResult) {
+		if ctx.Err() != nil {
+			// cancelled with the read, not a failure of the replica
+			return
+		}
+		if onAttempt != nil {
+			onAttempt(r.urlString, r.err)
+		}
+		readFailureLog.Logf("read "+replicaServer(r.urlString), "read %s failed, err: %v", r.urlString, r.err)
+	}
+	succeeded := func(r hedgedResult) ([]byte, error) {
+		if onAttempt != nil {
+			onAttempt(r.urlString, nil)
+		}
+		return r.data, nil
+	}
+
+	go fetch(urlStrings[0])
+	inFlight := 1
+
+	timer := time.NewTimer(h.delay)
+	defer timer.Stop()
+	select {
+	case r := <-results:
+		if r.err == nil {
+			return succeeded(r)
+		}
+		failed(r)
+		inFlight--
+	case <-timer.C:
+		select {
+		case h.tokens <- struct{}{}:
+			go func() {
+				defer func() {
+					<-h.tokens
+				}()
+				fetch(urlStrings[1])
+			}()
+			inFlight++
+		default:
+			// too many hedged requests already, keep waiting for the first replica
+		}
+	}
+
+	for ; inFlight > 0; inFlight-- {
+		r := <-results
+		if r.err == nil {
+			return succeeded(r)
+		}
+		failed(r)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// no luck with the quick attempts, go through all replicas with retries
+	return retriedFetchChunkDataWithAttempts(ctx, urlStrings, cipherKey, isGzipped, true, 0, int(chunkSize), onAttempt)
+}
+
+func fetchWholeChunkWithContext(ctx context.Context, urlString string, cipherKey []byte, isGzipped bool) ([]byte, error) {
+	if strings.Contains(urlString, "%") {
+		urlString = url.PathEscape(urlString)
+	}
+	var data []byte
+	_, err := readUrlAsStream(ctx, readDeletedUrl(urlString), cipherKey, isGzipped, true, 0, 0, func(received []byte) {
+		data = append(data, received...)
+	})
+	return data, err
+}
@@ -0,0 +1,136 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestReadHedgerFastReplicaWins(t *testing.T) {
+
+	fastData, slowData := randomBytes(4096), randomBytes(4096)
+	cancelled := make(chan struct{}, 1)
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			cancelled <- struct{}{}
+		case <-time.After(5 * time.Second):
+			w.Write(slowData)
+		}
+	}))
+	defer slow.Close()
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(fastData)
+	}))
+	defer fast.Close()
+
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{slow.URL + "/" + fileId, fast.URL + "/" + fileId}, nil
+	}
+	readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+		{FileId: "1,0f01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+	}, newMapChunkCache(), 4096)
+	readerAt.SetReadHedger(NewReadHedger(20*time.Millisecond, 1))
+
+	start := time.Now()
+	buf := make([]byte, 4096)
+	if n, err := readerAt.ReadAt(buf, 0); n != 4096 || (err != nil && err != io.EOF) {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(buf, fastData) {
+		t.Errorf("expect the data of the fast replica")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("hedged read took %v", elapsed)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Errorf("the request to the slow replica was not cancelled")
+	}
+
+}
+
+func TestReadHedgerChecksTheChunkSize(t *testing.T) {
+
+	data := randomBytes(4096)
+	truncated := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data[:1024])
+	}))
+	defer truncated.Close()
+	healthy := newTestVolumeServer(map[string][]byte{"1,0f02": data})
+	defer healthy.Close()
+
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{truncated.URL + "/" + fileId, healthy.URL + "/" + fileId}, nil
+	}
+	readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+		{FileId: "1,0f02", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+	}, newMapChunkCache(), 4096)
+	readerAt.SetReadHedger(NewReadHedger(time.Second, 1))
+	events := make(chan ReadEvent, 16)
+	readerAt.SetEventSink(events)
+
+	buf := make([]byte, 4096)
+	if n, err := readerAt.ReadAt(buf, 0); n != 4096 || (err != nil && err != io.EOF) {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(buf, data) {
+		t.Errorf("expect the data of the healthy replica")
+	}
+
+	truncatedHost, _ := url.Parse(truncated.URL)
+	var failovers int
+	for len(events) > 0 {
+		event := <-events
+		if event.Type != ReadEventFailover {
+			continue
+		}
+		failovers++
+		var truncatedErr *TruncatedChunkError
+		if event.Server != truncatedHost.Host || !errors.As(event.Err, &truncatedErr) {
+			t.Errorf("failover from %s: %v, expect truncated from %s", event.Server, event.Err, truncatedHost.Host)
+		}
+	}
+	if failovers == 0 {
+		t.Errorf("the truncated replica is not reported")
+	}
+
+}
+
+func TestReadHedgerCancelledWithTheRead(t *testing.T) {
+
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hung.Close()
+
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{hung.URL + "/" + fileId, hung.URL + "/" + fileId}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var attempts int
+	start := time.Now()
+	_, err := NewReadHedger(10*time.Millisecond, 1).fetchChunk(ctx, lookupFn, "1,0f03", nil, false, 4096, func(urlString string, err error) {
+		attempts++
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("hedged fetch past the deadline: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("hedged fetch took %v past the deadline", elapsed)
+	}
+	if attempts != 0 {
+		t.Errorf("%d replicas reported failing with the read cancelled", attempts)
+	}
+
+}
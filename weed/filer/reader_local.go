@@ -0,0 +1,85 @@
+package filer
+
+import (
+	"fmt"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// LocalChunkReader reads chunks straight from the volume files of the volume server on this host.
+type LocalChunkReader interface {
+	// ReadChunk returns the needle data of the chunk as stored, and whether the needle is compressed.
+	ReadChunk(fileId string) (data []byte, isCompressed bool, err error)
+}
+
+type localShortcut struct {
+	address string // the volume server address as in the replica urls, e.g. "localhost:8080"
+	reader  LocalChunkReader
+}
+
+// SetLocalChunkReader makes the reader read the chunks with a replica on the volume server at the address
+// through the local chunk reader instead of over http. Chunks with no local replica, or failing to read locally,
+// are read over http as usual. A nil reader disables the shortcut.
+func (c *ChunkReadAt) SetLocalChunkReader(address string, reader LocalChunkReader) {
+	if reader == nil {
+		c.localShortcut = nil
+		return
+	}
+	c.localShortcut = &localShortcut{
+		address: address,
+		reader:  reader,
+	}
+}
+
+// readChunk returns the whole chunk if it has a replica on the local volume server, and reads fine from it.
+func (s *localShortcut) readChunk(urlStrings []string, fileId string, cipherKey []byte, isGzipped bool) ([]byte, bool) {
+	isLocal := false
+	for _, urlString := range urlStrings {
+		if replicaServer(urlString) == s.address {
+			isLocal = true
+			break
+		}
+	}
+	if !isLocal {
+		return nil, false
+	}
+
+	data, isCompressed, err := s.reader.ReadChunk(fileId)
+	if err == nil {
+		data, err = decodeChunkData(data, isCompressed, cipherKey, isGzipped)
+	}
+	if err != nil {
+		glog.V(1).Infof("read %s locally: %v", fileId, err)
+		return nil, false
+	}
+	return data, true
+}
+
+// decodeChunkData decodes the needle data of a chunk, the same as reading it from a volume server
+func decodeChunkData(data []byte, isCompressed bool, cipherKey []byte, isGzipped bool) ([]byte, error) {
+	var err error
+	if cipherKey != nil {
+		if data, err = util.Decrypt(data, util.CipherKey(cipherKey)); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDecryption, err)
+		}
+		isCompressed = isGzipped && util.IsGzippedContent(data)
+	}
+	if isCompressed {
+		if data, err = util.DecompressData(data); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrDecompression, err)
+		}
+	}
+	return data, nil
+}
+
+func (c *ChunkReadAt) fetchLocalChunk(chunkView *ChunkView) ([]byte, bool) {
+	if c.localShortcut == nil {
+		return nil, false
+	}
+	urlStrings, err := c.lookupFileId(chunkView.FileId)
+	if err != nil {
+		return nil, false
+	}
+	return c.localShortcut.readChunk(urlStrings, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped)
+}
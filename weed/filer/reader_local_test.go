@@ -0,0 +1,82 @@
+package filer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// fakeLocalChunkReader serves the chunks of a volume server on this host
+type fakeLocalChunkReader struct {
+	chunks map[string][]byte
+	sync.Mutex
+	reads []string
+}
+
+func (r *fakeLocalChunkReader) ReadChunk(fileId string) ([]byte, bool, error) {
+	r.Lock()
+	defer r.Unlock()
+	r.reads = append(r.reads, fileId)
+	data, found := r.chunks[fileId]
+	if !found {
+		return nil, false, errors.New("not found")
+	}
+	if util.IsGzippedContent(data) {
+		return data, true, nil
+	}
+	return data, false, nil
+}
+
+func TestReaderAtReadsLocalReplica(t *testing.T) {
+
+	localData, remoteData, lostData := randomBytes(4096), randomBytes(4096), randomBytes(4096)
+	gzipped, _ := util.GzipData(localData)
+	remote := newTestVolumeServer(map[string][]byte{
+		"1,0a01": localData,
+		"2,0b01": remoteData,
+		"1,0a02": lostData,
+	})
+	defer remote.Close()
+
+	localAddress := "localhost:18080"
+	lookupFn := func(fileId string) ([]string, error) {
+		if VolumeId(fileId) == "1" {
+			return []string{remote.URL + "/" + fileId, "http://" + localAddress + "/" + fileId}, nil
+		}
+		return []string{remote.URL + "/" + fileId}, nil
+	}
+	localReader := &fakeLocalChunkReader{chunks: map[string][]byte{
+		"1,0a01": gzipped,
+	}}
+
+	readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+		{FileId: "1,0a01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+		{FileId: "2,0b01", Size: 4096, ChunkSize: 4096, LogicOffset: 4096},
+		{FileId: "1,0a02", Size: 4096, ChunkSize: 4096, LogicOffset: 8192},
+	}, newMapChunkCache(), 3*4096)
+	readerAt.SetLocalChunkReader(localAddress, localReader)
+
+	buf := make([]byte, 3*4096)
+	if n, err := readerAt.ReadAt(buf, 0); n != len(buf) || (err != nil && err != io.EOF) {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(buf[:4096], localData) || !bytes.Equal(buf[4096:8192], remoteData) || !bytes.Equal(buf[8192:], lostData) {
+		t.Errorf("read data differs")
+	}
+
+	// the local replica is read locally, the remote chunk and the lost local one over http
+	localReader.Lock()
+	defer localReader.Unlock()
+	if reads := localReader.reads; len(reads) != 2 || reads[0] != "1,0a01" || reads[1] != "1,0a02" {
+		t.Errorf("local reads %v, expect [1,0a01 1,0a02]", reads)
+	}
+	if requests := atomic.LoadInt32(&remote.requests); requests != 2 {
+		t.Errorf("%d http requests, expect 2", requests)
+	}
+
+}
@@ -0,0 +1,95 @@
+package filer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// DefaultReadErrorLogInterval is how often repeated read errors of the same volume or server are logged.
+const DefaultReadErrorLogInterval = time.Minute
+
+var (
+	readErrorLog   = NewLogThrottle(DefaultReadErrorLogInterval, glog.Errorf)
+	readFailureLog = NewLogThrottle(DefaultReadErrorLogInterval, glog.V(0).Infof)
+)
+
+// SetReadErrorLogInterval sets how often repeated read errors of the same volume or server are logged.
+// 0 logs every error.
+func SetReadErrorLogInterval(interval time.Duration) {
+	readErrorLog.SetInterval(interval)
+	readFailureLog.SetInterval(interval)
+}
+
+// LogThrottle keeps an outage from flooding the log. The first message of a key is logged,
+// and the repeated ones within the interval are only counted, to be summarized after the interval.
+type LogThrottle struct {
+	sync.Mutex
+	interval  time.Duration
+	output    func(format string, args ...interface{})
+	entries   map[string]*throttledLog
+	lastSweep time.Time
+}
+
+type throttledLog struct {
+	loggedAt   time.Time
+	suppressed int
+	format     string // the last suppressed message
+	args       []interface{}
+}
+
+func NewLogThrottle(interval time.Duration, output func(format string, args ...interface{})) *LogThrottle {
+	return &LogThrottle{
+		interval: interval,
+		output:   output,
+		entries:  make(map[string]*throttledLog),
+	}
+}
+
+func (t *LogThrottle) SetInterval(interval time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+	t.interval = interval
+}
+
+// Logf logs the message unless one of the same key was logged within the interval.
+func (t *LogThrottle) Logf(key string, format string, args ...interface{}) {
+
+	t.Lock()
+	defer t.Unlock()
+
+	if t.interval <= 0 {
+		t.output(format, args...)
+		return
+	}
+
+	now := time.Now()
+	t.sweep(now)
+
+	if entry, found := t.entries[key]; found {
+		entry.suppressed++
+		entry.format, entry.args = format, args
+		return
+	}
+	t.output(format, args...)
+	t.entries[key] = &throttledLog{loggedAt: now}
+}
+
+// sweep summarizes and forgets the keys logged more than the interval ago, once per interval.
+func (t *LogThrottle) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < t.interval {
+		return
+	}
+	t.lastSweep = now
+	for key, entry := range t.entries {
+		if now.Sub(entry.loggedAt) < t.interval {
+			continue
+		}
+		if entry.suppressed > 0 {
+			t.output("%s (%d similar in the last %v)", fmt.Sprintf(entry.format, entry.args...), entry.suppressed, now.Sub(entry.loggedAt).Round(time.Millisecond))
+		}
+		delete(t.entries, key)
+	}
+}
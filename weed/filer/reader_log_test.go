@@ -0,0 +1,41 @@
+package filer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogThrottle(t *testing.T) {
+
+	var lines []string
+	throttle := NewLogThrottle(50*time.Millisecond, func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	})
+
+	for i := 0; i < 1000; i++ {
+		throttle.Logf("read server1:8080", "read http://server1:8080/3,%x failed", i)
+	}
+	throttle.Logf("read server2:8080", "read http://server2:8080/3,01 failed")
+	if len(lines) != 2 {
+		t.Fatalf("expect one line per key, got %d: %v", len(lines), lines)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	throttle.Logf("read server1:8080", "read http://server1:8080/3,ffff failed")
+	if len(lines) != 4 {
+		t.Fatalf("expect a summary and a new line, got %d: %v", len(lines), lines)
+	}
+	if !strings.Contains(lines[2], "read http://server1:8080/3,3e7 failed (999 similar in the last") {
+		t.Errorf("unexpected summary %q", lines[2])
+	}
+
+	throttle.SetInterval(0)
+	throttle.Logf("read server1:8080", "read http://server1:8080/3,01 failed")
+	throttle.Logf("read server1:8080", "read http://server1:8080/3,01 failed")
+	if len(lines) != 6 {
+		t.Errorf("expect every line without throttling, got %d", len(lines))
+	}
+
+}
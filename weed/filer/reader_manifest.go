@@ -0,0 +1,91 @@
+package filer
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
+	"github.com/golang/protobuf/proto"
+)
+
+// manifests are nested only when a file has more than ManifestBatch^depth chunks
+const maxManifestDepth = 8
+
+// NewChunkReaderAtFromEntry creates a reader of the entry, whose chunks may be manifest chunks, nested or not.
+// Unlike ViewFromChunks, failing to resolve a manifest is an error instead of a file with holes.
+// The fetched manifests are kept in the chunk cache, so reopening the file does not fetch them again.
+func NewChunkReaderAtFromEntry(lookupFn wdclient.LookupFileIdFunctionType, entry *filer_pb.Entry, chunkCache chunk_cache.ChunkCache) (*ChunkReadAt, error) {
+	return newChunkReaderAtFromChunks(lookupFn, entry.Chunks, int64(FileSize(entry)), chunkCache)
+}
+
+// NewChunkReaderAtFromFilerEntry is NewChunkReaderAtFromEntry for the entries of the filer store and the meta cache.
+// The small files kept in the entry content have no chunks to read, and fail.
+func NewChunkReaderAtFromFilerEntry(lookupFn wdclient.LookupFileIdFunctionType, entry *Entry, chunkCache chunk_cache.ChunkCache) (*ChunkReadAt, error) {
+	if len(entry.Content) > 0 {
+		return nil, fmt.Errorf("%s is stored in the entry content, not in chunks", entry.FullPath)
+	}
+	return newChunkReaderAtFromChunks(lookupFn, entry.Chunks, int64(maxUint64(TotalSize(entry.Chunks), entry.FileSize)), chunkCache)
+}
+
+func newChunkReaderAtFromChunks(lookupFn wdclient.LookupFileIdFunctionType, chunks []*filer_pb.FileChunk, fileSize int64, chunkCache chunk_cache.ChunkCache) (*ChunkReadAt, error) {
+
+	dataChunks, err := resolveManifestChunksWithCache(lookupFn, chunkCache, chunks, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkViews := ViewFromVisibleIntervals(readResolvedChunks(dataChunks), 0, math.MaxInt64)
+	return NewChunkReaderAtFromClient(lookupFn, chunkViews, chunkCache, fileSize), nil
+}
+
+func resolveManifestChunksWithCache(lookupFn wdclient.LookupFileIdFunctionType, chunkCache chunk_cache.ChunkCache, chunks []*filer_pb.FileChunk, depth int) (dataChunks []*filer_pb.FileChunk, err error) {
+
+	for _, chunk := range chunks {
+		if !chunk.IsChunkManifest {
+			dataChunks = append(dataChunks, chunk)
+			continue
+		}
+		if depth >= maxManifestDepth {
+			return nil, fmt.Errorf("manifest %s nested deeper than %d", chunk.GetFileIdString(), maxManifestDepth)
+		}
+
+		resolvedChunks, err := fetchManifestWithCache(lookupFn, chunkCache, chunk)
+		if err != nil {
+			return nil, err
+		}
+		subChunks, err := resolveManifestChunksWithCache(lookupFn, chunkCache, resolvedChunks, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		dataChunks = append(dataChunks, subChunks...)
+	}
+	return
+}
+
+func fetchManifestWithCache(lookupFn wdclient.LookupFileIdFunctionType, chunkCache chunk_cache.ChunkCache, chunk *filer_pb.FileChunk) ([]*filer_pb.FileChunk, error) {
+
+	fileId := chunk.GetFileIdString()
+	var data []byte
+	if chunkCache != nil {
+		// the chunk size is the file range covered by the manifest, not the size of the manifest itself
+		data = chunkCache.GetChunk(fileId, 0)
+	}
+	if len(data) == 0 {
+		var err error
+		if data, err = fetchChunk(lookupFn, fileId, chunk.CipherKey, chunk.IsCompressed); err != nil {
+			return nil, fmt.Errorf("fail to read manifest %s: %v", fileId, err)
+		}
+		if chunkCache != nil {
+			chunkCache.SetChunk(fileId, data)
+		}
+	}
+
+	m := &filer_pb.FileChunkManifest{}
+	if err := proto.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("fail to unmarshal manifest %s: %v", fileId, err)
+	}
+	filer_pb.AfterEntryDeserialization(m.Chunks)
+	return m.Chunks, nil
+}
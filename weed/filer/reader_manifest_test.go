@@ -0,0 +1,162 @@
+package filer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestReaderAtFromEntryFlattensNestedManifests(t *testing.T) {
+
+	const chunkSize, chunkCount = 1000, 4
+	content := randomBytes(chunkSize * chunkCount)
+
+	var lock sync.Mutex
+	blobs := make(map[string][]byte)
+	fetches := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fileId := strings.TrimPrefix(r.URL.Path, "/")
+		lock.Lock()
+		data, found := blobs[fileId]
+		fetches[fileId]++
+		lock.Unlock()
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	var dataChunks []*filer_pb.FileChunk
+	for i := 0; i < chunkCount; i++ {
+		fileId := fmt.Sprintf("9,%x", 0x100+i)
+		blobs[fileId] = content[i*chunkSize : (i+1)*chunkSize]
+		dataChunks = append(dataChunks, &filer_pb.FileChunk{
+			FileId: fileId,
+			Offset: int64(i * chunkSize),
+			Size:   chunkSize,
+			Mtime:  int64(i + 1),
+		})
+	}
+	manifest := func(fileId string, chunks []*filer_pb.FileChunk) *filer_pb.FileChunk {
+		data, err := proto.Marshal(&filer_pb.FileChunkManifest{Chunks: chunks})
+		if err != nil {
+			t.Fatalf("marshal manifest: %v", err)
+		}
+		blobs[fileId] = data
+		start, stop := chunks[0].Offset, chunks[len(chunks)-1].Offset+int64(chunks[len(chunks)-1].Size)
+		return &filer_pb.FileChunk{
+			FileId:          fileId,
+			Offset:          start,
+			Size:            uint64(stop - start),
+			Mtime:           1,
+			IsChunkManifest: true,
+		}
+	}
+	top := manifest("9,200", []*filer_pb.FileChunk{
+		manifest("9,201", dataChunks[:2]),
+		manifest("9,202", dataChunks[2:]),
+	})
+	entry := &filer_pb.Entry{
+		Name:       "large",
+		Chunks:     []*filer_pb.FileChunk{top},
+		Attributes: &filer_pb.FuseAttributes{FileSize: uint64(len(content))},
+	}
+
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+	chunkCache := newMapChunkCache()
+	for round := 0; round < 2; round++ {
+		readerAt, err := NewChunkReaderAtFromEntry(lookupFn, entry, chunkCache)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		buf := make([]byte, len(content))
+		if n, err := readerAt.ReadAt(buf, 0); n != len(content) || (err != nil && err != io.EOF) {
+			t.Fatalf("read: n=%d err=%v", n, err)
+		}
+		if !bytes.Equal(buf, content) {
+			t.Errorf("round %d: content mismatch", round)
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, fileId := range []string{"9,200", "9,201", "9,202"} {
+		if fetches[fileId] != 1 {
+			t.Errorf("manifest %s fetched %d times, expect once", fileId, fetches[fileId])
+		}
+	}
+
+}
+
+func TestReaderAtFromEntryMissingManifest(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+	entry := &filer_pb.Entry{
+		Name: "broken",
+		Chunks: []*filer_pb.FileChunk{
+			{FileId: "9,300", Size: 1000, IsChunkManifest: true},
+		},
+		Attributes: &filer_pb.FuseAttributes{},
+	}
+	if _, err := NewChunkReaderAtFromEntry(lookupFn, entry, newMapChunkCache()); err == nil {
+		t.Errorf("expect an error for a missing manifest")
+	}
+
+}
+
+func TestReaderAtFromFilerEntry(t *testing.T) {
+
+	older, newer := randomBytes(2000), randomBytes(1000)
+	server := newTestVolumeServer(map[string][]byte{"9,400": older, "9,401": newer})
+	defer server.Close()
+
+	// the newer chunk overwrites the middle of the older one, and the file was truncated up afterwards
+	entry := &Entry{
+		FullPath: "/dir/file",
+		Attr:     Attr{FileSize: 3000},
+		Chunks: []*filer_pb.FileChunk{
+			{FileId: "9,400", Offset: 0, Size: 2000, Mtime: 1},
+			{FileId: "9,401", Offset: 500, Size: 1000, Mtime: 2},
+		},
+	}
+	content := make([]byte, 3000)
+	copy(content, older)
+	copy(content[500:], newer)
+
+	readerAt, err := NewChunkReaderAtFromFilerEntry(server.lookupFn, entry, newMapChunkCache())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	buf := make([]byte, 4000)
+	if n, err := readerAt.ReadAt(buf, 0); n != len(content) || err != io.EOF {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(buf[:len(content)], content) {
+		t.Errorf("content mismatch")
+	}
+
+	inline := &Entry{FullPath: "/dir/small", Attr: Attr{FileSize: 5}, Content: []byte("small")}
+	if _, err := NewChunkReaderAtFromFilerEntry(server.lookupFn, inline, newMapChunkCache()); err == nil {
+		t.Errorf("expect an error for a file in the entry content")
+	}
+
+}
@@ -0,0 +1,142 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+)
+
+// ByteRange is a requested part [Start, Start+Length) of a file.
+type ByteRange struct {
+	Start  int64
+	Length int64
+}
+
+type fetchedSpan struct {
+	start, stop int64 // the file offsets of the data
+	data        []byte
+}
+
+// NewMultipartRangeReader streams the ranges as a multipart/byteranges body, and returns it with its content type.
+// The ranges must be sorted by their start, and may overlap. Each chunk is fetched at most once,
+// covering all the ranges it serves.
+func (c *ChunkReadAt) NewMultipartRangeReader(ranges []ByteRange, mimeType string) (body io.ReadCloser, contentType string, err error) {
+
+	for i, r := range ranges {
+		if r.Start < 0 || r.Length <= 0 || r.Start+r.Length > c.fileSize {
+			return nil, "", fmt.Errorf("range [%d,%d) out of file size %d", r.Start, r.Start+r.Length, c.fileSize)
+		}
+		if i > 0 && r.Start < ranges[i-1].Start {
+			return nil, "", fmt.Errorf("range [%d,%d) not sorted", r.Start, r.Start+r.Length)
+		}
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(c.writeMultipartRanges(mw, ranges, mimeType))
+	}()
+
+	return pr, "multipart/byteranges; boundary=" + mw.Boundary(), nil
+}
+
+func (c *ChunkReadAt) writeMultipartRanges(mw *multipart.Writer, ranges []ByteRange, mimeType string) error {
+
+	fetched := make(map[int]*fetchedSpan)
+	for i, r := range ranges {
+		start, stop := r.Start, r.Start+r.Length
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, stop-1, c.fileSize))
+		if mimeType != "" {
+			header.Set("Content-Type", mimeType)
+		}
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+
+		// the ranges are sorted, so the chunk data before this range is not needed any more
+		for j, span := range fetched {
+			if span.stop <= start {
+				delete(fetched, j)
+			}
+		}
+
+		offset := start
+		for j, chunkView := range c.chunkViews {
+			viewStart, viewStop := chunkView.LogicOffset, chunkView.LogicOffset+int64(chunkView.Size)
+			if chunkView.Size == 0 || viewStop <= start || viewStart >= stop {
+				continue
+			}
+			if offset < viewStart {
+				if err = writeZeros(part, viewStart-offset); err != nil {
+					return err
+				}
+				offset = viewStart
+			}
+			span, found := fetched[j]
+			if !found {
+				if span, err = c.fetchSpan(chunkView, ranges[i:]); err != nil {
+					return err
+				}
+				fetched[j] = span
+			}
+			writeStop := min(viewStop, stop)
+			if _, err = part.Write(span.data[offset-span.start : writeStop-span.start]); err != nil {
+				return err
+			}
+			offset = writeStop
+		}
+		if offset < stop {
+			if err = writeZeros(part, stop-offset); err != nil {
+				return err
+			}
+		}
+	}
+
+	return mw.Close()
+}
+
+// fetchSpan fetches the part of the chunk view needed by the sorted ranges, the first of which overlaps the chunk view.
+func (c *ChunkReadAt) fetchSpan(chunkView *ChunkView, ranges []ByteRange) (*fetchedSpan, error) {
+
+	viewStart, viewStop := chunkView.LogicOffset, chunkView.LogicOffset+int64(chunkView.Size)
+	start, stop := max(viewStart, ranges[0].Start), int64(0)
+	for _, r := range ranges {
+		if r.Start >= viewStop {
+			break
+		}
+		stop = max(stop, r.Start+r.Length)
+	}
+	stop = min(stop, viewStop)
+
+	partialView := *chunkView
+	partialView.Offset = chunkView.Offset + (start - viewStart)
+	partialView.Size = uint64(stop - start)
+	partialView.LogicOffset = start
+	data, err := c.fetchChunkView(context.Background(), &partialView)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fetchedSpan{
+		start: start,
+		stop:  stop,
+		data:  data,
+	}, nil
+}
+
+func writeZeros(w io.Writer, size int64) error {
+	zeros := make([]byte, min(size, 64*1024))
+	for size > 0 {
+		n, err := w.Write(zeros[:min(size, int64(len(zeros)))])
+		if err != nil {
+			return err
+		}
+		size -= int64(n)
+	}
+	return nil
+}
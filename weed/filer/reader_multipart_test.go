@@ -0,0 +1,82 @@
+package filer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"testing"
+)
+
+func TestMultipartRangeReader(t *testing.T) {
+
+	chunks := map[string][]byte{
+		"1,0e01": randomBytes(1000),
+		"1,0e02": randomBytes(1000),
+		"1,0e03": randomBytes(1000),
+	}
+	server := newTestVolumeServer(chunks)
+	defer server.Close()
+
+	// a hole between the second and the third chunk
+	content := make([]byte, 3500)
+	copy(content[0:], chunks["1,0e01"])
+	copy(content[1000:], chunks["1,0e02"])
+	copy(content[2500:], chunks["1,0e03"])
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{
+		{FileId: "1,0e01", Size: 1000, ChunkSize: 1000, LogicOffset: 0},
+		{FileId: "1,0e02", Size: 1000, ChunkSize: 1000, LogicOffset: 1000},
+		{FileId: "1,0e03", Size: 1000, ChunkSize: 1000, LogicOffset: 2500},
+	}, newMapChunkCache(), 3500)
+
+	ranges := []ByteRange{
+		{Start: 100, Length: 100},
+		{Start: 150, Length: 250}, // overlapping
+		{Start: 400, Length: 100}, // adjacent
+		{Start: 1900, Length: 700},
+		{Start: 3400, Length: 100},
+	}
+	body, contentType, err := readerAt.NewMultipartRangeReader(ranges, "text/plain")
+	if err != nil {
+		t.Fatalf("new multipart reader: %v", err)
+	}
+	defer body.Close()
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("content type %s: %v", contentType, err)
+	}
+	mr := multipart.NewReader(body, params["boundary"])
+	for _, r := range ranges {
+		part, err := mr.NextPart()
+		if err != nil {
+			t.Fatalf("part [%d,%d): %v", r.Start, r.Start+r.Length, err)
+		}
+		if contentRange := part.Header.Get("Content-Range"); contentRange != fmt.Sprintf("bytes %d-%d/3500", r.Start, r.Start+r.Length-1) {
+			t.Errorf("part [%d,%d): content range %s", r.Start, r.Start+r.Length, contentRange)
+		}
+		if part.Header.Get("Content-Type") != "text/plain" {
+			t.Errorf("part [%d,%d): content type %s", r.Start, r.Start+r.Length, part.Header.Get("Content-Type"))
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("read part [%d,%d): %v", r.Start, r.Start+r.Length, err)
+		}
+		if !bytes.Equal(data, content[r.Start:r.Start+r.Length]) {
+			t.Errorf("part [%d,%d): unexpected content", r.Start, r.Start+r.Length)
+		}
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("expect the end of the parts, got %v", err)
+	}
+
+	if server.requests != 3 {
+		t.Errorf("%d chunk fetches, expect 3", server.requests)
+	}
+
+	if _, _, err := readerAt.NewMultipartRangeReader([]ByteRange{{Start: 400, Length: 1}, {Start: 100, Length: 1}}, ""); err == nil {
+		t.Errorf("expect error for unsorted ranges")
+	}
+
+}
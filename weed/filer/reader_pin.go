@@ -0,0 +1,19 @@
+package filer
+
+import (
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
+)
+
+// pinChunk keeps the chunk in the cache while a read uses it, if the cache can pin chunks,
+// so that a full cache does not evict it for the chunks prefetched meanwhile, only to fetch it again.
+func (c *ChunkReadAt) pinChunk(chunkView *ChunkView) (unpin func()) {
+	pinning, ok := c.chunkCache.(chunk_cache.PinningChunkCache)
+	if !ok {
+		return func() {}
+	}
+	key := c.cacheKey(chunkView.FileId)
+	pinning.Pin(key)
+	return func() {
+		pinning.Unpin(key)
+	}
+}
@@ -0,0 +1,61 @@
+package filer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
+)
+
+// pressuringChunkCache caches other chunks right after a chunk is cached, as concurrent prefetches would
+type pressuringChunkCache struct {
+	*chunk_cache.TieredChunkCache
+	onSet func(fileId string)
+}
+
+func (c *pressuringChunkCache) SetChunk(fileId string, data []byte) {
+	c.TieredChunkCache.SetChunk(fileId, data)
+	c.onSet(fileId)
+}
+
+func TestReaderAtPinsTheChunkBeingRead(t *testing.T) {
+
+	data := randomBytes(1024)
+	server := newTestVolumeServer(map[string][]byte{"1,6a01": data})
+	defer server.Close()
+
+	// keyed in a namespace, the chunks are only kept in memory, which holds 2 of them
+	tiered := chunk_cache.NewTieredChunkCache(2, t.TempDir(), 1, 1024*1024)
+	defer tiered.Shutdown()
+	cache := &pressuringChunkCache{TieredChunkCache: tiered}
+	activeKey := CacheKeyInNamespace("t")("1,6a01")
+	evictedMidRead := false
+	cache.onSet = func(fileId string) {
+		if fileId != activeKey {
+			return
+		}
+		// probed once the policy has evicted it, since each probe is a hit keeping it recently used
+		for i := 0; i < 4; i++ {
+			tiered.SetChunk(CacheKeyInNamespace("t")(fmt.Sprintf("9,%02x", i)), randomBytes(1024))
+		}
+		evictedMidRead = tiered.GetChunk(activeKey, 1024) == nil
+	}
+	for i := 4; i < 6; i++ {
+		tiered.SetChunk(CacheKeyInNamespace("t")(fmt.Sprintf("9,%02x", i)), randomBytes(1024))
+	}
+
+	chunkViews := []*ChunkView{{FileId: "1,6a01", Size: 1024, ChunkSize: 1024, LogicOffset: 0}}
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, cache, 1024)
+	readerAt.SetCacheKeyFn(CacheKeyInNamespace("t"))
+	if _, err := readerAt.ReadAt(make([]byte, 1024), 0); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	if evictedMidRead {
+		t.Errorf("the chunk being read was evicted")
+	}
+	if tiered.GetChunk(activeKey, 1024) != nil {
+		t.Errorf("expect the chunk dropped once the read is done, as the cache is full")
+	}
+
+}
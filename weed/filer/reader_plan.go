@@ -0,0 +1,52 @@
+package filer
+
+// PlannedRead is a part of a chunk view that a read goes to.
+type PlannedRead struct {
+	ChunkView   *ChunkView
+	VolumeId    string
+	ChunkOffset int64 // the start of the read inside the chunk
+	Size        int64
+}
+
+// PlanRead reports the chunk views a ReadAt of size bytes at offset would read, in order, without fetching anything.
+// Holes between the chunk views and beyond the last one are not read, and so not reported.
+func (c *ChunkReadAt) PlanRead(offset int64, size int) (plannedReads []PlannedRead) {
+
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+
+	if offset >= c.fileSize {
+		return nil
+	}
+	remaining := min(int64(size), c.fileSize-offset)
+
+	startOffset := offset
+	for _, chunk := range c.chunkViews {
+		if remaining <= 0 {
+			break
+		}
+		if chunk.Size == 0 {
+			continue
+		}
+		if startOffset < chunk.LogicOffset {
+			gap := chunk.LogicOffset - startOffset
+			startOffset, remaining = chunk.LogicOffset, remaining-gap
+			if remaining <= 0 {
+				break
+			}
+		}
+		chunkStart, chunkStop := max(chunk.LogicOffset, startOffset), min(chunk.LogicOffset+int64(chunk.Size), startOffset+remaining)
+		if chunkStart >= chunkStop {
+			continue
+		}
+		plannedReads = append(plannedReads, PlannedRead{
+			ChunkView:   chunk,
+			VolumeId:    VolumeId(chunk.FileId),
+			ChunkOffset: chunkStart - chunk.LogicOffset + chunk.Offset,
+			Size:        chunkStop - chunkStart,
+		})
+		startOffset, remaining = chunkStop, remaining-(chunkStop-chunkStart)
+	}
+
+	return
+}
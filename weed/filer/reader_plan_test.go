@@ -0,0 +1,79 @@
+package filer
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPlanReadMatchesRead(t *testing.T) {
+
+	chunks := map[string][]byte{
+		"1,1001": randomBytes(1000),
+		"2,1002": randomBytes(1000),
+		"3,1003": randomBytes(1000),
+	}
+	volumeServer := newTestVolumeServer(chunks)
+	defer volumeServer.Close()
+
+	// trace the chunk fetches and their ranges
+	var traceLock sync.Mutex
+	var trace []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceLock.Lock()
+		trace = append(trace, strings.TrimPrefix(r.URL.Path, "/")+" "+r.Header.Get("Range"))
+		traceLock.Unlock()
+		volumeServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+
+	chunkViews := []*ChunkView{
+		{FileId: "1,1001", Size: 1000, ChunkSize: 1000, LogicOffset: 0},
+		{FileId: "2,1002", Size: 1000, ChunkSize: 1000, LogicOffset: 1000},
+		{FileId: "3,1003", Size: 1000, ChunkSize: 1000, LogicOffset: 2500},
+		{FileId: "3,1004", Size: 0, ChunkSize: 1000, LogicOffset: 3500},
+	}
+
+	for _, read := range []struct {
+		offset int64
+		size   int
+	}{
+		{100, 50},
+		{900, 200},
+		{1900, 800},
+		{2000, 400},
+		{3400, 600},
+		{1, 3999},
+		{4000, 10},
+	} {
+		readerAt := NewChunkReaderAtFromClient(lookupFn, chunkViews, newMapChunkCache(), 4000)
+		plannedReads := readerAt.PlanRead(read.offset, read.size)
+
+		traceLock.Lock()
+		trace = nil
+		traceLock.Unlock()
+		readerAt.ReadAt(make([]byte, read.size), read.offset)
+		traceLock.Lock()
+		fetched := fmt.Sprint(trace)
+		traceLock.Unlock()
+
+		var planned []string
+		for _, plannedRead := range plannedReads {
+			if plannedRead.VolumeId != strings.Split(plannedRead.ChunkView.FileId, ",")[0] {
+				t.Errorf("read [%d,%d): volume id %s of %s", read.offset, read.offset+int64(read.size), plannedRead.VolumeId, plannedRead.ChunkView.FileId)
+			}
+			rangeHeader := fmt.Sprintf("bytes=%d-%d", plannedRead.ChunkOffset, plannedRead.ChunkOffset+plannedRead.Size-1)
+			planned = append(planned, plannedRead.ChunkView.FileId+" "+rangeHeader)
+		}
+		if fmt.Sprint(planned) != fetched {
+			t.Errorf("read [%d,%d): planned %v, fetched %v", read.offset, read.offset+int64(read.size), planned, fetched)
+		}
+	}
+
+}
@@ -0,0 +1,249 @@
+package filer
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
+)
+
+// DefaultPrefetchLimit is the number of background chunk fetches allowed at the same time in this process.
+const DefaultPrefetchLimit = 16
+
+// prefetchQueueLimit bounds the jobs waiting for a worker, the farthest and lowest priority ones dropped beyond it
+const prefetchQueueLimit = 1024
+
+// PrefetchScheduler runs the background chunk fetches shared by all readers, queued for a bounded number of workers.
+type PrefetchScheduler struct {
+	sync.Mutex
+	paused             int32 // accessed atomically
+	limit              int
+	workers            int               // running, up to the limit, and exiting once the queue is empty
+	jobs               [2][]*prefetchJob // by ReadPriority, then by distance
+	interactiveStarted int               // interactive jobs started while background ones wait
+}
+
+type prefetchJob struct {
+	ctx      context.Context
+	distance int
+	run      func()
+}
+
+var prefetchScheduler = NewPrefetchScheduler(DefaultPrefetchLimit)
+
+func NewPrefetchScheduler(limit int) *PrefetchScheduler {
+	if limit <= 0 {
+		limit = DefaultPrefetchLimit
+	}
+	return &PrefetchScheduler{
+		limit: limit,
+	}
+}
+
+// Go runs the job in the background once a prefetch worker is available.
+func (s *PrefetchScheduler) Go(job func()) {
+	s.GoWithPriority(ReadPriorityInteractive, job)
+}
+
+// GoWithPriority runs the job in the background once a prefetch worker is available,
+// ahead of the lower priority jobs.
+func (s *PrefetchScheduler) GoWithPriority(priority ReadPriority, job func()) {
+	s.GoAhead(priority, 0, job)
+}
+
+// GoAhead is GoWithPriority for a job fetching the chunk distance chunks ahead of a read.
+// Among the jobs of the same priority, the nearest chunks are fetched first,
+// since the next chunk is needed well before the deeper ones.
+// While paused, the jobs are dropped, including those still queued.
+func (s *PrefetchScheduler) GoAhead(priority ReadPriority, distance int, job func()) {
+	s.GoAheadWithContext(context.Background(), priority, distance, job)
+}
+
+// GoAheadWithContext is GoAhead for a job dropped once the context is done, e.g. by a reader closed meanwhile,
+// including while queued.
+func (s *PrefetchScheduler) GoAheadWithContext(ctx context.Context, priority ReadPriority, distance int, job func()) {
+	if s.Paused() || ctx.Err() != nil {
+		return
+	}
+	if priority != ReadPriorityBackground {
+		priority = ReadPriorityInteractive
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	jobs := s.jobs[priority]
+	i := len(jobs)
+	for i > 0 && jobs[i-1].distance > distance {
+		i--
+	}
+	jobs = append(jobs, nil)
+	copy(jobs[i+1:], jobs[i:])
+	jobs[i] = &prefetchJob{ctx: ctx, distance: distance, run: job}
+	s.jobs[priority] = jobs
+
+	if len(s.jobs[ReadPriorityInteractive])+len(s.jobs[ReadPriorityBackground]) > prefetchQueueLimit {
+		dropped := ReadPriorityBackground
+		if len(s.jobs[dropped]) == 0 {
+			dropped = ReadPriorityInteractive
+		}
+		s.jobs[dropped] = s.jobs[dropped][:len(s.jobs[dropped])-1]
+	}
+	if s.workers < s.limit {
+		s.workers++
+		go s.work()
+	}
+}
+
+func (s *PrefetchScheduler) work() {
+	for {
+		job := s.next()
+		if job == nil {
+			return
+		}
+		job.run()
+	}
+}
+
+// next takes the job to run, by priority, skipping those gone stale while queued,
+// and returns nil once there is none, with the worker exiting.
+func (s *PrefetchScheduler) next() *prefetchJob {
+	s.Lock()
+	defer s.Unlock()
+	for {
+		interactive, background := s.jobs[ReadPriorityInteractive], s.jobs[ReadPriorityBackground]
+		var job *prefetchJob
+		switch {
+		case len(interactive) > 0 && (len(background) == 0 || s.interactiveStarted < interactiveWeight):
+			if len(background) > 0 {
+				s.interactiveStarted++
+			}
+			job = interactive[0]
+			s.jobs[ReadPriorityInteractive] = interactive[1:]
+		case len(background) > 0:
+			s.interactiveStarted = 0
+			job = background[0]
+			s.jobs[ReadPriorityBackground] = background[1:]
+		default:
+			s.workers--
+			return nil
+		}
+		if s.Paused() || job.ctx.Err() != nil {
+			continue
+		}
+		return job
+	}
+}
+
+// queued returns the number of jobs waiting for a worker
+func (s *PrefetchScheduler) queued() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.jobs[ReadPriorityInteractive]) + len(s.jobs[ReadPriorityBackground])
+}
+
+// Pause stops the background fetches until Resume, e.g. during a volume server maintenance window.
+// The fetches already running are let finish, and the reads themselves go on as usual.
+func (s *PrefetchScheduler) Pause() {
+	atomic.StoreInt32(&s.paused, 1)
+}
+
+func (s *PrefetchScheduler) Resume() {
+	atomic.StoreInt32(&s.paused, 0)
+}
+
+func (s *PrefetchScheduler) Paused() bool {
+	return atomic.LoadInt32(&s.paused) == 1
+}
+
+// PausePrefetch pauses the background chunk fetches of all readers in this process, see PrefetchScheduler.Pause.
+func PausePrefetch() {
+	prefetchScheduler.Pause()
+}
+
+// ResumePrefetch resumes the background chunk fetches paused by PausePrefetch.
+func ResumePrefetch() {
+	prefetchScheduler.Resume()
+}
+
+// SetPrefetchDepth sets how many chunks a sequential read fetches ahead into the chunk cache, 1 if not set.
+func (c *ChunkReadAt) SetPrefetchDepth(depth int) {
+	c.prefetchDepth = depth
+}
+
+// SetSynchronousPrefetch makes a read fetch the chunks ahead itself, nearest first, before returning,
+// instead of in the background, and keeps them on the reader till read. The reads get slower, but no longer
+// race with their prefetches, so with a fixed SetPrefetchDepth the same reads fetch the same chunks
+// in the same order, e.g. for reproducible benchmarks of the read path.
+func (c *ChunkReadAt) SetSynchronousPrefetch(synchronous bool) {
+	c.syncPrefetch = synchronous
+}
+
+// prefetchContext is done once the reader is closed or reset, so the prefetches queued for it are dropped
+func (c *ChunkReadAt) prefetchContext() context.Context {
+	if c.prefetchCtx == nil {
+		c.prefetchCtx, c.prefetchCancel = context.WithCancel(context.Background())
+	}
+	return c.prefetchCtx
+}
+
+// prefetchSynchronously fetches the chunks ahead, nearest first, and keeps them on the reader for the reads reaching them.
+// The chunks kept before and no longer ahead are dropped.
+func (c *ChunkReadAt) prefetchSynchronously(ctx context.Context, nextChunkViews []*ChunkView) {
+	prefetched := make(map[string][]byte, len(nextChunkViews))
+	for _, nextChunkView := range nextChunkViews {
+		if nextChunkView == nil {
+			continue
+		}
+		if data, found := c.prefetchedChunks[nextChunkView.FileId]; found {
+			prefetched[nextChunkView.FileId] = data
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		v, err := c.readOneWholeChunk(ctx, nextChunkView)
+		if err != nil {
+			glog.V(1).Infof("prefetch %s: %v", nextChunkView.FileId, err)
+			continue
+		}
+		prefetched[nextChunkView.FileId] = v.([]byte)
+	}
+	c.prefetchedChunks = prefetched
+	c.recountCachedBytes()
+}
+
+// WarmFirstChunk fetches the first chunk of a file into the chunk cache in the background,
+// unless it is cached already.
+func WarmFirstChunk(lookupFn wdclient.LookupFileIdFunctionType, chunkCache chunk_cache.ChunkCache, chunks []*filer_pb.FileChunk) {
+	WarmFirstChunkWithContext(context.Background(), lookupFn, chunkCache, chunks)
+}
+
+// WarmFirstChunkWithContext abandons the warm-up if the context is done while it waits for a prefetch slot.
+// A started warm-up is detached from the context, and fetches the chunk for the next reads to find it cached.
+func WarmFirstChunkWithContext(ctx context.Context, lookupFn wdclient.LookupFileIdFunctionType, chunkCache chunk_cache.ChunkCache, chunks []*filer_pb.FileChunk) {
+	prefetchScheduler.GoAheadWithContext(ctx, ReadPriorityInteractive, 0, func() {
+		doWarmFirstChunk(lookupFn, chunkCache, chunks)
+	})
+}
+
+func doWarmFirstChunk(lookupFn wdclient.LookupFileIdFunctionType, chunkCache chunk_cache.ChunkCache, chunks []*filer_pb.FileChunk) {
+	chunkViews := ViewFromChunks(lookupFn, chunks, 0, math.MaxInt64)
+	if len(chunkViews) == 0 || chunkViews[0].LogicOffset != 0 {
+		return
+	}
+	chunkView := chunkViews[0]
+	if chunkCache.GetChunk(chunkView.FileId, chunkView.ChunkSize) != nil {
+		return
+	}
+	data, err := fetchChunkOfSize(context.Background(), lookupFn, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, chunkView.ChunkSize)
+	if err != nil {
+		glog.V(1).Infof("warm chunk %s: %v", chunkView.FileId, err)
+		return
+	}
+	chunkCache.SetChunk(chunkView.FileId, data)
+}
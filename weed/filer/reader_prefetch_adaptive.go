@@ -0,0 +1,99 @@
+package filer
+
+import (
+	"sync"
+	"time"
+)
+
+// adaptivePrefetch estimates the bandwidth delay product of a sequential read in chunks:
+// the chunks the reader consumes while one chunk is fetched. Prefetching that many chunks ahead
+// keeps a reader that keeps up with the link from waiting, while a reader slower than the link
+// gets a shallow depth instead of prefetched chunks sitting unused in the chunk cache.
+type adaptivePrefetch struct {
+	sync.Mutex
+	minDepth        int
+	maxDepth        int
+	fetchLatency    time.Duration // smoothed, of whole chunk fetches
+	consumeInterval time.Duration // smoothed, between the chunks read in order
+	lastConsumed    time.Time
+}
+
+// the weight of a new sample in the smoothed durations
+const adaptivePrefetchSmoothing = 0.25
+
+// SetAdaptivePrefetch makes a sequential read adjust how many chunks it fetches ahead between minDepth and maxDepth,
+// by the measured fetch latency and the rate the chunks are read, timed by the reader clock, see SetClock.
+// A maxDepth of 0 returns to the fixed depth of SetPrefetchDepth.
+func (c *ChunkReadAt) SetAdaptivePrefetch(minDepth, maxDepth int) {
+	if maxDepth <= 0 {
+		c.adaptivePrefetch = nil
+		return
+	}
+	if minDepth < 1 {
+		minDepth = 1
+	}
+	if maxDepth < minDepth {
+		maxDepth = minDepth
+	}
+	c.adaptivePrefetch = &adaptivePrefetch{
+		minDepth: minDepth,
+		maxDepth: maxDepth,
+	}
+}
+
+// PrefetchDepth returns how many chunks a sequential read currently fetches ahead.
+func (c *ChunkReadAt) PrefetchDepth() int {
+	if c.adaptivePrefetch != nil {
+		return c.adaptivePrefetch.depth()
+	}
+	if c.prefetchDepth < 1 {
+		return 1
+	}
+	return c.prefetchDepth
+}
+
+func (p *adaptivePrefetch) observeFetch(latency time.Duration) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	p.fetchLatency = smoothDuration(p.fetchLatency, latency)
+}
+
+// observeConsume is called when the reader moves on to the next chunk
+func (p *adaptivePrefetch) observeConsume(now time.Time) {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	if !p.lastConsumed.IsZero() {
+		p.consumeInterval = smoothDuration(p.consumeInterval, now.Sub(p.lastConsumed))
+	}
+	p.lastConsumed = now
+}
+
+func (p *adaptivePrefetch) depth() int {
+	p.Lock()
+	defer p.Unlock()
+	if p.fetchLatency <= 0 || p.consumeInterval <= 0 {
+		return p.minDepth
+	}
+	// round up, and one more for the chunk being fetched when the reader gets there
+	depth := int((p.fetchLatency+p.consumeInterval-1)/p.consumeInterval) + 1
+	if depth < p.minDepth {
+		return p.minDepth
+	}
+	if depth > p.maxDepth {
+		return p.maxDepth
+	}
+	return depth
+}
+
+func smoothDuration(smoothed, sample time.Duration) time.Duration {
+	if smoothed <= 0 {
+		return sample
+	}
+	return smoothed + time.Duration(adaptivePrefetchSmoothing*float64(sample-smoothed))
+}
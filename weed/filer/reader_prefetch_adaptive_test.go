@@ -0,0 +1,54 @@
+package filer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptivePrefetchDepth(t *testing.T) {
+
+	readerAt := &ChunkReadAt{}
+	if depth := readerAt.PrefetchDepth(); depth != 1 {
+		t.Errorf("default depth %d, expect 1", depth)
+	}
+	readerAt.SetAdaptivePrefetch(1, 8)
+	p := readerAt.adaptivePrefetch
+
+	now := time.Unix(1600000000, 0)
+	readChunks := func(count int, consumeInterval, fetchLatency time.Duration) {
+		for i := 0; i < count; i++ {
+			now = now.Add(consumeInterval)
+			p.observeConsume(now)
+			p.observeFetch(fetchLatency)
+		}
+	}
+
+	if depth := readerAt.PrefetchDepth(); depth != 1 {
+		t.Errorf("depth %d before any measurement, expect the minimum 1", depth)
+	}
+
+	// a reader keeping up with a fast link consumes 5 chunks while one is fetched
+	readChunks(50, 2*time.Millisecond, 10*time.Millisecond)
+	if depth := readerAt.PrefetchDepth(); depth != 6 {
+		t.Errorf("depth %d on a fast link, expect 6", depth)
+	}
+
+	// the reader slows down, and deep prefetches would sit unused
+	readChunks(50, 40*time.Millisecond, 10*time.Millisecond)
+	if depth := readerAt.PrefetchDepth(); depth != 2 {
+		t.Errorf("depth %d for a slow reader, expect 2", depth)
+	}
+
+	// a slow link and a fast reader, bounded by the maximum
+	readChunks(50, time.Millisecond, 100*time.Millisecond)
+	if depth := readerAt.PrefetchDepth(); depth != 8 {
+		t.Errorf("depth %d on a slow link, expect the maximum 8", depth)
+	}
+
+	readerAt.SetAdaptivePrefetch(0, 0)
+	readerAt.SetPrefetchDepth(3)
+	if depth := readerAt.PrefetchDepth(); depth != 3 {
+		t.Errorf("depth %d after disabling, expect the fixed 3", depth)
+	}
+
+}
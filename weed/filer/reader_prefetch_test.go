@@ -0,0 +1,364 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestWarmFirstChunk(t *testing.T) {
+
+	data := randomBytes(4096)
+	server := newTestVolumeServer(map[string][]byte{
+		"1,0a01": data,
+		"1,0a02": randomBytes(4096),
+	})
+	defer server.Close()
+
+	cache := newMapChunkCache()
+	chunks := []*filer_pb.FileChunk{
+		{FileId: "1,0a01", Offset: 0, Size: 4096},
+		{FileId: "1,0a02", Offset: 4096, Size: 4096},
+	}
+
+	doWarmFirstChunk(server.lookupFn, cache, chunks)
+
+	if !bytes.Equal(cache.GetChunk("1,0a01", 4096), data) {
+		t.Errorf("first chunk is not warmed")
+	}
+	if cache.GetChunk("1,0a02", 0) != nil {
+		t.Errorf("only the first chunk should be warmed")
+	}
+
+	// already cached chunks are not fetched again
+	requests := server.requests
+	doWarmFirstChunk(server.lookupFn, cache, chunks)
+	if server.requests != requests {
+		t.Errorf("cached chunk was fetched again")
+	}
+
+}
+
+func TestWarmFirstChunkCancelled(t *testing.T) {
+
+	scheduler := NewPrefetchScheduler(1)
+	defer func(original *PrefetchScheduler) { prefetchScheduler = original }(prefetchScheduler)
+	prefetchScheduler = scheduler
+
+	server := newTestVolumeServer(map[string][]byte{"1,0b01": randomBytes(4096)})
+	defer server.Close()
+	chunks := []*filer_pb.FileChunk{{FileId: "1,0b01", Offset: 0, Size: 4096}}
+
+	// hold the only prefetch worker, so that the warm-up is queued
+	release, held := make(chan struct{}), make(chan struct{})
+	scheduler.Go(func() {
+		close(held)
+		<-release
+	})
+	<-held
+
+	ctx, cancel := context.WithCancel(context.Background())
+	WarmFirstChunkWithContext(ctx, server.lookupFn, newMapChunkCache(), chunks)
+	if queued := scheduler.queued(); queued != 1 {
+		t.Fatalf("%d jobs queued, expect the warm-up", queued)
+	}
+
+	// a cancelled warm-up is dropped instead of taking the freed worker
+	cancel()
+	ran := make(chan struct{})
+	scheduler.Go(func() { close(ran) })
+	close(release)
+	<-ran
+	if requests := atomic.LoadInt32(&server.requests); requests != 0 {
+		t.Errorf("%d requests of a cancelled warm-up", requests)
+	}
+	if queued := scheduler.queued(); queued != 0 {
+		t.Errorf("%d jobs left queued", queued)
+	}
+
+}
+
+func TestPrefetchNextChunkAheadOfDeeperOnes(t *testing.T) {
+
+	scheduler := NewPrefetchScheduler(1)
+	defer func(original *PrefetchScheduler) { prefetchScheduler = original }(prefetchScheduler)
+	prefetchScheduler = scheduler
+
+	arrived := make(chan string, 8)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- strings.TrimPrefix(r.URL.Path, "/")
+		w.Write(make([]byte, 1024))
+	}))
+	defer server.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+
+	var chunkViews []*ChunkView
+	for i := 0; i < 5; i++ {
+		chunkViews = append(chunkViews, &ChunkView{FileId: fmt.Sprintf("1,7a%02x", i), Size: 1024, ChunkSize: 1024, LogicOffset: int64(i * 1024)})
+	}
+	readerAt := NewChunkReaderAtFromClient(lookupFn, chunkViews, newMapChunkCache(), 5*1024)
+	readerAt.SetPrefetchDepth(3)
+
+	// hold the only prefetch worker, so that the prefetches of the read queue up
+	release, held := make(chan struct{}), make(chan struct{})
+	scheduler.Go(func() {
+		close(held)
+		<-release
+	})
+	<-held
+
+	if _, err := readerAt.ReadAt(make([]byte, 100), 0); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if fileId := <-arrived; fileId != chunkViews[0].FileId {
+		t.Fatalf("read fetched %s, expect %s", fileId, chunkViews[0].FileId)
+	}
+	if queued := scheduler.queued(); queued != 3 {
+		t.Fatalf("%d prefetches queued, expect 3", queued)
+	}
+
+	close(release)
+	for _, chunkView := range chunkViews[1:4] {
+		select {
+		case fileId := <-arrived:
+			if fileId != chunkView.FileId {
+				t.Errorf("prefetched %s, expect %s first", fileId, chunkView.FileId)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for prefetch of %s", chunkView.FileId)
+		}
+	}
+
+}
+
+func TestPrefetchSchedulerBoundsWorkersAndQueue(t *testing.T) {
+
+	scheduler := NewPrefetchScheduler(2)
+	release := make(chan struct{})
+	var held sync.WaitGroup
+	held.Add(2)
+	for i := 0; i < 2; i++ {
+		scheduler.Go(func() {
+			held.Done()
+			<-release
+		})
+	}
+	held.Wait()
+
+	// the jobs over the workers wait in the queue, the farthest ones dropped beyond its limit
+	var ran int32
+	var done sync.WaitGroup
+	for i := 0; i < prefetchQueueLimit+10; i++ {
+		distance := i
+		if distance < prefetchQueueLimit {
+			done.Add(1)
+		}
+		scheduler.GoAhead(ReadPriorityInteractive, distance, func() {
+			if distance >= prefetchQueueLimit {
+				t.Errorf("ran the job at distance %d, beyond the queue limit", distance)
+				return
+			}
+			atomic.AddInt32(&ran, 1)
+			done.Done()
+		})
+	}
+	scheduler.Lock()
+	workers := scheduler.workers
+	scheduler.Unlock()
+	if workers != 2 {
+		t.Errorf("%d workers, expect 2", workers)
+	}
+	if queued := scheduler.queued(); queued != prefetchQueueLimit {
+		t.Errorf("%d jobs queued, expect %d", queued, prefetchQueueLimit)
+	}
+
+	close(release)
+	done.Wait()
+	if ran != prefetchQueueLimit {
+		t.Errorf("ran %d jobs, expect %d", ran, prefetchQueueLimit)
+	}
+
+}
+
+func TestPrefetchOfClosedReaderDropped(t *testing.T) {
+
+	scheduler := NewPrefetchScheduler(1)
+	defer func(original *PrefetchScheduler) { prefetchScheduler = original }(prefetchScheduler)
+	prefetchScheduler = scheduler
+
+	chunks := make(map[string][]byte)
+	var chunkViews []*ChunkView
+	for i := 0; i < 4; i++ {
+		fileId := fmt.Sprintf("1,7e%02x", i)
+		chunks[fileId] = randomBytes(1024)
+		chunkViews = append(chunkViews, &ChunkView{FileId: fileId, Size: 1024, ChunkSize: 1024, LogicOffset: int64(i * 1024)})
+	}
+	server := newTestVolumeServer(chunks)
+	defer server.Close()
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, newMapChunkCache(), 4*1024)
+	readerAt.SetPrefetchDepth(3)
+
+	release, held := make(chan struct{}), make(chan struct{})
+	scheduler.Go(func() {
+		close(held)
+		<-release
+	})
+	<-held
+
+	if _, err := readerAt.ReadAt(make([]byte, 100), 0); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if queued := scheduler.queued(); queued != 3 {
+		t.Fatalf("%d prefetches queued, expect 3", queued)
+	}
+	readerAt.Close()
+
+	// queued behind the prefetches
+	ran := make(chan struct{})
+	scheduler.GoAhead(ReadPriorityInteractive, 4, func() { close(ran) })
+	close(release)
+	<-ran
+	if requests := atomic.LoadInt32(&server.requests); requests != 1 {
+		t.Errorf("%d requests, expect only the read, not the prefetches of the closed reader", requests)
+	}
+
+}
+
+func TestSynchronousPrefetchInOrder(t *testing.T) {
+
+	var arrived []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived = append(arrived, strings.TrimPrefix(r.URL.Path, "/1,7b"))
+		w.Write(make([]byte, 1024))
+	}))
+	defer server.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+
+	var chunkViews []*ChunkView
+	for i := 0; i < 4; i++ {
+		chunkViews = append(chunkViews, &ChunkView{FileId: fmt.Sprintf("1,7b%02x", i), Size: 1024, ChunkSize: 1024, LogicOffset: int64(i * 1024)})
+	}
+	readerAt := NewChunkReaderAtFromClient(lookupFn, chunkViews, newMapChunkCache(), 4*1024)
+	readerAt.SetPrefetchDepth(2)
+	readerAt.SetSynchronousPrefetch(true)
+
+	// the prefetches are done once the read returns, with no goroutine left to race with the next read
+	if _, err := readerAt.ReadAt(make([]byte, 1024), 0); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if fetched := strings.Join(arrived, " "); fetched != "00 01 02" {
+		t.Errorf("fetched %s, expect 00 01 02", fetched)
+	}
+
+	// the following reads are served by the chunks prefetched, and only fetch the chunk newly ahead
+	for i := 1; i < 4; i++ {
+		if _, err := readerAt.ReadAt(make([]byte, 1024), int64(i*1024)); err != nil && err != io.EOF {
+			t.Fatalf("read chunk %d: %v", i, err)
+		}
+		if fetched := strings.Join(arrived, " "); fetched != "00 01 02 03" {
+			t.Errorf("fetched %s after reading chunk %d, expect 00 01 02 03", fetched, i)
+		}
+	}
+	// no chunk is kept once read, but the last one read
+	if cached := readerAt.CachedBytes(); cached != 1024 {
+		t.Errorf("reader holds %d bytes once all read, expect the last chunk read", cached)
+	}
+
+}
+
+func BenchmarkSequentialReadWithSynchronousPrefetch(b *testing.B) {
+
+	const chunkSize, chunkCount = 64 * 1024, 16
+	chunks := make(map[string][]byte)
+	var chunkViews []*ChunkView
+	for i := 0; i < chunkCount; i++ {
+		fileId := fmt.Sprintf("1,7c%02x", i)
+		chunks[fileId] = randomBytes(chunkSize)
+		chunkViews = append(chunkViews, &ChunkView{FileId: fileId, Size: chunkSize, ChunkSize: chunkSize, LogicOffset: int64(i * chunkSize)})
+	}
+	server := newTestVolumeServer(chunks)
+	defer server.Close()
+
+	buf := make([]byte, 16*1024)
+	b.SetBytes(chunkSize * chunkCount)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, newMapChunkCache(), chunkSize*chunkCount)
+		readerAt.SetPrefetchDepth(2)
+		readerAt.SetSynchronousPrefetch(true)
+		for offset := int64(0); offset < chunkSize*chunkCount; offset += int64(len(buf)) {
+			if _, err := readerAt.ReadAt(buf, offset); err != nil {
+				b.Fatalf("read at %d: %v", offset, err)
+			}
+		}
+	}
+
+}
+
+func TestPausedPrefetch(t *testing.T) {
+
+	defer func(original *PrefetchScheduler) { prefetchScheduler = original }(prefetchScheduler)
+	prefetchScheduler = NewPrefetchScheduler(DefaultPrefetchLimit)
+
+	var arrived []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived = append(arrived, strings.TrimPrefix(r.URL.Path, "/1,7d"))
+		w.Write(make([]byte, 1024))
+	}))
+	defer server.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+
+	var chunkViews []*ChunkView
+	for i := 0; i < 6; i++ {
+		chunkViews = append(chunkViews, &ChunkView{FileId: fmt.Sprintf("1,7d%02x", i), Size: 1024, ChunkSize: 1024, LogicOffset: int64(i * 1024)})
+	}
+	readerAt := NewChunkReaderAtFromClient(lookupFn, chunkViews, newMapChunkCache(), 6*1024)
+	readerAt.SetPrefetchDepth(2)
+	readerAt.SetSynchronousPrefetch(true)
+
+	// the reads go on, fetching only the chunks they read
+	PausePrefetch()
+	for _, offset := range []int64{0, 1024} {
+		if _, err := readerAt.ReadAt(make([]byte, 100), offset); err != nil {
+			t.Fatalf("read at %d while paused: %v", offset, err)
+		}
+	}
+	if fetched := strings.Join(arrived, " "); fetched != "00 01" {
+		t.Errorf("fetched %s while paused, expect 00 01", fetched)
+	}
+
+	// and so are the other background jobs
+	ran := make(chan struct{}, 1)
+	prefetchScheduler.Go(func() { ran <- struct{}{} })
+
+	ResumePrefetch()
+	arrived = nil
+	if _, err := readerAt.ReadAt(make([]byte, 100), 2048); err != nil {
+		t.Fatalf("read after resuming: %v", err)
+	}
+	if fetched := strings.Join(arrived, " "); fetched != "02 03 04" {
+		t.Errorf("fetched %s after resuming, expect 02 03 04", fetched)
+	}
+	select {
+	case <-ran:
+		t.Errorf("a job scheduled while paused ran")
+	default:
+	}
+
+}
@@ -0,0 +1,229 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
+)
+
+// DefaultPrewarmConcurrency is the number of chunks a CachePrewarmer fetches at the same time, if not set.
+const DefaultPrewarmConcurrency = 4
+
+type PrewarmOptions struct {
+	// the chunks fetched at the same time, DefaultPrewarmConcurrency if not set
+	Concurrency int
+	// the bytes fetched per second over all the chunks, unlimited if 0
+	BytesPerSecond int64
+	// if set, called after each file is resolved and each chunk is done, one call at a time
+	Progress func(PrewarmProgress)
+	// the key of the chunks in the cache, the same as ChunkReadAt.SetCacheKeyFn of the readers to warm, the file id if nil
+	CacheKey func(fileId string) string
+}
+
+// PrewarmProgress counts the files and chunks of a Prewarm call done so far.
+type PrewarmProgress struct {
+	Files         int // the files to warm
+	FilesResolved int
+	FilesFailed   int
+	Chunks        int // the distinct chunks of the files resolved so far
+	ChunksCached  int // skipped, as already in the cache
+	ChunksFetched int
+	ChunksFailed  int
+	BytesFetched  int64
+}
+
+func (p PrewarmProgress) String() string {
+	return fmt.Sprintf("%d/%d files, %d/%d chunks fetched, %d cached already, %d failed, %d bytes",
+		p.FilesResolved, p.Files, p.ChunksFetched, p.Chunks, p.ChunksCached, p.FilesFailed+p.ChunksFailed, p.BytesFetched)
+}
+
+// CachePrewarmer fetches the chunks of a known hot set of files into the shared chunk cache, e.g. at startup.
+type CachePrewarmer struct {
+	filerClient filer_pb.FilerClient
+	lookupFn    wdclient.LookupFileIdFunctionType
+	chunkCache  chunk_cache.ChunkCache
+	opts        PrewarmOptions
+}
+
+func NewCachePrewarmer(filerClient filer_pb.FilerClient, chunkCache chunk_cache.ChunkCache, opts *PrewarmOptions) *CachePrewarmer {
+	p := &CachePrewarmer{
+		filerClient: filerClient,
+		lookupFn:    LookupFn(filerClient),
+		chunkCache:  chunkCache,
+	}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.Concurrency <= 0 {
+		p.opts.Concurrency = DefaultPrewarmConcurrency
+	}
+	return p
+}
+
+type prewarmRun struct {
+	sync.Mutex
+	progress PrewarmProgress
+	firstErr error
+	onUpdate func(PrewarmProgress)
+	seen     map[string]struct{}
+	// the time the bytes fetched so far are paced until
+	pacedUntil time.Time
+}
+
+// Prewarm resolves the files in order, and fetches their chunks not cached yet in the background as they are found.
+// It returns once all the chunks are done or the context is done, with the first error, if any.
+// A file or chunk failing does not stop the others.
+func (p *CachePrewarmer) Prewarm(ctx context.Context, paths []util.FullPath) (PrewarmProgress, error) {
+
+	run := &prewarmRun{
+		progress: PrewarmProgress{Files: len(paths)},
+		onUpdate: p.opts.Progress,
+		seen:     make(map[string]struct{}),
+	}
+	slots := make(chan struct{}, p.opts.Concurrency)
+	var wg sync.WaitGroup
+
+	for _, path := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		chunkViews, err := p.resolve(path)
+		run.update(func(progress *PrewarmProgress) error {
+			if err != nil {
+				progress.FilesFailed++
+				return err
+			}
+			progress.FilesResolved++
+			return nil
+		})
+
+		for _, chunkView := range chunkViews {
+			if !run.add(chunkView.FileId) {
+				continue
+			}
+			if p.chunkCache.GetChunk(p.cacheKey(chunkView.FileId), chunkView.ChunkSize) != nil {
+				run.update(func(progress *PrewarmProgress) error {
+					progress.ChunksCached++
+					return nil
+				})
+				continue
+			}
+			select {
+			case slots <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			wg.Add(1)
+			go func(chunkView *ChunkView) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				p.fetch(ctx, run, chunkView)
+			}(chunkView)
+		}
+	}
+	wg.Wait()
+
+	run.Lock()
+	defer run.Unlock()
+	if run.firstErr == nil {
+		run.firstErr = ctx.Err()
+	}
+	return run.progress, run.firstErr
+}
+
+// resolve returns the chunk views of the whole file, with the chunks of its manifests
+func (p *CachePrewarmer) resolve(path util.FullPath) ([]*ChunkView, error) {
+	entry, err := filer_pb.GetEntry(p.filerClient, path)
+	if err != nil {
+		return nil, fmt.Errorf("prewarm %s: %v", path, err)
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("prewarm %s: %v", path, filer_pb.ErrNotFound)
+	}
+	chunks, _, err := ResolveChunkManifest(p.lookupFn, entry.Chunks, 0, math.MaxInt64)
+	if err != nil {
+		return nil, fmt.Errorf("prewarm %s: %v", path, err)
+	}
+	return ViewFromChunks(p.lookupFn, chunks, 0, math.MaxInt64), nil
+}
+
+func (p *CachePrewarmer) fetch(ctx context.Context, run *prewarmRun, chunkView *ChunkView) {
+	if wait := run.pace(int64(chunkView.ChunkSize), p.opts.BytesPerSecond); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+	}
+	data, err := fetchChunkOfSize(ctx, p.lookupFn, chunkView.FileId, chunkView.CipherKey, chunkView.IsGzipped, chunkView.ChunkSize)
+	if err == nil {
+		p.chunkCache.SetChunk(p.cacheKey(chunkView.FileId), data)
+	} else {
+		glog.V(1).Infof("prewarm chunk %s: %v", chunkView.FileId, err)
+	}
+	run.update(func(progress *PrewarmProgress) error {
+		if err != nil {
+			progress.ChunksFailed++
+			return &ChunkFetchError{FileId: chunkView.FileId, Err: err}
+		}
+		progress.ChunksFetched++
+		progress.BytesFetched += int64(len(data))
+		return nil
+	})
+}
+
+func (p *CachePrewarmer) cacheKey(fileId string) string {
+	if p.opts.CacheKey != nil {
+		return p.opts.CacheKey(fileId)
+	}
+	return fileId
+}
+
+// add counts a chunk the first time it is seen, in any of the files
+func (run *prewarmRun) add(fileId string) bool {
+	run.Lock()
+	defer run.Unlock()
+	if _, found := run.seen[fileId]; found {
+		return false
+	}
+	run.seen[fileId] = struct{}{}
+	run.progress.Chunks++
+	return true
+}
+
+func (run *prewarmRun) update(fn func(progress *PrewarmProgress) error) {
+	run.Lock()
+	defer run.Unlock()
+	if err := fn(&run.progress); err != nil && run.firstErr == nil {
+		run.firstErr = err
+	}
+	if run.onUpdate != nil {
+		run.onUpdate(run.progress)
+	}
+}
+
+// pace reserves the time to fetch size bytes at the rate after the bytes reserved before, and returns how long to wait for it
+func (run *prewarmRun) pace(size int64, bytesPerSecond int64) time.Duration {
+	if bytesPerSecond <= 0 {
+		return 0
+	}
+	run.Lock()
+	defer run.Unlock()
+	now := time.Now()
+	if run.pacedUntil.Before(now) {
+		run.pacedUntil = now
+	}
+	wait := run.pacedUntil.Sub(now)
+	run.pacedUntil = run.pacedUntil.Add(time.Duration(float64(size) / float64(bytesPerSecond) * float64(time.Second)))
+	return wait
+}
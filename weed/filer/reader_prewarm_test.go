@@ -0,0 +1,159 @@
+package filer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"google.golang.org/grpc"
+)
+
+// prewarmFilerClient finds the entries besides the volumes of fakeFilerClient
+type prewarmFilerClient struct {
+	*fakeFilerClient
+	entries map[util.FullPath]*filer_pb.Entry
+}
+
+func (f *prewarmFilerClient) WithFilerClient(streamingMode bool, fn func(filer_pb.SeaweedFilerClient) error) error {
+	return fn(f)
+}
+
+func (f *prewarmFilerClient) LookupDirectoryEntry(ctx context.Context, in *filer_pb.LookupDirectoryEntryRequest, opts ...grpc.CallOption) (*filer_pb.LookupDirectoryEntryResponse, error) {
+	entry, found := f.entries[util.NewFullPath(in.Directory, in.Name)]
+	if !found {
+		return nil, filer_pb.ErrNotFound
+	}
+	return &filer_pb.LookupDirectoryEntryResponse{Entry: entry}, nil
+}
+
+func TestCachePrewarmer(t *testing.T) {
+
+	chunks := map[string][]byte{}
+	var lock sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		data := chunks[strings.TrimPrefix(r.URL.Path, "/")]
+		lock.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		w.Write(data)
+		lock.Lock()
+		inFlight--
+		lock.Unlock()
+	}))
+	defer server.Close()
+
+	entries := map[util.FullPath]*filer_pb.Entry{}
+	newFile := func(path util.FullPath, fileIds ...string) {
+		entry := &filer_pb.Entry{Name: path.Name()}
+		for i, fileId := range fileIds {
+			if _, found := chunks[fileId]; !found {
+				chunks[fileId] = randomBytes(1024)
+			}
+			entry.Chunks = append(entry.Chunks, &filer_pb.FileChunk{FileId: fileId, Offset: int64(i * 1024), Size: 1024, Mtime: 1})
+		}
+		entries[path] = entry
+	}
+	newFile("/hot/a", "8,01", "8,02", "8,03")
+	newFile("/hot/b", "8,04", "8,05", "8,06", "8,07")
+	newFile("/hot/c", "8,02", "8,08")
+
+	filerClient := &prewarmFilerClient{
+		fakeFilerClient: &fakeFilerClient{locations: map[string][]string{"8": {strings.TrimPrefix(server.URL, "http://")}}},
+		entries:         entries,
+	}
+	cache := newMapChunkCache()
+	cache.SetChunk("8,05", chunks["8,05"])
+
+	var updates []PrewarmProgress
+	prewarmer := NewCachePrewarmer(filerClient, cache, &PrewarmOptions{
+		Concurrency: 2,
+		Progress: func(progress PrewarmProgress) {
+			updates = append(updates, progress)
+		},
+	})
+	progress, err := prewarmer.Prewarm(context.Background(), []util.FullPath{"/hot/a", "/hot/b", "/hot/missing", "/hot/c"})
+
+	if err == nil || !strings.Contains(err.Error(), "/hot/missing") {
+		t.Errorf("prewarm error %v, expect the missing file", err)
+	}
+	expected := PrewarmProgress{Files: 4, FilesResolved: 3, FilesFailed: 1, Chunks: 8, ChunksCached: 1, ChunksFetched: 7, BytesFetched: 7 * 1024}
+	if progress != expected {
+		t.Errorf("progress %v, expect %v", progress, expected)
+	}
+	if len(updates) == 0 || updates[len(updates)-1] != progress {
+		t.Errorf("progress reported %d times, last %v", len(updates), updates)
+	}
+	for fileId, data := range chunks {
+		if cached := cache.GetChunk(fileId, 1024); string(cached) != string(data) {
+			t.Errorf("chunk %s not cached", fileId)
+		}
+	}
+	if maxInFlight > 2 {
+		t.Errorf("%d chunks fetched at the same time, limit 2", maxInFlight)
+	}
+
+}
+
+func TestCachePrewarmerPacesBytes(t *testing.T) {
+
+	run := &prewarmRun{}
+	var waits []time.Duration
+	for i := 0; i < 3; i++ {
+		waits = append(waits, run.pace(1024, 10*1024))
+	}
+	// each 1KB takes 100ms at 10KB/s, so the third fetch waits for the first two
+	if waits[0] != 0 || waits[2] < 150*time.Millisecond || waits[2] > 200*time.Millisecond {
+		t.Errorf("paced waits %v, expect 0, ~100ms and ~200ms", waits)
+	}
+
+}
+
+func TestPrewarmedChunksAreReadFromTheCache(t *testing.T) {
+
+	chunks := map[string][]byte{"9,01": randomBytes(1024), "9,02": randomBytes(1024), "9,03": randomBytes(1024)}
+	server := newTestVolumeServer(chunks)
+	defer server.Close()
+	entry := &filer_pb.Entry{Name: "f"}
+	for i, fileId := range []string{"9,01", "9,02", "9,03"} {
+		entry.Chunks = append(entry.Chunks, &filer_pb.FileChunk{FileId: fileId, Offset: int64(i * 1024), Size: 1024, Mtime: 1})
+	}
+	filerClient := &prewarmFilerClient{
+		fakeFilerClient: &fakeFilerClient{locations: map[string][]string{"9": {strings.TrimPrefix(server.URL, "http://")}}},
+		entries:         map[util.FullPath]*filer_pb.Entry{"/warm/f": entry},
+	}
+
+	cache := newMapChunkCache()
+	prewarmer := NewCachePrewarmer(filerClient, cache, &PrewarmOptions{CacheKey: CacheKeyInNamespace("tenant1")})
+	if _, err := prewarmer.Prewarm(context.Background(), []util.FullPath{"/warm/f"}); err != nil {
+		t.Fatalf("prewarm: %v", err)
+	}
+	fetched := atomic.LoadInt32(&server.requests)
+
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, ViewFromChunks(server.lookupFn, entry.Chunks, 0, 3072), cache, 3072)
+	readerAt.SetCacheKeyFn(CacheKeyInNamespace("tenant1"))
+	defer readerAt.Close()
+	// the chunks past the first one, not cached by the reads themselves
+	buf := make([]byte, 2048)
+	if n, err := readerAt.ReadAt(buf, 1024); n != 2048 || err != nil {
+		t.Fatalf("read %d bytes: %v", n, err)
+	}
+	if string(buf[:1024]) != string(chunks["9,02"]) || string(buf[1024:]) != string(chunks["9,03"]) {
+		t.Errorf("read other data than prewarmed")
+	}
+	if requests := atomic.LoadInt32(&server.requests); requests != fetched {
+		t.Errorf("%d chunks fetched again after prewarming", requests-fetched)
+	}
+
+}
@@ -0,0 +1,146 @@
+package filer
+
+import (
+	"context"
+	"sync"
+)
+
+// ReadPriority lets interactive reads get the shared fetch slots ahead of background ones, e.g. scrubbing,
+// when they share the volume servers.
+type ReadPriority int
+
+const (
+	ReadPriorityInteractive ReadPriority = iota
+	ReadPriorityBackground
+)
+
+// interactiveWeight is the number of slots given to interactive waiters for each one given to a background waiter,
+// so that background reads slow down instead of starving.
+const interactiveWeight = 4
+
+// prioritySemaphore hands out a limited number of slots, the freed ones to the waiters by their priority,
+// and among those of the same priority to the nearest one first.
+type prioritySemaphore struct {
+	sync.Mutex
+	limit              int
+	inUse              int
+	waiters            [2][]*slotWaiter // by ReadPriority, then by distance
+	interactiveGranted int              // slots given to interactive waiters while background ones wait
+}
+
+type slotWaiter struct {
+	granted  chan struct{}
+	distance int
+}
+
+func newPrioritySemaphore(limit int) *prioritySemaphore {
+	return &prioritySemaphore{
+		limit: limit,
+	}
+}
+
+func (s *prioritySemaphore) acquire(ctx context.Context, priority ReadPriority) error {
+	return s.acquireAt(ctx, priority, 0)
+}
+
+// acquireAt waits behind the waiters of the same priority at the same or a nearer distance.
+// Once ctx is done, it leaves the queue without a slot and returns the ctx error.
+func (s *prioritySemaphore) acquireAt(ctx context.Context, priority ReadPriority, distance int) error {
+	if priority != ReadPriorityBackground {
+		priority = ReadPriorityInteractive
+	}
+	s.Lock()
+	if s.inUse < s.limit {
+		s.inUse++
+		s.Unlock()
+		return nil
+	}
+	waiter := &slotWaiter{granted: make(chan struct{}), distance: distance}
+	waiters := s.waiters[priority]
+	i := len(waiters)
+	for i > 0 && waiters[i-1].distance > distance {
+		i--
+	}
+	waiters = append(waiters, nil)
+	copy(waiters[i+1:], waiters[i:])
+	waiters[i] = waiter
+	s.waiters[priority] = waiters
+	s.Unlock()
+
+	select {
+	case <-waiter.granted:
+		return nil
+	case <-ctx.Done():
+	}
+	s.Lock()
+	for i, w := range s.waiters[priority] {
+		if w == waiter {
+			s.waiters[priority] = append(s.waiters[priority][:i:i], s.waiters[priority][i+1:]...)
+			s.Unlock()
+			return ctx.Err()
+		}
+	}
+	s.Unlock()
+	// granted while giving up, so the slot goes to the next waiter
+	<-waiter.granted
+	s.release()
+	return ctx.Err()
+}
+
+// release passes the slot to the next waiter, if any
+func (s *prioritySemaphore) release() {
+	s.Lock()
+	defer s.Unlock()
+	interactive, background := s.waiters[ReadPriorityInteractive], s.waiters[ReadPriorityBackground]
+	switch {
+	case len(interactive) > 0 && (len(background) == 0 || s.interactiveGranted < interactiveWeight):
+		if len(background) > 0 {
+			s.interactiveGranted++
+		}
+		close(interactive[0].granted)
+		s.waiters[ReadPriorityInteractive] = interactive[1:]
+	case len(background) > 0:
+		s.interactiveGranted = 0
+		close(background[0].granted)
+		s.waiters[ReadPriorityBackground] = background[1:]
+	default:
+		s.inUse--
+	}
+}
+
+var (
+	chunkFetchLimiter     *prioritySemaphore
+	chunkFetchLimiterLock sync.RWMutex
+)
+
+// SetChunkFetchLimit bounds the chunk fetches of all readers in this process,
+// with the freed slots going to interactive reads ahead of background ones. 0, the default, leaves them unbounded.
+func SetChunkFetchLimit(limit int) {
+	chunkFetchLimiterLock.Lock()
+	defer chunkFetchLimiterLock.Unlock()
+	if limit <= 0 {
+		chunkFetchLimiter = nil
+		return
+	}
+	chunkFetchLimiter = newPrioritySemaphore(limit)
+}
+
+// acquireFetchSlot waits for a slot of the chunk fetch limit, if set, and returns the func to free it.
+// It gives up once ctx is done.
+func acquireFetchSlot(ctx context.Context, priority ReadPriority) (release func(), err error) {
+	chunkFetchLimiterLock.RLock()
+	limiter := chunkFetchLimiter
+	chunkFetchLimiterLock.RUnlock()
+	if limiter == nil {
+		return func() {}, nil
+	}
+	if err = limiter.acquire(ctx, priority); err != nil {
+		return nil, err
+	}
+	return limiter.release, nil
+}
+
+// SetPriority sets the priority of the chunk fetches and prefetches of this reader.
+func (c *ChunkReadAt) SetPriority(priority ReadPriority) {
+	c.priority = priority
+}
@@ -0,0 +1,201 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInteractiveReadAheadOfBackgroundFlood(t *testing.T) {
+
+	SetChunkFetchLimit(2)
+	defer SetChunkFetchLimit(0)
+	limiter := chunkFetchLimiter
+
+	data := randomBytes(1024)
+	arrived, release := make(chan string, 16), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fileId := strings.TrimPrefix(r.URL.Path, "/")
+		if !strings.HasPrefix(fileId, "2,") {
+			<-release // the background reads are held by the volume server
+		}
+		arrived <- fileId
+		w.Write(data)
+	}))
+	defer server.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+
+	read := func(fileId string, priority ReadPriority, done chan error) {
+		readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+			{FileId: fileId, Size: 1024, ChunkSize: 1024, LogicOffset: 0},
+		}, newMapChunkCache(), 1024)
+		readerAt.SetPriority(priority)
+		_, err := readerAt.ReadAt(make([]byte, 1024), 0)
+		if err == io.EOF {
+			err = nil
+		}
+		done <- err
+	}
+	waitFor := func(what string, cond func() bool) {
+		for start := time.Now(); !cond(); time.Sleep(time.Millisecond) {
+			if time.Since(start) > 5*time.Second {
+				t.Fatalf("timeout waiting for %s", what)
+			}
+		}
+	}
+	waiting := func(priority ReadPriority) int {
+		limiter.Lock()
+		defer limiter.Unlock()
+		return len(limiter.waiters[priority])
+	}
+
+	backgroundDone := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go read(fmt.Sprintf("1,6a%02x", i), ReadPriorityBackground, backgroundDone)
+	}
+	waitFor("background reads to queue", func() bool { return waiting(ReadPriorityBackground) == 8 })
+
+	interactiveDone := make(chan error, 1)
+	go read("2,6b01", ReadPriorityInteractive, interactiveDone)
+	waitFor("interactive read to queue", func() bool { return waiting(ReadPriorityInteractive) == 1 })
+
+	// the first freed slot goes to the interactive read, ahead of the 8 queued background reads
+	release <- struct{}{}
+	select {
+	case err := <-interactiveDone:
+		if err != nil {
+			t.Errorf("interactive read: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("interactive read not served after one background read finished")
+	}
+	first, second := <-arrived, <-arrived
+	if !strings.HasPrefix(first, "1,") || second != "2,6b01" {
+		t.Errorf("served %s then %s, expect the interactive read right after the released background read", first, second)
+	}
+
+	close(release)
+	for i := 0; i < 10; i++ {
+		if err := <-backgroundDone; err != nil {
+			t.Errorf("background read: %v", err)
+		}
+	}
+
+}
+
+func TestPrioritySemaphoreDoesNotStarveBackground(t *testing.T) {
+
+	s := newPrioritySemaphore(1)
+	s.acquire(context.Background(), ReadPriorityInteractive)
+
+	var order []ReadPriority
+	granted := make(chan ReadPriority, 20)
+	enqueue := func(priority ReadPriority, count int) {
+		for i := 0; i < count; i++ {
+			go func() {
+				s.acquire(context.Background(), priority)
+				granted <- priority
+			}()
+			want := i + 1
+			for {
+				s.Lock()
+				n := len(s.waiters[priority])
+				s.Unlock()
+				if n == want {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}
+	enqueue(ReadPriorityBackground, 2)
+	enqueue(ReadPriorityInteractive, 10)
+
+	for i := 0; i < 12; i++ {
+		s.release()
+		order = append(order, <-granted)
+	}
+	// 4 interactive, 1 background, 4 interactive, 1 background, then the remaining interactive ones
+	if order[interactiveWeight] != ReadPriorityBackground || order[2*interactiveWeight+1] != ReadPriorityBackground {
+		t.Errorf("unexpected grant order %v", order)
+	}
+
+}
+
+func TestPrioritySemaphoreWaitCancelled(t *testing.T) {
+
+	s := newPrioritySemaphore(1)
+	s.acquire(context.Background(), ReadPriorityInteractive)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error, 1)
+	go func() {
+		cancelled <- s.acquire(ctx, ReadPriorityInteractive)
+	}()
+	for {
+		s.Lock()
+		n := len(s.waiters[ReadPriorityInteractive])
+		s.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-cancelled; err != context.Canceled {
+		t.Errorf("cancelled wait returned %v", err)
+	}
+	if n := len(s.waiters[ReadPriorityInteractive]); n != 0 {
+		t.Errorf("%d waiters left after cancelling", n)
+	}
+
+	// the slot is handed back, not to the cancelled waiter
+	s.release()
+	if err := s.acquire(context.Background(), ReadPriorityBackground); err != nil {
+		t.Errorf("acquire after cancelling: %v", err)
+	}
+	if s.inUse != 1 {
+		t.Errorf("%d slots in use, expect 1", s.inUse)
+	}
+
+}
+
+func TestCancelledReadStopsWaitingForAFetchSlot(t *testing.T) {
+
+	SetChunkFetchLimit(1)
+	defer SetChunkFetchLimit(0)
+
+	server := newTestVolumeServer(map[string][]byte{"1,0d01": randomBytes(4096)})
+	defer server.Close()
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{
+		{FileId: "1,0d01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+	}, newMapChunkCache(), 4096)
+
+	// the only slot is taken
+	release, err := acquireFetchSlot(context.Background(), ReadPriorityInteractive)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := readerAt.doFetchRangeChunkData(ctx, readerAt.chunkViews[0], 0, 100); err != context.DeadlineExceeded {
+		t.Errorf("range fetch waiting for a slot: %v, expect the deadline exceeded", err)
+	}
+	if err := readerAt.verifyChunk(ctx, readerAt.chunkViews[0], 4096); err != context.DeadlineExceeded {
+		t.Errorf("verify waiting for a slot: %v, expect the deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("waited %v for a slot past the deadline", elapsed)
+	}
+
+}
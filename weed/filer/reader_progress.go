@@ -0,0 +1,55 @@
+package filer
+
+import (
+	"sync"
+)
+
+// ProgressFn is told the bytes delivered by a reader so far, and the file size.
+type ProgressFn func(delivered, fileSize int64)
+
+type readProgress struct {
+	fn       ProgressFn
+	interval int64
+
+	delivered    int64 // guarded by the reader lock
+	lastReported int64 // guarded by the reader lock, the delivered bytes last scheduled for report
+
+	reportLock sync.Mutex
+	reported   int64 // guarded by reportLock
+}
+
+// SetProgressFn reports the bytes delivered by the reads, e.g. for backup tools streaming a large file.
+// The fn is called every interval bytes and when a read reaches the end of the file, outside of the reader lock,
+// with the delivered bytes never decreasing. A nil fn stops the reports.
+func (c *ChunkReadAt) SetProgressFn(fn ProgressFn, interval int64) {
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+	if fn == nil {
+		c.progress = nil
+		return
+	}
+	c.progress = &readProgress{fn: fn, interval: interval}
+}
+
+// trackProgress counts the delivered bytes under the reader lock, and returns a report to make after unlocking.
+func (c *ChunkReadAt) trackProgress(offset int64, n int) (report func()) {
+	progress := c.progress
+	if progress == nil || n <= 0 {
+		return nil
+	}
+	progress.delivered += int64(n)
+	if progress.delivered-progress.lastReported < progress.interval && offset+int64(n) < c.fileSize {
+		return nil
+	}
+	progress.lastReported = progress.delivered
+	delivered, fileSize := progress.delivered, c.fileSize
+	return func() {
+		progress.reportLock.Lock()
+		defer progress.reportLock.Unlock()
+		// a concurrent read may have reported more already
+		if delivered > progress.reported {
+			progress.reported = delivered
+			progress.fn(delivered, fileSize)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package filer
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestReaderAtReportsProgress(t *testing.T) {
+
+	chunks := make(map[string][]byte)
+	var chunkViews []*ChunkView
+	for i := 0; i < 10; i++ {
+		fileId := fmt.Sprintf("1,4a%02x", i)
+		chunks[fileId] = randomBytes(1000)
+		chunkViews = append(chunkViews, &ChunkView{FileId: fileId, Size: 1000, ChunkSize: 1000, LogicOffset: int64(i * 1000)})
+	}
+	server := newTestVolumeServer(chunks)
+	defer server.Close()
+
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, newMapChunkCache(), 10000)
+	var reports []int64
+	readerAt.SetProgressFn(func(delivered, fileSize int64) {
+		if fileSize != 10000 {
+			t.Errorf("reported file size %d", fileSize)
+		}
+		reports = append(reports, delivered)
+	}, 2048)
+
+	buf := make([]byte, 300)
+	for offset := int64(0); offset < 10000; offset += 300 {
+		if _, err := readerAt.ReadAt(buf, offset); err != nil && err != io.EOF {
+			t.Fatalf("read at %d: %v", offset, err)
+		}
+	}
+
+	if len(reports) == 0 || len(reports) > 10000/2048+1 {
+		t.Fatalf("unexpected number of reports %v", reports)
+	}
+	for i := 1; i < len(reports); i++ {
+		if reports[i] <= reports[i-1] {
+			t.Errorf("progress not increasing: %v", reports)
+		}
+	}
+	if last := reports[len(reports)-1]; last != 10000 {
+		t.Errorf("final progress %d, expect the file size", last)
+	}
+
+}
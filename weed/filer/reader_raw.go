@@ -0,0 +1,68 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// ReadRawChunk returns the chunk of the chunk view as stored on the volume server, neither decrypted nor
+// decompressed, e.g. to copy it to another server without decoding and encoding it again.
+// The stored form of an encrypted or compressed chunk differs in size from its content, so its offsets are
+// not those of the file: the chunk view's Offset, Size and LogicOffset do not apply, and the whole stored
+// chunk is returned. The chunk cache only keeps decoded chunks, so it is neither read nor filled.
+func (c *ChunkReadAt) ReadRawChunk(ctx context.Context, chunkView *ChunkView) ([]byte, error) {
+
+	if c.lookupFileId == nil {
+		return nil, fmt.Errorf("read raw chunk %s: no volume lookup", chunkView.FileId)
+	}
+
+	urlStrings, err := c.lookupFileId(chunkView.FileId)
+	if err != nil {
+		return nil, lookupError(chunkView.FileId, err)
+	}
+
+	var data []byte
+	for _, urlString := range urlStrings {
+		if data, err = fetchRawChunk(ctx, urlString); err == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		readFailureLog.Logf("read "+replicaServer(urlString), "read raw %s failed, err: %v", urlString, err)
+	}
+	if err == nil {
+		err = fmt.Errorf("no location of %s", chunkView.FileId)
+	}
+	return nil, &ChunkFetchError{FileId: chunkView.FileId, Err: &ReplicasFailedError{Urls: urlStrings, Err: err}}
+}
+
+func fetchRawChunk(ctx context.Context, urlString string) ([]byte, error) {
+
+	req, err := http.NewRequestWithContext(ctx, "GET", readDeletedUrl(urlString), nil)
+	if err != nil {
+		return nil, err
+	}
+	// a compressed chunk is sent as stored, and the transport leaves it compressed
+	// since the header is set explicitly
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	release, err := acquireServerSlot(ctx, urlString)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	resp, err := util.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer util.CloseResponse(resp)
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("%s: %s", urlString, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
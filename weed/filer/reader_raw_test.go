@@ -0,0 +1,63 @@
+package filer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadRawChunk(t *testing.T) {
+
+	content := bytes.Repeat([]byte("compressible content "), 1000)
+	var stored bytes.Buffer
+	gw := gzip.NewWriter(&stored)
+	gw.Write(content)
+	gw.Close()
+
+	// a volume server sends a compressed needle as is to clients accepting gzip
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/1,0f01" {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(stored.Bytes())
+			return
+		}
+		w.Write(content)
+	}))
+	defer server.Close()
+
+	chunkView := &ChunkView{FileId: "1,0f01", Size: uint64(len(content)), ChunkSize: uint64(len(content)), IsGzipped: true}
+	readerAt := NewChunkReaderAtFromClient(func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}, []*ChunkView{chunkView}, newMapChunkCache(), int64(len(content)))
+
+	raw, err := readerAt.ReadRawChunk(context.Background(), chunkView)
+	if err != nil {
+		t.Fatalf("read raw: %v", err)
+	}
+	if !bytes.Equal(raw, stored.Bytes()) {
+		t.Errorf("read %d raw bytes, expect the %d stored bytes", len(raw), stored.Len())
+	}
+
+	data := make([]byte, len(content))
+	if n, err := readerAt.ReadAt(data, 0); n != len(content) || (err != nil && err != io.EOF) {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Errorf("read compressed bytes in the normal mode")
+	}
+
+	if _, err = readerAt.ReadRawChunk(context.Background(), &ChunkView{FileId: "1,0f02"}); !errors.Is(err, ErrChunkFetch) {
+		t.Errorf("read a missing raw chunk: %v, expect a chunk fetch error", err)
+	}
+
+}
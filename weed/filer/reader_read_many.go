@@ -0,0 +1,62 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// ReadRequest is one range of a ReadAtMany batch, read into Buf from the file offset Offset.
+type ReadRequest struct {
+	Offset int64
+	Buf    []byte
+
+	// set by ReadAtMany, as by ReadAt, with io.EOF for a range cut at the end of the file
+	N   int
+	Err error
+}
+
+// BatchErrorPolicy tells ReadAtMany what to do once a range fails.
+type BatchErrorPolicy int
+
+const (
+	// BatchAbortOnError fails the whole batch with the first failing range, leaving the ranges after it unread.
+	BatchAbortOnError BatchErrorPolicy = iota
+	// BatchBestEffort reads all the ranges, leaving the error of each one in its request.
+	BatchBestEffort
+)
+
+// BatchReadError is the range failing a batch read with BatchAbortOnError.
+type BatchReadError struct {
+	Index  int // of the request
+	Offset int64
+	Err    error
+}
+
+func (e *BatchReadError) Error() string {
+	return fmt.Sprintf("read range %d at %d: %v", e.Index, e.Offset, e.Err)
+}
+
+func (e *BatchReadError) Unwrap() error {
+	return e.Err
+}
+
+// ReadAtMany reads the ranges in order. A range reaching the end of the file is not a failure, and only gets io.EOF
+// in its request. With BatchAbortOnError, a failing range stops the batch with a BatchReadError. With BatchBestEffort,
+// the failures are left in the requests and nil is returned, unless the context is done.
+func (c *ChunkReadAt) ReadAtMany(ctx context.Context, requests []*ReadRequest, policy BatchErrorPolicy) error {
+
+	for i, request := range requests {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		request.N, request.Err = c.ReadAtWithContext(ctx, request.Buf, request.Offset)
+		if request.Err == nil || request.Err == io.EOF {
+			continue
+		}
+		if policy == BatchAbortOnError {
+			return &BatchReadError{Index: i, Offset: request.Offset, Err: request.Err}
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,64 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func newReadManyRequests() []*ReadRequest {
+	// the second range is in the missing chunk, the last one reaches the end of the file
+	return []*ReadRequest{
+		{Offset: 100, Buf: make([]byte, 200)},
+		{Offset: 1100, Buf: make([]byte, 200)},
+		{Offset: 2100, Buf: make([]byte, 200)},
+		{Offset: 2900, Buf: make([]byte, 400)},
+	}
+}
+
+func TestReaderAtReadAtMany(t *testing.T) {
+
+	first, third := randomBytes(1024), randomBytes(1024)
+	server := newTestVolumeServer(map[string][]byte{"9,1a01": first, "9,1a03": third})
+	defer server.Close()
+	newReader := func() *ChunkReadAt {
+		return NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{
+			{FileId: "9,1a01", Size: 1024, ChunkSize: 1024, LogicOffset: 0},
+			{FileId: "9,1a02", Size: 1024, ChunkSize: 1024, LogicOffset: 1024},
+			{FileId: "9,1a03", Size: 1024, ChunkSize: 1024, LogicOffset: 2048},
+		}, newMapChunkCache(), 3072)
+	}
+
+	requests := newReadManyRequests()
+	err := newReader().ReadAtMany(context.Background(), requests, BatchAbortOnError)
+	var batchErr *BatchReadError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, ErrChunkFetch) {
+		t.Fatalf("abort on error: %v, expect the second range to fail the batch", err)
+	}
+	if requests[0].N != 200 || requests[0].Err != nil || !bytes.Equal(requests[0].Buf, first[100:300]) {
+		t.Errorf("abort on error: first range n=%d err=%v", requests[0].N, requests[0].Err)
+	}
+	if requests[2].N != 0 || requests[3].N != 0 {
+		t.Errorf("abort on error: ranges after the failing one were read")
+	}
+
+	requests = newReadManyRequests()
+	if err := newReader().ReadAtMany(context.Background(), requests, BatchBestEffort); err != nil {
+		t.Fatalf("best effort: %v", err)
+	}
+	if requests[0].N != 200 || requests[0].Err != nil || !bytes.Equal(requests[0].Buf, first[100:300]) {
+		t.Errorf("best effort: first range n=%d err=%v", requests[0].N, requests[0].Err)
+	}
+	if !errors.Is(requests[1].Err, ErrChunkFetch) {
+		t.Errorf("best effort: failing range err=%v, expect a chunk fetch error", requests[1].Err)
+	}
+	if requests[2].N != 200 || requests[2].Err != nil || !bytes.Equal(requests[2].Buf, third[52:252]) {
+		t.Errorf("best effort: third range n=%d err=%v", requests[2].N, requests[2].Err)
+	}
+	if requests[3].N != 172 || requests[3].Err != io.EOF || !bytes.Equal(requests[3].Buf[:172], third[852:]) {
+		t.Errorf("best effort: last range n=%d err=%v, expect 172 bytes and EOF", requests[3].N, requests[3].Err)
+	}
+
+}
@@ -0,0 +1,107 @@
+package filer
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/operation"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// ReadRepair is a chunk read from a healthy replica after some other replicas failed to serve it.
+type ReadRepair struct {
+	FileId        string
+	CipherKey     []byte
+	IsGzipped     bool
+	Data          []byte // the chunk content as read, decrypted and uncompressed, not to be modified
+	HealthyServer string
+	FailedServers []string
+}
+
+type ReadRepairFn func(repair *ReadRepair)
+
+// readRepairConcurrency limits the repairs running at a time. Repairs beyond it are dropped,
+// the chunk being repaired on a later read.
+const readRepairConcurrency = 4
+
+var readRepairSlots = make(chan struct{}, readRepairConcurrency)
+
+// SetReadRepairFn restores the replicas failing to serve whole chunk fetches with the data read from a healthy one,
+// e.g. with RewriteFailedReplicas. The fn runs in the background after the read, and a nil fn disables the repairs.
+// Fetches through a ReadHedger are not repaired, since it cancels the requests to slower replicas.
+func (c *ChunkReadAt) SetReadRepairFn(fn ReadRepairFn) {
+	c.readRepairFn = fn
+}
+
+func scheduleReadRepair(readRepairFn ReadRepairFn, repair *ReadRepair) {
+	select {
+	case readRepairSlots <- struct{}{}:
+	default:
+		glog.V(1).Infof("skip repairing %s on %v: too many repairs", repair.FileId, repair.FailedServers)
+		return
+	}
+	go func() {
+		defer func() { <-readRepairSlots }()
+		readRepairFn(repair)
+	}()
+}
+
+// RewriteFailedReplicas writes the chunk back to each failed replica, without replicating it further.
+// The name, mime type and modified time of the needle are taken from the healthy replica,
+// and the failed replica applies the ttl of its volume, as it does for the replicated writes.
+// Encrypted chunks are skipped, since the chunk can only be uploaded under a new cipher key.
+func RewriteFailedReplicas(repair *ReadRepair) {
+	if len(repair.CipherKey) > 0 {
+		glog.V(1).Infof("skip repairing encrypted %s on %v", repair.FileId, repair.FailedServers)
+		return
+	}
+	data := repair.Data
+	if repair.IsGzipped {
+		gzipped, err := util.GzipData(data)
+		if err != nil {
+			glog.Warningf("repair %s: gzip: %v", repair.FileId, err)
+			return
+		}
+		data = gzipped
+	}
+	filename, mimeType, modifiedTime := readNeedleMetadata(repair.HealthyServer, repair.FileId)
+	for _, server := range repair.FailedServers {
+		uploadUrl := fmt.Sprintf("http://%s/%s?type=replicate", server, repair.FileId)
+		if !modifiedTime.IsZero() {
+			uploadUrl = fmt.Sprintf("%s&ts=%d", uploadUrl, modifiedTime.Unix())
+		}
+		_, err := operation.UploadData(data, &operation.UploadOption{
+			UploadUrl:         uploadUrl,
+			Filename:          filename,
+			MimeType:          mimeType,
+			IsInputCompressed: repair.IsGzipped,
+		})
+		if err != nil {
+			glog.Warningf("repair %s on %s: %v", repair.FileId, server, err)
+			continue
+		}
+		glog.V(0).Infof("repaired %s on %s from %s", repair.FileId, server, repair.HealthyServer)
+	}
+}
+
+// readNeedleMetadata gets the needle metadata from the response headers of the volume server.
+// A failed request leaves the metadata empty, the chunk content being rewritten all the same.
+func readNeedleMetadata(server, fileId string) (filename, mimeType string, modifiedTime time.Time) {
+	header, err := util.Head(fmt.Sprintf("http://%s/%s", server, fileId))
+	if err != nil {
+		glog.V(1).Infof("repair %s: read metadata from %s: %v", fileId, server, err)
+		return
+	}
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		if unescaped, err := url.QueryUnescape(params["filename"]); err == nil {
+			filename = unescaped
+		}
+	}
+	mimeType = header.Get("Content-Type")
+	modifiedTime, _ = http.ParseTime(header.Get("Last-Modified"))
+	return
+}
@@ -0,0 +1,102 @@
+package filer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestReaderAtRepairsFailedReplica(t *testing.T) {
+
+	data := randomBytes(4096)
+	lastModified := time.Unix(1600000000, 0)
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `inline; filename="a+b.txt"`)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+		w.Write(data)
+	}))
+	defer healthy.Close()
+	healthyUrl, _ := url.Parse(healthy.URL)
+
+	// the failed replica can not serve the chunk, but takes uploads
+	uploads := make(chan []byte, 1)
+	failed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Path != "/7,0f01" || r.FormValue("type") != "replicate" {
+			t.Errorf("unexpected upload %s", r.URL)
+		}
+		if r.FormValue("ts") != "1600000000" {
+			t.Errorf("upload with modified time %q, expect 1600000000", r.FormValue("ts"))
+		}
+		file, fileHeader, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("upload form: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if fileHeader.Filename != "a b.txt" || fileHeader.Header.Get("Content-Type") != "text/plain" {
+			t.Errorf("upload as %q %q, expect the name and mime type of the needle", fileHeader.Filename, fileHeader.Header.Get("Content-Type"))
+		}
+		uploaded, _ := io.ReadAll(file)
+		if fileHeader.Header.Get("Content-Encoding") == "gzip" {
+			uploaded, _ = util.DecompressData(uploaded)
+		}
+		uploads <- uploaded
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"size":4096}`))
+	}))
+	defer failed.Close()
+	failedUrl, _ := url.Parse(failed.URL)
+
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{failed.URL + "/" + fileId, healthy.URL + "/" + fileId}, nil
+	}
+	readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+		{FileId: "7,0f01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+	}, newMapChunkCache(), 4096)
+	repairs := make(chan *ReadRepair, 1)
+	readerAt.SetReadRepairFn(func(repair *ReadRepair) {
+		repairs <- repair
+		RewriteFailedReplicas(repair)
+	})
+
+	buf := make([]byte, 4096)
+	if n, err := readerAt.ReadAt(buf, 0); n != 4096 || (err != nil && err != io.EOF) {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+
+	select {
+	case repair := <-repairs:
+		if repair.FileId != "7,0f01" || repair.HealthyServer != healthyUrl.Host {
+			t.Errorf("unexpected repair %+v", repair)
+		}
+		if len(repair.FailedServers) != 1 || repair.FailedServers[0] != failedUrl.Host {
+			t.Errorf("repair targets %v, expect [%s]", repair.FailedServers, failedUrl.Host)
+		}
+		if !bytes.Equal(repair.Data, data) {
+			t.Errorf("repair with data other than the healthy replica's")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no repair after a failover")
+	}
+
+	select {
+	case uploaded := <-uploads:
+		if !bytes.Equal(uploaded, data) {
+			t.Errorf("rewrote %d bytes other than the healthy replica's", len(uploaded))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("failed replica not rewritten")
+	}
+
+}
@@ -0,0 +1,96 @@
+package filer
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
+)
+
+// UnderReplicatedEvent tells that a read could not get a chunk from some of the replicas of its volume.
+type UnderReplicatedEvent struct {
+	VolumeId      string
+	FileId        string
+	Replicas      int      // the number of replicas returned by the lookup
+	FailedServers []string // the servers that did not serve the chunk
+}
+
+type UnderReplicatedFn func(event *UnderReplicatedEvent)
+
+// SetUnderReplicatedFn reports whole chunk fetches that found unreachable replicas,
+// e.g. for an operator tool to trigger re-replication of the volume. A nil fn disables the reports.
+// Fetches through a ReadHedger are not checked, since it cancels the requests to slower replicas.
+func (c *ChunkReadAt) SetUnderReplicatedFn(fn UnderReplicatedFn) {
+	c.underReplicatedFn = fn
+}
+
+// replicaFetchFn fetches a whole chunk from its replicas, calling onAttempt with the outcome of each request to one
+type replicaFetchFn func(urlStrings []string, onAttempt func(urlString string, err error)) ([]byte, error)
+
+// retriedReplicaFetch fetches the chunk over http, retrying the replicas
+func retriedReplicaFetch(ctx context.Context, cipherKey []byte, isGzipped bool, chunkSize uint64) replicaFetchFn {
+	return func(urlStrings []string, onAttempt func(urlString string, err error)) ([]byte, error) {
+		return retriedFetchChunkDataWithAttempts(ctx, urlStrings, cipherKey, isGzipped, true, 0, int(chunkSize), onAttempt)
+	}
+}
+
+// fetchChunkReportingReplicas fetches the chunk with fetchFn, and reports the replicas failing to serve it.
+func fetchChunkReportingReplicas(lookupFileIdFn wdclient.LookupFileIdFunctionType, fileId string, cipherKey []byte, isGzipped bool, underReplicatedFn UnderReplicatedFn, readRepairFn ReadRepairFn, eventSink *readEventSink, fetchFn replicaFetchFn) ([]byte, error) {
+
+	urlStrings, err := lookupFileIdFn(fileId)
+	if err != nil {
+		readErrorLog.Logf("lookup "+VolumeId(fileId), "operation LookupFileId %s failed, err: %v", fileId, err)
+		return nil, lookupError(fileId, err)
+	}
+
+	// a replica that failed once but served the chunk on a retry is healthy
+	lastErrs := make(map[string]error)
+	var servers []string
+	var healthyServer string
+	data, err := fetchFn(urlStrings, func(urlString string, attemptErr error) {
+		server := replicaServer(urlString)
+		if _, found := lastErrs[server]; !found {
+			servers = append(servers, server)
+		}
+		lastErrs[server] = attemptErr
+		if attemptErr == nil {
+			healthyServer = server
+		} else {
+			eventSink.emit(ReadEvent{Type: ReadEventFailover, FileId: fileId, Server: server, Err: attemptErr})
+		}
+	})
+
+	var failedServers []string
+	for _, server := range servers {
+		if lastErrs[server] != nil {
+			failedServers = append(failedServers, server)
+		}
+	}
+	if len(failedServers) > 0 && underReplicatedFn != nil {
+		underReplicatedFn(&UnderReplicatedEvent{
+			VolumeId:      VolumeId(fileId),
+			FileId:        fileId,
+			Replicas:      len(urlStrings),
+			FailedServers: failedServers,
+		})
+	}
+	if len(failedServers) > 0 && err == nil && readRepairFn != nil {
+		scheduleReadRepair(readRepairFn, &ReadRepair{
+			FileId:        fileId,
+			CipherKey:     cipherKey,
+			IsGzipped:     isGzipped,
+			Data:          data,
+			HealthyServer: healthyServer,
+			FailedServers: failedServers,
+		})
+	}
+
+	return data, err
+}
+
+func replicaServer(urlString string) string {
+	if u, err := url.Parse(urlString); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return urlString
+}
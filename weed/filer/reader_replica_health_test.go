@@ -0,0 +1,78 @@
+package filer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestReaderAtReportsMissingReplica(t *testing.T) {
+
+	data := randomBytes(4096)
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer healthy.Close()
+	missing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	missing.Close()
+	missingUrl, _ := url.Parse(missing.URL)
+
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{missing.URL + "/" + fileId, healthy.URL + "/" + fileId}, nil
+	}
+	var events []*UnderReplicatedEvent
+	readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+		{FileId: "7,0f01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+	}, newMapChunkCache(), 4096)
+	readerAt.SetUnderReplicatedFn(func(event *UnderReplicatedEvent) {
+		events = append(events, event)
+	})
+
+	buf := make([]byte, 4096)
+	if n, err := readerAt.ReadAt(buf, 0); n != 4096 || (err != nil && err != io.EOF) {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+	if !bytes.Equal(buf, data) {
+		t.Errorf("expect the data of the healthy replica")
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expect one event, got %d", len(events))
+	}
+	event := events[0]
+	if event.VolumeId != "7" || event.FileId != "7,0f01" || event.Replicas != 2 {
+		t.Errorf("unexpected event %+v", event)
+	}
+	if len(event.FailedServers) != 1 || event.FailedServers[0] != missingUrl.Host {
+		t.Errorf("failed servers %v, expect [%s]", event.FailedServers, missingUrl.Host)
+	}
+
+}
+
+func TestReaderAtHealthyReplicasNotReported(t *testing.T) {
+
+	data := randomBytes(1024)
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer healthy.Close()
+
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{healthy.URL + "/" + fileId, healthy.URL + "/" + fileId}, nil
+	}
+	readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+		{FileId: "7,0f02", Size: 1024, ChunkSize: 1024, LogicOffset: 0},
+	}, newMapChunkCache(), 1024)
+	readerAt.SetUnderReplicatedFn(func(event *UnderReplicatedEvent) {
+		t.Errorf("unexpected event %+v", event)
+	})
+
+	buf := make([]byte, 1024)
+	if n, err := readerAt.ReadAt(buf, 0); n != 1024 || (err != nil && err != io.EOF) {
+		t.Fatalf("read: n=%d err=%v", n, err)
+	}
+
+}
@@ -0,0 +1,104 @@
+package filer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+// SetVolumeLookup makes the reader find the chunks through the volume lookup, which ResolveLocations can fill ahead.
+func (c *ChunkReadAt) SetVolumeLookup(volumeLookup *VolumeLookup) {
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+	c.volumeLookup = volumeLookup
+	c.lookupFileId = volumeLookup.LookupFileId
+	c.pinnedLookup = nil
+}
+
+// ResolveLocations looks up the volumes of all chunks in one request, instead of one request per volume
+// on the first read of each, e.g. for reads across a WAN. It does nothing without a volume lookup.
+func (c *ChunkReadAt) ResolveLocations(ctx context.Context) error {
+
+	volumeLookup, vids := c.volumeLookupOfChunks()
+	if volumeLookup == nil {
+		return nil
+	}
+	return volumeLookup.ResolveVolumes(ctx, vids)
+}
+
+// PinLocations resolves the volumes of all chunks like ResolveLocations, and keeps reading them
+// from the replicas found now, even after the volume lookup finds them elsewhere.
+// While the cluster is rebalancing, a volume can briefly be on both the old and the new servers,
+// and a pinned reader does not bounce between them, fetching the chunks again.
+// Pin before the first read. Volumes of chunk views added later are looked up as usual.
+// It does nothing without a volume lookup.
+func (c *ChunkReadAt) PinLocations(ctx context.Context) error {
+	return c.pinLocations(ctx, false)
+}
+
+// RefreshPinnedLocations looks up the pinned volumes again, ignoring the cached locations,
+// e.g. after the pinned replicas became unreachable. The old pins are kept if the lookup fails.
+func (c *ChunkReadAt) RefreshPinnedLocations(ctx context.Context) error {
+	return c.pinLocations(ctx, true)
+}
+
+func (c *ChunkReadAt) pinLocations(ctx context.Context, refresh bool) error {
+
+	volumeLookup, vids := c.volumeLookupOfChunks()
+	if volumeLookup == nil {
+		return nil
+	}
+
+	if refresh {
+		volumeLookup.forgetLocations(vids)
+	}
+	if err := volumeLookup.ResolveVolumes(ctx, vids); err != nil {
+		return err
+	}
+	locations := volumeLookup.snapshotLocations(vids)
+
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+	if c.pinnedLookup == nil || c.pinnedLookup.volumeLookup != volumeLookup {
+		c.pinnedLookup = &pinnedLookup{volumeLookup: volumeLookup}
+		c.lookupFileId = c.pinnedLookup.LookupFileId
+	}
+	// the pinned lookup is only swapped in on the first pin, so a refresh does not race with the prefetches in flight
+	c.pinnedLookup.pin(locations)
+	return nil
+}
+
+// pinnedLookup finds the pinned volumes at the locations they were pinned to, and the others through the volume lookup
+type pinnedLookup struct {
+	sync.RWMutex
+	volumeLookup *VolumeLookup
+	locations    map[string]*filer_pb.Locations
+}
+
+func (p *pinnedLookup) pin(locations map[string]*filer_pb.Locations) {
+	p.Lock()
+	defer p.Unlock()
+	p.locations = locations
+}
+
+func (p *pinnedLookup) LookupFileId(fileId string) (targetUrls []string, err error) {
+	p.RLock()
+	locations, found := p.locations[VolumeId(fileId)]
+	p.RUnlock()
+	if found {
+		return p.volumeLookup.targetUrls(fileId, locations)
+	}
+	return p.volumeLookup.LookupFileId(fileId)
+}
+
+func (c *ChunkReadAt) volumeLookupOfChunks() (volumeLookup *VolumeLookup, vids []string) {
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+	for _, chunkView := range c.chunkViews {
+		if chunkView.Size > 0 {
+			vids = append(vids, VolumeId(chunkView.FileId))
+		}
+	}
+	return c.volumeLookup, vids
+}
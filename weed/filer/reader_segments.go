@@ -0,0 +1,100 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
+)
+
+// SegmentedReaderAt reads a logical file written as several append-only segments, each a filer entry of its own,
+// as if they were one file of the segments one after another.
+type SegmentedReaderAt struct {
+	segments []*ChunkReadAt
+	offsets  []int64 // where each segment starts in the logical file, followed by the total size
+}
+
+var _ = io.ReaderAt(&SegmentedReaderAt{})
+var _ = io.Closer(&SegmentedReaderAt{})
+
+// NewSegmentedReaderAt joins the readers of the segments, in order.
+// The segments must keep their sizes while read, as their offsets are taken once here.
+func NewSegmentedReaderAt(segments ...*ChunkReadAt) *SegmentedReaderAt {
+	r := &SegmentedReaderAt{
+		segments: segments,
+		offsets:  make([]int64, len(segments)+1),
+	}
+	for i, segment := range segments {
+		segment.readerLock.Lock()
+		r.offsets[i+1] = r.offsets[i] + segment.fileSize
+		segment.readerLock.Unlock()
+	}
+	return r
+}
+
+// NewSegmentedReaderAtFromEntries joins the segment entries, in order, reading them all through the one chunk cache.
+func NewSegmentedReaderAtFromEntries(lookupFn wdclient.LookupFileIdFunctionType, entries []*filer_pb.Entry, chunkCache chunk_cache.ChunkCache) (*SegmentedReaderAt, error) {
+	segments := make([]*ChunkReadAt, 0, len(entries))
+	for _, entry := range entries {
+		segment, err := NewChunkReaderAtFromEntry(lookupFn, entry, chunkCache)
+		if err != nil {
+			return nil, fmt.Errorf("segment %s: %v", entry.Name, err)
+		}
+		segments = append(segments, segment)
+	}
+	return NewSegmentedReaderAt(segments...), nil
+}
+
+// Size is the total size of the segments.
+func (r *SegmentedReaderAt) Size() int64 {
+	return r.offsets[len(r.segments)]
+}
+
+func (r *SegmentedReaderAt) ReadAt(p []byte, offset int64) (n int, err error) {
+	return r.ReadAtWithContext(context.Background(), p, offset)
+}
+
+// ReadAtWithContext reads across the segment boundaries, returning io.EOF if the read goes past the last segment.
+func (r *SegmentedReaderAt) ReadAtWithContext(ctx context.Context, p []byte, offset int64) (n int, err error) {
+
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset %d", offset)
+	}
+
+	// the first segment ending after the offset, skipping the empty ones
+	i := sort.Search(len(r.segments), func(i int) bool {
+		return r.offsets[i+1] > offset
+	})
+	for ; i < len(r.segments) && n < len(p); i++ {
+		segmentOffset := offset + int64(n) - r.offsets[i]
+		part := p[n:min(int64(len(p)), int64(n)+r.offsets[i+1]-r.offsets[i]-segmentOffset)]
+		if len(part) == 0 {
+			continue
+		}
+		read, readErr := r.segments[i].ReadAtWithContext(ctx, part, segmentOffset)
+		n += read
+		if readErr == io.EOF && read < len(part) {
+			// the segment is shorter than it was
+			return n, io.ErrUnexpectedEOF
+		}
+		if readErr != nil && readErr != io.EOF {
+			return n, readErr
+		}
+	}
+
+	if n < len(p) {
+		err = io.EOF
+	}
+	return
+}
+
+func (r *SegmentedReaderAt) Close() error {
+	for _, segment := range r.segments {
+		segment.Close()
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package filer
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+func TestSegmentedReaderAt(t *testing.T) {
+
+	data := randomBytes(3300)
+	server := newTestVolumeServer(map[string][]byte{
+		"1,5a01": data[0:1000],
+		"1,5a02": data[1000:2000],
+		"1,5a03": data[2000:2500],
+		"1,5a04": data[2500:3300],
+	})
+	defer server.Close()
+
+	segment := func(name string, chunks ...*filer_pb.FileChunk) *filer_pb.Entry {
+		entry := &filer_pb.Entry{Name: name, Chunks: chunks, Attributes: &filer_pb.FuseAttributes{}}
+		entry.Attributes.FileSize = FileSize(entry)
+		return entry
+	}
+	entries := []*filer_pb.Entry{
+		segment("part0", &filer_pb.FileChunk{FileId: "1,5a01", Offset: 0, Size: 1000, Mtime: 1}),
+		segment("part1", &filer_pb.FileChunk{FileId: "1,5a02", Offset: 0, Size: 1000, Mtime: 1},
+			&filer_pb.FileChunk{FileId: "1,5a03", Offset: 1000, Size: 500, Mtime: 2}),
+		segment("empty"),
+		segment("part2", &filer_pb.FileChunk{FileId: "1,5a04", Offset: 0, Size: 800, Mtime: 1}),
+	}
+
+	cache := newMapChunkCache()
+	readerAt, err := NewSegmentedReaderAtFromEntries(server.lookupFn, entries, cache)
+	if err != nil {
+		t.Fatalf("new segmented reader: %v", err)
+	}
+	defer readerAt.Close()
+	if readerAt.Size() != int64(len(data)) {
+		t.Fatalf("size %d, expect %d", readerAt.Size(), len(data))
+	}
+
+	for _, tt := range []struct {
+		name         string
+		offset, size int64
+		expectEOF    bool
+	}{
+		{name: "within one segment", offset: 1200, size: 600},
+		{name: "across two boundaries", offset: 900, size: 1700},
+		{name: "from the start of a segment", offset: 2500, size: 100},
+		{name: "whole file", offset: 0, size: int64(len(data))},
+		{name: "past the end", offset: 3200, size: 200, expectEOF: true},
+	} {
+		buf := make([]byte, tt.size)
+		n, err := readerAt.ReadAt(buf, tt.offset)
+		expected := data[tt.offset:min(tt.offset+tt.size, int64(len(data)))]
+		if tt.expectEOF != (err == io.EOF) || !tt.expectEOF && err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+		}
+		if n != len(expected) || !bytes.Equal(buf[:n], expected) {
+			t.Errorf("%s: read %d bytes, expect %d", tt.name, n, len(expected))
+		}
+	}
+
+	// the segments share the cache, which keeps the first chunk of the segment first read from its start,
+	// while the segments first read in their middle are read randomly, fetching only the ranges read
+	if _, found := cache.chunks["1,5a04"]; !found {
+		t.Errorf("chunk 1,5a04 not in the shared cache")
+	}
+	for _, fileId := range []string{"1,5a01", "1,5a02"} {
+		if _, found := cache.chunks[fileId]; found {
+			t.Errorf("chunk %s of a randomly read segment cached", fileId)
+		}
+	}
+
+}
@@ -0,0 +1,165 @@
+package filer
+
+import (
+	"context"
+	"io"
+)
+
+// SequentialChunkReader streams a file from the beginning to the end,
+// keeping up to the readahead depth of chunks fetched ahead of the consumer.
+// It is meant for io.Copy style streaming, while ChunkReadAt serves random reads.
+type SequentialChunkReader struct {
+	reader  *ChunkReadAt
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pieces  chan *sequentialPiece
+	current *sequentialPiece
+	offset  int64 // offset inside the current piece
+}
+
+type sequentialPiece struct {
+	zeros int64
+	data  []byte
+	err   error
+	done  chan struct{}
+}
+
+var _ = io.ReadCloser(&SequentialChunkReader{})
+
+// NewSequentialReader returns a reader of the whole file content.
+// Closing it stops fetching the chunks ahead.
+func (c *ChunkReadAt) NewSequentialReader(readaheadDepth int) *SequentialChunkReader {
+	if readaheadDepth < 1 {
+		readaheadDepth = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &SequentialChunkReader{
+		reader: c,
+		ctx:    ctx,
+		cancel: cancel,
+		pieces: make(chan *sequentialPiece, readaheadDepth),
+	}
+	go r.readAhead()
+	return r
+}
+
+func (r *SequentialChunkReader) readAhead() {
+	defer close(r.pieces)
+
+	var offset int64
+	for _, chunkView := range r.reader.chunkViews {
+		if chunkView.LogicOffset >= r.reader.fileSize {
+			break
+		}
+		if chunkView.Size == 0 {
+			continue
+		}
+		if offset < chunkView.LogicOffset {
+			if !r.push(newZeroPiece(chunkView.LogicOffset - offset)) {
+				return
+			}
+		}
+		piece := &sequentialPiece{
+			done: make(chan struct{}),
+		}
+		if !r.push(piece) {
+			return
+		}
+		go func(chunkView *ChunkView) {
+			piece.data, piece.err = r.reader.fetchChunkView(r.ctx, chunkView)
+			close(piece.done)
+		}(chunkView)
+		offset = chunkView.LogicOffset + int64(chunkView.Size)
+	}
+	if offset < r.reader.fileSize {
+		r.push(newZeroPiece(r.reader.fileSize - offset))
+	}
+}
+
+func newZeroPiece(size int64) *sequentialPiece {
+	piece := &sequentialPiece{
+		zeros: size,
+		done:  make(chan struct{}),
+	}
+	close(piece.done)
+	return piece
+}
+
+func (r *SequentialChunkReader) push(piece *sequentialPiece) bool {
+	select {
+	case r.pieces <- piece:
+		return true
+	case <-r.ctx.Done():
+		return false
+	}
+}
+
+func (r *SequentialChunkReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if r.current == nil {
+			piece, ok := <-r.pieces
+			if !ok {
+				if n > 0 {
+					return n, nil
+				}
+				return 0, io.EOF
+			}
+			select {
+			case <-piece.done:
+			case <-r.ctx.Done():
+				return n, r.ctx.Err()
+			}
+			if piece.err != nil {
+				return n, piece.err
+			}
+			r.current, r.offset = piece, 0
+		}
+		var copied int
+		if r.current.zeros > 0 {
+			copied = int(min(int64(len(p)-n), r.current.zeros-r.offset))
+			for i := n; i < n+copied; i++ {
+				p[i] = 0
+			}
+			if r.offset+int64(copied) >= r.current.zeros {
+				r.current = nil
+			}
+		} else {
+			copied = copy(p[n:], r.current.data[r.offset:])
+			if r.offset+int64(copied) >= int64(len(r.current.data)) {
+				r.current = nil
+			}
+		}
+		r.offset += int64(copied)
+		n += copied
+	}
+	return
+}
+
+func (r *SequentialChunkReader) Close() error {
+	r.cancel()
+	return nil
+}
+
+// fetchChunkView reads the part of the chunk visible in the chunk view.
+func (c *ChunkReadAt) fetchChunkView(ctx context.Context, chunkView *ChunkView) ([]byte, error) {
+	if !chunkView.IsFullChunk() && chunkView.CipherKey == nil && !chunkView.IsGzipped {
+		data, err := c.doFetchRangeChunkData(ctx, chunkView, uint64(chunkView.Offset), chunkView.Size)
+		if err == nil && uint64(len(data)) < chunkView.Size {
+			err = &TruncatedChunkError{Size: int64(len(data)), Expected: int64(chunkView.Size), Offset: chunkView.Offset}
+		}
+		if err != nil {
+			return nil, newChunkFetchError(chunkView, chunkView.Offset, err)
+		}
+		return data, nil
+	}
+	v, err := c.readOneWholeChunk(ctx, chunkView)
+	if err != nil {
+		return nil, newChunkFetchError(chunkView, chunkView.Offset, err)
+	}
+	data := v.([]byte)
+	stop := chunkView.Offset + int64(chunkView.Size)
+	if int64(len(data)) < stop {
+		return nil, newChunkFetchError(chunkView, chunkView.Offset, &TruncatedChunkError{Size: int64(len(data)), Expected: stop})
+	}
+	return data[chunkView.Offset:stop], nil
+}
@@ -0,0 +1,82 @@
+package filer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func newTestSequentialFile(chunkCount, chunkSize int) (*testVolumeServer, []*ChunkView, []byte) {
+	chunks := make(map[string][]byte)
+	var chunkViews []*ChunkView
+	var content []byte
+	for i := 0; i < chunkCount; i++ {
+		fileId := fmt.Sprintf("1,%x0a0b0c0d", i+1)
+		data := randomBytes(chunkSize)
+		chunks[fileId] = data
+		chunkViews = append(chunkViews, &ChunkView{
+			FileId:      fileId,
+			Size:        uint64(chunkSize),
+			ChunkSize:   uint64(chunkSize),
+			LogicOffset: int64(i * chunkSize),
+		})
+		content = append(content, data...)
+	}
+	return newTestVolumeServer(chunks), chunkViews, content
+}
+
+func TestSequentialReader(t *testing.T) {
+
+	server, chunkViews, content := newTestSequentialFile(8, 4096)
+	defer server.Close()
+
+	// leave a hole between the chunks, and after the last chunk
+	chunkViews[3].LogicOffset += 100
+	for _, chunkView := range chunkViews[4:] {
+		chunkView.LogicOffset += 100
+	}
+	expected := append(append(append([]byte{}, content[:3*4096]...), make([]byte, 100)...), content[3*4096:]...)
+	expected = append(expected, make([]byte, 50)...)
+
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, newMapChunkCache(), int64(len(expected)))
+	reader := readerAt.NewSequentialReader(3)
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, reader); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("streamed %d bytes differ from the %d bytes expected", buf.Len(), len(expected))
+	}
+
+}
+
+func BenchmarkSequentialReader(b *testing.B) {
+
+	server, chunkViews, content := newTestSequentialFile(16, 256*1024)
+	defer server.Close()
+
+	b.SetBytes(int64(len(content)))
+	for i := 0; i < b.N; i++ {
+		readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, newMapChunkCache(), int64(len(content)))
+		reader := readerAt.NewSequentialReader(4)
+		io.Copy(io.Discard, reader)
+		reader.Close()
+	}
+
+}
+
+func BenchmarkRepeatedReadAt(b *testing.B) {
+
+	server, chunkViews, content := newTestSequentialFile(16, 256*1024)
+	defer server.Close()
+
+	b.SetBytes(int64(len(content)))
+	for i := 0; i < b.N; i++ {
+		readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, newMapChunkCache(), int64(len(content)))
+		io.Copy(io.Discard, io.NewSectionReader(readerAt, 0, int64(len(content))))
+	}
+
+}
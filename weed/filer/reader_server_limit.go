@@ -0,0 +1,74 @@
+package filer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+var (
+	serverFetchLimit   int
+	serverFetchLimits  map[string]*prioritySemaphore
+	serverFetchLimitMu sync.Mutex
+)
+
+// SetPerServerFetchLimit bounds the requests this process sends to each volume server at the same time,
+// so that the reads of a hot file do not pile up on the servers holding it, while the other servers
+// are fetched from in parallel. The requests over the limit are sent in the order they came.
+// 0, the default, leaves them unbounded.
+func SetPerServerFetchLimit(limit int) {
+	serverFetchLimitMu.Lock()
+	defer serverFetchLimitMu.Unlock()
+	if limit < 0 {
+		limit = 0
+	}
+	// the fetches in flight release the slots of the former limiters
+	serverFetchLimit, serverFetchLimits = limit, nil
+}
+
+// acquireServerSlot waits for a slot of the server behind the url, if limited, and returns the func to free it.
+// Once ctx is done, it stops waiting and returns the ctx error.
+func acquireServerSlot(ctx context.Context, urlString string) (release func(), err error) {
+	serverFetchLimitMu.Lock()
+	if serverFetchLimit == 0 {
+		serverFetchLimitMu.Unlock()
+		return func() {}, nil
+	}
+	server := replicaServer(urlString)
+	limiter, found := serverFetchLimits[server]
+	if !found {
+		if serverFetchLimits == nil {
+			serverFetchLimits = make(map[string]*prioritySemaphore)
+		}
+		limiter = newPrioritySemaphore(serverFetchLimit)
+		serverFetchLimits[server] = limiter
+	}
+	serverFetchLimitMu.Unlock()
+
+	if err = limiter.acquire(ctx, ReadPriorityInteractive); err != nil {
+		return nil, err
+	}
+	return limiter.release, nil
+}
+
+// readUrlAsStream is util.ReadUrlAsStreamWithContext within a slot of the server behind the url.
+// All reads of the volume servers go through it, or take the slot themselves.
+func readUrlAsStream(ctx context.Context, urlString string, cipherKey []byte, isContentGzipped bool, isFullChunk bool, offset int64, size int, fn func(data []byte)) (retryable bool, err error) {
+	release, err := acquireServerSlot(ctx, urlString)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	return util.ReadUrlAsStreamWithContext(ctx, urlString, cipherKey, isContentGzipped, isFullChunk, offset, size, fn)
+}
+
+// resumeUrlAsStream is util.ResumeUrlAsStreamWithContext within a slot of the server behind the url.
+func resumeUrlAsStream(ctx context.Context, urlString string, offset int64, size int, fn func(data []byte)) (retryable bool, err error) {
+	release, err := acquireServerSlot(ctx, urlString)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+	return util.ResumeUrlAsStreamWithContext(ctx, urlString, offset, size, fn)
+}
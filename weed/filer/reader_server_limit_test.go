@@ -0,0 +1,131 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingServer serves the chunk once unblocked, counting the requests in flight
+type blockingServer struct {
+	*httptest.Server
+	inFlight    int32
+	maxInFlight int32
+}
+
+func newBlockingServer(data []byte, unblocked <-chan struct{}) *blockingServer {
+	s := &blockingServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight := atomic.AddInt32(&s.inFlight, 1)
+		defer atomic.AddInt32(&s.inFlight, -1)
+		for {
+			maxInFlight := atomic.LoadInt32(&s.maxInFlight)
+			if inFlight <= maxInFlight || atomic.CompareAndSwapInt32(&s.maxInFlight, maxInFlight, inFlight) {
+				break
+			}
+		}
+		<-unblocked
+		w.Write(data)
+	}))
+	return s
+}
+
+func TestPerServerFetchLimit(t *testing.T) {
+
+	SetPerServerFetchLimit(2)
+	defer SetPerServerFetchLimit(0)
+
+	data := randomBytes(1024)
+	hotUnblocked, otherUnblocked := make(chan struct{}), make(chan struct{})
+	close(otherUnblocked)
+	hot, other := newBlockingServer(data, hotUnblocked), newBlockingServer(data, otherUnblocked)
+	defer hot.Close()
+	defer other.Close()
+
+	var wg sync.WaitGroup
+	var failed int32
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if received, err := retriedFetchChunkData([]string{hot.URL + "/1,01"}, nil, false, true, 0, 0); err != nil || len(received) != len(data) {
+				atomic.AddInt32(&failed, 1)
+			}
+		}()
+	}
+
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(&hot.inFlight) < 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests in flight to the hot server, expect 2", atomic.LoadInt32(&hot.inFlight))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the other server is not held up by the hot one
+	done := make(chan error, 1)
+	go func() {
+		_, err := retriedFetchChunkData([]string{other.URL + "/1,02"}, nil, false, true, 0, 0)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("fetch from the other server: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("fetch from the other server waits for the hot one")
+	}
+
+	close(hotUnblocked)
+	wg.Wait()
+	if failed > 0 {
+		t.Errorf("%d fetches from the hot server failed", failed)
+	}
+	if maxInFlight := atomic.LoadInt32(&hot.maxInFlight); maxInFlight != 2 {
+		t.Errorf("%d requests in flight to the hot server, expect at most 2", maxInFlight)
+	}
+
+}
+
+func TestPerServerFetchLimitOnEveryPath(t *testing.T) {
+
+	SetPerServerFetchLimit(1)
+	defer SetPerServerFetchLimit(0)
+
+	data := randomBytes(1024)
+	server := newTestVolumeServer(map[string][]byte{"1,01": data})
+	defer server.Close()
+	urlString := server.URL + "/1,01"
+
+	release, err := acquireServerSlot(context.Background(), urlString)
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	// the hedged, health probe and raw fetches wait for the slot, until given up
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := fetchWholeChunkWithContext(ctx, urlString, nil, false); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("hedged fetch while the server is busy: %v", err)
+	}
+	if _, err := fetchRawChunk(ctx, urlString); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("raw fetch while the server is busy: %v", err)
+	}
+	if requests := atomic.LoadInt32(&server.requests); requests != 0 {
+		t.Errorf("%d requests over the limit of the server", requests)
+	}
+
+	// the given up waits leave no slot taken
+	release()
+	if received, err := fetchWholeChunkWithContext(context.Background(), urlString, nil, false); err != nil || len(received) != len(data) {
+		t.Errorf("fetch after the server is free: %d bytes, %v", len(received), err)
+	}
+	if received, err := fetchWholeChunkWithContext(context.Background(), urlString, nil, false); err != nil || len(received) != len(data) {
+		t.Errorf("fetch again: %d bytes, %v", len(received), err)
+	}
+
+}
@@ -0,0 +1,48 @@
+package filer
+
+import (
+	"errors"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// StaleReadFn is told of each chunk served from the chunk cache because fetching it failed, with the fetch error.
+type StaleReadFn func(fileId string, err error)
+
+// SetServeStaleOnError serves the chunks that can not be fetched, as their volumes can not be looked up or
+// all their replicas fail, from the chunk cache if kept there by then, e.g. to keep reading while the volume
+// servers are down. The chunks are looked up in the cache before they are fetched, so these are the chunks
+// cached while the fetch was failing, such as by other readers or prefetches. Such data is not checked to
+// still belong to the file, which may have changed since, so staleFn, if set, is told of each chunk served this way.
+func (c *ChunkReadAt) SetServeStaleOnError(staleFn StaleReadFn) {
+	c.readerLock.Lock()
+	defer c.readerLock.Unlock()
+	c.serveStale = true
+	c.staleReadFn = staleFn
+}
+
+// staleChunk returns the cached length bytes of the chunk from offset, length 0 for the whole chunk,
+// if the fetch failed for the backend being unavailable.
+func (c *ChunkReadAt) staleChunk(chunkView *ChunkView, offset, length uint64, fetchErr error) ([]byte, bool) {
+	if !c.serveStale || c.chunkCache == nil {
+		return nil, false
+	}
+	if !errors.Is(fetchErr, ErrVolumeLookup) && !errors.Is(fetchErr, ErrReplicasFailed) {
+		return nil, false
+	}
+	var data []byte
+	if length == 0 {
+		data = c.chunkCache.GetChunk(c.cacheKey(chunkView.FileId), chunkView.ChunkSize)
+	} else {
+		data = c.chunkCache.GetChunkSlice(c.cacheKey(chunkView.FileId), offset, length)
+	}
+	if len(data) == 0 {
+		return nil, false
+	}
+	glog.V(1).Infof("serve cached chunk %s after fetch failure: %v", chunkView.FileId, fetchErr)
+	c.eventSink.emit(ReadEvent{Type: ReadEventCacheHit, FileId: chunkView.FileId, Offset: int64(offset), Size: int64(len(data))})
+	if c.staleReadFn != nil {
+		c.staleReadFn(chunkView.FileId, fetchErr)
+	}
+	return data, true
+}
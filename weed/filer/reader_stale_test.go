@@ -0,0 +1,70 @@
+package filer
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
+)
+
+func TestServeStaleChunksWhileBackendIsDown(t *testing.T) {
+
+	fileIds := []string{"3,01637037d6", "3,02637037d6", "3,03637037d6"}
+	var content []byte
+	var chunkViews []*ChunkView
+	for i, fileId := range fileIds {
+		content = append(content, randomBytes(4096)...)
+		chunkViews = append(chunkViews, &ChunkView{FileId: fileId, Size: 4096, ChunkSize: 4096, LogicOffset: int64(i * 4096)})
+	}
+	// the first chunk kept by an earlier read, the second cached by another reader while this one fails to fetch it,
+	// the third never read. Chunks larger than the unit size are only kept on disk.
+	var cache *chunk_cache.TieredChunkCache
+	newCache := func() {
+		cache = chunk_cache.NewTieredChunkCache(4, t.TempDir(), 1024, 1024)
+		cache.SetChunk(fileIds[0], content[:4096])
+	}
+	down := func(fileId string) ([]string, error) {
+		if fileId == fileIds[1] {
+			cache.SetChunk(fileId, content[4096:8192])
+		}
+		return nil, errors.New("connection refused")
+	}
+
+	// without serving stale chunks, the chunk cached while its fetch fails is not read
+	newCache()
+	readerAt := NewChunkReaderAtFromClient(down, chunkViews, cache, int64(len(content)))
+	if _, err := readerAt.ReadAt(make([]byte, 8192), 0); !errors.Is(err, ErrVolumeLookup) {
+		t.Errorf("expected the lookup to fail without serving stale chunks, got %v", err)
+	}
+	cache.Shutdown()
+
+	newCache()
+	defer cache.Shutdown()
+	readerAt = NewChunkReaderAtFromClient(down, chunkViews, cache, int64(len(content)))
+	// no prefetch left in the background once the cache is shut down
+	readerAt.SetSynchronousPrefetch(true)
+	var stale []string
+	readerAt.SetServeStaleOnError(func(fileId string, err error) {
+		if !errors.Is(err, ErrVolumeLookup) {
+			t.Errorf("stale chunk %s served on %v", fileId, err)
+		}
+		stale = append(stale, fileId)
+	})
+	buf := make([]byte, 8192)
+	if n, err := readerAt.ReadAt(buf, 0); err != nil || n != len(buf) {
+		t.Fatalf("read %d bytes: %v", n, err)
+	}
+	if !bytes.Equal(buf, content[:8192]) {
+		t.Errorf("stale read mismatch")
+	}
+	if len(stale) != 1 || stale[0] != fileIds[1] {
+		t.Errorf("flagged %v as stale, expect only the second chunk", stale)
+	}
+
+	// chunks not cached still fail
+	if _, err := readerAt.ReadAt(make([]byte, 4096), 8192); !errors.Is(err, ErrVolumeLookup) {
+		t.Errorf("expected the uncached chunk to fail, got %v", err)
+	}
+
+}
@@ -0,0 +1,99 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
+)
+
+// ReadTransformed reads the whole file transformed by the volume server, e.g. an image resized with the
+// "width", "height" and "mode" parameters, which are added to the query of the chunk url.
+// Only a file of one plain chunk can be transformed by the server. Other files, or a failed transform,
+// fall back to the file content as is, which is also what a server not knowing the parameters returns.
+// The transformed content is cached in memory under a key of the chunk and the parameters.
+func (c *ChunkReadAt) ReadTransformed(ctx context.Context, transform url.Values) ([]byte, error) {
+
+	c.readerLock.Lock()
+	chunkView := c.transformableChunkView()
+	c.readerLock.Unlock()
+
+	if chunkView != nil && len(transform) > 0 {
+		cacheKey := chunk_cache.NamespacedKey("transform?"+transform.Encode(), c.cacheKey(chunkView.FileId))
+		if c.chunkCache != nil {
+			if data := c.chunkCache.GetChunk(cacheKey, 0); len(data) > 0 {
+				return data, nil
+			}
+		}
+		data, err := c.fetchTransformed(ctx, chunkView.FileId, transform)
+		if err == nil {
+			if c.chunkCache != nil {
+				c.chunkCache.SetChunk(cacheKey, data)
+			}
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		glog.V(1).Infof("read %s as is: %v", chunkView.FileId, err)
+	}
+
+	data := make([]byte, c.fileSize)
+	n, err := c.ReadAtWithContext(ctx, data, 0)
+	if err == io.EOF {
+		err = nil
+	}
+	return data[:n], err
+}
+
+// transformableChunkView returns the only chunk view if it covers the whole chunk and file, unencrypted and uncompressed
+func (c *ChunkReadAt) transformableChunkView() *ChunkView {
+	if c.lookupFileId == nil {
+		return nil
+	}
+	var found *ChunkView
+	for _, chunkView := range c.chunkViews {
+		if chunkView.Size == 0 {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = chunkView
+	}
+	if found == nil || found.LogicOffset != 0 || found.Offset != 0 || found.Size != found.ChunkSize || int64(found.Size) != c.fileSize {
+		return nil
+	}
+	if found.CipherKey != nil || found.IsGzipped {
+		return nil
+	}
+	return found
+}
+
+func (c *ChunkReadAt) fetchTransformed(ctx context.Context, fileId string, transform url.Values) (data []byte, err error) {
+
+	urlStrings, err := c.lookupFileId(fileId)
+	if err != nil {
+		return nil, lookupError(fileId, err)
+	}
+
+	for _, urlString := range urlStrings {
+		data = data[:0]
+		_, err = readUrlAsStream(ctx, appendQuery(urlString, transform.Encode()), nil, false, true, 0, 0, func(received []byte) {
+			data = append(data, received...)
+		})
+		if err == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("no location of %s", fileId)
+	}
+	return nil, &ReplicasFailedError{Urls: urlStrings, Err: err}
+}
@@ -0,0 +1,88 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadTransformed(t *testing.T) {
+
+	original, other := randomBytes(4096), randomBytes(1024)
+	var transformRequests, plainRequests int32
+	var unsupported int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fileId := strings.TrimPrefix(r.URL.Path, "/")
+		if thumbnail := r.URL.Query().Get("thumbnail"); thumbnail != "" {
+			atomic.AddInt32(&transformRequests, 1)
+			if atomic.LoadInt32(&unsupported) != 0 {
+				w.WriteHeader(http.StatusNotImplemented)
+				return
+			}
+			w.Write([]byte("thumbnail " + thumbnail + " of " + fileId))
+			return
+		}
+		atomic.AddInt32(&plainRequests, 1)
+		data := original
+		if fileId == "1,2b02" {
+			data = other
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+	transform := url.Values{"thumbnail": []string{"200x200"}}
+
+	readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+		{FileId: "1,2b01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+	}, newMapChunkCache(), 4096)
+	for i := 0; i < 2; i++ {
+		data, err := readerAt.ReadTransformed(context.Background(), transform)
+		if err != nil {
+			t.Fatalf("read transformed: %v", err)
+		}
+		if string(data) != "thumbnail 200x200 of 1,2b01" {
+			t.Fatalf("read transformed %q", data)
+		}
+	}
+	if atomic.LoadInt32(&transformRequests) != 1 || atomic.LoadInt32(&plainRequests) != 0 {
+		t.Errorf("%d transform and %d plain requests, expect the cached transform to be reused", atomic.LoadInt32(&transformRequests), atomic.LoadInt32(&plainRequests))
+	}
+	// the transformed content is cached apart from the chunk
+	if data, err := readerAt.ReadTransformed(context.Background(), nil); err != nil || !bytes.Equal(data, original) {
+		t.Errorf("read without transform: err=%v, content mismatch=%v", err, !bytes.Equal(data, original))
+	}
+
+	// a server failing the transform returns the content as is
+	atomic.StoreInt32(&unsupported, 1)
+	readerAt = NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+		{FileId: "1,2b01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+	}, newMapChunkCache(), 4096)
+	if data, err := readerAt.ReadTransformed(context.Background(), transform); err != nil || !bytes.Equal(data, original) {
+		t.Errorf("read unsupported transform: err=%v, content mismatch=%v", err, !bytes.Equal(data, original))
+	}
+
+	// a file of several chunks is not transformed by the server
+	atomic.StoreInt32(&unsupported, 0)
+	atomic.StoreInt32(&transformRequests, 0)
+	readerAt = NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+		{FileId: "1,2b01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+		{FileId: "1,2b02", Size: 1024, ChunkSize: 1024, LogicOffset: 4096},
+	}, newMapChunkCache(), 5120)
+	data, err := readerAt.ReadTransformed(context.Background(), transform)
+	if err != nil || !bytes.Equal(data, append(append([]byte{}, original...), other...)) {
+		t.Errorf("read transformed file of several chunks: err=%v", err)
+	}
+	if atomic.LoadInt32(&transformRequests) != 0 {
+		t.Errorf("%d transform requests for a file of several chunks", atomic.LoadInt32(&transformRequests))
+	}
+
+}
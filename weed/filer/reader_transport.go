@@ -0,0 +1,203 @@
+package filer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// ReadTransport is how whole chunks are read from a volume server.
+type ReadTransport int
+
+const (
+	ReadOverHTTP ReadTransport = iota
+	ReadOverGRPC
+)
+
+func (t ReadTransport) String() string {
+	if t == ReadOverGRPC {
+		return "grpc"
+	}
+	return "http"
+}
+
+// DefaultTransportReprobeInterval is how long a TransportSelector keeps the transport chosen for a volume server.
+const DefaultTransportReprobeInterval = 5 * time.Minute
+
+// GrpcChunkFetchFn reads the whole chunk of the file id from the volume server at the address over gRPC,
+// decoded as the http reads are. The volume servers have no call reading a chunk by its file id,
+// so the gRPC read is supplied by the caller.
+type GrpcChunkFetchFn func(ctx context.Context, serverAddress, fileId string, cipherKey []byte, isGzipped bool) ([]byte, error)
+
+// TransportSelector reads each volume server over whichever of http and gRPC is faster for it.
+// The first fetches from a server probe each transport in turn, and the following ones use the one
+// that took less time per byte, until the reprobe interval passes or the chosen transport fails.
+// A transport failing while probed loses to the other. The choices are shared by all readers using the selector.
+type TransportSelector struct {
+	sync.Mutex
+	grpcFetch       GrpcChunkFetchFn
+	reprobeInterval time.Duration
+	clock           util.Clock
+	servers         map[string]*serverTransport
+}
+
+type serverTransport struct {
+	probed   [2]bool
+	cost     [2]float64 // nanoseconds per byte of the probe of each transport
+	chosen   ReadTransport
+	chosenAt time.Time // zero while probing
+}
+
+// NewTransportSelector probes again after reprobeInterval, DefaultTransportReprobeInterval if not positive.
+// A nil grpcFetch reads over http only.
+func NewTransportSelector(grpcFetch GrpcChunkFetchFn, reprobeInterval time.Duration) *TransportSelector {
+	if reprobeInterval <= 0 {
+		reprobeInterval = DefaultTransportReprobeInterval
+	}
+	return &TransportSelector{
+		grpcFetch:       grpcFetch,
+		reprobeInterval: reprobeInterval,
+		clock:           util.RealClock,
+		servers:         make(map[string]*serverTransport),
+	}
+}
+
+// SetClock replaces the clock timing the reprobes, e.g. with a util.TestClock.
+func (s *TransportSelector) SetClock(clock util.Clock) {
+	s.Lock()
+	defer s.Unlock()
+	s.clock = clock
+}
+
+// SetTransportSelector reads the whole chunks over the transport the selector picks for each volume server.
+// The replicas failing over either transport are reported and repaired as with the http reads.
+// A nil selector reads over http only.
+func (c *ChunkReadAt) SetTransportSelector(selector *TransportSelector) {
+	c.transportSelector = selector
+}
+
+// Transport returns the transport chosen for the volume server, and false while it is being probed.
+func (s *TransportSelector) Transport(serverAddress string) (ReadTransport, bool) {
+	s.Lock()
+	defer s.Unlock()
+	server, found := s.servers[serverAddress]
+	if !found || server.chosenAt.IsZero() {
+		return ReadOverHTTP, false
+	}
+	return server.chosen, true
+}
+
+func (s *TransportSelector) pick(serverAddress string) ReadTransport {
+	if s.grpcFetch == nil {
+		return ReadOverHTTP
+	}
+	s.Lock()
+	defer s.Unlock()
+	server, found := s.servers[serverAddress]
+	if !found {
+		server = &serverTransport{}
+		s.servers[serverAddress] = server
+	}
+	if !server.chosenAt.IsZero() {
+		if s.clock.Now().Sub(server.chosenAt) < s.reprobeInterval {
+			return server.chosen
+		}
+		*server = serverTransport{}
+	}
+	if !server.probed[ReadOverHTTP] {
+		return ReadOverHTTP
+	}
+	return ReadOverGRPC
+}
+
+func (s *TransportSelector) observe(serverAddress string, transport ReadTransport, elapsed time.Duration, size int, err error) {
+	s.Lock()
+	defer s.Unlock()
+	server, found := s.servers[serverAddress]
+	if !found {
+		// not picked, e.g. without a gRPC read
+		return
+	}
+	if !server.chosenAt.IsZero() {
+		if err != nil && transport == server.chosen {
+			*server = serverTransport{}
+		}
+		return
+	}
+	if size < 1 {
+		size = 1
+	}
+	server.probed[transport] = true
+	server.cost[transport] = float64(elapsed) / float64(size)
+	if err != nil {
+		server.cost[transport] = -1
+	}
+	if !server.probed[ReadOverHTTP] || !server.probed[ReadOverGRPC] {
+		return
+	}
+	httpCost, grpcCost := server.cost[ReadOverHTTP], server.cost[ReadOverGRPC]
+	// http stays chosen unless gRPC worked, and either http failed or gRPC was faster
+	server.chosen = ReadOverHTTP
+	if grpcCost >= 0 && (httpCost < 0 || grpcCost < httpCost) {
+		server.chosen = ReadOverGRPC
+	}
+	server.chosenAt = s.clock.Now()
+	glog.V(1).Infof("read %s over %v", serverAddress, server.chosen)
+}
+
+// replicaFetch fetches the chunk of the file id from the replicas over the transports picked for them,
+// at least chunkSize bytes if positive, as the http fetches do.
+func (s *TransportSelector) replicaFetch(ctx context.Context, fileId string, cipherKey []byte, isGzipped bool, chunkSize uint64) replicaFetchFn {
+	return func(urlStrings []string, onAttempt func(urlString string, err error)) ([]byte, error) {
+		return s.fetchChunk(ctx, urlStrings, fileId, cipherKey, isGzipped, chunkSize, onAttempt)
+	}
+}
+
+func (s *TransportSelector) fetchChunk(ctx context.Context, urlStrings []string, fileId string, cipherKey []byte, isGzipped bool, chunkSize uint64, onAttempt func(urlString string, err error)) ([]byte, error) {
+
+	var err error
+	for _, urlString := range urlStrings {
+		serverAddress := replicaServer(urlString)
+		transport := s.pick(serverAddress)
+		start := time.Now()
+		var data []byte
+		if transport == ReadOverGRPC {
+			data, err = s.grpcFetchInSlot(ctx, urlString, fileId, cipherKey, isGzipped)
+		} else {
+			data, err = fetchWholeChunkWithContext(ctx, urlString, cipherKey, isGzipped)
+		}
+		if err == nil {
+			data, err = checkReceivedSize(urlString, data, int(chunkSize), 0)
+		}
+		s.observe(serverAddress, transport, time.Since(start), len(data), err)
+		if err == nil {
+			if onAttempt != nil {
+				onAttempt(urlString, nil)
+			}
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if onAttempt != nil {
+			onAttempt(urlString, err)
+		}
+		readFailureLog.Logf("read "+serverAddress, "read %s over %v failed, err: %v", urlString, transport, err)
+	}
+
+	// no luck with the quick attempts, go through all replicas over http with retries
+	return retriedFetchChunkDataWithAttempts(ctx, urlStrings, cipherKey, isGzipped, true, 0, int(chunkSize), onAttempt)
+}
+
+// grpcFetchInSlot reads the chunk over gRPC within a slot of the server behind the url, as the http reads are
+func (s *TransportSelector) grpcFetchInSlot(ctx context.Context, urlString, fileId string, cipherKey []byte, isGzipped bool) ([]byte, error) {
+	release, err := acquireServerSlot(ctx, urlString)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return s.grpcFetch(ctx, replicaServer(urlString), fileId, cipherKey, isGzipped)
+}
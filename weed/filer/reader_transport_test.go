@@ -0,0 +1,166 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestTransportSelectorConvergesToFasterGrpc(t *testing.T) {
+
+	chunks := make(map[string][]byte)
+	var chunkViews []*ChunkView
+	for i := 0; i < 6; i++ {
+		fileId := fmt.Sprintf("1,9a%02x", i)
+		chunks[fileId] = randomBytes(4096)
+		chunkViews = append(chunkViews, &ChunkView{FileId: fileId, Size: 4096, ChunkSize: 4096, LogicOffset: int64(i * 4096)})
+	}
+	var httpReads, grpcReads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&httpReads, 1)
+		time.Sleep(20 * time.Millisecond)
+		w.Write(chunks[strings.TrimPrefix(r.URL.Path, "/")])
+	}))
+	defer server.Close()
+	serverAddress := strings.TrimPrefix(server.URL, "http://")
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{server.URL + "/" + fileId}, nil
+	}
+
+	selector := NewTransportSelector(func(ctx context.Context, address, fileId string, cipherKey []byte, isGzipped bool) ([]byte, error) {
+		if address != serverAddress {
+			t.Errorf("read %s from %s over grpc", fileId, address)
+		}
+		atomic.AddInt32(&grpcReads, 1)
+		return chunks[fileId], nil
+	}, time.Minute)
+	clock := util.NewTestClock(time.Now())
+	selector.SetClock(clock)
+
+	readAll := func() {
+		readerAt := NewChunkReaderAtFromClient(lookupFn, chunkViews, newMapChunkCache(), 6*4096)
+		readerAt.SetTransportSelector(selector)
+		readerAt.SetSynchronousPrefetch(true)
+		for _, chunkView := range chunkViews {
+			buf := make([]byte, chunkView.Size)
+			if _, err := readerAt.ReadAt(buf, chunkView.LogicOffset); err != nil {
+				t.Fatalf("read at %d: %v", chunkView.LogicOffset, err)
+			}
+			if !bytes.Equal(buf, chunks[chunkView.FileId]) {
+				t.Fatalf("read mismatch at %d", chunkView.LogicOffset)
+			}
+		}
+	}
+
+	readAll()
+	if transport, chosen := selector.Transport(serverAddress); !chosen || transport != ReadOverGRPC {
+		t.Errorf("chose %v %v, expect grpc", transport, chosen)
+	}
+	// only the probe went over http
+	if httpReads != 1 || grpcReads < 5 {
+		t.Errorf("%d reads over http and %d over grpc, expect 1 probe over http", httpReads, grpcReads)
+	}
+
+	// probed again once the interval passes, still choosing grpc
+	clock.Advance(time.Minute)
+	readAll()
+	if transport, chosen := selector.Transport(serverAddress); !chosen || transport != ReadOverGRPC {
+		t.Errorf("chose %v %v after probing again, expect grpc", transport, chosen)
+	}
+	if httpReads != 2 {
+		t.Errorf("%d reads over http after probing again, expect 2", httpReads)
+	}
+
+}
+
+func TestTransportSelectorWithoutGrpc(t *testing.T) {
+
+	data := randomBytes(4096)
+	server := newTestVolumeServer(map[string][]byte{"1,9b01": data})
+	defer server.Close()
+
+	selector := NewTransportSelector(nil, time.Minute)
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, []*ChunkView{
+		{FileId: "1,9b01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+	}, newMapChunkCache(), 4096)
+	readerAt.SetTransportSelector(selector)
+	buf := make([]byte, 4096)
+	if _, err := readerAt.ReadAt(buf, 0); err != nil || !bytes.Equal(buf, data) {
+		t.Fatalf("read without a grpc read: %v", err)
+	}
+	if transport, _ := selector.Transport(strings.TrimPrefix(server.URL, "http://")); transport != ReadOverHTTP {
+		t.Errorf("chose %v without a grpc read", transport)
+	}
+
+}
+
+func TestTransportSelectorStaysOnHttpWhenBothFail(t *testing.T) {
+
+	selector := NewTransportSelector(func(ctx context.Context, address, fileId string, cipherKey []byte, isGzipped bool) ([]byte, error) {
+		return nil, fmt.Errorf("unavailable")
+	}, time.Minute)
+
+	for _, transport := range []ReadTransport{ReadOverHTTP, ReadOverGRPC} {
+		if picked := selector.pick("volume:8080"); picked != transport {
+			t.Fatalf("probing %v, expect %v", picked, transport)
+		}
+		selector.observe("volume:8080", transport, time.Millisecond, 0, fmt.Errorf("failed"))
+	}
+	if transport, chosen := selector.Transport("volume:8080"); !chosen || transport != ReadOverHTTP {
+		t.Errorf("chose %v %v once both probes failed, expect http", transport, chosen)
+	}
+
+	// while a failing http still loses to a working grpc
+	selector = NewTransportSelector(func(ctx context.Context, address, fileId string, cipherKey []byte, isGzipped bool) ([]byte, error) {
+		return nil, nil
+	}, time.Minute)
+	selector.pick("volume:8080")
+	selector.observe("volume:8080", ReadOverHTTP, time.Millisecond, 0, fmt.Errorf("failed"))
+	selector.pick("volume:8080")
+	selector.observe("volume:8080", ReadOverGRPC, time.Second, 4096, nil)
+	if transport, chosen := selector.Transport("volume:8080"); !chosen || transport != ReadOverGRPC {
+		t.Errorf("chose %v %v once http failed, expect grpc", transport, chosen)
+	}
+
+}
+
+func TestTransportSelectorReportsFailedReplicas(t *testing.T) {
+
+	data := randomBytes(4096)
+	failed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failed.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(data)
+	}))
+	defer healthy.Close()
+	lookupFn := func(fileId string) ([]string, error) {
+		return []string{failed.URL + "/" + fileId, healthy.URL + "/" + fileId}, nil
+	}
+
+	readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+		{FileId: "1,9c01", Size: 4096, ChunkSize: 4096, LogicOffset: 0},
+	}, newMapChunkCache(), 4096)
+	readerAt.SetTransportSelector(NewTransportSelector(nil, time.Minute))
+	var events []*UnderReplicatedEvent
+	readerAt.SetUnderReplicatedFn(func(event *UnderReplicatedEvent) {
+		events = append(events, event)
+	})
+	buf := make([]byte, 4096)
+	if _, err := readerAt.ReadAt(buf, 0); err != nil || !bytes.Equal(buf, data) {
+		t.Fatalf("read: %v", err)
+	}
+	if len(events) != 1 || len(events[0].FailedServers) != 1 || events[0].FailedServers[0] != strings.TrimPrefix(failed.URL, "http://") {
+		t.Errorf("reported %+v, expect the failed replica read through the selector", events)
+	}
+
+}
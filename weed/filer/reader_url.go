@@ -0,0 +1,38 @@
+package filer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VolumeUrlFunc builds the url to read the file id from the volume server at the address, e.g. "10.0.0.1:8080"
+// as adjusted by the filer client. The url may carry its own query, e.g. an auth token.
+type VolumeUrlFunc func(serverAddress, fileId string) string
+
+// DefaultVolumeUrl reads the file id over plain http from the root of the volume server.
+func DefaultVolumeUrl(serverAddress, fileId string) string {
+	return fmt.Sprintf("http://%s/%s", serverAddress, fileId)
+}
+
+func (vl *VolumeLookup) volumeUrl(serverAddress, fileId string) string {
+	if vl.opts.VolumeUrl != nil {
+		return vl.opts.VolumeUrl(serverAddress, fileId)
+	}
+	return DefaultVolumeUrl(serverAddress, fileId)
+}
+
+// readDeletedUrl asks for the chunk even if deleted since, keeping the query the url may already have.
+func readDeletedUrl(urlString string) string {
+	return appendQuery(urlString, "readDeleted=true")
+}
+
+// appendQuery adds the encoded query to the url, after the query the url may already have.
+func appendQuery(urlString, query string) string {
+	if query == "" {
+		return urlString
+	}
+	if strings.Contains(urlString, "?") {
+		return urlString + "&" + query
+	}
+	return urlString + "?" + query
+}
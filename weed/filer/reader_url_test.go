@@ -0,0 +1,127 @@
+package filer
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestLookupWithVolumeUrlFunc(t *testing.T) {
+
+	filerClient := &fakeFilerClient{
+		locations: map[string][]string{
+			"5": {"server1:8080", "server2:8080", "server3:8080"},
+		},
+	}
+	var built []string
+	lookupFn := LookupFnWithOptions(filerClient, &LookupOptions{
+		VolumeUrl: func(serverAddress, fileId string) string {
+			built = append(built, serverAddress)
+			return "https://proxy.example.com/volumes/" + serverAddress + "/" + fileId + "?token=secret"
+		},
+	})
+
+	urls, err := lookupFn("5,01637037d6")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	sort.Strings(urls)
+	sort.Strings(built)
+	expected := []string{
+		"https://proxy.example.com/volumes/server1:8080/5,01637037d6?token=secret",
+		"https://proxy.example.com/volumes/server2:8080/5,01637037d6?token=secret",
+		"https://proxy.example.com/volumes/server3:8080/5,01637037d6?token=secret",
+	}
+	if strings.Join(urls, " ") != strings.Join(expected, " ") {
+		t.Errorf("located at %v", urls)
+	}
+	if strings.Join(built, " ") != "server1:8080 server2:8080 server3:8080" {
+		t.Errorf("built the urls of %v", built)
+	}
+
+	// the default keeps plain http from the root of the volume servers
+	urls, _ = LookupFnWithOptions(filerClient, &LookupOptions{NoShuffle: true})("5,01637037d6")
+	if urls[0] != "http://server1:8080/5,01637037d6" {
+		t.Errorf("located at %v by default", urls)
+	}
+
+}
+
+func TestReadFromVolumeUrlWithQuery(t *testing.T) {
+
+	data := randomBytes(1024)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/prefix/6,01637037d6" || r.URL.Query().Get("token") != "secret" || r.URL.Query().Get("readDeleted") != "true" {
+			t.Errorf("requested %s", r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	filerClient := &fakeFilerClient{
+		locations: map[string][]string{
+			"6": {strings.TrimPrefix(server.URL, "http://")},
+		},
+	}
+	lookupFn := LookupFnWithOptions(filerClient, &LookupOptions{
+		VolumeUrl: func(serverAddress, fileId string) string {
+			if _, _, err := net.SplitHostPort(serverAddress); err != nil {
+				t.Errorf("server address %q: %v", serverAddress, err)
+			}
+			return "http://" + serverAddress + "/prefix/" + fileId + "?token=secret"
+		},
+	})
+
+	received, err := fetchChunk(lookupFn, "6,01637037d6", nil, false)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if !bytes.Equal(received, data) {
+		t.Errorf("fetched data mismatch")
+	}
+
+}
+
+func TestReadTransformedFromVolumeUrlWithQuery(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/prefix/6,02637037d6" || r.URL.Query().Get("token") != "secret" {
+			t.Errorf("requested %s", r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("thumbnail " + r.URL.Query().Get("thumbnail")))
+	}))
+	defer server.Close()
+
+	filerClient := &fakeFilerClient{
+		locations: map[string][]string{
+			"6": {strings.TrimPrefix(server.URL, "http://")},
+		},
+	}
+	lookupFn := LookupFnWithOptions(filerClient, &LookupOptions{
+		VolumeUrl: func(serverAddress, fileId string) string {
+			return "http://" + serverAddress + "/prefix/" + fileId + "?token=secret"
+		},
+	})
+
+	readerAt := NewChunkReaderAtFromClient(lookupFn, []*ChunkView{
+		{FileId: "6,02637037d6", Size: 1024, ChunkSize: 1024, LogicOffset: 0},
+	}, newMapChunkCache(), 1024)
+	data, err := readerAt.ReadTransformed(context.Background(), url.Values{"thumbnail": []string{"200x200"}})
+	if err != nil {
+		t.Fatalf("read transformed: %v", err)
+	}
+	if string(data) != "thumbnail 200x200" {
+		t.Errorf("read transformed %q", data)
+	}
+
+}
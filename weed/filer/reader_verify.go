@@ -0,0 +1,132 @@
+package filer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// VerifyConcurrency is the number of chunks fetched at the same time by Verify.
+const VerifyConcurrency = 4
+
+// VerifyError lists the chunks that Verify could not read.
+type VerifyError struct {
+	Failures []*ChunkFetchError
+}
+
+func (e *VerifyError) Error() string {
+	if len(e.Failures) == 1 {
+		return fmt.Sprintf("verify: %v", e.Failures[0])
+	}
+	return fmt.Sprintf("verify: %d chunks failed, first %v", len(e.Failures), e.Failures[0])
+}
+
+// Unwrap returns the first failure, so that errors.Is(err, ErrChunkFetch) holds.
+func (e *VerifyError) Unwrap() error {
+	return e.Failures[0]
+}
+
+// Verify fetches every chunk of the file, bypassing the chunk cache, to check that each is readable
+// from at least one replica and holds all the data its views refer to, e.g. for scrubbing tools.
+// The volume servers check the chunk checksums when serving them. All failures are returned as a *VerifyError.
+func (c *ChunkReadAt) Verify(ctx context.Context) error {
+
+	if c.lookupFileId == nil {
+		return nil
+	}
+
+	c.readerLock.Lock()
+	allChunkViews := c.chunkViews
+	c.readerLock.Unlock()
+
+	// look up the volumes of all chunks at once if the reader can,
+	// leaving any volume not found to fail its chunks below
+	c.ResolveLocations(ctx)
+
+	// the least size of each chunk for its views
+	minSizes := make(map[string]int64)
+	var chunkViews []*ChunkView
+	for _, chunkView := range allChunkViews {
+		if chunkView.Size == 0 {
+			continue
+		}
+		if _, found := minSizes[chunkView.FileId]; !found {
+			chunkViews = append(chunkViews, chunkView)
+		}
+		minSizes[chunkView.FileId] = max(minSizes[chunkView.FileId], chunkView.Offset+int64(chunkView.Size))
+	}
+
+	var lock sync.Mutex
+	var failures []*ChunkFetchError
+	var wg sync.WaitGroup
+	tokens := make(chan struct{}, VerifyConcurrency)
+	for _, chunkView := range chunkViews {
+		select {
+		case tokens <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func(chunkView *ChunkView) {
+			defer func() {
+				<-tokens
+				wg.Done()
+			}()
+			if err := c.verifyChunk(ctx, chunkView, minSizes[chunkView.FileId]); err != nil {
+				lock.Lock()
+				failures = append(failures, &ChunkFetchError{FileId: chunkView.FileId, Err: err})
+				lock.Unlock()
+			}
+		}(chunkView)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(failures) > 0 {
+		// in file order, for a stable first failure
+		ordered := make([]*ChunkFetchError, 0, len(failures))
+		for _, chunkView := range chunkViews {
+			for _, failure := range failures {
+				if failure.FileId == chunkView.FileId {
+					ordered = append(ordered, failure)
+				}
+			}
+		}
+		return &VerifyError{Failures: ordered}
+	}
+	return nil
+}
+
+func (c *ChunkReadAt) verifyChunk(ctx context.Context, chunkView *ChunkView, minSize int64) error {
+
+	urlStrings, err := c.lookupFileId(chunkView.FileId)
+	if err != nil {
+		return lookupError(chunkView.FileId, err)
+	}
+	if len(urlStrings) == 0 {
+		return fmt.Errorf("no replica")
+	}
+	release, err := acquireFetchSlot(ctx, c.priority)
+	if err != nil {
+		return err
+	}
+	defer release()
+	for _, urlString := range urlStrings {
+		var data []byte
+		data, err = fetchWholeChunkWithContext(ctx, urlString, chunkView.CipherKey, chunkView.IsGzipped)
+		if err == nil && int64(len(data)) < minSize {
+			err = fmt.Errorf("%s: %w", urlString, &TruncatedChunkError{Size: int64(len(data)), Expected: minSize})
+		}
+		if err == nil {
+			if checkErr := c.checksums.check(chunkView.FileId, data); checkErr != nil {
+				err = fmt.Errorf("%s: %w", urlString, checkErr)
+			}
+		}
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
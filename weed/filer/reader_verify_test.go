@@ -0,0 +1,47 @@
+package filer
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestVerifyReportsUnreadableChunk(t *testing.T) {
+
+	server := newTestVolumeServer(map[string][]byte{
+		"1,3a01": randomBytes(1024),
+		"1,3a03": randomBytes(512), // shorter than its view
+		"1,3a04": randomBytes(1024),
+	})
+	defer server.Close()
+
+	chunkViews := []*ChunkView{
+		{FileId: "1,3a01", Size: 1024, ChunkSize: 1024, LogicOffset: 0},
+		{FileId: "1,3a02", Size: 1024, ChunkSize: 1024, LogicOffset: 1024},
+		{FileId: "1,3a03", Size: 1024, ChunkSize: 1024, LogicOffset: 2048},
+		{FileId: "1,3a04", Size: 1024, ChunkSize: 1024, LogicOffset: 3072},
+	}
+	cache := newMapChunkCache()
+	readerAt := NewChunkReaderAtFromClient(server.lookupFn, chunkViews, cache, 4096)
+
+	err := readerAt.Verify(context.Background())
+	var verifyErr *VerifyError
+	if !errors.As(err, &verifyErr) {
+		t.Fatalf("expect a verify error, got %v", err)
+	}
+	if !errors.Is(err, ErrChunkFetch) {
+		t.Errorf("verify error is not a chunk fetch error")
+	}
+	if len(verifyErr.Failures) != 2 || verifyErr.Failures[0].FileId != "1,3a02" || verifyErr.Failures[1].FileId != "1,3a03" {
+		t.Errorf("unexpected failures %v", verifyErr)
+	}
+	if len(cache.chunks) != 0 {
+		t.Errorf("verify should bypass the chunk cache")
+	}
+
+	readerAt.Reset(chunkViews[:1], 1024)
+	if err = readerAt.Verify(context.Background()); err != nil {
+		t.Errorf("verify intact file: %v", err)
+	}
+
+}
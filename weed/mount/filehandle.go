@@ -49,7 +49,9 @@ func newFileHandle(wfs *WFS, handleId FileHandleId, inode uint64, entry *filer_p
 }
 
 func (fh *FileHandle) FullPath() util.FullPath {
-	return fh.wfs.inodeToPath.GetPath(fh.inode)
+	// the open file handle keeps its inode from being forgotten
+	fp, _ := fh.wfs.inodeToPath.GetPath(fh.inode)
+	return fp
 }
 
 func (fh *FileHandle) addChunks(chunks []*filer_pb.FileChunk) {
@@ -84,6 +86,7 @@ func (fh *FileHandle) addChunks(chunks []*filer_pb.FileChunk) {
 	fh.chunkAddLock.Lock()
 	fh.entry.Chunks = append(fh.entry.Chunks, newChunks...)
 	fh.entryViewCache = nil
+	fh.wfs.readCache.Invalidate(fh.inode)
 	fh.chunkAddLock.Unlock()
 }
 
@@ -74,7 +74,7 @@ func (fh *FileHandle) readFromChunks(buff []byte, offset int64) (int64, error) {
 	}
 	fh.reader = reader
 
-	totalRead, err := reader.ReadAt(buff, offset)
+	totalRead, err := fh.wfs.readCache.ReadAt(fh.inode, reader, buff, offset, fileSize)
 
 	if err != nil && err != io.EOF {
 		glog.Errorf("file handle read %s: %v", fileFullPath, err)
@@ -3,7 +3,10 @@ package mount
 import (
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"strings"
 	"sync"
+	"syscall"
 )
 
 type InodeToPath struct {
@@ -11,12 +14,15 @@ type InodeToPath struct {
 	nextInodeId uint64
 	inode2path  map[uint64]*InodeEntry
 	path2inode  map[util.FullPath]uint64
+	// directories with cached children but no inode yet, e.g. prefetched before the kernel looks them up
+	childrenCachedPaths map[util.FullPath]struct{}
 }
 type InodeEntry struct {
 	util.FullPath
 	nlookup          uint64
 	isDirectory      bool
 	isChildrenCached bool
+	generation       uint64 // bumped when the inode is reused for another entry at its path
 }
 
 func NewInodeToPath() *InodeToPath {
@@ -24,8 +30,10 @@ func NewInodeToPath() *InodeToPath {
 		inode2path:  make(map[uint64]*InodeEntry),
 		path2inode:  make(map[util.FullPath]uint64),
 		nextInodeId: 2, // the root inode id is 1
+
+		childrenCachedPaths: make(map[util.FullPath]struct{}),
 	}
-	t.inode2path[1] = &InodeEntry{"/", 1, true, false}
+	t.inode2path[1] = &InodeEntry{"/", 1, true, false, 1}
 	t.path2inode["/"] = 1
 	return t
 }
@@ -38,7 +46,9 @@ func (i *InodeToPath) Lookup(path util.FullPath, isDirectory bool) uint64 {
 		inode = i.nextInodeId
 		i.nextInodeId++
 		i.path2inode[path] = inode
-		i.inode2path[inode] = &InodeEntry{path, 1, isDirectory, false}
+		_, isChildrenCached := i.childrenCachedPaths[path]
+		delete(i.childrenCachedPaths, path)
+		i.inode2path[inode] = &InodeEntry{path, 1, isDirectory, isChildrenCached, 1}
 	} else {
 		i.inode2path[inode].nlookup++
 	}
@@ -59,14 +69,16 @@ func (i *InodeToPath) GetInode(path util.FullPath) uint64 {
 	return inode
 }
 
-func (i *InodeToPath) GetPath(inode uint64) util.FullPath {
+// GetPath returns ESTALE if the inode is already forgotten, e.g. evicted while a request is in flight.
+func (i *InodeToPath) GetPath(inode uint64) (util.FullPath, fuse.Status) {
 	i.RLock()
 	defer i.RUnlock()
 	path, found := i.inode2path[inode]
 	if !found {
-		glog.Fatalf("not found inode %d", inode)
+		glog.V(1).Infof("not found inode %d", inode)
+		return "", fuse.Status(syscall.ESTALE)
 	}
-	return path.FullPath
+	return path.FullPath, fuse.OK
 }
 
 func (i *InodeToPath) HasPath(path util.FullPath) bool {
@@ -77,11 +89,12 @@ func (i *InodeToPath) HasPath(path util.FullPath) bool {
 }
 
 func (i *InodeToPath) MarkChildrenCached(fullpath util.FullPath) {
-	i.RLock()
-	defer i.RUnlock()
+	i.Lock()
+	defer i.Unlock()
 	inode, found := i.path2inode[fullpath]
 	if !found {
-		glog.Fatalf("MarkChildrenCached not found inode %v", fullpath)
+		i.childrenCachedPaths[fullpath] = struct{}{}
+		return
 	}
 	path, found := i.inode2path[inode]
 	path.isChildrenCached = true
@@ -92,7 +105,8 @@ func (i *InodeToPath) IsChildrenCached(fullpath util.FullPath) bool {
 	defer i.RUnlock()
 	inode, found := i.path2inode[fullpath]
 	if !found {
-		return false
+		_, found = i.childrenCachedPaths[fullpath]
+		return found
 	}
 	path, found := i.inode2path[inode]
 	if found {
@@ -101,6 +115,26 @@ func (i *InodeToPath) IsChildrenCached(fullpath util.FullPath) bool {
 	return false
 }
 
+// Generation returns the generation of the inode, 1 unless the inode has been reused.
+func (i *InodeToPath) Generation(inode uint64) uint64 {
+	i.RLock()
+	defer i.RUnlock()
+	if path, found := i.inode2path[inode]; found {
+		return path.generation
+	}
+	return 1
+}
+
+// NextGeneration bumps the generation of the inode of the path, whose entry is gone while the kernel still holds the inode.
+// An entry created later at the path reuses the inode under the new generation.
+func (i *InodeToPath) NextGeneration(path util.FullPath) {
+	i.Lock()
+	defer i.Unlock()
+	if inode, found := i.path2inode[path]; found {
+		i.inode2path[inode].generation++
+	}
+}
+
 func (i *InodeToPath) HasInode(inode uint64) bool {
 	if inode == 1 {
 		return true
@@ -114,6 +148,7 @@ func (i *InodeToPath) HasInode(inode uint64) bool {
 func (i *InodeToPath) RemovePath(path util.FullPath) {
 	i.Lock()
 	defer i.Unlock()
+	i.forgetChildrenCachedPaths(path)
 	inode, found := i.path2inode[path]
 	if found {
 		delete(i.path2inode, path)
@@ -121,9 +156,21 @@ func (i *InodeToPath) RemovePath(path util.FullPath) {
 	}
 }
 
+// forgetChildrenCachedPaths drops the inode-less cached directories at or under the path,
+// since their cached children are not moved or removed with it.
+func (i *InodeToPath) forgetChildrenCachedPaths(path util.FullPath) {
+	for cachedPath := range i.childrenCachedPaths {
+		if cachedPath == path || strings.HasPrefix(string(cachedPath), string(path)+"/") {
+			delete(i.childrenCachedPaths, cachedPath)
+		}
+	}
+}
+
 func (i *InodeToPath) MovePath(sourcePath, targetPath util.FullPath) {
 	i.Lock()
 	defer i.Unlock()
+	i.forgetChildrenCachedPaths(sourcePath)
+	i.forgetChildrenCachedPaths(targetPath)
 	sourceInode, sourceFound := i.path2inode[sourcePath]
 	targetInode, targetFound := i.path2inode[targetPath]
 	if sourceFound {
@@ -7,7 +7,9 @@ import (
 	"github.com/chrislusf/seaweedfs/weed/glog"
 	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
 	"github.com/chrislusf/seaweedfs/weed/util"
+	"math"
 	"os"
+	"time"
 )
 
 // need to have logic similar to FilerStoreWrapper
@@ -20,6 +22,9 @@ type MetaCache struct {
 	markCachedFn   func(fullpath util.FullPath)
 	isCachedFn     func(fullpath util.FullPath) bool
 	invalidateFunc func(fullpath util.FullPath, entry *filer_pb.Entry)
+	dirWatches     dirWatches
+	// called with the chunks of the files deleted or overwritten by other clients
+	replacedChunksFn func(fileIds []string)
 }
 
 func NewMetaCache(dbFolder string, uidGidMapper *UidGidMapper, markCachedFn func(path util.FullPath), isCachedFn func(path util.FullPath) bool, invalidateFunc func(util.FullPath, *filer_pb.Entry)) *MetaCache {
@@ -55,7 +60,14 @@ func openMetaStore(dbFolder string) filer.VirtualFilerStore {
 func (mc *MetaCache) InsertEntry(ctx context.Context, entry *filer.Entry) error {
 	//mc.Lock()
 	//defer mc.Unlock()
-	return mc.doInsertEntry(ctx, entry)
+	watched, existed := mc.exists(ctx, entry.FullPath)
+	if err := mc.doInsertEntry(ctx, entry); err != nil {
+		return err
+	}
+	if watched {
+		mc.recordChange(entry.FullPath, existed, entry)
+	}
+	return nil
 }
 
 func (mc *MetaCache) doInsertEntry(ctx context.Context, entry *filer.Entry) error {
@@ -74,7 +86,7 @@ func (mc *MetaCache) AtomicUpdateEntryFromFiler(ctx context.Context, oldPath uti
 				// leave the update to the following InsertEntry operation
 			} else {
 				glog.V(3).Infof("DeleteEntry %s", oldPath)
-				if err := mc.localStore.DeleteEntry(ctx, oldPath); err != nil {
+				if err := mc.DeleteEntry(ctx, oldPath); err != nil {
 					return err
 				}
 			}
@@ -87,7 +99,7 @@ func (mc *MetaCache) AtomicUpdateEntryFromFiler(ctx context.Context, oldPath uti
 		newDir, _ := newEntry.DirAndName()
 		if mc.isCachedFn(util.FullPath(newDir)) {
 			glog.V(3).Infof("InsertEntry %s/%s", newDir, newEntry.Name())
-			if err := mc.localStore.InsertEntry(ctx, newEntry); err != nil {
+			if err := mc.InsertEntry(ctx, newEntry); err != nil {
 				return err
 			}
 		}
@@ -98,7 +110,14 @@ func (mc *MetaCache) AtomicUpdateEntryFromFiler(ctx context.Context, oldPath uti
 func (mc *MetaCache) UpdateEntry(ctx context.Context, entry *filer.Entry) error {
 	//mc.Lock()
 	//defer mc.Unlock()
-	return mc.localStore.UpdateEntry(ctx, entry)
+	watched, existed := mc.exists(ctx, entry.FullPath)
+	if err := mc.localStore.UpdateEntry(ctx, entry); err != nil {
+		return err
+	}
+	if watched {
+		mc.recordChange(entry.FullPath, existed, entry)
+	}
+	return nil
 }
 
 func (mc *MetaCache) FindEntry(ctx context.Context, fp util.FullPath) (entry *filer.Entry, err error) {
@@ -115,15 +134,42 @@ func (mc *MetaCache) FindEntry(ctx context.Context, fp util.FullPath) (entry *fi
 func (mc *MetaCache) DeleteEntry(ctx context.Context, fp util.FullPath) (err error) {
 	//mc.Lock()
 	//defer mc.Unlock()
-	return mc.localStore.DeleteEntry(ctx, fp)
+	watched, existed := mc.exists(ctx, fp)
+	if err = mc.localStore.DeleteEntry(ctx, fp); err != nil {
+		return err
+	}
+	if watched && existed {
+		mc.recordChange(fp, existed, nil)
+	}
+	return nil
 }
 func (mc *MetaCache) DeleteFolderChildren(ctx context.Context, fp util.FullPath) (err error) {
 	//mc.Lock()
 	//defer mc.Unlock()
+	mc.expireChanges(fp)
 	return mc.localStore.DeleteFolderChildren(ctx, fp)
 }
 
 func (mc *MetaCache) ListDirectoryEntries(ctx context.Context, dirPath util.FullPath, startFileName string, includeStartFile bool, limit int64, eachEntryFunc filer.ListEachEntryFunc) error {
+	return mc.ListDirectoryEntriesWithOptions(ctx, dirPath, ListOptions{
+		StartFileName:    startFileName,
+		IncludeStartFile: includeStartFile,
+		Limit:            limit,
+	}, eachEntryFunc)
+}
+
+// ListOptions tells which entries of a directory to list, and in which order.
+type ListOptions struct {
+	StartFileName    string
+	IncludeStartFile bool
+	Limit            int64
+	// list in descending name order, from the entry before StartFileName, or from the last entry if it is empty
+	Descending bool
+	// if not zero, list only the entries modified after it, the Limit counting only them
+	ModifiedSince time.Time
+}
+
+func (mc *MetaCache) ListDirectoryEntriesWithOptions(ctx context.Context, dirPath util.FullPath, opts ListOptions, eachEntryFunc filer.ListEachEntryFunc) error {
 	//mc.RLock()
 	//defer mc.RUnlock()
 
@@ -132,13 +178,40 @@ func (mc *MetaCache) ListDirectoryEntries(ctx context.Context, dirPath util.Full
 		glog.Warningf("unsynchronized dir: %v", dirPath)
 	}
 
-	_, err := mc.localStore.ListDirectoryEntries(ctx, dirPath, startFileName, includeStartFile, limit, func(entry *filer.Entry) bool {
+	eachLocalEntryFunc := func(entry *filer.Entry) bool {
 		mc.mapIdFromFilerToLocal(entry)
 		return eachEntryFunc(entry)
-	})
-	if err != nil {
+	}
+
+	if !opts.ModifiedSince.IsZero() && !opts.Descending {
+		_, err := mc.localStore.ListDirectoryEntriesModifiedSince(ctx, dirPath, opts.StartFileName, opts.IncludeStartFile, opts.Limit, opts.ModifiedSince, eachLocalEntryFunc)
+		if err != filer.ErrUnsupportedModifiedSinceListing {
+			return err
+		}
+	}
+
+	listFn := mc.localStore.ListDirectoryEntries
+	if opts.Descending {
+		listFn = mc.localStore.ListDirectoryEntriesDescending
+	}
+	if opts.ModifiedSince.IsZero() {
+		_, err := listFn(ctx, dirPath, opts.StartFileName, opts.IncludeStartFile, opts.Limit, eachLocalEntryFunc)
 		return err
 	}
+
+	// the store can not skip the older entries, so all entries after StartFileName are read
+	// until Limit newer ones are found, which can be the whole directory for a few changes
+	count := int64(0)
+	_, err := listFn(ctx, dirPath, opts.StartFileName, opts.IncludeStartFile, math.MaxInt32, func(entry *filer.Entry) bool {
+		if !entry.Mtime.After(opts.ModifiedSince) {
+			return true
+		}
+		count++
+		if count > opts.Limit {
+			return false
+		}
+		return eachLocalEntryFunc(entry) && count < opts.Limit
+	})
 	return err
 }
 
@@ -0,0 +1,34 @@
+package meta_cache
+
+import (
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+// SetReplacedChunksFn calls fn with the chunks no longer used once another client deletes or overwrites a file,
+// e.g. to drop them from the chunk cache. Renamed files keep their chunks.
+func (mc *MetaCache) SetReplacedChunksFn(fn func(fileIds []string)) {
+	mc.replacedChunksFn = fn
+}
+
+func (mc *MetaCache) dropReplacedChunks(oldEntry, newEntry *filer_pb.Entry) {
+	if mc.replacedChunksFn == nil || oldEntry == nil {
+		return
+	}
+	if fileIds := replacedChunks(oldEntry, newEntry); len(fileIds) > 0 {
+		mc.replacedChunksFn(fileIds)
+	}
+}
+
+// replacedChunks returns the file ids of the chunks of the old entry the new one, if any, no longer uses
+func replacedChunks(oldEntry, newEntry *filer_pb.Entry) (fileIds []string) {
+	kept := make(map[string]struct{})
+	for _, chunk := range newEntry.GetChunks() {
+		kept[chunk.GetFileIdString()] = struct{}{}
+	}
+	for _, chunk := range oldEntry.GetChunks() {
+		if _, found := kept[chunk.GetFileIdString()]; !found {
+			fileIds = append(fileIds, chunk.GetFileIdString())
+		}
+	}
+	return
+}
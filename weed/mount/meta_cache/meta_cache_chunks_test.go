@@ -0,0 +1,75 @@
+package meta_cache
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestReplacedChunksOfFollowedChanges(t *testing.T) {
+
+	uidGidMapper, _ := NewUidGidMapper("", "")
+	cached := map[util.FullPath]bool{"/dir": true, "/other": true}
+	mc := NewMetaCache(filepath.Join(t.TempDir(), "meta"), uidGidMapper, func(path util.FullPath) {
+		cached[path] = true
+	}, func(path util.FullPath) bool {
+		return cached[path]
+	}, func(path util.FullPath, entry *filer_pb.Entry) {
+	})
+	defer mc.Shutdown()
+
+	var dropped []string
+	mc.SetReplacedChunksFn(func(fileIds []string) {
+		dropped = append(dropped, fileIds...)
+	})
+	newEntry := func(name string, fileIds ...string) *filer_pb.Entry {
+		entry := &filer_pb.Entry{Name: name, Attributes: &filer_pb.FuseAttributes{FileMode: 0644}}
+		for _, fileId := range fileIds {
+			entry.Chunks = append(entry.Chunks, &filer_pb.FileChunk{FileId: fileId})
+		}
+		return entry
+	}
+	apply := func(directory string, oldEntry, newEntry *filer_pb.Entry, newParentPath string) string {
+		t.Helper()
+		dropped = nil
+		if err := mc.applyMetaEvent(&filer_pb.SubscribeMetadataResponse{
+			Directory: directory,
+			EventNotification: &filer_pb.EventNotification{
+				OldEntry:      oldEntry,
+				NewEntry:      newEntry,
+				NewParentPath: newParentPath,
+			},
+		}); err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+		return strings.Join(dropped, " ")
+	}
+
+	created := newEntry("file", "1,01", "1,02")
+	if replaced := apply("/dir", nil, created, "/dir"); replaced != "" {
+		t.Errorf("created file replaced %s", replaced)
+	}
+
+	// overwritten in part
+	overwritten := newEntry("file", "1,01", "1,03")
+	if replaced := apply("/dir", created, overwritten, "/dir"); replaced != "1,02" {
+		t.Errorf("overwrite replaced %q, expect 1,02", replaced)
+	}
+
+	// renamed into another directory, and within it, keeping all the chunks
+	if replaced := apply("/dir", overwritten, overwritten, "/other"); replaced != "" {
+		t.Errorf("move replaced %q", replaced)
+	}
+	renamed := newEntry("renamed", "1,01", "1,03")
+	if replaced := apply("/other", overwritten, renamed, "/other"); replaced != "" {
+		t.Errorf("rename replaced %q", replaced)
+	}
+
+	if replaced := apply("/other", renamed, nil, ""); replaced != "1,01 1,03" {
+		t.Errorf("delete replaced %q, expect 1,01 1,03", replaced)
+	}
+
+}
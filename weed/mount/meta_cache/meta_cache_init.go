@@ -3,6 +3,7 @@ package meta_cache
 import (
 	"context"
 	"fmt"
+	"math"
 
 	"github.com/chrislusf/seaweedfs/weed/filer"
 	"github.com/chrislusf/seaweedfs/weed/glog"
@@ -11,6 +12,11 @@ import (
 )
 
 func EnsureVisited(mc *MetaCache, client filer_pb.FilerClient, dirPath util.FullPath) error {
+	return EnsureVisitedWithContext(context.Background(), mc, client, dirPath)
+}
+
+// EnsureVisitedWithContext stops listing once the context is done, leaving the unfinished directory not cached.
+func EnsureVisitedWithContext(ctx context.Context, mc *MetaCache, client filer_pb.FilerClient, dirPath util.FullPath) error {
 
 	for {
 
@@ -20,7 +26,7 @@ func EnsureVisited(mc *MetaCache, client filer_pb.FilerClient, dirPath util.Full
 			return nil
 		}
 
-		if err := doEnsureVisited(mc, client, dirPath); err != nil {
+		if err := doEnsureVisited(ctx, mc, client, dirPath); err != nil {
 			return err
 		}
 
@@ -37,17 +43,20 @@ func EnsureVisited(mc *MetaCache, client filer_pb.FilerClient, dirPath util.Full
 
 }
 
-func doEnsureVisited(mc *MetaCache, client filer_pb.FilerClient, path util.FullPath) error {
+func doEnsureVisited(ctx context.Context, mc *MetaCache, client filer_pb.FilerClient, path util.FullPath) error {
 
 	glog.V(4).Infof("ReadDirAllEntries %s ...", path)
 
 	err := util.Retry("ReadDirAllEntries", func() error {
-		return filer_pb.ReadDirAllEntries(client, path, "", func(pbEntry *filer_pb.Entry, isLast bool) error {
+		return filer_pb.ReadDirAllEntriesWithContext(ctx, client, path, "", func(pbEntry *filer_pb.Entry, isLast bool) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 			entry := filer.FromPbEntry(string(path), pbEntry)
 			if IsHiddenSystemEntry(string(path), entry.Name()) {
 				return nil
 			}
-			if err := mc.doInsertEntry(context.Background(), entry); err != nil {
+			if err := mc.doInsertEntry(ctx, entry); err != nil {
 				glog.V(0).Infof("read %s: %v", entry.FullPath, err)
 				return err
 			}
@@ -55,6 +64,9 @@ func doEnsureVisited(mc *MetaCache, client filer_pb.FilerClient, path util.FullP
 		})
 	})
 
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("list %s: %w", path, ctxErr)
+	}
 	if err != nil {
 		err = fmt.Errorf("list %s: %v", path, err)
 	}
@@ -62,6 +74,66 @@ func doEnsureVisited(mc *MetaCache, client filer_pb.FilerClient, path util.FullP
 	return err
 }
 
+// RefreshDirectory re-reads the directory children from the filer even if they are cached,
+// updating the changed entries and removing the ones gone from the filer.
+func RefreshDirectory(mc *MetaCache, client filer_pb.FilerClient, dirPath util.FullPath) error {
+	return RefreshDirectoryWithContext(context.Background(), mc, client, dirPath)
+}
+
+// RefreshDirectoryWithContext stops listing once the context is done, keeping the cached entries.
+func RefreshDirectoryWithContext(ctx context.Context, mc *MetaCache, client filer_pb.FilerClient, dirPath util.FullPath) error {
+
+	glog.V(4).Infof("RefreshDirectory %s ...", dirPath)
+
+	var names map[string]struct{}
+	err := util.Retry("RefreshDirectory", func() error {
+		names = make(map[string]struct{})
+		return filer_pb.ReadDirAllEntriesWithContext(ctx, client, dirPath, "", func(pbEntry *filer_pb.Entry, isLast bool) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			entry := filer.FromPbEntry(string(dirPath), pbEntry)
+			if IsHiddenSystemEntry(string(dirPath), entry.Name()) {
+				return nil
+			}
+			names[entry.Name()] = struct{}{}
+			return mc.doInsertEntry(context.Background(), entry)
+		})
+	})
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("refresh %s: %w", dirPath, ctxErr)
+	}
+	if err != nil {
+		return fmt.Errorf("refresh %s: %v", dirPath, err)
+	}
+
+	var staleEntries []*filer.Entry
+	_, err = mc.localStore.ListDirectoryEntries(context.Background(), dirPath, "", false, math.MaxInt32, func(entry *filer.Entry) bool {
+		if _, found := names[entry.Name()]; !found {
+			staleEntries = append(staleEntries, entry)
+		}
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("refresh %s: %v", dirPath, err)
+	}
+	for _, entry := range staleEntries {
+		if entry.IsDirectory() {
+			if err := mc.localStore.DeleteFolderChildren(context.Background(), entry.FullPath); err != nil {
+				return fmt.Errorf("refresh %s: %v", dirPath, err)
+			}
+		}
+		if err := mc.localStore.DeleteEntry(context.Background(), entry.FullPath); err != nil {
+			return fmt.Errorf("refresh %s: %v", dirPath, err)
+		}
+	}
+
+	// the watchers list the refreshed directory again, rather than follow each entry refreshed
+	mc.expireChanges(dirPath)
+	mc.markCachedFn(dirPath)
+	return nil
+}
+
 func IsHiddenSystemEntry(dir, name string) bool {
 	return dir == "/" && (name == "topics" || name == "etc")
 }
@@ -0,0 +1,152 @@
+package meta_cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// fakeFilerClient lists the directory entries kept in memory
+type fakeFilerClient struct {
+	filer_pb.SeaweedFilerClient
+	entries map[string][]*filer_pb.Entry
+	onRecv  func() // called for each entry received
+}
+
+func (c *fakeFilerClient) WithFilerClient(streamingMode bool, fn func(filer_pb.SeaweedFilerClient) error) error {
+	return fn(c)
+}
+
+func (c *fakeFilerClient) AdjustedUrl(location *filer_pb.Location) string {
+	return location.Url
+}
+
+func (c *fakeFilerClient) ListEntries(ctx context.Context, in *filer_pb.ListEntriesRequest, opts ...grpc.CallOption) (filer_pb.SeaweedFiler_ListEntriesClient, error) {
+	return &fakeListEntriesClient{entries: c.entries[in.Directory], onRecv: c.onRecv}, nil
+}
+
+type fakeListEntriesClient struct {
+	filer_pb.SeaweedFiler_ListEntriesClient
+	entries []*filer_pb.Entry
+	onRecv  func()
+}
+
+func (c *fakeListEntriesClient) Recv() (*filer_pb.ListEntriesResponse, error) {
+	if len(c.entries) == 0 {
+		return nil, io.EOF
+	}
+	if c.onRecv != nil {
+		c.onRecv()
+	}
+	entry := c.entries[0]
+	c.entries = c.entries[1:]
+	return &filer_pb.ListEntriesResponse{Entry: entry}, nil
+}
+
+func listNames(t *testing.T, mc *MetaCache, dirPath util.FullPath) (names string) {
+	err := mc.ListDirectoryEntries(context.Background(), dirPath, "", false, math.MaxInt32, func(entry *filer.Entry) bool {
+		names += entry.Name() + " "
+		return true
+	})
+	if err != nil {
+		t.Fatalf("list %s: %v", dirPath, err)
+	}
+	return
+}
+
+func TestRefreshDirectory(t *testing.T) {
+
+	uidGidMapper, _ := NewUidGidMapper("", "")
+	cached := make(map[util.FullPath]bool)
+	mc := NewMetaCache(filepath.Join(t.TempDir(), "meta"), uidGidMapper, func(path util.FullPath) {
+		cached[path] = true
+	}, func(path util.FullPath) bool {
+		return cached[path]
+	}, func(path util.FullPath, entry *filer_pb.Entry) {
+	})
+	defer mc.Shutdown()
+
+	client := &fakeFilerClient{entries: map[string][]*filer_pb.Entry{
+		"/dir": {{Name: "a"}, {Name: "old"}},
+	}}
+	if err := EnsureVisited(mc, client, "/dir"); err != nil {
+		t.Fatalf("visit: %v", err)
+	}
+
+	// changed by another client, not seen through the cache
+	client.entries["/dir"] = []*filer_pb.Entry{{Name: "a"}, {Name: "new"}}
+	if err := EnsureVisited(mc, client, "/dir"); err != nil {
+		t.Fatalf("visit: %v", err)
+	}
+	if names := listNames(t, mc, "/dir"); names != "a old " {
+		t.Fatalf("cached listing: %q", names)
+	}
+
+	if err := RefreshDirectory(mc, client, "/dir"); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if names := listNames(t, mc, "/dir"); names != "a new " {
+		t.Errorf("refreshed listing: %q", names)
+	}
+
+}
+
+func TestEnsureVisitedCancelled(t *testing.T) {
+
+	uidGidMapper, _ := NewUidGidMapper("", "")
+	cached := make(map[util.FullPath]bool)
+	mc := NewMetaCache(filepath.Join(t.TempDir(), "meta"), uidGidMapper, func(path util.FullPath) {
+		cached[path] = true
+	}, func(path util.FullPath) bool {
+		return cached[path]
+	}, func(path util.FullPath, entry *filer_pb.Entry) {
+	})
+	defer mc.Shutdown()
+	cached["/"] = true
+
+	var entries []*filer_pb.Entry
+	for i := 0; i < 1000; i++ {
+		entries = append(entries, &filer_pb.Entry{Name: fmt.Sprintf("file%04d", i)})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	received := 0
+	client := &fakeFilerClient{
+		entries: map[string][]*filer_pb.Entry{"/dir": entries},
+		onRecv: func() {
+			received++
+			if received == 10 {
+				cancel()
+			}
+		},
+	}
+
+	err := EnsureVisitedWithContext(ctx, mc, client, "/dir")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled visit: %v", err)
+	}
+	if received > 11 {
+		t.Errorf("received %d entries after cancelling at the 10th", received)
+	}
+	if cached["/dir"] {
+		t.Errorf("partly listed directory marked as cached")
+	}
+
+	client.onRecv = nil
+	if err := EnsureVisited(mc, client, "/dir"); err != nil {
+		t.Fatalf("visit: %v", err)
+	}
+	if names := listNames(t, mc, "/dir"); len(names) != 1000*len("file0000 ") {
+		t.Errorf("listed %d bytes of names after visiting again", len(names))
+	}
+
+}
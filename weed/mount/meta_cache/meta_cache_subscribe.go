@@ -12,49 +12,12 @@ import (
 func SubscribeMetaEvents(mc *MetaCache, selfSignature int32, client filer_pb.FilerClient, dir string, lastTsNs int64) error {
 
 	processEventFn := func(resp *filer_pb.SubscribeMetadataResponse) error {
-		message := resp.EventNotification
-
-		for _, sig := range message.Signatures {
+		for _, sig := range resp.EventNotification.Signatures {
 			if sig == selfSignature && selfSignature != 0 {
 				return nil
 			}
 		}
-
-		dir := resp.Directory
-		var oldPath util.FullPath
-		var newEntry *filer.Entry
-		if message.OldEntry != nil {
-			oldPath = util.NewFullPath(dir, message.OldEntry.Name)
-			glog.V(4).Infof("deleting %v", oldPath)
-		}
-
-		if message.NewEntry != nil {
-			if message.NewParentPath != "" {
-				dir = message.NewParentPath
-			}
-			key := util.NewFullPath(dir, message.NewEntry.Name)
-			glog.V(4).Infof("creating %v", key)
-			newEntry = filer.FromPbEntry(dir, message.NewEntry)
-		}
-		err := mc.AtomicUpdateEntryFromFiler(context.Background(), oldPath, newEntry)
-		if err == nil {
-			if message.OldEntry != nil && message.NewEntry != nil {
-				oldKey := util.NewFullPath(resp.Directory, message.OldEntry.Name)
-				mc.invalidateFunc(oldKey, message.OldEntry)
-				if message.OldEntry.Name != message.NewEntry.Name {
-					newKey := util.NewFullPath(dir, message.NewEntry.Name)
-					mc.invalidateFunc(newKey, message.NewEntry)
-				}
-			} else if message.OldEntry == nil && message.NewEntry != nil {
-				// no need to invaalidate
-			} else if message.OldEntry != nil && message.NewEntry == nil {
-				oldKey := util.NewFullPath(resp.Directory, message.OldEntry.Name)
-				mc.invalidateFunc(oldKey, message.OldEntry)
-			}
-		}
-
-		return err
-
+		return mc.applyMetaEvent(resp)
 	}
 
 	util.RetryForever("followMetaUpdates", func() error {
@@ -66,3 +29,44 @@ func SubscribeMetaEvents(mc *MetaCache, selfSignature int32, client filer_pb.Fil
 
 	return nil
 }
+
+// applyMetaEvent updates the cached entries by a change followed from the filer
+func (mc *MetaCache) applyMetaEvent(resp *filer_pb.SubscribeMetadataResponse) error {
+	message := resp.EventNotification
+
+	dir := resp.Directory
+	var oldPath util.FullPath
+	var newEntry *filer.Entry
+	if message.OldEntry != nil {
+		oldPath = util.NewFullPath(dir, message.OldEntry.Name)
+		glog.V(4).Infof("deleting %v", oldPath)
+	}
+
+	if message.NewEntry != nil {
+		if message.NewParentPath != "" {
+			dir = message.NewParentPath
+		}
+		key := util.NewFullPath(dir, message.NewEntry.Name)
+		glog.V(4).Infof("creating %v", key)
+		newEntry = filer.FromPbEntry(dir, message.NewEntry)
+	}
+	err := mc.AtomicUpdateEntryFromFiler(context.Background(), oldPath, newEntry)
+	if err == nil {
+		if message.OldEntry != nil && message.NewEntry != nil {
+			oldKey := util.NewFullPath(resp.Directory, message.OldEntry.Name)
+			mc.invalidateFunc(oldKey, message.OldEntry)
+			if message.OldEntry.Name != message.NewEntry.Name {
+				newKey := util.NewFullPath(dir, message.NewEntry.Name)
+				mc.invalidateFunc(newKey, message.NewEntry)
+			}
+		} else if message.OldEntry == nil && message.NewEntry != nil {
+			// no need to invaalidate
+		} else if message.OldEntry != nil && message.NewEntry == nil {
+			oldKey := util.NewFullPath(resp.Directory, message.OldEntry.Name)
+			mc.invalidateFunc(oldKey, message.OldEntry)
+		}
+		mc.dropReplacedChunks(message.OldEntry, message.NewEntry)
+	}
+
+	return err
+}
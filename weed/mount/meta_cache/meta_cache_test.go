@@ -0,0 +1,80 @@
+package meta_cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestListDirectoryEntriesModifiedSince(t *testing.T) {
+
+	uidGidMapper, _ := NewUidGidMapper("", "")
+	mc := NewMetaCache(filepath.Join(t.TempDir(), "meta"), uidGidMapper, func(path util.FullPath) {
+	}, func(path util.FullPath) bool {
+		return true
+	}, func(path util.FullPath, entry *filer_pb.Entry) {
+	})
+	defer mc.Shutdown()
+
+	ctx := context.Background()
+	lastRun := time.Unix(1600000000, 0)
+	for name, mtime := range map[string]time.Time{
+		"a": lastRun.Add(-time.Hour),
+		"b": lastRun.Add(time.Second),
+		"c": lastRun,
+		"d": lastRun.Add(time.Hour),
+		"e": lastRun.Add(-time.Second),
+		"f": lastRun.Add(2 * time.Hour),
+		"g": lastRun.Add(-2 * time.Hour),
+	} {
+		entry := &filer.Entry{FullPath: util.NewFullPath("/dir", name), Attr: filer.Attr{Mtime: mtime, Crtime: mtime}}
+		if err := mc.InsertEntry(ctx, entry); err != nil {
+			t.Fatalf("insert %s: %v", name, err)
+		}
+	}
+
+	listByPages := func(descending bool) (listed []string) {
+		for lastFileName := ""; ; {
+			count := 0
+			err := mc.ListDirectoryEntriesWithOptions(ctx, "/dir", ListOptions{
+				StartFileName: lastFileName,
+				Limit:         2,
+				Descending:    descending,
+				ModifiedSince: lastRun,
+			}, func(entry *filer.Entry) bool {
+				if !entry.Mtime.After(lastRun) {
+					t.Errorf("listed %s modified at %v", entry.Name(), entry.Mtime)
+				}
+				listed = append(listed, entry.Name())
+				lastFileName = entry.Name()
+				count++
+				return true
+			})
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if count > 2 {
+				t.Fatalf("listed %d entries in a page of 2", count)
+			}
+			if count == 0 {
+				return
+			}
+		}
+	}
+
+	// filtered by the store
+	if listed := listByPages(false); fmt.Sprint(listed) != "[b d f]" {
+		t.Errorf("listed %v, expect [b d f]", listed)
+	}
+	// filtered after listing, as the store can not filter descending listings
+	if listed := listByPages(true); fmt.Sprint(listed) != "[f d b]" {
+		t.Errorf("listed %v in descending order, expect [f d b]", listed)
+	}
+
+}
@@ -0,0 +1,197 @@
+package meta_cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// ErrDirChangesExpired is returned for changes no longer kept, or for an unwatched directory.
+// The watcher needs to list the directory again, and continue from the version returned by WatchDirectory.
+var ErrDirChangesExpired = errors.New("directory changes expired")
+
+// maxDirChanges bounds the changes kept for each watched directory
+const maxDirChanges = 4096
+
+type DirChangeType int
+
+const (
+	DirEntryAdded DirChangeType = iota
+	DirEntryRemoved
+	DirEntryModified
+)
+
+// DirChange is the net change to one entry name, e.g. a file created and removed again is no change.
+type DirChange struct {
+	Type  DirChangeType
+	Name  string
+	Entry *filer.Entry // the current entry, nil if removed
+}
+
+type dirChange struct {
+	version int64
+	name    string
+	existed bool
+	exists  bool
+	entry   *filer.Entry
+}
+
+type dirChangeLog struct {
+	since   int64 // the oldest version the changes go back to
+	changes []dirChange
+	watches int // the WatchDirectory calls not unwatched yet
+}
+
+// dirWatches keeps the entry changes of the watched directories, made locally or followed from the filer.
+type dirWatches struct {
+	sync.Mutex
+	version int64
+	dirs    map[util.FullPath]*dirChangeLog
+}
+
+// WatchDirectory starts keeping the changes of the directory, and returns the version to list them from.
+// The changes come through the meta cache, so the directory changes made by other clients are seen
+// as far as the filer metadata subscription follows them.
+// Each call is paired with an UnwatchDirectory call, and the changes are kept until the last watch is unwatched.
+func (mc *MetaCache) WatchDirectory(dirPath util.FullPath) (version int64) {
+	w := &mc.dirWatches
+	w.Lock()
+	defer w.Unlock()
+	if w.dirs == nil {
+		w.dirs = make(map[util.FullPath]*dirChangeLog)
+	}
+	log, found := w.dirs[dirPath]
+	if !found {
+		log = &dirChangeLog{since: w.version}
+		w.dirs[dirPath] = log
+	}
+	log.watches++
+	return w.version
+}
+
+func (mc *MetaCache) UnwatchDirectory(dirPath util.FullPath) {
+	w := &mc.dirWatches
+	w.Lock()
+	defer w.Unlock()
+	log, found := w.dirs[dirPath]
+	if !found {
+		return
+	}
+	if log.watches--; log.watches <= 0 {
+		delete(w.dirs, dirPath)
+	}
+}
+
+// DirectoryChangesSince returns the changes of the watched directory after the version, in the order of
+// their first change, and the version to continue from.
+func (mc *MetaCache) DirectoryChangesSince(dirPath util.FullPath, version int64) (changes []DirChange, latest int64, err error) {
+	w := &mc.dirWatches
+	w.Lock()
+	defer w.Unlock()
+
+	log, found := w.dirs[dirPath]
+	if !found || version < log.since || version > w.version {
+		return nil, w.version, ErrDirChangesExpired
+	}
+
+	// only the state before the first change and after the last one matter
+	netChanges := make(map[string]*dirChange)
+	for i := range log.changes {
+		change := &log.changes[i]
+		if change.version <= version {
+			continue
+		}
+		netChange, found := netChanges[change.name]
+		if !found {
+			netChange = &dirChange{name: change.name, existed: change.existed}
+			netChanges[change.name] = netChange
+			changes = append(changes, DirChange{Name: change.name})
+		}
+		netChange.exists, netChange.entry = change.exists, change.entry
+	}
+
+	n := 0
+	for _, change := range changes {
+		netChange := netChanges[change.Name]
+		switch {
+		case !netChange.existed && netChange.exists:
+			change.Type = DirEntryAdded
+		case netChange.existed && !netChange.exists:
+			change.Type = DirEntryRemoved
+		case netChange.existed && netChange.exists:
+			change.Type = DirEntryModified
+		default:
+			continue
+		}
+		if netChange.exists {
+			change.Entry = netChange.entry
+		}
+		changes[n] = change
+		n++
+	}
+
+	return changes[:n], w.version, nil
+}
+
+func (w *dirWatches) isWatched(dirPath util.FullPath) bool {
+	w.Lock()
+	defer w.Unlock()
+	_, found := w.dirs[dirPath]
+	return found
+}
+
+func (mc *MetaCache) recordChange(fullpath util.FullPath, existed bool, entry *filer.Entry) {
+	dir, name := fullpath.DirAndName()
+	w := &mc.dirWatches
+	w.Lock()
+	defer w.Unlock()
+	log, found := w.dirs[util.FullPath(dir)]
+	if !found {
+		return
+	}
+	w.version++
+	change := dirChange{version: w.version, name: name, existed: existed, exists: entry != nil}
+	if entry != nil {
+		// the stored entries keep the filer ids
+		localEntry := *entry
+		mc.mapIdFromFilerToLocal(&localEntry)
+		change.entry = &localEntry
+	}
+	log.changes = append(log.changes, change)
+	if len(log.changes) > maxDirChanges {
+		dropped := len(log.changes) - maxDirChanges/2
+		log.since = log.changes[dropped-1].version
+		log.changes = append([]dirChange(nil), log.changes[dropped:]...)
+	}
+}
+
+// expireChanges drops the changes of the directory, e.g. when it is listed again, not one entry at a time
+func (mc *MetaCache) expireChanges(dirPath util.FullPath) {
+	w := &mc.dirWatches
+	w.Lock()
+	defer w.Unlock()
+	log, found := w.dirs[dirPath]
+	if !found {
+		return
+	}
+	w.version++
+	log.since, log.changes = w.version, nil
+}
+
+// exists tells whether the entry is cached, before it is changed in a watched directory
+func (mc *MetaCache) exists(ctx context.Context, fullpath util.FullPath) (watched, existed bool) {
+	dir, _ := fullpath.DirAndName()
+	if !mc.dirWatches.isWatched(util.FullPath(dir)) {
+		return false, false
+	}
+	_, err := mc.localStore.FindEntry(ctx, fullpath)
+	if err != nil && err != filer_pb.ErrNotFound {
+		glog.V(1).Infof("find %s: %v", fullpath, err)
+	}
+	return true, err == nil
+}
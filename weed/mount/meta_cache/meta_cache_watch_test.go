@@ -0,0 +1,138 @@
+package meta_cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func describeChanges(changes []DirChange) string {
+	var descriptions []string
+	for _, change := range changes {
+		switch change.Type {
+		case DirEntryAdded:
+			descriptions = append(descriptions, "+"+change.Name)
+		case DirEntryRemoved:
+			descriptions = append(descriptions, "-"+change.Name)
+		case DirEntryModified:
+			descriptions = append(descriptions, fmt.Sprintf("~%s:%d", change.Name, change.Entry.FileSize))
+		}
+	}
+	return strings.Join(descriptions, " ")
+}
+
+func TestDirectoryChangesSince(t *testing.T) {
+
+	uidGidMapper, _ := NewUidGidMapper("", "")
+	cached := map[util.FullPath]bool{"/dir": true, "/other": true}
+	mc := NewMetaCache(filepath.Join(t.TempDir(), "meta"), uidGidMapper, func(path util.FullPath) {
+		cached[path] = true
+	}, func(path util.FullPath) bool {
+		return cached[path]
+	}, func(path util.FullPath, entry *filer_pb.Entry) {
+	})
+	defer mc.Shutdown()
+
+	ctx := context.Background()
+	newEntry := func(fullpath util.FullPath, size uint64) *filer.Entry {
+		return &filer.Entry{FullPath: fullpath, Attr: filer.Attr{FileSize: size}}
+	}
+	mustDo := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	changesSince := func(version int64) (string, int64) {
+		t.Helper()
+		changes, latest, err := mc.DirectoryChangesSince("/dir", version)
+		if err != nil {
+			t.Fatalf("changes since %d: %v", version, err)
+		}
+		return describeChanges(changes), latest
+	}
+
+	for _, name := range []string{"a", "b", "c"} {
+		mustDo(mc.InsertEntry(ctx, newEntry(util.NewFullPath("/dir", name), 1)))
+	}
+	start := mc.WatchDirectory("/dir")
+	if changes, _ := changesSince(start); changes != "" {
+		t.Errorf("changes right after watching: %q", changes)
+	}
+
+	mustDo(mc.InsertEntry(ctx, newEntry("/dir/d", 1)))
+	mustDo(mc.DeleteEntry(ctx, "/dir/b"))
+	mustDo(mc.AtomicUpdateEntryFromFiler(ctx, "/dir/c", newEntry("/dir/e", 1)))
+	mustDo(mc.UpdateEntry(ctx, newEntry("/dir/a", 2)))
+	mustDo(mc.InsertEntry(ctx, newEntry("/dir/a", 3)))
+	// a short lived file is no change
+	mustDo(mc.InsertEntry(ctx, newEntry("/dir/tmp", 1)))
+	mustDo(mc.DeleteEntry(ctx, "/dir/tmp"))
+	// nor are the changes in other directories
+	mustDo(mc.InsertEntry(ctx, newEntry("/other/x", 1)))
+
+	changes, version := changesSince(start)
+	if changes != "+d -b -c +e ~a:3" {
+		t.Errorf("changes since watching: %q", changes)
+	}
+
+	// removed and created again is modified, renamed away is removed
+	mustDo(mc.DeleteEntry(ctx, "/dir/d"))
+	mustDo(mc.InsertEntry(ctx, newEntry("/dir/d", 4)))
+	mustDo(mc.AtomicUpdateEntryFromFiler(ctx, "/dir/e", newEntry("/other/e", 1)))
+	if changes, _ := changesSince(version); changes != "~d:4 -e" {
+		t.Errorf("changes since %d: %q", version, changes)
+	}
+	if changes, _ := changesSince(start); changes != "+d -b -c ~a:3" {
+		t.Errorf("changes since watching, again: %q", changes)
+	}
+
+	if _, _, err := mc.DirectoryChangesSince("/other", start); !errors.Is(err, ErrDirChangesExpired) {
+		t.Errorf("changes of an unwatched directory: %v", err)
+	}
+	mustDo(mc.DeleteFolderChildren(ctx, "/dir"))
+	if _, _, err := mc.DirectoryChangesSince("/dir", version); !errors.Is(err, ErrDirChangesExpired) {
+		t.Errorf("changes after dropping the directory from the cache: %v", err)
+	}
+
+}
+
+func TestWatchDirectoryUntilLastUnwatched(t *testing.T) {
+
+	uidGidMapper, _ := NewUidGidMapper("", "")
+	mc := NewMetaCache(filepath.Join(t.TempDir(), "meta"), uidGidMapper, func(path util.FullPath) {
+	}, func(path util.FullPath) bool {
+		return true
+	}, func(path util.FullPath, entry *filer_pb.Entry) {
+	})
+	defer mc.Shutdown()
+
+	first := mc.WatchDirectory("/dir")
+	mc.WatchDirectory("/dir")
+	if err := mc.InsertEntry(context.Background(), &filer.Entry{FullPath: "/dir/a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	mc.UnwatchDirectory("/dir")
+	if changes, _, err := mc.DirectoryChangesSince("/dir", first); err != nil || describeChanges(changes) != "+a" {
+		t.Errorf("changes %q, %v, after unwatching one of two watches", describeChanges(changes), err)
+	}
+	mc.UnwatchDirectory("/dir")
+	if _, _, err := mc.DirectoryChangesSince("/dir", first); !errors.Is(err, ErrDirChangesExpired) {
+		t.Errorf("changes after unwatching all watches: %v", err)
+	}
+	// unwatching more than watched does not take a later watch
+	mc.UnwatchDirectory("/dir")
+	version := mc.WatchDirectory("/dir")
+	if _, _, err := mc.DirectoryChangesSince("/dir", version); err != nil {
+		t.Errorf("changes of a watch after unwatching too often: %v", err)
+	}
+
+}
@@ -0,0 +1,84 @@
+package mount
+
+import (
+	"sync"
+	"unicode/utf8"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// NameAliases remembers the names handed out for entries other than their own, e.g. shortened for
+// names exceeding Option.MaxNameLength, or normalized, so that Lookup can resolve them back.
+// The aliases of a directory are dropped when its entries are removed or renamed, or when it is forgotten.
+type NameAliases struct {
+	sync.RWMutex
+	dirs map[util.FullPath]map[string]string // the names by alias, of each directory
+}
+
+func NewNameAliases() *NameAliases {
+	return &NameAliases{
+		dirs: make(map[util.FullPath]map[string]string),
+	}
+}
+
+func (na *NameAliases) Add(dir util.FullPath, alias, name string) {
+	na.Lock()
+	defer na.Unlock()
+	aliases, found := na.dirs[dir]
+	if !found {
+		aliases = make(map[string]string)
+		na.dirs[dir] = aliases
+	}
+	aliases[alias] = name
+}
+
+// Resolve returns the original name for the alias, or the name itself if it is not an alias.
+func (na *NameAliases) Resolve(dir util.FullPath, name string) string {
+	na.RLock()
+	defer na.RUnlock()
+	if original, found := na.dirs[dir][name]; found {
+		return original
+	}
+	return name
+}
+
+// Remove drops the name as an alias, and the aliases of the name, e.g. once the entry is deleted or renamed.
+func (na *NameAliases) Remove(dir util.FullPath, name string) {
+	na.Lock()
+	defer na.Unlock()
+	aliases, found := na.dirs[dir]
+	if !found {
+		return
+	}
+	delete(aliases, name)
+	for alias, original := range aliases {
+		if original == name {
+			delete(aliases, alias)
+		}
+	}
+	if len(aliases) == 0 {
+		delete(na.dirs, dir)
+	}
+}
+
+// RemoveDir drops the aliases of the entries in the directory.
+func (na *NameAliases) RemoveDir(dir util.FullPath) {
+	na.Lock()
+	defer na.Unlock()
+	delete(na.dirs, dir)
+}
+
+// shortenName deterministically derives an alias no longer than maxLength bytes,
+// keeping a readable prefix of the name followed by "~" and a hash of the full name.
+func shortenName(name string, maxLength int) string {
+	hash := util.Md5String([]byte(name))[:8]
+	keep := maxLength - len(hash) - 1
+	if keep <= 0 {
+		return hash[:min(int64(len(hash)), int64(maxLength))]
+	}
+	prefix := name[:keep]
+	for len(prefix) > 0 && !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix + "~" + hash
+}
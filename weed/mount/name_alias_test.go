@@ -0,0 +1,111 @@
+package mount
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestShortenName(t *testing.T) {
+
+	name := strings.Repeat("a", 300) + ".txt"
+
+	alias := shortenName(name, 64)
+	if len(alias) > 64 {
+		t.Errorf("alias %s is longer than 64 bytes", alias)
+	}
+	if alias != shortenName(name, 64) {
+		t.Errorf("alias should be deterministic")
+	}
+	if alias == shortenName(strings.Repeat("a", 300)+".log", 64) {
+		t.Errorf("different names should have different aliases")
+	}
+
+	// never cut a multi-byte rune in half
+	if alias := shortenName(strings.Repeat("é", 100), 20); !strings.HasSuffix(alias, "~"+util.Md5String([]byte(strings.Repeat("é", 100)))[:8]) || len(alias) > 20 {
+		t.Errorf("unexpected alias %s", alias)
+	}
+
+}
+
+func TestNameAliases(t *testing.T) {
+
+	aliases := NewNameAliases()
+	dir := util.FullPath("/some/dir")
+	name := strings.Repeat("b", 300)
+	alias := shortenName(name, 255)
+
+	aliases.Add(dir, alias, name)
+
+	if resolved := aliases.Resolve(dir, alias); resolved != name {
+		t.Errorf("alias resolved to %s", resolved)
+	}
+	if resolved := aliases.Resolve(dir, "short"); resolved != "short" {
+		t.Errorf("regular name resolved to %s", resolved)
+	}
+	if resolved := aliases.Resolve("/other", alias); resolved != alias {
+		t.Errorf("alias should not resolve in another directory")
+	}
+
+}
+
+func TestNameAliasesRemove(t *testing.T) {
+
+	aliases := NewNameAliases()
+	aliases.Add("/dir", "short~1", "long name")
+	aliases.Add("/dir", "nfc", "nfd")
+	aliases.Add("/dir", "other~2", "other name")
+	aliases.Add("/dir/sub", "x~3", "x name")
+
+	// by the name and by the alias
+	aliases.Remove("/dir", "long name")
+	aliases.Remove("/dir", "nfc")
+	if resolved := aliases.Resolve("/dir", "short~1"); resolved != "short~1" {
+		t.Errorf("alias of a removed name resolved to %s", resolved)
+	}
+	if resolved := aliases.Resolve("/dir", "nfc"); resolved != "nfc" {
+		t.Errorf("removed alias resolved to %s", resolved)
+	}
+	if resolved := aliases.Resolve("/dir", "other~2"); resolved != "other name" {
+		t.Errorf("alias of another name resolved to %s", resolved)
+	}
+
+	aliases.RemoveDir("/dir")
+	if resolved := aliases.Resolve("/dir", "other~2"); resolved != "other~2" {
+		t.Errorf("alias in a removed directory resolved to %s", resolved)
+	}
+	if resolved := aliases.Resolve("/dir/sub", "x~3"); resolved != "x name" {
+		t.Errorf("alias in a subdirectory resolved to %s", resolved)
+	}
+	aliases.Remove("/dir/sub", "x name")
+	if len(aliases.dirs) != 0 {
+		t.Errorf("aliases of %d directories kept after removing all", len(aliases.dirs))
+	}
+
+}
+
+func TestLookupExactNameBeforeAlias(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	dirInode := insertTestFiles(t, wfs, "/dir", 2)
+	// a stale alias, named like an entry created since
+	wfs.nameAliases.Add("/dir", "file00000", "file00001")
+	wfs.nameAliases.Add("/dir", "file~1", "file00001")
+
+	var out fuse.EntryOut
+	if status := wfs.Lookup(nil, &fuse.InHeader{NodeId: dirInode}, "file00000", &out); status != fuse.OK {
+		t.Fatalf("look up file00000: %v", status)
+	}
+	if path, _ := wfs.inodeToPath.GetPath(out.NodeId); path != "/dir/file00000" {
+		t.Errorf("file00000 resolved to %s", path)
+	}
+	if status := wfs.Lookup(nil, &fuse.InHeader{NodeId: dirInode}, "file~1", &out); status != fuse.OK {
+		t.Fatalf("look up the alias: %v", status)
+	}
+	if path, _ := wfs.inodeToPath.GetPath(out.NodeId); path != "/dir/file00001" {
+		t.Errorf("the alias resolved to %s", path)
+	}
+
+}
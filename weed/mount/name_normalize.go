@@ -0,0 +1,72 @@
+package mount
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"golang.org/x/text/unicode/norm"
+)
+
+// macOS clients name files in NFD, decomposed, and Linux ones in NFC, composed,
+// so the same name may be stored in either form
+const (
+	NameNormalizationNFC = "nfc"
+	NameNormalizationNFD = "nfd"
+)
+
+// CheckNameNormalization accepts NameNormalizationNFC, NameNormalizationNFD, or "" for names as stored.
+func CheckNameNormalization(mode string) error {
+	switch mode {
+	case "", NameNormalizationNFC, NameNormalizationNFD:
+		return nil
+	}
+	return fmt.Errorf("unknown name normalization %q", mode)
+}
+
+func normalizeName(mode, name string) string {
+	switch mode {
+	case NameNormalizationNFC:
+		return norm.NFC.String(name)
+	case NameNormalizationNFD:
+		return norm.NFD.String(name)
+	}
+	return name
+}
+
+// findNormalizedEntry looks through the cached directory for the entry whose name differs from the name
+// in normalization only, and remembers it for the following lookups.
+// ASCII names are left to the exact lookup, to keep the lookups of missing files cheap.
+func (wfs *WFS) findNormalizedEntry(dirPath util.FullPath, name string) (found *filer.Entry) {
+	if isASCII(name) {
+		return nil
+	}
+	normalized := normalizeName(wfs.option.NameNormalization, name)
+	err := wfs.metaCache.ListDirectoryEntries(context.Background(), dirPath, "", false, int64(math.MaxInt32), func(entry *filer.Entry) bool {
+		if isASCII(entry.Name()) || normalizeName(wfs.option.NameNormalization, entry.Name()) != normalized {
+			return true
+		}
+		found = entry
+		return false
+	})
+	if err != nil {
+		glog.Warningf("list %s for %s: %v", dirPath, name, err)
+		return nil
+	}
+	if found != nil {
+		wfs.nameAliases.Add(dirPath, name, found.Name())
+	}
+	return
+}
+
+func isASCII(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
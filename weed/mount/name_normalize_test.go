@@ -0,0 +1,104 @@
+package mount
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+const (
+	nfcName = "caf\u00e9.txt"
+	nfdName = "cafe\u0301.txt"
+)
+
+func TestLookupNormalizedName(t *testing.T) {
+
+	for _, mode := range []string{NameNormalizationNFC, NameNormalizationNFD} {
+		wfs := newTestWFS(t)
+		wfs.option.NameNormalization = mode
+		dirInode := insertTestFiles(t, wfs, "/dir", 0)
+		entry := &filer.Entry{FullPath: "/dir/" + nfdName, Attr: filer.Attr{Mode: 0644, Mtime: time.Now()}}
+		if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+			t.Fatalf("insert %s: %v", entry.FullPath, err)
+		}
+
+		var nfdOut, nfcOut fuse.EntryOut
+		if status := wfs.Lookup(nil, &fuse.InHeader{NodeId: dirInode}, nfdName, &nfdOut); status != fuse.OK {
+			t.Fatalf("%s: look up the name as stored: %v", mode, status)
+		}
+		if status := wfs.Lookup(nil, &fuse.InHeader{NodeId: dirInode}, nfcName, &nfcOut); status != fuse.OK {
+			t.Fatalf("%s: look up the NFC form: %v", mode, status)
+		}
+		if nfcOut.NodeId != nfdOut.NodeId {
+			t.Errorf("%s: the NFC form resolved to inode %d, the stored name to %d", mode, nfcOut.NodeId, nfdOut.NodeId)
+		}
+		if path, _ := wfs.inodeToPath.GetPath(nfcOut.NodeId); path != entry.FullPath {
+			t.Errorf("%s: the NFC form resolved to %s", mode, path)
+		}
+
+		var out fuse.EntryOut
+		if status := wfs.Lookup(nil, &fuse.InHeader{NodeId: dirInode}, "caf\u00e8.txt", &out); status != fuse.ENOENT {
+			t.Errorf("%s: looked up another name: %v", mode, status)
+		}
+	}
+
+}
+
+func TestLookupWithoutNormalization(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	dirInode := insertTestFiles(t, wfs, "/dir", 0)
+	entry := &filer.Entry{FullPath: "/dir/" + nfdName, Attr: filer.Attr{Mode: 0644, Mtime: time.Now()}}
+	if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+		t.Fatalf("insert %s: %v", entry.FullPath, err)
+	}
+
+	var out fuse.EntryOut
+	if status := wfs.Lookup(nil, &fuse.InHeader{NodeId: dirInode}, nfcName, &out); status == fuse.OK {
+		t.Errorf("the NFC form found without normalization")
+	}
+
+}
+
+func TestReadDirNormalizedNames(t *testing.T) {
+
+	for _, mode := range []string{NameNormalizationNFC, NameNormalizationNFD} {
+		wfs := newTestWFS(t)
+		wfs.option.NameNormalization = mode
+		dirInode := insertTestFiles(t, wfs, "/dir", 1)
+		// stored in the other form
+		stored, listed := nfdName, nfcName
+		if mode == NameNormalizationNFD {
+			stored, listed = nfcName, nfdName
+		}
+		entry := &filer.Entry{FullPath: "/dir/" + util.FullPath(stored), Attr: filer.Attr{Mode: 0644, Mtime: time.Now()}}
+		if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+			t.Fatalf("insert %s: %v", entry.FullPath, err)
+		}
+
+		var openOut fuse.OpenOut
+		wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: dirInode}}, &openOut)
+		buf := make([]byte, 4096)
+		input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: dirInode, Length: 1}, Fh: openOut.Fh, Size: uint32(len(buf))}
+		if status := wfs.ReadDir(nil, input, fuse.NewDirEntryList(buf, 0)); status != fuse.OK {
+			t.Fatalf("%s: read dir: %v", mode, status)
+		}
+		wfs.ReleaseDir(&fuse.ReleaseIn{Fh: openOut.Fh})
+
+		if names := direntNames(buf); fmt.Sprint(names) != fmt.Sprint([]string{".", "..", listed, "file00000"}) {
+			t.Errorf("%s: listed %q", mode, names)
+		}
+
+		// the listed name looks up the entry
+		var out fuse.EntryOut
+		if status := wfs.Lookup(nil, &fuse.InHeader{NodeId: dirInode}, listed, &out); status != fuse.OK {
+			t.Errorf("%s: look up the listed %q: %v", mode, listed, status)
+		}
+	}
+
+}
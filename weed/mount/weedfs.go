@@ -50,6 +50,66 @@ type Option struct {
 	Cipher             bool   // whether encrypt data on volume server
 	UidGidMapper       *meta_cache.UidGidMapper
 
+	// entries with longer names are skipped, or listed under a shortened alias if AliasLongNames is set
+	MaxNameLength  int
+	AliasLongNames bool
+
+	// list and look up the entry names in NameNormalizationNFC or NameNormalizationNFD form, whichever form they are stored in
+	NameNormalization string
+
+	// if not 0, listing a directory in plus mode prefetches the first chunk of files up to this size
+	WarmFileSizeLimit int64
+
+	// list directories by DirSortByMtime or DirSortBySize instead of by name,
+	// for directories with at most DirSortLimit entries, or by DirSortByNameDesc natively
+	DirSortMode  string
+	DirSortLimit int
+
+	// list directories from the filer whenever they are read from the start, instead of from the meta cache.
+	// Changes made through other filer clients are seen at once,
+	// at the cost of a filer round trip per listing.
+	DirListNoCache bool
+
+	// list only the entries matching this filepath.Match pattern, e.g. "*.parquet",
+	// starting from the literal prefix of the pattern if any
+	DirListGlob string
+
+	// list only the subdirectories, for mounts serving tree walkers such as `find -type d`
+	DirListDirsOnly bool
+
+	// answer the FullPathXAttrName extended attribute of each entry with its path from the mount root
+	DirListFullPaths bool
+
+	// extended attributes kept from plus mode listings, to answer the following getxattr calls
+	ListXAttrNames []string
+
+	// if not 0, listing a directory in plus mode lists its subdirectories in the background with this many workers,
+	// and theirs down to DirPrefetchDepth levels
+	DirPrefetchConcurrency int
+	DirPrefetchDepth       int
+
+	// tell the kernel a new inode generation when an inode is reused for a new entry at its path,
+	// e.g. after another client deletes and recreates the file
+	EntryGeneration bool
+
+	// if more than 1, the attributes of the entries listed in plus mode are filled in by this many workers
+	DirPlusWorkers int
+
+	// list each union directory merged with its lower directory, see UnionDirs.
+	// Only changes under FilerMountRootPath are followed, so a lower directory outside of it may list stale entries.
+	UnionDirs map[util.FullPath]util.FullPath
+
+	// if not 0, keep up to this many MB of recently read file data in memory, see ReadCache
+	ReadCacheSizeMB int64
+
+	// the read bandwidth in bytes per second of each QoS class, for the files tagged with it, see ReadQoS
+	ReadQoSClasses map[string]int64
+
+	// if not 0, share the listing of a directory among the directory handles reading it within this long,
+	// for directories with at most DirListingCacheLimit entries, see DirListingCache
+	DirListingCacheTTL   time.Duration
+	DirListingCacheLimit int
+
 	uniqueCacheDir         string
 	uniqueCacheTempPageDir string
 }
@@ -68,6 +128,13 @@ type WFS struct {
 	inodeToPath       *InodeToPath
 	fhmap             *FileHandleToInode
 	dhmap             *DirectoryHandleToInode
+	nameAliases       *NameAliases
+	listedXAttrs      *ListedXAttrs
+	dirPrefetcher     *DirPrefetcher
+	unionDirs         *UnionDirs
+	readCache         *ReadCache
+	readQoS           *ReadQoS
+	dirListingCache   *DirListingCache
 }
 
 func NewSeaweedFileSystem(option *Option) *WFS {
@@ -78,7 +145,13 @@ func NewSeaweedFileSystem(option *Option) *WFS {
 		inodeToPath:   NewInodeToPath(),
 		fhmap:         NewFileHandleToInode(),
 		dhmap:         NewDirectoryHandleToInode(),
+		nameAliases:   NewNameAliases(),
+		listedXAttrs:  NewListedXAttrs(option.ListXAttrNames),
+		unionDirs:     NewUnionDirs(option.UnionDirs),
+		readCache:     NewReadCache(option.ReadCacheSizeMB*1024*1024, readCachePageSize),
+		readQoS:       NewReadQoS(option.ReadQoSClasses),
 	}
+	wfs.dirPrefetcher = NewDirPrefetcher(wfs, wfs, option.DirPrefetchConcurrency, option.DirPrefetchDepth)
 
 	wfs.root = Directory{
 		name:   "/",
@@ -98,7 +171,12 @@ func NewSeaweedFileSystem(option *Option) *WFS {
 	}, func(path util.FullPath) bool {
 		return wfs.inodeToPath.IsChildrenCached(path)
 	}, func(filePath util.FullPath, entry *filer_pb.Entry) {
+		wfs.invalidateEntry(filePath, entry)
 	})
+	if wfs.chunkCache != nil {
+		wfs.metaCache.SetReplacedChunksFn(wfs.chunkCache.InvalidateChunks)
+	}
+	wfs.dirListingCache = NewDirListingCache(wfs.metaCache, option.DirListingCacheTTL, option.DirListingCacheLimit)
 	grace.OnInterrupt(func() {
 		wfs.metaCache.Shutdown()
 	})
@@ -123,7 +201,10 @@ func (wfs *WFS) String() string {
 }
 
 func (wfs *WFS) maybeReadEntry(inode uint64) (path util.FullPath, fh *FileHandle, entry *filer_pb.Entry, status fuse.Status) {
-	path = wfs.inodeToPath.GetPath(inode)
+	path, status = wfs.inodeToPath.GetPath(inode)
+	if status != fuse.OK {
+		return
+	}
 	var found bool
 	if fh, found = wfs.fhmap.FindFileHandle(inode); found {
 		return path, fh, fh.entry, fuse.OK
@@ -156,11 +237,32 @@ func (wfs *WFS) maybeLoadEntry(fullpath util.FullPath) (*filer_pb.Entry, fuse.St
 	meta_cache.EnsureVisited(wfs.metaCache, wfs, util.FullPath(dir))
 	cachedEntry, cacheErr := wfs.metaCache.FindEntry(context.Background(), fullpath)
 	if cacheErr == filer_pb.ErrNotFound {
-		return nil, fuse.ENOENT
+		if cachedEntry, cacheErr = wfs.findLowerEntry(context.Background(), fullpath); cacheErr != nil {
+			return nil, fuse.ENOENT
+		}
 	}
 	return cachedEntry.ToProtoEntry(), fuse.OK
 }
 
+// invalidateEntry is called when another client changes or removes the entry at the path
+func (wfs *WFS) invalidateEntry(filePath util.FullPath, entry *filer_pb.Entry) {
+	if entry != nil && !entry.IsDirectory {
+		wfs.listedXAttrs.Invalidate(filePath)
+	} else {
+		wfs.listedXAttrs.InvalidateTree(filePath)
+	}
+	if inode := wfs.inodeToPath.GetInode(filePath); inode != 0 {
+		wfs.readCache.Invalidate(inode)
+		wfs.readQoS.Invalidate(inode)
+	}
+	if !wfs.option.EntryGeneration {
+		return
+	}
+	if _, err := wfs.metaCache.FindEntry(context.Background(), filePath); err == filer_pb.ErrNotFound {
+		wfs.inodeToPath.NextGeneration(filePath)
+	}
+}
+
 func (wfs *WFS) LookupFn() wdclient.LookupFileIdFunctionType {
 	if wfs.option.VolumeServerAccess == "filerProxy" {
 		return func(fileId string) (targetUrls []string, err error) {
@@ -32,6 +32,9 @@ func (wfs *WFS) SetAttr(cancel <-chan struct{}, input *fuse.SetAttrIn, out *fuse
 	if status != fuse.OK {
 		return status
 	}
+	if status := wfs.checkUnionWritable(path); status != fuse.OK {
+		return status
+	}
 
 	if size, ok := input.GetSize(); ok {
 		glog.V(4).Infof("%v setattr set size=%v chunks=%d", path, size, len(entry.Chunks))
@@ -62,6 +65,7 @@ func (wfs *WFS) SetAttr(cancel <-chan struct{}, input *fuse.SetAttrIn, out *fuse
 		}
 		entry.Attributes.Mtime = time.Now().Unix()
 		entry.Attributes.FileSize = size
+		wfs.readCache.Invalidate(input.NodeId)
 
 	}
 
@@ -81,7 +85,12 @@ func (wfs *WFS) SetAttr(cancel <-chan struct{}, input *fuse.SetAttrIn, out *fuse
 		entry.Attributes.Mtime = mtime.Unix()
 	}
 
-	entry.Attributes.Mtime = time.Now().Unix()
+	if atime, ok := input.GetATime(); ok {
+		entry.Extended = setExtendedTime(entry.Extended, extendedAtimeKey, atime)
+	}
+
+	// changing the attributes alone leaves the modification time as set, e.g. by rsync
+	entry.Extended = setExtendedTime(entry.Extended, extendedCtimeKey, time.Now())
 	out.AttrValid = 1
 	wfs.setAttrByPbEntry(&out.Attr, input.NodeId, entry)
 
@@ -113,9 +122,10 @@ func (wfs *WFS) setAttrByPbEntry(out *fuse.Attr, inode uint64, entry *filer_pb.E
 	out.Size = filer.FileSize(entry)
 	out.Blocks = (out.Size + blockSize - 1) / blockSize
 	setBlksize(out, blockSize)
+	atime, ctime := entryTimes(entry.Attributes.Mtime, entry.Extended)
+	out.Atime = uint64(atime)
 	out.Mtime = uint64(entry.Attributes.Mtime)
-	out.Ctime = uint64(entry.Attributes.Mtime)
-	out.Atime = uint64(entry.Attributes.Mtime)
+	out.Ctime = uint64(ctime)
 	out.Mode = toSystemMode(os.FileMode(entry.Attributes.FileMode))
 	if entry.HardLinkCounter > 0 {
 		out.Nlink = uint32(entry.HardLinkCounter)
@@ -126,14 +136,17 @@ func (wfs *WFS) setAttrByPbEntry(out *fuse.Attr, inode uint64, entry *filer_pb.E
 	out.Gid = entry.Attributes.Gid
 }
 
+// There is no device number to set: the kernel reports the device of the fuse mount for every entry,
+// so subtrees needing their own st_dev have to be mounted separately, with -filer.path.
 func (wfs *WFS) setAttrByFilerEntry(out *fuse.Attr, inode uint64, entry *filer.Entry) {
 	out.Ino = inode
 	out.Size = entry.FileSize
 	out.Blocks = (out.Size + blockSize - 1) / blockSize
 	setBlksize(out, blockSize)
-	out.Atime = uint64(entry.Attr.Mtime.Unix())
+	atime, ctime := entryTimes(entry.Attr.Mtime.Unix(), entry.Extended)
+	out.Atime = uint64(atime)
 	out.Mtime = uint64(entry.Attr.Mtime.Unix())
-	out.Ctime = uint64(entry.Attr.Mtime.Unix())
+	out.Ctime = uint64(ctime)
 	out.Mode = toSystemMode(entry.Attr.Mode)
 	if entry.HardLinkCounter > 0 {
 		out.Nlink = uint32(entry.HardLinkCounter)
@@ -146,7 +159,7 @@ func (wfs *WFS) setAttrByFilerEntry(out *fuse.Attr, inode uint64, entry *filer.E
 
 func (wfs *WFS) outputPbEntry(out *fuse.EntryOut, inode uint64, entry *filer_pb.Entry) {
 	out.NodeId = inode
-	out.Generation = 1
+	out.Generation = wfs.generation(inode)
 	out.EntryValid = 1
 	out.AttrValid = 1
 	wfs.setAttrByPbEntry(&out.Attr, inode, entry)
@@ -154,12 +167,19 @@ func (wfs *WFS) outputPbEntry(out *fuse.EntryOut, inode uint64, entry *filer_pb.
 
 func (wfs *WFS) outputFilerEntry(out *fuse.EntryOut, inode uint64, entry *filer.Entry) {
 	out.NodeId = inode
-	out.Generation = 1
+	out.Generation = wfs.generation(inode)
 	out.EntryValid = 1
 	out.AttrValid = 1
 	wfs.setAttrByFilerEntry(&out.Attr, inode, entry)
 }
 
+func (wfs *WFS) generation(inode uint64) uint64 {
+	if !wfs.option.EntryGeneration {
+		return 1
+	}
+	return wfs.inodeToPath.Generation(inode)
+}
+
 func toSystemMode(mode os.FileMode) uint32 {
 	return toSystemType(mode) | uint32(mode)
 }
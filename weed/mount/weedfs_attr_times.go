@@ -0,0 +1,46 @@
+package mount
+
+import (
+	"strconv"
+	"time"
+)
+
+// The filer keeps only the modification and creation times of an entry. The access time set by utimes,
+// and the time the mount last changed the attributes of an entry, are kept in its extended attributes,
+// outside of the "xattr-" namespace listed by listxattr.
+const (
+	extendedAtimeKey = "Seaweed-Atime"
+	extendedCtimeKey = "Seaweed-Ctime"
+)
+
+// entryTimes returns the access and change times of an entry in unix seconds.
+// Access times are not tracked on reads, so without one set by utimes it is the modification time.
+// A modification is a change too, so the change time is never before the modification time,
+// e.g. for entries written by other filer clients.
+func entryTimes(mtime int64, extended map[string][]byte) (atime, ctime int64) {
+	atime, ctime = mtime, mtime
+	if t, ok := extendedTime(extended, extendedAtimeKey); ok {
+		atime = t
+	}
+	if t, ok := extendedTime(extended, extendedCtimeKey); ok && t > mtime {
+		ctime = t
+	}
+	return
+}
+
+func extendedTime(extended map[string][]byte, key string) (int64, bool) {
+	data, found := extended[key]
+	if !found {
+		return 0, false
+	}
+	t, err := strconv.ParseInt(string(data), 10, 64)
+	return t, err == nil
+}
+
+func setExtendedTime(extended map[string][]byte, key string, t time.Time) map[string][]byte {
+	if extended == nil {
+		extended = make(map[string][]byte)
+	}
+	extended[key] = []byte(strconv.FormatInt(t.Unix(), 10))
+	return extended
+}
@@ -0,0 +1,48 @@
+package mount
+
+import (
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestOutputFilerEntryTimes(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	created, modified := time.Unix(1600000000, 0), time.Unix(1600000100, 0)
+	accessed, changed := time.Unix(1600000050, 0), time.Unix(1600000200, 0)
+
+	entry := &filer.Entry{
+		FullPath: "/dir/file",
+		Attr:     filer.Attr{Mode: 0644, Crtime: created, Mtime: modified},
+	}
+	var out fuse.EntryOut
+	wfs.outputFilerEntry(&out, 2, entry)
+	if out.Atime != uint64(modified.Unix()) || out.Mtime != uint64(modified.Unix()) || out.Ctime != uint64(modified.Unix()) {
+		t.Errorf("untracked times: atime %d mtime %d ctime %d, expect all the mtime", out.Atime, out.Mtime, out.Ctime)
+	}
+
+	entry.Extended = setExtendedTime(entry.Extended, extendedAtimeKey, accessed)
+	entry.Extended = setExtendedTime(entry.Extended, extendedCtimeKey, changed)
+	wfs.outputFilerEntry(&out, 2, entry)
+	if out.Atime != uint64(accessed.Unix()) || out.Mtime != uint64(modified.Unix()) || out.Ctime != uint64(changed.Unix()) {
+		t.Errorf("tracked times: atime %d mtime %d ctime %d", out.Atime, out.Mtime, out.Ctime)
+	}
+
+	// modified by another filer client after the last change through the mount
+	entry.Attr.Mtime = time.Unix(1600000300, 0)
+	wfs.outputFilerEntry(&out, 2, entry)
+	if out.Ctime != out.Mtime || out.Atime != uint64(accessed.Unix()) {
+		t.Errorf("ctime %d before mtime %d", out.Ctime, out.Mtime)
+	}
+
+	pbEntry := entry.ToProtoEntry()
+	var attr fuse.Attr
+	wfs.setAttrByPbEntry(&attr, 2, pbEntry)
+	if attr.Atime != out.Atime || attr.Mtime != out.Mtime || attr.Ctime != out.Ctime {
+		t.Errorf("pb entry times %d %d %d differ from the filer entry ones %d %d %d", attr.Atime, attr.Mtime, attr.Ctime, out.Atime, out.Mtime, out.Ctime)
+	}
+
+}
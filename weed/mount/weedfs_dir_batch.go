@@ -0,0 +1,54 @@
+package mount
+
+import (
+	"math"
+)
+
+// the bytes of a directory entry in the fuse buffer before its name, which is padded to 8 bytes
+const (
+	direntHeaderSize     = 24       // fuse_dirent
+	direntPlusHeaderSize = 128 + 24 // fuse_direntplus, the fuse_entry_out before the fuse_dirent
+)
+
+// the name length assumed for the entries of a directory before any of them is listed
+const defaultDirentNameLength = 16
+
+// dirBatch sizes the store fetches of a readdir to about the entries the fuse buffer can still hold,
+// estimating their size from the names listed so far by the directory handle.
+type dirBatch struct {
+	bufferSize int // 0 if not known, fetching all entries at once
+	used       int
+	isPlusMode bool
+}
+
+func direntSize(nameLength int, isPlusMode bool) int {
+	size := direntHeaderSize + nameLength
+	if isPlusMode {
+		size = direntPlusHeaderSize + nameLength
+	}
+	return (size + 7) &^ 7
+}
+
+// added counts the buffer bytes taken by an entry of the name, and the name for the estimates of the handle.
+func (b *dirBatch) added(dh *DirectoryHandle, name string) {
+	b.used += direntSize(len(name), b.isPlusMode)
+	dh.listedNameBytes += int64(len(name))
+	dh.listedNames++
+}
+
+// limit is the number of entries to fetch next from the store, one more than estimated to fit,
+// so that a full buffer is told apart from the end of the directory.
+func (b *dirBatch) limit(dh *DirectoryHandle) int64 {
+	if b.bufferSize <= 0 {
+		return math.MaxInt32
+	}
+	nameLength := defaultDirentNameLength
+	if dh.listedNames > 0 {
+		nameLength = int((dh.listedNameBytes + dh.listedNames - 1) / dh.listedNames)
+	}
+	remaining := b.bufferSize - b.used
+	if remaining < 0 {
+		remaining = 0
+	}
+	return int64(remaining/direntSize(nameLength, b.isPlusMode)) + 1
+}
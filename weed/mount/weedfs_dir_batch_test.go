@@ -0,0 +1,65 @@
+package mount
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/mount/meta_cache"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestReadDirFetchesWhatFitsTheBuffer(t *testing.T) {
+
+	fetched := 0
+	defer func(listFn func(context.Context, *meta_cache.MetaCache, util.FullPath, string, int64, func(*filer.Entry) bool) error) {
+		listMetaCacheEntries = listFn
+	}(listMetaCacheEntries)
+	listFn := listMetaCacheEntries
+	listMetaCacheEntries = func(ctx context.Context, metaCache *meta_cache.MetaCache, dirPath util.FullPath, startFileName string, limit int64, eachEntryFn func(*filer.Entry) bool) error {
+		return listFn(ctx, metaCache, dirPath, startFileName, limit, func(entry *filer.Entry) bool {
+			fetched++
+			return eachEntryFn(entry)
+		})
+	}
+
+	for _, bufferSize := range []int{512, 1024, 4096} {
+		wfs := newTestWFS(t)
+		inode := insertTestFiles(t, wfs, "/dir", 300)
+
+		var openOut fuse.OpenOut
+		wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: inode}}, &openOut)
+
+		var listed []string
+		for offset, page := uint64(0), 0; ; page++ {
+			buf := make([]byte, bufferSize)
+			input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: inode, Length: 1}, Fh: openOut.Fh, Offset: offset, Size: uint32(bufferSize)}
+			fetched = 0
+			if status := wfs.ReadDir(nil, input, fuse.NewDirEntryList(buf, offset)); status != fuse.OK {
+				t.Fatalf("read dir: %v", status)
+			}
+			names := direntNames(buf)
+			// the entries of 9 byte names take 40 bytes each
+			if page == 0 && (len(names) < bufferSize/40 || fetched > bufferSize/40+1) {
+				t.Errorf("buffer of %d bytes: fetched %d entries for the %d listed", bufferSize, fetched, len(names))
+			}
+			// at most the one not fitting in the buffer is fetched in vain
+			if fetched > len(names)+1 {
+				t.Errorf("buffer of %d bytes: fetched %d entries for the %d listed at offset %d", bufferSize, fetched, len(names), offset)
+			}
+			if len(names) == 0 {
+				break
+			}
+			listed = append(listed, names...)
+			offset += uint64(len(names))
+		}
+		wfs.ReleaseDir(&fuse.ReleaseIn{Fh: openOut.Fh})
+
+		if expected := fmt.Sprint(append([]string{".", ".."}, testNames("file%05d", 0, 300)...)); fmt.Sprint(listed) != expected {
+			t.Errorf("buffer of %d bytes: listed %v", bufferSize, listed)
+		}
+	}
+
+}
@@ -0,0 +1,65 @@
+package mount
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// dirGlob lists only the entries matching a filepath.Match pattern. The names in name order sharing the
+// literal prefix of the pattern are contiguous, so the listing starts at the prefix and stops after it.
+type dirGlob struct {
+	pattern string
+	prefix  string
+}
+
+// afterAllNames sorts after any name starting with the same prefix, as no utf-8 name contains the byte
+const afterAllNames = "\xff"
+
+func newDirGlob(pattern string) (*dirGlob, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("glob %q: %v", pattern, err)
+	}
+	g := &dirGlob{
+		pattern: pattern,
+	}
+	if i := strings.IndexAny(pattern, `*?[\`); i >= 0 {
+		g.prefix = pattern[:i]
+	} else {
+		g.prefix = pattern
+	}
+	return g, nil
+}
+
+func (g *dirGlob) match(name string) bool {
+	matched, _ := filepath.Match(g.pattern, name)
+	return matched
+}
+
+// startFileName returns where to list from, if the literal prefix skips some names
+func (g *dirGlob) startFileName(lastEntryName string, descending bool) (startFileName string, includeStartFile bool, ok bool) {
+	if g == nil || g.prefix == "" {
+		return "", false, false
+	}
+	if descending {
+		if start := g.prefix + afterAllNames; lastEntryName == "" || lastEntryName > start {
+			return start, false, true
+		}
+		return "", false, false
+	}
+	if lastEntryName < g.prefix {
+		return g.prefix, true, true
+	}
+	return "", false, false
+}
+
+// isPast tells whether the names listed after this one can no longer match
+func (g *dirGlob) isPast(name string, descending bool) bool {
+	if descending {
+		return name < g.prefix
+	}
+	return name > g.prefix && !strings.HasPrefix(name, g.prefix)
+}
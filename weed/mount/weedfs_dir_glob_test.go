@@ -0,0 +1,101 @@
+package mount
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestReadDirGlob(t *testing.T) {
+
+	for _, tt := range []struct {
+		pattern  string
+		sortMode string
+		expected []string
+	}{
+		{pattern: "file0005*", expected: testNames("file%05d", 50, 60)},
+		{pattern: "file0005*", sortMode: DirSortByNameDesc, expected: reversedNames(testNames("file%05d", 50, 60))},
+		{pattern: "*7", expected: []string{"file00007", "file00017", "file00027", "file00037", "file00047", "file00057", "file00067", "file00077", "file00087", "file00097"}},
+		{pattern: "file00042", expected: []string{"file00042"}},
+		{pattern: "nothing*", expected: nil},
+	} {
+		wfs := newTestWFS(t)
+		wfs.option.DirListGlob = tt.pattern
+		wfs.option.DirSortMode = tt.sortMode
+		inode := insertTestFiles(t, wfs, "/dir", 100)
+
+		listed := readDirByPages(t, wfs, inode)
+		if len(listed) < 2 || listed[0] != "." || listed[1] != ".." {
+			t.Fatalf("%s: listed %v, expect . and .. first", tt.pattern, listed)
+		}
+		if fmt.Sprint(listed[2:]) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s %s: listed %v, expect %v", tt.pattern, tt.sortMode, listed[2:], tt.expected)
+		}
+	}
+
+}
+
+func TestDirGlobStart(t *testing.T) {
+
+	g, err := newDirGlob("file0005*")
+	if err != nil {
+		t.Fatalf("new glob: %v", err)
+	}
+	if start, include, ok := g.startFileName("", false); !ok || start != "file0005" || !include {
+		t.Errorf("ascending start %q %v %v, expect the prefix", start, include, ok)
+	}
+	if _, _, ok := g.startFileName("file00051", false); ok {
+		t.Errorf("ascending start after the prefix should continue from the last entry")
+	}
+	if start, _, ok := g.startFileName("", true); !ok || start != "file0005"+afterAllNames {
+		t.Errorf("descending start %q %v, expect after the prefix", start, ok)
+	}
+	if !g.isPast("file00060", false) || g.isPast("file00059", false) || !g.isPast("file00049", true) {
+		t.Errorf("unexpected end of the prefix")
+	}
+
+	if _, err := newDirGlob("file["); err == nil {
+		t.Errorf("expect an error for a malformed pattern")
+	}
+	if g, _ := newDirGlob("*"); g == nil || g.prefix != "" {
+		t.Errorf("a leading wildcard has no prefix")
+	}
+
+}
+
+// readDirByPages lists the directory one small read at a time, as the descending test does
+func readDirByPages(t *testing.T, wfs *WFS, inode uint64) (listed []string) {
+	var openOut fuse.OpenOut
+	wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: inode}}, &openOut)
+	defer wfs.ReleaseDir(&fuse.ReleaseIn{Fh: openOut.Fh})
+
+	for offset := uint64(0); ; {
+		buf := make([]byte, 256)
+		input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: inode, Length: 1}, Fh: openOut.Fh, Offset: offset}
+		if status := wfs.ReadDir(nil, input, fuse.NewDirEntryList(buf, offset)); status != fuse.OK {
+			t.Fatalf("read dir: %v", status)
+		}
+		page := direntNames(buf)
+		if len(page) == 0 {
+			return
+		}
+		listed = append(listed, page...)
+		offset += uint64(len(page))
+	}
+}
+
+func testNames(format string, from, to int) (list []string) {
+	for i := from; i < to; i++ {
+		list = append(list, fmt.Sprintf(format, i))
+	}
+	return
+}
+
+func reversedNames(list []string) []string {
+	r := make([]string, len(list))
+	for i, s := range list {
+		r[len(list)-1-i] = s
+	}
+	return r
+}
@@ -0,0 +1,162 @@
+package mount
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/mount/meta_cache"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/golang/groupcache/singleflight"
+)
+
+// DefaultDirListingCacheLimit is the max number of entries of a directory listing shared among directory handles,
+// larger directories are listed by each handle
+const DefaultDirListingCacheLimit = 10000
+
+// replaced in tests
+var dirListingNow = time.Now
+
+// DirListingCache shares the listing of a directory among the directory handles reading it within a short window,
+// e.g. several processes walking the same tree, instead of each handle listing the meta cache again.
+// A listing is kept until it expires, or until an entry of its directory changes in the meta cache,
+// which the cache follows by watching the directories it keeps.
+type DirListingCache struct {
+	sync.Mutex
+	metaCache  *meta_cache.MetaCache
+	ttl        time.Duration
+	maxEntries int
+	listings   map[util.FullPath]*dirListing
+	listGroup  singleflight.Group
+}
+
+type dirListing struct {
+	entries  []*filer.Entry // in name order
+	version  int64          // the meta cache watch version of the directory when listed
+	listedAt time.Time
+	tooLarge bool // too large to share, so the handles within the window list the directory on their own right away, and not watched
+}
+
+// NewDirListingCache returns nil if the ttl is not positive, which lets each directory handle list on its own.
+func NewDirListingCache(metaCache *meta_cache.MetaCache, ttl time.Duration, maxEntries int) *DirListingCache {
+	if ttl <= 0 {
+		return nil
+	}
+	if maxEntries <= 0 {
+		maxEntries = DefaultDirListingCacheLimit
+	}
+	return &DirListingCache{
+		metaCache:  metaCache,
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		listings:   make(map[util.FullPath]*dirListing),
+	}
+}
+
+// List calls eachEntryFn with the entries after startFileName in name order, from the shared listing of the directory
+// if it is still valid, or else from a new one made by listFn. Directories too large to share are listed by listFn.
+func (c *DirListingCache) List(dirPath util.FullPath, startFileName string, eachEntryFn func(entry *filer.Entry) bool,
+	listFn func(startFileName string, eachEntryFn func(entry *filer.Entry) bool) error) error {
+
+	if c == nil {
+		return listFn(startFileName, eachEntryFn)
+	}
+	entries, found := c.entries(dirPath, listFn)
+	if !found {
+		return listFn(startFileName, eachEntryFn)
+	}
+	for i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].Name() > startFileName
+	}); i < len(entries); i++ {
+		if !eachEntryFn(entries[i]) {
+			break
+		}
+	}
+	return nil
+}
+
+func (c *DirListingCache) entries(dirPath util.FullPath, listFn func(startFileName string, eachEntryFn func(entry *filer.Entry) bool) error) ([]*filer.Entry, bool) {
+
+	if listing := c.validListing(dirPath); listing != nil {
+		return listing.entries, !listing.tooLarge
+	}
+
+	// the handles missing the listing at the same time wait for the one listing the directory
+	v, err := c.listGroup.Do(string(dirPath), func() (interface{}, error) {
+		version := c.metaCache.WatchDirectory(dirPath)
+		listing := &dirListing{version: version, listedAt: dirListingNow()}
+		tooMany := false
+		err := listFn("", func(entry *filer.Entry) bool {
+			if len(listing.entries) >= c.maxEntries {
+				tooMany = true
+				return false
+			}
+			listing.entries = append(listing.entries, entry)
+			return true
+		})
+		c.Lock()
+		defer c.Unlock()
+		// the watch taken for the listing is released with it, leaving those of the other watchers
+		if err != nil {
+			c.metaCache.UnwatchDirectory(dirPath)
+			return nil, err
+		}
+		if tooMany {
+			c.metaCache.UnwatchDirectory(dirPath)
+			listing.entries, listing.tooLarge = nil, true
+		}
+		c.drop(dirPath)
+		c.dropExpired()
+		c.listings[dirPath] = listing
+		return listing, nil
+	})
+	if err != nil {
+		// e.g. interrupted while listing for another handle
+		return nil, false
+	}
+	listing := v.(*dirListing)
+	return listing.entries, !listing.tooLarge
+}
+
+func (c *DirListingCache) validListing(dirPath util.FullPath) *dirListing {
+	c.Lock()
+	defer c.Unlock()
+	listing, found := c.listings[dirPath]
+	if !found {
+		return nil
+	}
+	if dirListingNow().Sub(listing.listedAt) >= c.ttl {
+		c.drop(dirPath)
+		return nil
+	}
+	if listing.tooLarge {
+		return listing
+	}
+	// the changes netting out, e.g. a temporary file created and removed again, keep the listing valid
+	if changes, _, err := c.metaCache.DirectoryChangesSince(dirPath, listing.version); err != nil || len(changes) > 0 {
+		c.drop(dirPath)
+		return nil
+	}
+	return listing
+}
+
+func (c *DirListingCache) drop(dirPath util.FullPath) {
+	listing, found := c.listings[dirPath]
+	if !found {
+		return
+	}
+	delete(c.listings, dirPath)
+	if !listing.tooLarge {
+		c.metaCache.UnwatchDirectory(dirPath)
+	}
+}
+
+func (c *DirListingCache) dropExpired() {
+	now := dirListingNow()
+	for dirPath, listing := range c.listings {
+		if now.Sub(listing.listedAt) >= c.ttl {
+			c.drop(dirPath)
+		}
+	}
+}
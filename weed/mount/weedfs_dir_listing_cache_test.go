@@ -0,0 +1,122 @@
+package mount
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/mount/meta_cache"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func TestDirListingSharedAmongHandles(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	inode := insertTestFiles(t, wfs, "/dir", 50)
+	wfs.dirListingCache = NewDirListingCache(wfs.metaCache, time.Minute, 0)
+
+	now := time.Now()
+	defer func(nowFn func() time.Time) { dirListingNow = nowFn }(dirListingNow)
+	dirListingNow = func() time.Time { return now }
+
+	listings := 0
+	defer func(listFn func(context.Context, *meta_cache.MetaCache, util.FullPath, string, int64, func(*filer.Entry) bool) error) {
+		listMetaCacheEntries = listFn
+	}(listMetaCacheEntries)
+	listFn := listMetaCacheEntries
+	listMetaCacheEntries = func(ctx context.Context, metaCache *meta_cache.MetaCache, dirPath util.FullPath, startFileName string, limit int64, eachEntryFn func(*filer.Entry) bool) error {
+		listings++
+		return listFn(ctx, metaCache, dirPath, startFileName, limit, eachEntryFn)
+	}
+
+	expected := fmt.Sprint(append([]string{".", ".."}, testNames("file%05d", 0, 50)...))
+
+	// two handles over the same directory, each read in many pages
+	for i := 0; i < 2; i++ {
+		if listed := fmt.Sprint(readDirByPages(t, wfs, inode)); listed != expected {
+			t.Fatalf("handle %d listed %s", i, listed)
+		}
+	}
+	if listings != 1 {
+		t.Errorf("%d listings of the meta cache, expect 1", listings)
+	}
+
+	// a file created and removed again is no change
+	mustDo := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustDo(wfs.metaCache.InsertEntry(context.Background(), &filer.Entry{FullPath: "/dir/tmp", Attr: filer.Attr{Mode: 0644}}))
+	mustDo(wfs.metaCache.DeleteEntry(context.Background(), "/dir/tmp"))
+	readDirByPages(t, wfs, inode)
+	if listings != 1 {
+		t.Errorf("%d listings of the meta cache after a temporary file, expect 1", listings)
+	}
+
+	// a new file invalidates the listing
+	mustDo(wfs.metaCache.InsertEntry(context.Background(), &filer.Entry{FullPath: "/dir/file00050", Attr: filer.Attr{Mode: 0644}}))
+	expected = fmt.Sprint(append([]string{".", ".."}, testNames("file%05d", 0, 51)...))
+	if listed := fmt.Sprint(readDirByPages(t, wfs, inode)); listed != expected {
+		t.Errorf("listed %s after adding a file", listed)
+	}
+	if listings != 2 {
+		t.Errorf("%d listings of the meta cache after adding a file, expect 2", listings)
+	}
+
+	// and so does the window passing
+	now = now.Add(time.Minute)
+	readDirByPages(t, wfs, inode)
+	if listings != 3 {
+		t.Errorf("%d listings of the meta cache after the window, expect 3", listings)
+	}
+
+}
+
+func TestDirListingTooLargeToShare(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	inode := insertTestFiles(t, wfs, "/dir", 50)
+	wfs.dirListingCache = NewDirListingCache(wfs.metaCache, time.Minute, 20)
+
+	expected := fmt.Sprint(append([]string{".", ".."}, testNames("file%05d", 0, 50)...))
+	for i := 0; i < 2; i++ {
+		if listed := fmt.Sprint(readDirByPages(t, wfs, inode)); listed != expected {
+			t.Fatalf("handle %d listed %s", i, listed)
+		}
+	}
+	if listing := wfs.dirListingCache.validListing("/dir"); listing == nil || !listing.tooLarge {
+		t.Errorf("the directory should be kept as too large to share")
+	}
+
+}
+
+func TestDirListingKeepsOtherWatches(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	inode := insertTestFiles(t, wfs, "/dir", 50)
+	version := wfs.metaCache.WatchDirectory("/dir")
+
+	now := time.Now()
+	defer func(nowFn func() time.Time) { dirListingNow = nowFn }(dirListingNow)
+	dirListingNow = func() time.Time { return now }
+
+	// a listing shared, then dropped as expired, and one too large to share
+	wfs.dirListingCache = NewDirListingCache(wfs.metaCache, time.Minute, 0)
+	readDirByPages(t, wfs, inode)
+	now = now.Add(time.Minute)
+	readDirByPages(t, wfs, inode)
+	wfs.dirListingCache = NewDirListingCache(wfs.metaCache, time.Minute, 20)
+	readDirByPages(t, wfs, inode)
+
+	if err := wfs.metaCache.InsertEntry(context.Background(), &filer.Entry{FullPath: "/dir/file00050", Attr: filer.Attr{Mode: 0644}}); err != nil {
+		t.Fatal(err)
+	}
+	if changes, _, err := wfs.metaCache.DirectoryChangesSince("/dir", version); err != nil || len(changes) != 1 {
+		t.Errorf("%d changes, %v, of the directory watched besides the listings", len(changes), err)
+	}
+
+}
@@ -20,7 +20,10 @@ func (wfs *WFS) Lookup(cancel <-chan struct{}, header *fuse.InHeader, name strin
 		return s
 	}
 
-	dirPath := wfs.inodeToPath.GetPath(header.NodeId)
+	dirPath, code := wfs.inodeToPath.GetPath(header.NodeId)
+	if code != fuse.OK {
+		return
+	}
 
 	fullFilePath := dirPath.Child(name)
 
@@ -30,8 +33,20 @@ func (wfs *WFS) Lookup(cancel <-chan struct{}, header *fuse.InHeader, name strin
 		return fuse.EIO
 	}
 	localEntry, cacheErr := wfs.metaCache.FindEntry(context.Background(), fullFilePath)
+	// an entry of the name itself goes before the entry the name is an alias of
+	if original := wfs.nameAliases.Resolve(dirPath, name); cacheErr == filer_pb.ErrNotFound && original != name {
+		name, fullFilePath = original, dirPath.Child(original)
+		localEntry, cacheErr = wfs.metaCache.FindEntry(context.Background(), fullFilePath)
+	}
+	if cacheErr == filer_pb.ErrNotFound && wfs.option.NameNormalization != "" {
+		if entry := wfs.findNormalizedEntry(dirPath, name); entry != nil {
+			localEntry, cacheErr, fullFilePath = entry, nil, entry.FullPath
+		}
+	}
 	if cacheErr == filer_pb.ErrNotFound {
-		return fuse.ENOENT
+		if localEntry, cacheErr = wfs.findLowerEntry(context.Background(), fullFilePath); cacheErr != nil {
+			return fuse.ENOENT
+		}
 	}
 
 	if localEntry == nil {
@@ -37,7 +37,10 @@ func (wfs *WFS) Mkdir(cancel <-chan struct{}, in *fuse.MkdirIn, name string, out
 		},
 	}
 
-	dirFullPath := wfs.inodeToPath.GetPath(in.NodeId)
+	dirFullPath, code := wfs.inodeToPath.GetPath(in.NodeId)
+	if code != fuse.OK {
+		return
+	}
 
 	entryFullPath := dirFullPath.Child(name)
 
@@ -89,8 +92,14 @@ func (wfs *WFS) Rmdir(cancel <-chan struct{}, header *fuse.InHeader, name string
 		return fuse.Status(syscall.ENOTEMPTY)
 	}
 
-	dirFullPath := wfs.inodeToPath.GetPath(header.NodeId)
+	dirFullPath, code := wfs.inodeToPath.GetPath(header.NodeId)
+	if code != fuse.OK {
+		return
+	}
 	entryFullPath := dirFullPath.Child(name)
+	if code = wfs.checkUnionWritable(entryFullPath); code != fuse.OK {
+		return
+	}
 
 	glog.V(3).Infof("remove directory: %v", entryFullPath)
 	ignoreRecursiveErr := true // ignore recursion error since the OS should manage it
@@ -105,6 +114,9 @@ func (wfs *WFS) Rmdir(cancel <-chan struct{}, header *fuse.InHeader, name string
 
 	wfs.metaCache.DeleteEntry(context.Background(), entryFullPath)
 	wfs.inodeToPath.RemovePath(entryFullPath)
+	wfs.nameAliases.Remove(dirFullPath, name)
+	wfs.nameAliases.RemoveDir(entryFullPath)
+	wfs.listedXAttrs.InvalidateTree(entryFullPath)
 
 	return fuse.OK
 
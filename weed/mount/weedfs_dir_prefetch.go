@@ -0,0 +1,110 @@
+package mount
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/mount/meta_cache"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// DirPrefetcher lists the subdirectories of a directory read in plus mode into the meta cache in the background,
+// so that recursive walks like du or find open them warm.
+// Subdirectories found while all workers are busy are skipped, instead of queued or waited for.
+type DirPrefetcher struct {
+	wfs      *WFS
+	client   filer_pb.FilerClient
+	maxDepth int
+	workers  chan struct{}
+
+	sync.Mutex
+	inFlight map[util.FullPath]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewDirPrefetcher returns nil if concurrency or maxDepth is not positive, which disables prefetching.
+// With maxDepth 1 only the subdirectories of the listed directory are prefetched.
+func NewDirPrefetcher(wfs *WFS, client filer_pb.FilerClient, concurrency, maxDepth int) *DirPrefetcher {
+	if concurrency <= 0 || maxDepth <= 0 {
+		return nil
+	}
+	return &DirPrefetcher{
+		wfs:      wfs,
+		client:   client,
+		maxDepth: maxDepth,
+		workers:  make(chan struct{}, concurrency),
+		inFlight: make(map[util.FullPath]struct{}),
+	}
+}
+
+// Prefetch lists the directory at the depth below the directory read by the kernel, if not cached yet.
+func (p *DirPrefetcher) Prefetch(dirPath util.FullPath, depth int) {
+	if p == nil || depth > p.maxDepth {
+		return
+	}
+	if p.wfs.inodeToPath.IsChildrenCached(dirPath) {
+		return
+	}
+
+	p.Lock()
+	if _, found := p.inFlight[dirPath]; found {
+		p.Unlock()
+		return
+	}
+	select {
+	case p.workers <- struct{}{}:
+	default:
+		p.Unlock()
+		return
+	}
+	p.inFlight[dirPath] = struct{}{}
+	p.wg.Add(1)
+	p.Unlock()
+
+	go func() {
+		defer p.wg.Done()
+		subDirs := p.visit(dirPath, depth)
+
+		p.Lock()
+		delete(p.inFlight, dirPath)
+		p.Unlock()
+		<-p.workers
+
+		for _, subDir := range subDirs {
+			p.Prefetch(subDir, depth+1)
+		}
+	}()
+}
+
+// visit lists the directory into the meta cache, and returns its subdirectories if they are to be prefetched too.
+func (p *DirPrefetcher) visit(dirPath util.FullPath, depth int) (subDirs []util.FullPath) {
+	if err := meta_cache.EnsureVisited(p.wfs.metaCache, p.client, dirPath); err != nil {
+		glog.V(1).Infof("prefetch %s: %v", dirPath, err)
+		return nil
+	}
+	if depth >= p.maxDepth {
+		return nil
+	}
+	err := p.wfs.metaCache.ListDirectoryEntries(context.Background(), dirPath, "", false, int64(math.MaxInt32), func(entry *filer.Entry) bool {
+		if entry.IsDirectory() {
+			subDirs = append(subDirs, entry.FullPath)
+		}
+		return true
+	})
+	if err != nil {
+		glog.V(1).Infof("prefetch %s: %v", dirPath, err)
+		return nil
+	}
+	return subDirs
+}
+
+// Wait waits for the prefetches in flight.
+func (p *DirPrefetcher) Wait() {
+	if p != nil {
+		p.wg.Wait()
+	}
+}
@@ -0,0 +1,116 @@
+package mount
+
+import (
+	"context"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"google.golang.org/grpc"
+)
+
+// fakeListingFilerClient lists the directory entries kept in memory, and records the listed directories
+type fakeListingFilerClient struct {
+	filer_pb.SeaweedFilerClient
+	entries map[string][]*filer_pb.Entry
+
+	sync.Mutex
+	listed []string
+}
+
+func (c *fakeListingFilerClient) WithFilerClient(streamingMode bool, fn func(filer_pb.SeaweedFilerClient) error) error {
+	return fn(c)
+}
+
+func (c *fakeListingFilerClient) AdjustedUrl(location *filer_pb.Location) string {
+	return location.Url
+}
+
+func (c *fakeListingFilerClient) ListEntries(ctx context.Context, in *filer_pb.ListEntriesRequest, opts ...grpc.CallOption) (filer_pb.SeaweedFiler_ListEntriesClient, error) {
+	c.Lock()
+	c.listed = append(c.listed, in.Directory)
+	c.Unlock()
+	var entries []*filer_pb.Entry
+	for _, entry := range c.entries[in.Directory] {
+		if entry.Name > in.StartFromFileName {
+			entries = append(entries, entry)
+		}
+	}
+	return &fakeListEntriesClient{entries: entries}, nil
+}
+
+type fakeListEntriesClient struct {
+	filer_pb.SeaweedFiler_ListEntriesClient
+	entries []*filer_pb.Entry
+}
+
+func (c *fakeListEntriesClient) Recv() (*filer_pb.ListEntriesResponse, error) {
+	if len(c.entries) == 0 {
+		return nil, io.EOF
+	}
+	entry := c.entries[0]
+	c.entries = c.entries[1:]
+	return &filer_pb.ListEntriesResponse{Entry: entry}, nil
+}
+
+func TestReadDirPlusPrefetchesSubdirectories(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	dirAttr := &filer_pb.FuseAttributes{FileMode: uint32(os.ModeDir | 0755)}
+	client := &fakeListingFilerClient{entries: map[string][]*filer_pb.Entry{
+		"/a": {{Name: "x"}, {Name: "deeper", IsDirectory: true, Attributes: dirAttr}},
+		"/b": {{Name: "y"}},
+	}}
+	wfs.dirPrefetcher = NewDirPrefetcher(wfs, client, 2, 1)
+
+	for _, entry := range []*filer.Entry{
+		{FullPath: "/a", Attr: filer.Attr{Mode: os.ModeDir | 0755, Mtime: time.Now()}},
+		{FullPath: "/b", Attr: filer.Attr{Mode: os.ModeDir | 0755, Mtime: time.Now()}},
+		{FullPath: "/f", Attr: filer.Attr{Mode: 0644, Mtime: time.Now()}},
+	} {
+		if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+			t.Fatalf("insert %s: %v", entry.FullPath, err)
+		}
+	}
+
+	var openOut fuse.OpenOut
+	if status := wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: 1}}, &openOut); status != fuse.OK {
+		t.Fatalf("open dir: %v", status)
+	}
+	input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: 1, Length: 1024}, Fh: openOut.Fh}
+	if status := wfs.ReadDirPlus(nil, input, fuse.NewDirEntryList(make([]byte, 64*1024), 0)); status != fuse.OK {
+		t.Fatalf("read dir plus: %v", status)
+	}
+	wfs.dirPrefetcher.Wait()
+
+	client.Lock()
+	listed := append([]string(nil), client.listed...)
+	client.Unlock()
+	sort.Strings(listed)
+	if strings.Join(listed, " ") != "/a /b" {
+		t.Errorf("prefetched %v, expect only the child directories /a /b", listed)
+	}
+
+	// the child directories stay warm once the kernel looks them up
+	wfs.inodeToPath.Lookup("/a", true)
+	for _, dir := range []util.FullPath{"/a", "/b"} {
+		if !wfs.inodeToPath.IsChildrenCached(dir) {
+			t.Errorf("%s not marked cached", dir)
+		}
+	}
+	if entry, err := wfs.metaCache.FindEntry(context.Background(), "/a/x"); err != nil || entry == nil {
+		t.Errorf("prefetched entry /a/x not in the meta cache: %v", err)
+	}
+	if wfs.inodeToPath.IsChildrenCached("/a/deeper") {
+		t.Errorf("prefetched deeper than the depth limit")
+	}
+
+}
@@ -10,6 +10,8 @@ import (
 	"math"
 	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type DirectoryHandleId uint64
@@ -18,6 +20,32 @@ type DirectoryHandle struct {
 	isFinished    bool
 	counter       uint32
 	lastEntryName string
+
+	// for listings not in name order
+	sortMode      string
+	sortedEntries []*filer.Entry
+	sortedIndex   int
+	sortFallback  bool
+
+	// refresh the meta cache from the filer before listing
+	noCache bool
+
+	// list only the entries matching the glob, if any
+	glob *dirGlob
+
+	// list only the subdirectories, e.g. for tree walkers, skipping the files before filling in their attributes
+	dirsOnly bool
+
+	dirPath util.FullPath // guarded by the DirectoryHandleToInode lock
+	stats   DirectoryReadStats
+
+	// the names listed so far, to estimate how many entries fit in the fuse buffer
+	listedNameBytes int64
+	listedNames     int64
+
+	// done once the directory is released, abandoning the warm-ups of its files
+	warmCtx    context.Context
+	cancelWarm context.CancelFunc
 }
 
 type DirectoryHandleToInode struct {
@@ -40,12 +68,22 @@ func (wfs *WFS) AcquireDirectoryHandle() (DirectoryHandleId, *DirectoryHandle) {
 
 	wfs.dhmap.Lock()
 	defer wfs.dhmap.Unlock()
+	dh := wfs.newDirectoryHandle()
+	wfs.dhmap.dir2inode[DirectoryHandleId(fh)] = dh
+	return DirectoryHandleId(fh), dh
+}
+
+func (wfs *WFS) newDirectoryHandle() *DirectoryHandle {
 	dh := &DirectoryHandle{
 		isFinished:    false,
 		lastEntryName: "",
+		sortMode:      wfs.option.DirSortMode,
+		noCache:       wfs.option.DirListNoCache,
+		dirsOnly:      wfs.option.DirListDirsOnly,
 	}
-	wfs.dhmap.dir2inode[DirectoryHandleId(fh)] = dh
-	return DirectoryHandleId(fh), dh
+	dh.glob, _ = newDirGlob(wfs.option.DirListGlob)
+	dh.warmCtx, dh.cancelWarm = context.WithCancel(context.Background())
+	return dh
 }
 
 func (wfs *WFS) GetDirectoryHandle(dhid DirectoryHandleId) *DirectoryHandle {
@@ -54,10 +92,7 @@ func (wfs *WFS) GetDirectoryHandle(dhid DirectoryHandleId) *DirectoryHandle {
 	if dh, found := wfs.dhmap.dir2inode[dhid]; found {
 		return dh
 	}
-	dh := &DirectoryHandle{
-		isFinished:    false,
-		lastEntryName: "",
-	}
+	dh := wfs.newDirectoryHandle()
 
 	wfs.dhmap.dir2inode[dhid] = dh
 	return dh
@@ -66,6 +101,9 @@ func (wfs *WFS) GetDirectoryHandle(dhid DirectoryHandleId) *DirectoryHandle {
 func (wfs *WFS) ReleaseDirectoryHandle(dhid DirectoryHandleId) {
 	wfs.dhmap.Lock()
 	defer wfs.dhmap.Unlock()
+	if dh, found := wfs.dhmap.dir2inode[dhid]; found {
+		dh.cancelWarm()
+	}
 	delete(wfs.dhmap.dir2inode, dhid)
 }
 
@@ -125,22 +163,66 @@ func (wfs *WFS) FsyncDir(cancel <-chan struct{}, input *fuse.FsyncIn) (code fuse
  * '1'.
  */
 func (wfs *WFS) ReadDir(cancel <-chan struct{}, input *fuse.ReadIn, out *fuse.DirEntryList) (code fuse.Status) {
-	return wfs.doReadDirectory(input, out, false)
+	return wfs.doReadDirectory(cancel, input, out, false)
 }
 
 func (wfs *WFS) ReadDirPlus(cancel <-chan struct{}, input *fuse.ReadIn, out *fuse.DirEntryList) (code fuse.Status) {
-	return wfs.doReadDirectory(input, out, true)
+	return wfs.doReadDirectory(cancel, input, out, true)
 }
 
-func (wfs *WFS) doReadDirectory(input *fuse.ReadIn, out *fuse.DirEntryList, isPlusMode bool) fuse.Status {
+// cancelContext returns a context done once the fuse request is interrupted
+func cancelContext(cancel <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	select {
+	case <-cancel:
+		cancelFn()
+		return ctx, cancelFn
+	default:
+	}
+	if cancel != nil {
+		go func() {
+			select {
+			case <-cancel:
+				cancelFn()
+			case <-ctx.Done():
+			}
+		}()
+	}
+	return ctx, cancelFn
+}
+
+func (wfs *WFS) doReadDirectory(cancel <-chan struct{}, input *fuse.ReadIn, out *fuse.DirEntryList, isPlusMode bool) (status fuse.Status) {
 
 	dh := wfs.GetDirectoryHandle(DirectoryHandleId(input.Fh))
 	if dh.isFinished {
 		return fuse.OK
 	}
 
-	dirPath := wfs.inodeToPath.GetPath(input.NodeId)
+	dirPath, code := wfs.inodeToPath.GetPath(input.NodeId)
+	if code != fuse.OK {
+		return code
+	}
+	if dh.dirPath != dirPath {
+		wfs.dhmap.Lock()
+		dh.dirPath = dirPath
+		wfs.dhmap.Unlock()
+	}
+	defer func(start time.Time) {
+		dh.stats.addReadTime(time.Since(start))
+	}(time.Now())
 
+	ctx, cancelFn := cancelContext(cancel)
+	defer cancelFn()
+
+	// an interrupted or failed read is retried from the same offset, so forget the entries listed by it
+	counter, lastEntryName, sortedIndex := dh.counter, dh.lastEntryName, dh.sortedIndex
+	defer func() {
+		if status == fuse.EINTR || status == fuse.EIO {
+			dh.counter, dh.lastEntryName, dh.sortedIndex = counter, lastEntryName, sortedIndex
+		}
+	}()
+
+	batch := &dirBatch{bufferSize: int(input.Size), isPlusMode: isPlusMode}
 	var dirEntry fuse.DirEntry
 	if input.Offset == 0 && !isPlusMode {
 		dh.counter++
@@ -148,6 +230,7 @@ func (wfs *WFS) doReadDirectory(input *fuse.ReadIn, out *fuse.DirEntryList, isPl
 		dirEntry.Name = "."
 		dirEntry.Mode = toSystemMode(os.ModeDir)
 		out.AddDirEntry(dirEntry)
+		batch.used += direntSize(len(dirEntry.Name), false)
 
 		dh.counter++
 		parentDir, _ := dirPath.DirAndName()
@@ -156,13 +239,58 @@ func (wfs *WFS) doReadDirectory(input *fuse.ReadIn, out *fuse.DirEntryList, isPl
 		dirEntry.Name = ".."
 		dirEntry.Mode = toSystemMode(os.ModeDir)
 		out.AddDirEntry(dirEntry)
+		batch.used += direntSize(len(dirEntry.Name), false)
 
 	}
 
+	// the meta cache lists in descending name order natively, but union directories are merged in name order only
+	lowerDir, isUnion := wfs.unionDirs.Lower(dirPath)
+	descending := dh.sortMode == DirSortByNameDesc && !isUnion
+	nativeOrder := dh.sortMode == "" || dh.sortMode == DirSortByName || descending
+	pastGlob := false
+
+	// the attributes of the entries listed in plus mode are filled in by workers at the end
+	var pending []pendingEntryOut
+	if isPlusMode && wfs.option.DirPlusWorkers > 1 {
+		defer func() {
+			wfs.outputFilerEntries(pending, wfs.option.DirPlusWorkers)
+		}()
+	}
+
 	processEachEntryFn := func(entry *filer.Entry, isLast bool) bool {
-		dh.counter++
+		if ctx.Err() != nil {
+			return false
+		}
+		if dh.glob != nil && !dh.glob.match(entry.Name()) {
+			if (nativeOrder || dh.sortFallback) && dh.glob.isPast(entry.Name(), descending) {
+				pastGlob = true
+				return false
+			}
+			dh.lastEntryName = entry.Name()
+			return true
+		}
+		if dh.dirsOnly && !entry.IsDirectory() {
+			dh.lastEntryName = entry.Name()
+			return true
+		}
 		dirEntry.Name = entry.Name()
-		inode := wfs.inodeToPath.GetInode(dirPath.Child(dirEntry.Name))
+		if wfs.option.NameNormalization != "" {
+			if dirEntry.Name = normalizeName(wfs.option.NameNormalization, entry.Name()); dirEntry.Name != entry.Name() {
+				wfs.nameAliases.Add(dirPath, dirEntry.Name, entry.Name())
+			}
+		}
+		if wfs.option.MaxNameLength > 0 && len(dirEntry.Name) > wfs.option.MaxNameLength {
+			if !wfs.option.AliasLongNames {
+				glog.Warningf("skip %s: name longer than %d", entry.FullPath, wfs.option.MaxNameLength)
+				dh.lastEntryName = entry.Name()
+				return true
+			}
+			dirEntry.Name = shortenName(dirEntry.Name, wfs.option.MaxNameLength)
+			wfs.nameAliases.Add(dirPath, dirEntry.Name, entry.Name())
+			glog.Warningf("list %s as %s: name longer than %d", entry.FullPath, dirEntry.Name, wfs.option.MaxNameLength)
+		}
+		dh.counter++
+		inode := wfs.inodeToPath.GetInode(dirPath.Child(entry.Name()))
 		dirEntry.Ino = inode
 		dirEntry.Mode = toSystemMode(entry.Mode)
 		if !isPlusMode {
@@ -174,26 +302,215 @@ func (wfs *WFS) doReadDirectory(input *fuse.ReadIn, out *fuse.DirEntryList, isPl
 			if entryOut == nil {
 				return false
 			}
-			wfs.outputFilerEntry(entryOut, inode, entry)
+			if wfs.option.DirPlusWorkers > 1 {
+				pending = append(pending, pendingEntryOut{out: entryOut, inode: inode, entry: entry})
+			} else {
+				wfs.outputFilerEntry(entryOut, inode, entry)
+			}
+			wfs.listedXAttrs.Add(entry)
+			wfs.maybeWarmFile(dh, entry)
+			if entry.IsDirectory() {
+				wfs.dirPrefetcher.Prefetch(entry.FullPath, 1)
+			}
 		}
+		batch.added(dh, dirEntry.Name)
+		dh.stats.addEntry()
 		dh.lastEntryName = entry.Name()
 		return true
 	}
 
-	if err := meta_cache.EnsureVisited(wfs.metaCache, wfs, dirPath); err != nil {
+	if dh.noCache && input.Offset == 0 {
+		if err := meta_cache.RefreshDirectoryWithContext(ctx, wfs.metaCache, wfs, dirPath); err != nil {
+			if ctx.Err() != nil {
+				return fuse.EINTR
+			}
+			glog.Errorf("dir ReadDirAll %s: %v", dirPath, err)
+			return fuse.EIO
+		}
+	}
+	if err := meta_cache.EnsureVisitedWithContext(ctx, wfs.metaCache, wfs, dirPath); err != nil {
+		if ctx.Err() != nil {
+			return fuse.EINTR
+		}
 		glog.Errorf("dir ReadDirAll %s: %v", dirPath, err)
 		return fuse.EIO
 	}
-	listErr := wfs.metaCache.ListDirectoryEntries(context.Background(), dirPath, dh.lastEntryName, false, int64(math.MaxInt32), func(entry *filer.Entry) bool {
-		return processEachEntryFn(entry, false)
-	})
+	if isUnion {
+		if err := meta_cache.EnsureVisitedWithContext(ctx, wfs.metaCache, wfs, lowerDir); err != nil {
+			if ctx.Err() != nil {
+				return fuse.EINTR
+			}
+			glog.Errorf("dir ReadDirAll %s: %v", lowerDir, err)
+			return fuse.EIO
+		}
+	}
+
+	if !nativeOrder && !dh.sortFallback {
+		if dh.sortedEntries == nil {
+			if status := wfs.loadSortedEntries(ctx, dh, dirPath); status != fuse.OK {
+				return status
+			}
+		}
+		for !dh.sortFallback && dh.sortedIndex < len(dh.sortedEntries) {
+			if !processEachEntryFn(dh.sortedEntries[dh.sortedIndex], false) {
+				break
+			}
+			dh.sortedIndex++
+		}
+		if ctx.Err() != nil {
+			return fuse.EINTR
+		}
+		if !dh.sortFallback {
+			if dh.counter < input.Length {
+				dh.isFinished = true
+			}
+			return fuse.OK
+		}
+	}
+
+	listedCounter := dh.counter
+	var listErr error
+	// fetch about what the fuse buffer can hold at a time, until it is full or the directory ends
+	for {
+		dh.stats.addListCall()
+		limit := batch.limit(dh)
+		fetched, stopped := int64(0), false
+		eachEntryFn := func(entry *filer.Entry) bool {
+			fetched++
+			if !processEachEntryFn(entry, false) {
+				stopped = true
+				return false
+			}
+			return fetched < limit
+		}
+		startFileName, includeStartFile, globStart := dh.glob.startFileName(dh.lastEntryName, descending)
+		if !globStart {
+			startFileName, includeStartFile = dh.lastEntryName, false
+		}
+		if descending || globStart && !isUnion {
+			listErr = wfs.metaCache.ListDirectoryEntriesWithOptions(ctx, dirPath, meta_cache.ListOptions{
+				StartFileName:    startFileName,
+				IncludeStartFile: includeStartFile,
+				Limit:            limit,
+				Descending:       descending,
+			}, eachEntryFn)
+		} else {
+			listErr = wfs.listDirectoryEntries(ctx, dirPath, dh.lastEntryName, limit, eachEntryFn)
+		}
+		if listErr != nil || stopped || fetched < limit {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		return fuse.EINTR
+	}
 	if listErr != nil {
-		glog.Errorf("list meta cache: %v", listErr)
-		return fuse.EIO
+		if dh.counter == listedCounter {
+			glog.Errorf("list meta cache: %v", listErr)
+			return fuse.EIO
+		}
+		// keep the entries listed before the failure, and continue after the last one with the next read
+		glog.Warningf("list meta cache %s after %s: %v", dirPath, dh.lastEntryName, listErr)
+		return fuse.OK
 	}
-	if dh.counter < input.Length {
+	if pastGlob || dh.counter < input.Length {
 		dh.isFinished = true
 	}
 
 	return fuse.OK
 }
+
+func (wfs *WFS) loadSortedEntries(ctx context.Context, dh *DirectoryHandle, dirPath util.FullPath) fuse.Status {
+	limit := wfs.option.DirSortLimit
+	if limit <= 0 {
+		limit = DefaultDirSortLimit
+	}
+	dh.stats.addListCall()
+	entries, sorted, err := collectSortedEntries(func(eachEntryFn func(entry *filer.Entry) bool) error {
+		return wfs.listDirectoryEntries(ctx, dirPath, "", int64(math.MaxInt32), func(entry *filer.Entry) bool {
+			return ctx.Err() == nil && eachEntryFn(entry)
+		})
+	}, dh.sortMode, limit)
+	if ctx.Err() != nil {
+		return fuse.EINTR
+	}
+	if err != nil {
+		glog.Errorf("list meta cache: %v", err)
+		return fuse.EIO
+	}
+	if !sorted {
+		glog.V(1).Infof("list %s in name order: more than %d entries to sort", dirPath, limit)
+		dh.sortFallback = true
+		return fuse.OK
+	}
+	dh.sortedEntries = entries
+	return fuse.OK
+}
+
+type pendingEntryOut struct {
+	out   *fuse.EntryOut
+	inode uint64
+	entry *filer.Entry
+}
+
+// outputFilerEntries fills the entries with up to the given number of workers.
+// The entries are already in the listing in order, so only their attributes are filled in parallel.
+func (wfs *WFS) outputFilerEntries(pending []pendingEntryOut, workers int) {
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+	if workers <= 1 {
+		for _, p := range pending {
+			wfs.outputFilerEntry(p.out, p.inode, p.entry)
+		}
+		return
+	}
+	var wg sync.WaitGroup
+	next := int64(-1)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := atomic.AddInt64(&next, 1); j < int64(len(pending)); j = atomic.AddInt64(&next, 1) {
+				p := pending[j]
+				wfs.outputFilerEntry(p.out, p.inode, p.entry)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// listDirectoryEntries lists the cached directory entries after startFileName in name order,
+// merged with the lower directory entries for a union directory, which are not shared by the DirListingCache.
+// Up to limit entries are fetched from the meta cache, unless the listings are shared whole by the DirListingCache.
+func (wfs *WFS) listDirectoryEntries(ctx context.Context, dirPath util.FullPath, startFileName string, limit int64, eachEntryFn func(entry *filer.Entry) bool) error {
+	if lowerDir, isUnion := wfs.unionDirs.Lower(dirPath); isUnion {
+		return wfs.listUnionEntries(ctx, dirPath, lowerDir, startFileName, eachEntryFn)
+	}
+	if wfs.dirListingCache != nil {
+		limit = int64(math.MaxInt32)
+	}
+	return wfs.dirListingCache.List(dirPath, startFileName, eachEntryFn, func(startFileName string, eachEntryFn func(entry *filer.Entry) bool) error {
+		return listMetaCacheEntries(ctx, wfs.metaCache, dirPath, startFileName, limit, eachEntryFn)
+	})
+}
+
+// listMetaCacheEntries lists up to limit entries of a directory after startFileName in the meta cache. Replaced in tests.
+var listMetaCacheEntries = func(ctx context.Context, metaCache *meta_cache.MetaCache, dirPath util.FullPath, startFileName string, limit int64, eachEntryFn func(entry *filer.Entry) bool) error {
+	return metaCache.ListDirectoryEntries(ctx, dirPath, startFileName, false, limit, eachEntryFn)
+}
+
+// maybeWarmFile prefetches the first chunk of a small file, since files listed in plus mode are often read right away.
+// The warm-ups not started yet are abandoned once the directory is released.
+func (wfs *WFS) maybeWarmFile(dh *DirectoryHandle, entry *filer.Entry) {
+	if wfs.option.WarmFileSizeLimit <= 0 || wfs.chunkCache == nil {
+		return
+	}
+	if entry.IsDirectory() || len(entry.Chunks) == 0 {
+		return
+	}
+	if entry.Size() > uint64(wfs.option.WarmFileSizeLimit) {
+		return
+	}
+	filer.WarmFirstChunkWithContext(dh.warmCtx, wfs.LookupFn(), wfs.chunkCache, entry.Chunks)
+}
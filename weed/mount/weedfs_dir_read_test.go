@@ -0,0 +1,432 @@
+package mount
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/mount/meta_cache"
+	"github.com/chrislusf/seaweedfs/weed/pb"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/util/chunk_cache"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestReadDirOfForgottenInode(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	inode := wfs.inodeToPath.Lookup("/dir", true)
+	wfs.inodeToPath.Forget(inode, 1, nil)
+
+	input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: inode, Length: 1024}, Fh: 1}
+	status := wfs.ReadDir(nil, input, fuse.NewDirEntryList(make([]byte, 4096), 0))
+	if status != fuse.Status(syscall.ESTALE) {
+		t.Errorf("read dir of a forgotten inode: %v, expect ESTALE", status)
+	}
+
+	var out fuse.EntryOut
+	status = wfs.Lookup(nil, &fuse.InHeader{NodeId: inode}, "file", &out)
+	if status != fuse.Status(syscall.ESTALE) {
+		t.Errorf("lookup in a forgotten inode: %v, expect ESTALE", status)
+	}
+
+}
+
+func TestReadDirInterrupted(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	inode := wfs.inodeToPath.Lookup("/dir", true)
+	for i := 0; i < 10; i++ {
+		entry := &filer.Entry{FullPath: util.FullPath(fmt.Sprintf("/dir/file%d", i)), Attr: filer.Attr{Mode: 0644, Mtime: time.Now()}}
+		if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+			t.Fatalf("insert %s: %v", entry.FullPath, err)
+		}
+	}
+	wfs.inodeToPath.MarkChildrenCached("/dir")
+
+	input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: inode, Length: 1024}, Fh: 1}
+	cancel := make(chan struct{})
+	close(cancel)
+	status := wfs.ReadDir(cancel, input, fuse.NewDirEntryList(make([]byte, 4096), 0))
+	if status != fuse.EINTR {
+		t.Fatalf("interrupted read dir: %v, expect EINTR", status)
+	}
+	dh := wfs.GetDirectoryHandle(1)
+	if dh.counter != 0 || dh.lastEntryName != "" || dh.isFinished {
+		t.Errorf("interrupted read dir moved on to entry %d %q", dh.counter, dh.lastEntryName)
+	}
+
+	// the retried read lists all entries
+	status = wfs.ReadDir(nil, input, fuse.NewDirEntryList(make([]byte, 4096), 0))
+	if status != fuse.OK {
+		t.Fatalf("read dir: %v", status)
+	}
+	if dh.counter != 12 || dh.lastEntryName != "file9" {
+		t.Errorf("read dir listed %d entries up to %q, expect 12 up to file9", dh.counter, dh.lastEntryName)
+	}
+
+}
+
+func TestEntryGenerationOnInodeReuse(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	wfs.option.EntryGeneration = true
+	entry := &filer.Entry{FullPath: "/dir/file", Attr: filer.Attr{Mode: 0644, Mtime: time.Now()}}
+	if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+		t.Fatalf("insert %s: %v", entry.FullPath, err)
+	}
+	inode := wfs.inodeToPath.Lookup(entry.FullPath, false)
+
+	generationOf := func(inode uint64) uint64 {
+		var out fuse.EntryOut
+		wfs.outputFilerEntry(&out, inode, entry)
+		return out.Generation
+	}
+	generation := generationOf(inode)
+	if again := generationOf(inode); again != generation {
+		t.Fatalf("generation changed from %d to %d between reads", generation, again)
+	}
+
+	// changed by another client, still the same entry
+	wfs.invalidateEntry(entry.FullPath, entry.ToProtoEntry())
+	if changed := generationOf(inode); changed != generation {
+		t.Errorf("generation changed from %d to %d by an update", generation, changed)
+	}
+
+	// deleted and created again by another client, while the kernel holds the inode
+	if err := wfs.metaCache.DeleteEntry(context.Background(), entry.FullPath); err != nil {
+		t.Fatalf("delete %s: %v", entry.FullPath, err)
+	}
+	wfs.invalidateEntry(entry.FullPath, entry.ToProtoEntry())
+	if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+		t.Fatalf("insert %s: %v", entry.FullPath, err)
+	}
+	reused := wfs.inodeToPath.Lookup(entry.FullPath, false)
+	if reused != inode {
+		t.Fatalf("recreated file got inode %d, expect the reused %d", reused, inode)
+	}
+	if recreated := generationOf(reused); recreated == generation {
+		t.Errorf("reused inode %d kept generation %d", reused, recreated)
+	}
+
+}
+
+func TestReleaseDirAbandonsWarmUps(t *testing.T) {
+
+	var started int32
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&started, 1)
+		<-unblock
+		w.Write(make([]byte, 1024))
+	}))
+	defer server.Close()
+
+	wfs := newTestWFS(t)
+	wfs.option.FilerAddresses = []pb.ServerAddress{pb.ServerAddress(server.Listener.Addr().String())}
+	wfs.option.VolumeServerAccess = "filerProxy"
+	wfs.option.WarmFileSizeLimit = 4096
+	wfs.chunkCache = chunk_cache.NewTieredChunkCache(256, t.TempDir(), 8, 1024*1024)
+	defer wfs.chunkCache.Shutdown()
+
+	// more files than prefetch slots, so that most warm-ups wait for a slot
+	fileCount := 2*filer.DefaultPrefetchLimit + 8
+	inode := wfs.inodeToPath.Lookup("/dir", true)
+	for i := 0; i < fileCount; i++ {
+		entry := &filer.Entry{
+			FullPath: util.FullPath(fmt.Sprintf("/dir/file%02d", i)),
+			Attr:     filer.Attr{Mode: 0644, Mtime: time.Now(), FileSize: 1024},
+			Chunks:   []*filer_pb.FileChunk{{FileId: fmt.Sprintf("1,%x0a0b0c0d", i+1), Size: 1024}},
+		}
+		if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+			t.Fatalf("insert %s: %v", entry.FullPath, err)
+		}
+	}
+	wfs.inodeToPath.MarkChildrenCached("/dir")
+
+	var openOut fuse.OpenOut
+	if status := wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: inode}}, &openOut); status != fuse.OK {
+		t.Fatalf("open dir: %v", status)
+	}
+	input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: inode, Length: 64 * 1024}, Fh: openOut.Fh}
+	if status := wfs.ReadDirPlus(nil, input, fuse.NewDirEntryList(make([]byte, 64*1024), 0)); status != fuse.OK {
+		t.Fatalf("read dir: %v", status)
+	}
+	waitFor := func(what string, cond func() bool) {
+		for start := time.Now(); !cond(); time.Sleep(time.Millisecond) {
+			if time.Since(start) > 5*time.Second {
+				t.Fatalf("timeout waiting for %s", what)
+			}
+		}
+	}
+	waitFor("warm-ups to take the prefetch slots", func() bool {
+		return atomic.LoadInt32(&started) == int32(filer.DefaultPrefetchLimit)
+	})
+
+	wfs.ReleaseDir(&fuse.ReleaseIn{Fh: openOut.Fh})
+	close(unblock)
+
+	// the started warm-ups finish, the waiting ones are abandoned
+	waitFor("started warm-ups to finish", func() bool {
+		warmed := 0
+		for i := 0; i < fileCount; i++ {
+			if wfs.chunkCache.GetChunk(fmt.Sprintf("1,%x0a0b0c0d", i+1), 1024) != nil {
+				warmed++
+			}
+		}
+		return warmed == filer.DefaultPrefetchLimit
+	})
+	time.Sleep(100 * time.Millisecond)
+	if fetched := atomic.LoadInt32(&started); fetched != int32(filer.DefaultPrefetchLimit) {
+		t.Errorf("fetched %d chunks, expect only the %d started before the release", fetched, filer.DefaultPrefetchLimit)
+	}
+
+}
+
+func TestReadDirPlusWorkersKeepOrder(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	wfs.option.DirPlusWorkers = 4
+	inode := insertTestFiles(t, wfs, "/dir", 500)
+	// the listing hands out the inodes of the entries already looked up
+	for i := 0; i < 500; i++ {
+		wfs.inodeToPath.Lookup(util.FullPath(fmt.Sprintf("/dir/file%05d", i)), false)
+	}
+
+	var openOut fuse.OpenOut
+	wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: inode}}, &openOut)
+	defer wfs.ReleaseDir(&fuse.ReleaseIn{Fh: openOut.Fh})
+	buf := make([]byte, 1<<20)
+	input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: inode, Length: uint32(len(buf))}, Fh: openOut.Fh}
+	if status := wfs.ReadDirPlus(nil, input, fuse.NewDirEntryList(buf, 0)); status != fuse.OK {
+		t.Fatalf("read dir plus: %v", status)
+	}
+
+	// each listed entry, in name order, is followed by its own attributes
+	const direntSize = 24 // ino, off, namelen, type
+	listed := 0
+	for offset := 0; offset+int(unsafe.Sizeof(fuse.EntryOut{}))+direntSize <= len(buf); listed++ {
+		out := (*fuse.EntryOut)(unsafe.Pointer(&buf[offset]))
+		offset += int(unsafe.Sizeof(fuse.EntryOut{}))
+		ino := binary.LittleEndian.Uint64(buf[offset:])
+		nameLen := int(binary.LittleEndian.Uint32(buf[offset+16:]))
+		if nameLen == 0 {
+			break
+		}
+		name := string(buf[offset+direntSize : offset+direntSize+nameLen])
+		offset += (direntSize + nameLen + 7) &^ 7
+
+		if expected := fmt.Sprintf("file%05d", listed); name != expected {
+			t.Fatalf("listed %s at %d, expect %s", name, listed, expected)
+		}
+		if expected := wfs.inodeToPath.GetInode(util.FullPath("/dir").Child(name)); ino != expected {
+			t.Fatalf("%s: listed inode %d, expect %d", name, ino, expected)
+		}
+		if out.NodeId != ino || out.Attr.Ino != ino || out.Attr.Size != uint64(listed) {
+			t.Fatalf("%s: node %d attr ino %d size %d, expect node %d size %d", name, out.NodeId, out.Attr.Ino, out.Attr.Size, ino, listed)
+		}
+	}
+	if listed != 500 {
+		t.Errorf("listed %d entries, expect 500", listed)
+	}
+
+}
+
+func insertTestFiles(t testing.TB, wfs *WFS, dir util.FullPath, count int) (inode uint64) {
+	inode = wfs.inodeToPath.Lookup(dir, true)
+	for i := 0; i < count; i++ {
+		entry := &filer.Entry{
+			FullPath: dir.Child(fmt.Sprintf("file%05d", i)),
+			Attr:     filer.Attr{Mode: 0644, Mtime: time.Now(), FileSize: uint64(i)},
+		}
+		if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+			t.Fatalf("insert %s: %v", entry.FullPath, err)
+		}
+	}
+	wfs.inodeToPath.MarkChildrenCached(dir)
+	return
+}
+
+func BenchmarkReadDirPlus(b *testing.B) {
+
+	for _, workers := range []int{0, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			wfs := newTestWFS(b)
+			wfs.option.DirPlusWorkers = workers
+			inode := insertTestFiles(b, wfs, "/dir", 10000)
+			buf := make([]byte, 4<<20)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var openOut fuse.OpenOut
+				wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: inode}}, &openOut)
+				input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: inode, Length: uint32(len(buf))}, Fh: openOut.Fh}
+				if status := wfs.ReadDirPlus(nil, input, fuse.NewDirEntryList(buf, 0)); status != fuse.OK {
+					b.Fatalf("read dir plus: %v", status)
+				}
+				wfs.ReleaseDir(&fuse.ReleaseIn{Fh: openOut.Fh})
+			}
+		})
+	}
+
+}
+
+func TestReadDirDescendingByPages(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	wfs.option.DirSortMode = DirSortByNameDesc
+	inode := insertTestFiles(t, wfs, "/dir", 100)
+	insertTestFiles(t, wfs, "/dir/file00050", 3)
+
+	var openOut fuse.OpenOut
+	wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: inode}}, &openOut)
+	defer wfs.ReleaseDir(&fuse.ReleaseIn{Fh: openOut.Fh})
+
+	// small buffers, so that each read continues after the last entry listed by the previous one.
+	// The handle takes a read of fewer entries than the length for the last one, so ask for a single entry.
+	var names []string
+	for offset := uint64(0); ; {
+		buf := make([]byte, 256)
+		input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: inode, Length: 1}, Fh: openOut.Fh, Offset: offset}
+		out := fuse.NewDirEntryList(buf, offset)
+		if status := wfs.ReadDir(nil, input, out); status != fuse.OK {
+			t.Fatalf("read dir: %v", status)
+		}
+		page := direntNames(buf)
+		if len(page) == 0 {
+			break
+		}
+		names = append(names, page...)
+		offset += uint64(len(page))
+	}
+
+	if len(names) != 102 || names[0] != "." || names[1] != ".." {
+		t.Fatalf("listed %d entries starting with %v, expect 102 starting with . and ..", len(names), names[:2])
+	}
+	for i, name := range names[2:] {
+		if expected := fmt.Sprintf("file%05d", 99-i); name != expected {
+			t.Fatalf("listed %s at %d, expect %s", name, i, expected)
+		}
+	}
+
+}
+
+// direntNames decodes the names of the entries listed in a ReadDir buffer
+func direntNames(buf []byte) (names []string) {
+	const direntSize = 24 // ino, off, namelen, type
+	for offset := 0; offset+direntSize <= len(buf); {
+		nameLen := int(binary.LittleEndian.Uint32(buf[offset+16:]))
+		if nameLen == 0 {
+			break
+		}
+		names = append(names, string(buf[offset+direntSize:offset+direntSize+nameLen]))
+		offset += (direntSize + nameLen + 7) &^ 7
+	}
+	return
+}
+
+func TestReadDirKeepsEntriesListedBeforeFailure(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	inode := insertTestFiles(t, wfs, "/dir", 100)
+
+	// the first listing fails after 30 entries, the next one right away
+	failures := []int{30, 0}
+	defer func(listFn func(context.Context, *meta_cache.MetaCache, util.FullPath, string, int64, func(*filer.Entry) bool) error) {
+		listMetaCacheEntries = listFn
+	}(listMetaCacheEntries)
+	listFn := listMetaCacheEntries
+	listMetaCacheEntries = func(ctx context.Context, metaCache *meta_cache.MetaCache, dirPath util.FullPath, startFileName string, limit int64, eachEntryFn func(*filer.Entry) bool) error {
+		if len(failures) == 0 {
+			return listFn(ctx, metaCache, dirPath, startFileName, limit, eachEntryFn)
+		}
+		failAfter := failures[0]
+		failures = failures[1:]
+		listed := 0
+		err := listFn(ctx, metaCache, dirPath, startFileName, limit, func(entry *filer.Entry) bool {
+			if listed == failAfter {
+				return false
+			}
+			listed++
+			return eachEntryFn(entry)
+		})
+		if err == nil {
+			err = fmt.Errorf("leveldb: read error after %d entries", listed)
+		}
+		return err
+	}
+
+	var openOut fuse.OpenOut
+	wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: inode}}, &openOut)
+	defer wfs.ReleaseDir(&fuse.ReleaseIn{Fh: openOut.Fh})
+	readDir := func(offset uint64) ([]string, fuse.Status) {
+		buf := make([]byte, 8192)
+		input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: inode, Length: 1024}, Fh: openOut.Fh, Offset: offset}
+		status := wfs.ReadDir(nil, input, fuse.NewDirEntryList(buf, offset))
+		return direntNames(buf), status
+	}
+
+	names, status := readDir(0)
+	if status != fuse.OK || len(names) != 32 || names[31] != "file00029" {
+		t.Fatalf("first read %v listed %d entries, expect . .. and 30 files", status, len(names))
+	}
+	if _, status = readDir(32); status != fuse.EIO {
+		t.Fatalf("read failing before any entry: %v, expect EIO", status)
+	}
+	names, status = readDir(32)
+	if status != fuse.OK || len(names) != 70 || names[0] != "file00030" || names[69] != "file00099" {
+		t.Fatalf("retried read %v listed %d entries from %v, expect the other 70 files", status, len(names), names)
+	}
+	if names, status = readDir(102); status != fuse.OK || len(names) != 0 {
+		t.Errorf("read after the last entry %v listed %v", status, names)
+	}
+
+}
+
+func TestReadDirListsOnlyDirectories(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	wfs.option.DirListDirsOnly = true
+	inode := insertTestFiles(t, wfs, "/dir", 1000)
+	for _, name := range []string{"sub1", "sub2", "sub3"} {
+		entry := &filer.Entry{
+			FullPath: util.NewFullPath("/dir", name),
+			Attr:     filer.Attr{Mode: os.ModeDir | 0755, Mtime: time.Now()},
+		}
+		if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+			t.Fatalf("insert %s: %v", entry.FullPath, err)
+		}
+	}
+
+	if listed := readDirByPages(t, wfs, inode); fmt.Sprint(listed) != "[. .. sub1 sub2 sub3]" {
+		t.Errorf("listed %v, expect the directories only", listed)
+	}
+
+	// in plus mode, a buffer with room for a few entries is enough, as the files take no room
+	var openOut fuse.OpenOut
+	wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: inode}}, &openOut)
+	defer wfs.ReleaseDir(&fuse.ReleaseIn{Fh: openOut.Fh})
+	buf := make([]byte, 1024)
+	input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: inode, Length: uint32(len(buf))}, Fh: openOut.Fh}
+	if status := wfs.ReadDirPlus(nil, input, fuse.NewDirEntryList(buf, 0)); status != fuse.OK {
+		t.Fatalf("read dir plus: %v", status)
+	}
+	dh := wfs.GetDirectoryHandle(DirectoryHandleId(openOut.Fh))
+	if !dh.isFinished {
+		t.Errorf("expect the listing done in one read")
+	}
+	if stats := dh.stats.snapshot(); stats.EntriesEmitted != 3 {
+		t.Errorf("emitted %d entries, expect the 3 directories", stats.EntriesEmitted)
+	}
+
+}
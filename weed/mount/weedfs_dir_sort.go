@@ -0,0 +1,70 @@
+package mount
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+)
+
+// directory listings come in name order, ascending or descending, from the meta cache,
+// other orders need to buffer and sort the whole directory
+const (
+	DirSortByName     = "name"
+	DirSortByNameDesc = "name-desc"
+	DirSortByMtime    = "mtime"
+	DirSortBySize     = "size"
+)
+
+// DefaultDirSortLimit is the max number of entries sorted in memory,
+// larger directories are listed in name order
+const DefaultDirSortLimit = 100000
+
+// CheckDirSortMode accepts DirSortByName, DirSortByNameDesc, DirSortByMtime, DirSortBySize, or "" for by name.
+func CheckDirSortMode(sortMode string) error {
+	switch sortMode {
+	case "", DirSortByName, DirSortByNameDesc, DirSortByMtime, DirSortBySize:
+		return nil
+	}
+	return fmt.Errorf("unknown directory sort order %q", sortMode)
+}
+
+// collectSortedEntries reads the directory through listFn and sorts the entries, newest, largest or last named first.
+// It returns false if the directory has more than limit entries.
+func collectSortedEntries(listFn func(eachEntryFn func(entry *filer.Entry) bool) error, sortMode string, limit int) ([]*filer.Entry, bool, error) {
+	var entries []*filer.Entry
+	tooMany := false
+	err := listFn(func(entry *filer.Entry) bool {
+		if len(entries) >= limit {
+			tooMany = true
+			return false
+		}
+		entries = append(entries, entry)
+		return true
+	})
+	if err != nil || tooMany {
+		return nil, false, err
+	}
+	if entries == nil {
+		entries = []*filer.Entry{}
+	}
+	sortEntries(entries, sortMode)
+	return entries, true, nil
+}
+
+func sortEntries(entries []*filer.Entry, sortMode string) {
+	switch sortMode {
+	case DirSortByMtime:
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Mtime.After(entries[j].Mtime)
+		})
+	case DirSortBySize:
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Size() > entries[j].Size()
+		})
+	case DirSortByNameDesc:
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Name() > entries[j].Name()
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package mount
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+func testDirEntries() []*filer.Entry {
+	now := time.Now()
+	return []*filer.Entry{
+		{FullPath: "/dir/a", Attr: filer.Attr{Mtime: now.Add(-time.Hour), FileSize: 30}},
+		{FullPath: "/dir/b", Attr: filer.Attr{Mtime: now, FileSize: 10}},
+		{FullPath: "/dir/c", Attr: filer.Attr{Mtime: now.Add(-time.Minute), FileSize: 20}},
+	}
+}
+
+func listTestEntries(entries []*filer.Entry) func(eachEntryFn func(entry *filer.Entry) bool) error {
+	return func(eachEntryFn func(entry *filer.Entry) bool) error {
+		for _, entry := range entries {
+			if !eachEntryFn(entry) {
+				break
+			}
+		}
+		return nil
+	}
+}
+
+func entryNames(entries []*filer.Entry) (names string) {
+	for _, entry := range entries {
+		names += entry.Name()
+	}
+	return
+}
+
+func TestCollectSortedEntries(t *testing.T) {
+
+	for _, test := range []struct {
+		sortMode string
+		expected string
+	}{
+		{DirSortByName, "abc"},
+		{DirSortByNameDesc, "cba"},
+		{DirSortByMtime, "bca"},
+		{DirSortBySize, "acb"},
+	} {
+		entries, sorted, err := collectSortedEntries(listTestEntries(testDirEntries()), test.sortMode, DefaultDirSortLimit)
+		if err != nil || !sorted {
+			t.Fatalf("sort by %s: sorted=%v err=%v", test.sortMode, sorted, err)
+		}
+		if names := entryNames(entries); names != test.expected {
+			t.Errorf("sort by %s: got %s, expect %s", test.sortMode, names, test.expected)
+		}
+	}
+
+}
+
+func TestCollectSortedEntriesTooLarge(t *testing.T) {
+
+	var entries []*filer.Entry
+	for i := 0; i < 10; i++ {
+		entries = append(entries, &filer.Entry{FullPath: util.FullPath(fmt.Sprintf("/dir/%d", i))})
+	}
+
+	_, sorted, err := collectSortedEntries(listTestEntries(entries), DirSortBySize, 5)
+	if err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	if sorted {
+		t.Errorf("directories larger than the limit should fall back to name order")
+	}
+
+}
+
+func TestCheckDirSortMode(t *testing.T) {
+	for _, sortMode := range []string{"", DirSortByName, DirSortByNameDesc, DirSortByMtime, DirSortBySize} {
+		if err := CheckDirSortMode(sortMode); err != nil {
+			t.Errorf("sort by %q: %v", sortMode, err)
+		}
+	}
+	if err := CheckDirSortMode("ctime"); err == nil {
+		t.Errorf("accepted an unknown sort order")
+	}
+}
@@ -0,0 +1,67 @@
+package mount
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// DirectoryReadStats accumulates the listing work done for open directories,
+// to find out which directories are slow to list.
+type DirectoryReadStats struct {
+	ListCalls      int64         `json:"listCalls"`      // listings read from the meta cache
+	EntriesEmitted int64         `json:"entriesEmitted"` // entries returned to the kernel
+	ReadTime       time.Duration `json:"readTime"`       // time spent in ReadDir and ReadDirPlus
+}
+
+func (s *DirectoryReadStats) addListCall() {
+	atomic.AddInt64(&s.ListCalls, 1)
+}
+
+func (s *DirectoryReadStats) addEntry() {
+	atomic.AddInt64(&s.EntriesEmitted, 1)
+}
+
+func (s *DirectoryReadStats) addReadTime(d time.Duration) {
+	atomic.AddInt64((*int64)(&s.ReadTime), int64(d))
+}
+
+func (s *DirectoryReadStats) snapshot() DirectoryReadStats {
+	return DirectoryReadStats{
+		ListCalls:      atomic.LoadInt64(&s.ListCalls),
+		EntriesEmitted: atomic.LoadInt64(&s.EntriesEmitted),
+		ReadTime:       time.Duration(atomic.LoadInt64((*int64)(&s.ReadTime))),
+	}
+}
+
+// DirectoryReadStats sums up the statistics of the open directory handles by directory path.
+func (wfs *WFS) DirectoryReadStats() map[util.FullPath]DirectoryReadStats {
+	wfs.dhmap.Lock()
+	defer wfs.dhmap.Unlock()
+
+	stats := make(map[util.FullPath]DirectoryReadStats)
+	for _, dh := range wfs.dhmap.dir2inode {
+		if dh.dirPath == "" {
+			continue
+		}
+		s, t := stats[dh.dirPath], dh.stats.snapshot()
+		s.ListCalls += t.ListCalls
+		s.EntriesEmitted += t.EntriesEmitted
+		s.ReadTime += t.ReadTime
+		stats[dh.dirPath] = s
+	}
+	return stats
+}
+
+// ServeDirectoryReadStats writes the statistics of the open directory handles as json.
+func (wfs *WFS) ServeDirectoryReadStats(w http.ResponseWriter, r *http.Request) {
+	stats := make(map[string]DirectoryReadStats)
+	for dirPath, s := range wfs.DirectoryReadStats() {
+		stats[string(dirPath)] = s
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
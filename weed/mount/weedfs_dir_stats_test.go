@@ -0,0 +1,51 @@
+package mount
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestDirectoryReadStats(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	for _, name := range []string{"a", "b", "c"} {
+		entry := &filer.Entry{
+			FullPath: util.NewFullPath("/", name),
+			Attr:     filer.Attr{Mode: 0644, Mtime: time.Now()},
+		}
+		if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+			t.Fatalf("insert %s: %v", entry.FullPath, err)
+		}
+	}
+
+	var openOut fuse.OpenOut
+	if status := wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: 1}}, &openOut); status != fuse.OK {
+		t.Fatalf("open dir: %v", status)
+	}
+	input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: 1, Length: 1024}, Fh: openOut.Fh}
+	if status := wfs.ReadDir(nil, input, fuse.NewDirEntryList(make([]byte, 64*1024), 0)); status != fuse.OK {
+		t.Fatalf("read dir: %v", status)
+	}
+
+	stats, found := wfs.DirectoryReadStats()["/"]
+	if !found {
+		t.Fatalf("no stats for the open directory")
+	}
+	if stats.ListCalls != 1 || stats.EntriesEmitted != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if stats.ReadTime <= 0 {
+		t.Errorf("read time not recorded: %+v", stats)
+	}
+
+	wfs.ReleaseDir(&fuse.ReleaseIn{Fh: openOut.Fh})
+	if len(wfs.DirectoryReadStats()) != 0 {
+		t.Errorf("stats kept after the directory is released")
+	}
+
+}
@@ -61,6 +61,15 @@ import (
 	 * @param fi file information
 */
 func (wfs *WFS) Open(cancel <-chan struct{}, in *fuse.OpenIn, out *fuse.OpenOut) (status fuse.Status) {
+	if isWriteOpen(in.Flags) {
+		path, code := wfs.inodeToPath.GetPath(in.NodeId)
+		if code != fuse.OK {
+			return code
+		}
+		if code = wfs.checkUnionWritable(path); code != fuse.OK {
+			return code
+		}
+	}
 	fileHandle, code := wfs.AcquireHandle(in.NodeId, in.Uid, in.Gid)
 	if code == fuse.OK {
 		out.Fh = uint64(fileHandle.fh)
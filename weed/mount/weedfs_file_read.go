@@ -37,6 +37,11 @@ func (wfs *WFS) Read(cancel <-chan struct{}, in *fuse.ReadIn, buff []byte) (fuse
 		return nil, fuse.ENOENT
 	}
 
+	// paced before locking the range, so a throttled read does not hold up the writes to the file
+	if !wfs.readQoS.Throttle(cancel, fh.inode, fh.entry, len(buff)) {
+		return nil, fuse.EINTR
+	}
+
 	offset := int64(in.Offset)
 	fh.lockForRead(offset, len(buff))
 	defer fh.unlockForRead(offset, len(buff))
@@ -0,0 +1,138 @@
+package mount
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+)
+
+const (
+	// the extended attribute tagging a file with its read QoS class, e.g. `setfattr -n user.qos -v bulk`
+	readQoSXAttr = "user.qos"
+
+	readQoSMaxCachedInodes = 100000
+)
+
+// replaced in tests
+var readQoSSleep = func(cancel <-chan struct{}, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-cancel:
+		return false
+	}
+}
+
+// ParseReadQoSClasses parses comma separated <class>:<MB per second> pairs, e.g. "bulk:10,archive:1".
+func ParseReadQoSClasses(s string) (map[string]int64, error) {
+	classes := make(map[string]int64)
+	for _, pair := range strings.Split(s, ",") {
+		if pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("read qos %q: expecting <class>:<MB per second>", pair)
+		}
+		mbPerSecond, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || mbPerSecond <= 0 {
+			return nil, fmt.Errorf("read qos %q: expecting a positive MB per second", pair)
+		}
+		classes[parts[0]] = int64(mbPerSecond * 1024 * 1024)
+	}
+	return classes, nil
+}
+
+// ReadQoS throttles the reads of the files tagged with a class in their user.qos extended attribute,
+// each class sharing one bandwidth limit among all its files. Untagged files, and files of unknown classes,
+// are read at full speed. The class of a file is looked up on its first read, and kept until its
+// extended attributes change.
+type ReadQoS struct {
+	sync.Mutex
+	limiters map[string]*readRateLimiter
+	inodes   map[uint64]*readRateLimiter // nil for the files read at full speed
+}
+
+// NewReadQoS returns nil if no classes are given, which reads all files at full speed.
+func NewReadQoS(classes map[string]int64) *ReadQoS {
+	if len(classes) == 0 {
+		return nil
+	}
+	q := &ReadQoS{
+		limiters: make(map[string]*readRateLimiter),
+		inodes:   make(map[uint64]*readRateLimiter),
+	}
+	for class, bytesPerSecond := range classes {
+		q.limiters[class] = &readRateLimiter{bytesPerSecond: bytesPerSecond}
+	}
+	return q
+}
+
+// Throttle waits as long as reading n bytes of the file takes at the limit of its class,
+// and returns false if the read is interrupted by the kernel meanwhile.
+func (q *ReadQoS) Throttle(cancel <-chan struct{}, inode uint64, entry *filer_pb.Entry, n int) bool {
+	if q == nil || n <= 0 {
+		return true
+	}
+	if limiter := q.limiterOf(inode, entry); limiter != nil {
+		return readQoSSleep(cancel, limiter.reserve(int64(n)))
+	}
+	return true
+}
+
+// Invalidate forgets the class of the file, to look it up again on the next read.
+func (q *ReadQoS) Invalidate(inode uint64) {
+	if q == nil {
+		return
+	}
+	q.Lock()
+	defer q.Unlock()
+	delete(q.inodes, inode)
+}
+
+func (q *ReadQoS) limiterOf(inode uint64, entry *filer_pb.Entry) *readRateLimiter {
+	q.Lock()
+	defer q.Unlock()
+	if limiter, found := q.inodes[inode]; found {
+		return limiter
+	}
+	var limiter *readRateLimiter
+	if entry != nil {
+		if class, found := entry.Extended[XATTR_PREFIX+readQoSXAttr]; found {
+			limiter = q.limiters[string(class)]
+		}
+	}
+	if len(q.inodes) >= readQoSMaxCachedInodes {
+		q.inodes = make(map[uint64]*readRateLimiter)
+	}
+	q.inodes[inode] = limiter
+	return limiter
+}
+
+type readRateLimiter struct {
+	sync.Mutex
+	bytesPerSecond int64
+	// the time the bytes read so far are paced until
+	pacedUntil time.Time
+}
+
+// reserve reserves the time to read n bytes after the bytes reserved before, and returns how long to wait until done
+func (l *readRateLimiter) reserve(n int64) time.Duration {
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	if l.pacedUntil.Before(now) {
+		l.pacedUntil = now
+	}
+	l.pacedUntil = l.pacedUntil.Add(time.Duration(float64(n) / float64(l.bytesPerSecond) * float64(time.Second)))
+	return l.pacedUntil.Sub(now)
+}
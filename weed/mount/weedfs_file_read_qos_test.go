@@ -0,0 +1,93 @@
+package mount
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestReadQoSThrottlesTaggedFiles(t *testing.T) {
+
+	var slept, lastWait time.Duration
+	defer func(sleep func(<-chan struct{}, time.Duration) bool) {
+		readQoSSleep = sleep
+	}(readQoSSleep)
+	readQoSSleep = func(cancel <-chan struct{}, d time.Duration) bool {
+		slept, lastWait = slept+d, d
+		return true
+	}
+
+	wfs := newTestFileWFS(t)
+	wfs.readQoS = NewReadQoS(map[string]int64{"bulk": 1024 * 1024})
+	data := testFileData(1024 * 1024)
+	tagged, _ := newTestFileHandle(wfs, "/tagged", data)
+	tagged.entry.Extended = map[string][]byte{XATTR_PREFIX + readQoSXAttr: []byte("bulk")}
+	untagged, _ := newTestFileHandle(wfs, "/untagged", data)
+	unknown, _ := newTestFileHandle(wfs, "/unknown", data)
+	unknown.entry.Extended = map[string][]byte{XATTR_PREFIX + readQoSXAttr: []byte("gold")}
+
+	// the reads of a class are paced one after another, at 1MB/s the 4 reads of 256KB take a second.
+	// Not sleeping for real, the last read waits for all of them.
+	for i := 0; i < 4; i++ {
+		readTestFileHandle(t, wfs, tagged, int64(i)*256*1024, 256*1024)
+	}
+	if lastWait < 950*time.Millisecond || lastWait > time.Second {
+		t.Errorf("tagged reads paced to %v, expect 1 second", lastWait)
+	}
+
+	slept = 0
+	for i := 0; i < 4; i++ {
+		readTestFileHandle(t, wfs, untagged, int64(i)*256*1024, 256*1024)
+		readTestFileHandle(t, wfs, unknown, int64(i)*256*1024, 256*1024)
+	}
+	if slept != 0 {
+		t.Errorf("untagged reads waited %v", slept)
+	}
+
+	// the class is kept until the extended attributes change
+	delete(tagged.entry.Extended, XATTR_PREFIX+readQoSXAttr)
+	readTestFileHandle(t, wfs, tagged, 0, 256*1024)
+	if slept == 0 {
+		t.Errorf("expect the class of the file kept")
+	}
+	slept = 0
+	wfs.readQoS.Invalidate(tagged.inode)
+	readTestFileHandle(t, wfs, tagged, 0, 256*1024)
+	if slept != 0 {
+		t.Errorf("untagged file still throttled after the invalidation")
+	}
+
+}
+
+func TestReadQoSThrottleInterrupted(t *testing.T) {
+
+	wfs := newTestFileWFS(t)
+	wfs.readQoS = NewReadQoS(map[string]int64{"bulk": 1024})
+	fh, _ := newTestFileHandle(wfs, "/tagged", testFileData(4096))
+	fh.entry.Extended = map[string][]byte{XATTR_PREFIX + readQoSXAttr: []byte("bulk")}
+
+	// 4KB at 1KB/s waits for seconds, unless interrupted
+	cancel := make(chan struct{})
+	close(cancel)
+	start := time.Now()
+	if _, status := wfs.Read(cancel, &fuse.ReadIn{Fh: uint64(fh.fh)}, make([]byte, 4096)); status != fuse.EINTR {
+		t.Errorf("interrupted throttled read: %v", status)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("interrupted throttled read took %v", elapsed)
+	}
+
+}
+
+func TestParseReadQoSClasses(t *testing.T) {
+	classes, err := ParseReadQoSClasses("bulk:10,archive:0.5")
+	if err != nil || classes["bulk"] != 10*1024*1024 || classes["archive"] != 512*1024 {
+		t.Errorf("parsed %v: %v", classes, err)
+	}
+	for _, bad := range []string{"bulk", "bulk:fast", "bulk:0", ":1"} {
+		if _, err := ParseReadQoSClasses(bad); err == nil {
+			t.Errorf("expect an error for %q", bad)
+		}
+	}
+}
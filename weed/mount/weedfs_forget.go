@@ -64,5 +64,6 @@ Side effects: increments the lookup count on success
 func (wfs *WFS) Forget(nodeid, nlookup uint64) {
 	wfs.inodeToPath.Forget(nodeid, nlookup, func(dir util.FullPath) {
 		wfs.metaCache.DeleteFolderChildren(context.Background(), dir)
+		wfs.nameAliases.RemoveDir(dir)
 	})
 }
@@ -21,14 +21,23 @@ func (wfs *WFS) Link(cancel <-chan struct{}, in *fuse.LinkIn, name string, out *
 		return s
 	}
 
-	newParentPath := wfs.inodeToPath.GetPath(in.NodeId)
-	oldEntryPath := wfs.inodeToPath.GetPath(in.Oldnodeid)
+	newParentPath, code := wfs.inodeToPath.GetPath(in.NodeId)
+	if code != fuse.OK {
+		return
+	}
+	oldEntryPath, code := wfs.inodeToPath.GetPath(in.Oldnodeid)
+	if code != fuse.OK {
+		return
+	}
 	oldParentPath, _ := oldEntryPath.DirAndName()
 
 	oldEntry, status := wfs.maybeLoadEntry(oldEntryPath)
 	if status != fuse.OK {
 		return status
 	}
+	if status = wfs.checkUnionWritable(oldEntryPath); status != fuse.OK {
+		return status
+	}
 
 	// update old file to hardlink mode
 	if len(oldEntry.HardLinkId) == 0 {
@@ -0,0 +1,146 @@
+package mount
+
+import (
+	"container/list"
+	"io"
+	"sync"
+)
+
+const readCachePageSize = 128 * 1024
+
+// ReadCache keeps recently read pages of files in memory, keyed by inode and page offset,
+// so repeated reads of the same ranges skip the chunk reader.
+// The pages only hold data read from the chunks. Data not flushed yet is still read from the dirty pages,
+// and the pages of an inode are dropped whenever its chunks or size change.
+type ReadCache struct {
+	sync.Mutex
+	pageSize int64
+	maxBytes int64
+	bytes    int64
+	pages    map[uint64]map[int64]*list.Element
+	lru      *list.List // front is the most recently read
+	// bumped on each invalidation, so a page read before it is not kept
+	generation uint64
+}
+
+type readCachePage struct {
+	inode  uint64
+	offset int64
+	data   []byte
+}
+
+// NewReadCache returns nil if maxBytes is not positive, which disables the cache.
+func NewReadCache(maxBytes, pageSize int64) *ReadCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &ReadCache{
+		pageSize: pageSize,
+		maxBytes: maxBytes,
+		pages:    make(map[uint64]map[int64]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// ReadAt reads the file of the inode like r.ReadAt, from the cached pages if any,
+// reading and caching the whole page around each missing range.
+func (rc *ReadCache) ReadAt(inode uint64, r io.ReaderAt, buff []byte, offset int64, fileSize int64) (n int, err error) {
+	if rc == nil {
+		return r.ReadAt(buff, offset)
+	}
+
+	stop := offset + int64(len(buff))
+	if stop > fileSize {
+		stop = fileSize
+	}
+	for pos := offset; pos < stop; {
+		pageOffset := pos / rc.pageSize * rc.pageSize
+		data, found, generation := rc.get(inode, pageOffset)
+		if !found {
+			pageSize := min(rc.pageSize, fileSize-pageOffset)
+			data = make([]byte, pageSize)
+			read, readErr := r.ReadAt(data, pageOffset)
+			if readErr != nil && readErr != io.EOF {
+				return n, readErr
+			}
+			data = data[:read]
+			if int64(read) == pageSize {
+				rc.put(inode, pageOffset, data, generation)
+			}
+		}
+		if pos-pageOffset >= int64(len(data)) {
+			break
+		}
+		copied := copy(buff[pos-offset:stop-offset], data[pos-pageOffset:])
+		n += copied
+		pos += int64(copied)
+	}
+	if n < len(buff) {
+		err = io.EOF
+	}
+	return
+}
+
+// Invalidate drops the cached pages of the inode.
+func (rc *ReadCache) Invalidate(inode uint64) {
+	if rc == nil {
+		return
+	}
+	rc.Lock()
+	defer rc.Unlock()
+	rc.generation++
+	for _, element := range rc.pages[inode] {
+		rc.bytes -= int64(len(element.Value.(*readCachePage).data))
+		rc.lru.Remove(element)
+	}
+	delete(rc.pages, inode)
+}
+
+// Bytes tells the size of the cached pages.
+func (rc *ReadCache) Bytes() int64 {
+	if rc == nil {
+		return 0
+	}
+	rc.Lock()
+	defer rc.Unlock()
+	return rc.bytes
+}
+
+func (rc *ReadCache) get(inode uint64, offset int64) (data []byte, found bool, generation uint64) {
+	rc.Lock()
+	defer rc.Unlock()
+	element, found := rc.pages[inode][offset]
+	if !found {
+		return nil, false, rc.generation
+	}
+	rc.lru.MoveToFront(element)
+	return element.Value.(*readCachePage).data, true, rc.generation
+}
+
+func (rc *ReadCache) put(inode uint64, offset int64, data []byte, generation uint64) {
+	rc.Lock()
+	defer rc.Unlock()
+	if generation != rc.generation || int64(len(data)) > rc.maxBytes {
+		return
+	}
+	inodePages, found := rc.pages[inode]
+	if !found {
+		inodePages = make(map[int64]*list.Element)
+		rc.pages[inode] = inodePages
+	}
+	if _, found := inodePages[offset]; found {
+		return
+	}
+	inodePages[offset] = rc.lru.PushFront(&readCachePage{inode: inode, offset: offset, data: data})
+	rc.bytes += int64(len(data))
+
+	for rc.bytes > rc.maxBytes {
+		oldest := rc.lru.Back().Value.(*readCachePage)
+		rc.lru.Remove(rc.lru.Back())
+		rc.bytes -= int64(len(oldest.data))
+		delete(rc.pages[oldest.inode], oldest.offset)
+		if len(rc.pages[oldest.inode]) == 0 {
+			delete(rc.pages, oldest.inode)
+		}
+	}
+}
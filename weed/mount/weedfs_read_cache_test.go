@@ -0,0 +1,126 @@
+package mount
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+type countingReaderAt struct {
+	data  []byte
+	reads int
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.reads++
+	return bytes.NewReader(r.data).ReadAt(p, off)
+}
+
+func testFileData(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	return data
+}
+
+// newTestFileWFS is a test WFS with the options the dirty pages of its file handles are sized by
+func newTestFileWFS(t *testing.T) *WFS {
+	wfs := newTestWFS(t)
+	wfs.option.ChunkSizeLimit = 1024 * 1024
+	wfs.option.ConcurrentWriters = 1
+	return wfs
+}
+
+// newTestFileHandle opens a file handle reading the data through a countingReaderAt instead of the chunks
+func newTestFileHandle(wfs *WFS, path util.FullPath, data []byte) (*FileHandle, *countingReaderAt) {
+	inode := wfs.inodeToPath.Lookup(path, false)
+	entry := &filer_pb.Entry{Name: path.Name(), Attributes: &filer_pb.FuseAttributes{FileSize: uint64(len(data))}}
+	fh := wfs.fhmap.AcquireFileHandle(wfs, inode, entry)
+	fh.entry = entry
+	reader := &countingReaderAt{data: data}
+	fh.reader = reader
+	fh.entryViewCache = []filer.VisibleInterval{}
+	return fh, reader
+}
+
+func readTestFileHandle(t *testing.T, wfs *WFS, fh *FileHandle, offset int64, size int) []byte {
+	t.Helper()
+	result, status := wfs.Read(nil, &fuse.ReadIn{Fh: uint64(fh.fh), Offset: uint64(offset)}, make([]byte, size))
+	if status != fuse.OK {
+		t.Fatalf("read [%d,%d): %v", offset, offset+int64(size), status)
+	}
+	got, _ := result.Bytes(nil)
+	return got
+}
+
+func TestReadCacheHitsRepeatedReadsUntilWrite(t *testing.T) {
+
+	wfs := newTestFileWFS(t)
+	wfs.readCache = NewReadCache(1024*1024, 4096)
+
+	data := testFileData(10000)
+	fh, reader := newTestFileHandle(wfs, "/file", data)
+
+	read := func(offset int64, size int) []byte {
+		return readTestFileHandle(t, wfs, fh, offset, size)
+	}
+
+	for i := 0; i < 3; i++ {
+		if got := read(5000, 100); !bytes.Equal(got, data[5000:5100]) {
+			t.Fatalf("read %d: unexpected data", i)
+		}
+	}
+	if got := read(6000, 2000); !bytes.Equal(got, data[6000:8000]) {
+		t.Fatalf("read in the same page: unexpected data")
+	}
+	if reader.reads != 1 {
+		t.Fatalf("expected the repeated reads to hit the cache, got %d chunk reads", reader.reads)
+	}
+
+	if _, status := wfs.Write(nil, &fuse.WriteIn{Fh: uint64(fh.fh), Offset: 5000}, []byte("hello")); status != fuse.OK {
+		t.Fatalf("write: %v", status)
+	}
+	if cached := wfs.readCache.Bytes(); cached != 0 {
+		t.Fatalf("expected the write to drop the cached pages, %d bytes left", cached)
+	}
+	if got := read(5000, 100); !bytes.Equal(got[:5], []byte("hello")) || !bytes.Equal(got[5:], data[5005:5100]) {
+		t.Fatalf("read after write: unexpected data")
+	}
+	if reader.reads != 2 {
+		t.Fatalf("expected the read after the write to miss the cache, got %d chunk reads", reader.reads)
+	}
+}
+
+func TestReadCacheKeepsWithinMaxBytes(t *testing.T) {
+
+	data := testFileData(10000)
+	reader := &countingReaderAt{data: data}
+	rc := NewReadCache(2*1024, 1024)
+
+	buff := make([]byte, 3*1024)
+	n, err := rc.ReadAt(1, reader, buff, 0, int64(len(data)))
+	if err != nil || n != len(buff) || !bytes.Equal(buff, data[:len(buff)]) {
+		t.Fatalf("read: %d %v", n, err)
+	}
+	if cached := rc.Bytes(); cached != 2*1024 {
+		t.Fatalf("expected 2 pages cached, got %d bytes", cached)
+	}
+
+	// the first page is the least recently read, so it is evicted
+	rc.ReadAt(1, reader, buff[:10], 0, int64(len(data)))
+	if reader.reads != 4 {
+		t.Fatalf("expected the evicted page to be read again, got %d reads", reader.reads)
+	}
+
+	// the tail page is shorter than the page size
+	n, err = rc.ReadAt(1, reader, buff, 9*1024, int64(len(data)))
+	if err != io.EOF || n != len(data)-9*1024 || !bytes.Equal(buff[:n], data[9*1024:]) {
+		t.Fatalf("read tail: %d %v", n, err)
+	}
+}
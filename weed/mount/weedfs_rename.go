@@ -145,9 +145,18 @@ func (wfs *WFS) Rename(cancel <-chan struct{}, in *fuse.RenameIn, oldName string
 		return fuse.EINVAL
 	}
 
-	oldDir := wfs.inodeToPath.GetPath(in.NodeId)
+	oldDir, code := wfs.inodeToPath.GetPath(in.NodeId)
+	if code != fuse.OK {
+		return
+	}
 	oldPath := oldDir.Child(oldName)
-	newDir := wfs.inodeToPath.GetPath(in.Newdir)
+	if code = wfs.checkUnionWritable(oldPath); code != fuse.OK {
+		return
+	}
+	newDir, code := wfs.inodeToPath.GetPath(in.Newdir)
+	if code != fuse.OK {
+		return
+	}
 	newPath := newDir.Child(newName)
 
 	glog.V(4).Infof("dir Rename %s => %s", oldPath, newPath)
@@ -201,6 +210,14 @@ func (wfs *WFS) Rename(cancel <-chan struct{}, in *fuse.RenameIn, oldName string
 		return
 	}
 
+	// the aliases of the old name, of a replaced entry, and within a renamed directory, are stale
+	wfs.nameAliases.Remove(oldDir, oldName)
+	wfs.nameAliases.Remove(newDir, newName)
+	wfs.nameAliases.RemoveDir(oldPath)
+	// and so are the attributes listed at either path
+	wfs.listedXAttrs.InvalidateTree(oldPath)
+	wfs.listedXAttrs.InvalidateTree(newPath)
+
 	return fuse.OK
 
 }
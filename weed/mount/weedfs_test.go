@@ -0,0 +1,35 @@
+package mount
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/mount/meta_cache"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// newTestWFS returns a WFS backed by a local meta cache only,
+// with the root directory marked as cached so listing never reaches a filer.
+func newTestWFS(t testing.TB) *WFS {
+	uidGidMapper, err := meta_cache.NewUidGidMapper("", "")
+	if err != nil {
+		t.Fatalf("uid gid mapper: %v", err)
+	}
+	wfs := &WFS{
+		option:      &Option{},
+		inodeToPath: NewInodeToPath(),
+		fhmap:       NewFileHandleToInode(),
+		dhmap:       NewDirectoryHandleToInode(),
+		nameAliases: NewNameAliases(),
+	}
+	wfs.metaCache = meta_cache.NewMetaCache(filepath.Join(t.TempDir(), "meta"), uidGidMapper, func(path util.FullPath) {
+		wfs.inodeToPath.MarkChildrenCached(path)
+	}, func(path util.FullPath) bool {
+		return wfs.inodeToPath.IsChildrenCached(path)
+	}, func(filePath util.FullPath, entry *filer_pb.Entry) {
+	})
+	t.Cleanup(wfs.metaCache.Shutdown)
+	wfs.inodeToPath.MarkChildrenCached("/")
+	return wfs
+}
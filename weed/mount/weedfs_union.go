@@ -0,0 +1,217 @@
+package mount
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/mount/meta_cache"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+const unionListBatchSize = 1024
+
+// UnionDirs overlays directories with lower directories: a union directory lists its own entries
+// and the entries of its lower directory, the own ones shadowing the lower ones of the same name.
+// Subdirectories are merged the same way, and new entries are created in the union directory.
+// The lower entries are read only, since writing one back to the union directory would share
+// its chunks with the lower entry.
+type UnionDirs struct {
+	lowers map[util.FullPath]util.FullPath
+}
+
+// NewUnionDirs takes the lower directory of each union directory, as filer paths.
+func NewUnionDirs(lowers map[util.FullPath]util.FullPath) *UnionDirs {
+	if len(lowers) == 0 {
+		return nil
+	}
+	return &UnionDirs{lowers: lowers}
+}
+
+// ParseUnionDirs parses comma separated <dir>:<lowerDir> pairs of filer paths.
+func ParseUnionDirs(s string) (map[util.FullPath]util.FullPath, error) {
+	lowers := make(map[util.FullPath]util.FullPath)
+	for _, pair := range strings.Split(s, ",") {
+		if pair == "" {
+			continue
+		}
+		parts := strings.Split(pair, ":")
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "/") || !strings.HasPrefix(parts[1], "/") {
+			return nil, fmt.Errorf("union dirs %q: expecting <dir>:<lowerDir> absolute paths", pair)
+		}
+		dir, lowerDir := util.FullPath(strings.TrimSuffix(parts[0], "/")), util.FullPath(strings.TrimSuffix(parts[1], "/"))
+		if dir == "" || lowerDir == "" {
+			return nil, fmt.Errorf("union dirs %q: the root can not be a union or lower directory", pair)
+		}
+		if strings.HasPrefix(string(lowerDir)+"/", string(dir)+"/") || strings.HasPrefix(string(dir)+"/", string(lowerDir)+"/") {
+			return nil, fmt.Errorf("union dirs %q: a directory can not be under its lower directory or above it", pair)
+		}
+		lowers[dir] = lowerDir
+	}
+	return lowers, nil
+}
+
+// Lower returns the lower directory of the directory, if it is a union directory or under one.
+func (u *UnionDirs) Lower(dir util.FullPath) (util.FullPath, bool) {
+	if u == nil {
+		return "", false
+	}
+	for p := dir; ; {
+		if lower, found := u.lowers[p]; found {
+			if rel := strings.TrimPrefix(strings.TrimPrefix(string(dir), string(p)), "/"); rel != "" {
+				return lower.Child(rel), true
+			}
+			return lower, true
+		}
+		if p == "/" {
+			return "", false
+		}
+		parent, _ := p.DirAndName()
+		p = util.FullPath(parent)
+	}
+}
+
+// unionEntry presents a lower entry at its path in the union directory
+func unionEntry(dir util.FullPath, lowerEntry *filer.Entry) *filer.Entry {
+	entry := lowerEntry.ShallowClone()
+	entry.FullPath = dir.Child(lowerEntry.Name())
+	if !entry.IsDirectory() {
+		entry.Attr.Mode &^= 0222
+	}
+	return entry
+}
+
+// findLowerEntry looks up the entry in the lower directory, for an entry not found in its union directory.
+func (wfs *WFS) findLowerEntry(ctx context.Context, fullpath util.FullPath) (*filer.Entry, error) {
+	dir, name := fullpath.DirAndName()
+	lowerDir, found := wfs.unionDirs.Lower(util.FullPath(dir))
+	if !found {
+		return nil, filer_pb.ErrNotFound
+	}
+	if err := meta_cache.EnsureVisitedWithContext(ctx, wfs.metaCache, wfs, lowerDir); err != nil {
+		return nil, err
+	}
+	lowerEntry, err := wfs.metaCache.FindEntry(ctx, lowerDir.Child(name))
+	if err != nil {
+		return nil, err
+	}
+	return unionEntry(util.FullPath(dir), lowerEntry), nil
+}
+
+// checkUnionWritable returns EROFS for an entry only found in the lower directory of its union directory.
+func (wfs *WFS) checkUnionWritable(fullpath util.FullPath) fuse.Status {
+	if wfs.unionDirs == nil {
+		return fuse.OK
+	}
+	if _, err := wfs.metaCache.FindEntry(context.Background(), fullpath); err != filer_pb.ErrNotFound {
+		return fuse.OK
+	}
+	if _, err := wfs.findLowerEntry(context.Background(), fullpath); err == nil {
+		glog.V(1).Infof("%s: lower entry of a union directory is read only", fullpath)
+		return fuse.Status(syscall.EROFS)
+	}
+	return fuse.OK
+}
+
+// isWriteOpen tells whether the open flags allow changing the file
+func isWriteOpen(flags uint32) bool {
+	return flags&(uint32(os.O_WRONLY)|uint32(os.O_RDWR)|uint32(os.O_TRUNC)|uint32(os.O_APPEND)) != 0
+}
+
+// listUnionEntries lists the entries of the union directory after startFileName in name order,
+// merged with the entries of its lower directory not shadowed by an entry of the same name.
+func (wfs *WFS) listUnionEntries(ctx context.Context, dirPath, lowerDir util.FullPath, startFileName string, eachEntryFn func(entry *filer.Entry) bool) error {
+
+	lower := &lowerEntries{
+		wfs:      wfs,
+		ctx:      ctx,
+		dirPath:  dirPath,
+		lowerDir: lowerDir,
+		lastName: startFileName,
+	}
+
+	var lowerErr error
+	stopped := false
+	err := wfs.metaCache.ListDirectoryEntries(ctx, dirPath, startFileName, false, int64(math.MaxInt32), func(entry *filer.Entry) bool {
+		for {
+			next, err := lower.peek()
+			if err != nil {
+				lowerErr = err
+				return false
+			}
+			if next == nil || next.Name() > entry.Name() {
+				break
+			}
+			lower.pop()
+			if next.Name() == entry.Name() {
+				continue
+			}
+			if !eachEntryFn(next) {
+				stopped = true
+				return false
+			}
+		}
+		if !eachEntryFn(entry) {
+			stopped = true
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if lowerErr != nil || stopped {
+		return lowerErr
+	}
+
+	for {
+		next, err := lower.peek()
+		if err != nil || next == nil {
+			return err
+		}
+		lower.pop()
+		if !eachEntryFn(next) {
+			return nil
+		}
+	}
+}
+
+// lowerEntries iterates the lower directory entries in batches, presented in the union directory
+type lowerEntries struct {
+	wfs      *WFS
+	ctx      context.Context
+	dirPath  util.FullPath
+	lowerDir util.FullPath
+	lastName string
+	entries  []*filer.Entry
+	isLast   bool
+}
+
+func (l *lowerEntries) peek() (*filer.Entry, error) {
+	if len(l.entries) == 0 && !l.isLast {
+		err := l.wfs.metaCache.ListDirectoryEntries(l.ctx, l.lowerDir, l.lastName, false, unionListBatchSize, func(entry *filer.Entry) bool {
+			l.entries = append(l.entries, unionEntry(l.dirPath, entry))
+			l.lastName = entry.Name()
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		l.isLast = len(l.entries) < unionListBatchSize
+	}
+	if len(l.entries) == 0 {
+		return nil, nil
+	}
+	return l.entries[0], nil
+}
+
+func (l *lowerEntries) pop() {
+	l.entries = l.entries[1:]
+}
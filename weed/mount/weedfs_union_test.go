@@ -0,0 +1,156 @@
+package mount
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func newTestUnionWFS(t *testing.T, upperNames, lowerNames []string) *WFS {
+	wfs := newTestWFS(t)
+	wfs.unionDirs = NewUnionDirs(map[util.FullPath]util.FullPath{"/upper": "/lower"})
+	for _, dir := range []struct {
+		path  util.FullPath
+		names []string
+		mode  uint32
+	}{{"/upper", upperNames, 0644}, {"/lower", lowerNames, 0664}} {
+		for _, name := range dir.names {
+			entry := &filer.Entry{FullPath: dir.path.Child(name), Attr: filer.Attr{Mode: os.FileMode(dir.mode), Mtime: time.Now()}}
+			if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+				t.Fatalf("insert %s: %v", entry.FullPath, err)
+			}
+		}
+		wfs.inodeToPath.MarkChildrenCached(dir.path)
+	}
+	return wfs
+}
+
+func listUnionNames(t *testing.T, wfs *WFS, dirPath util.FullPath, startFileName string, limit int) (names []string) {
+	err := wfs.listDirectoryEntries(context.Background(), dirPath, startFileName, int64(limit), func(entry *filer.Entry) bool {
+		if entry.FullPath != dirPath.Child(entry.Name()) {
+			t.Errorf("listed %s in %s", entry.FullPath, dirPath)
+		}
+		names = append(names, entry.Name())
+		return len(names) < limit
+	})
+	if err != nil {
+		t.Fatalf("list %s: %v", dirPath, err)
+	}
+	return
+}
+
+func TestUnionDirShadowsLowerEntries(t *testing.T) {
+
+	wfs := newTestUnionWFS(t, []string{"a", "c", "e"}, []string{"b", "c", "d", "f"})
+
+	names := listUnionNames(t, wfs, "/upper", "", 100)
+	if expected := []string{"a", "b", "c", "d", "e", "f"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("listed %v, expect %v", names, expected)
+	}
+
+	dirInode := wfs.inodeToPath.Lookup("/upper", true)
+	var out fuse.EntryOut
+	if status := wfs.Lookup(nil, &fuse.InHeader{NodeId: dirInode}, "c", &out); status != fuse.OK {
+		t.Fatalf("lookup c: %v", status)
+	}
+	if out.Attr.Mode&0777 != 0644 {
+		t.Errorf("lookup c mode %o, expect the upper entry", out.Attr.Mode&0777)
+	}
+	if status := wfs.Lookup(nil, &fuse.InHeader{NodeId: dirInode}, "d", &out); status != fuse.OK {
+		t.Fatalf("lookup d: %v", status)
+	}
+	if out.Attr.Mode&0777 != 0444 {
+		t.Errorf("lookup d mode %o, expect the read only lower entry", out.Attr.Mode&0777)
+	}
+	if status := wfs.Lookup(nil, &fuse.InHeader{NodeId: dirInode}, "g", &out); status != fuse.ENOENT {
+		t.Errorf("lookup g: %v, expect ENOENT", status)
+	}
+
+	if status := wfs.checkUnionWritable("/upper/c"); status != fuse.OK {
+		t.Errorf("upper entry writable: %v", status)
+	}
+	if status := wfs.checkUnionWritable("/upper/d"); status != fuse.Status(syscall.EROFS) {
+		t.Errorf("lower entry writable: %v, expect EROFS", status)
+	}
+	if status := wfs.checkUnionWritable("/upper/g"); status != fuse.OK {
+		t.Errorf("new entry writable: %v", status)
+	}
+
+}
+
+func TestUnionDirPagination(t *testing.T) {
+
+	// more lower entries than one batch, interleaved with upper entries, some of the same names
+	var upperNames, lowerNames, expected []string
+	for i := 0; i < 3*unionListBatchSize; i++ {
+		name := fmt.Sprintf("f%05d", i)
+		switch {
+		case i%3 == 0:
+			upperNames = append(upperNames, name)
+		case i%5 == 0:
+			upperNames = append(upperNames, name)
+			lowerNames = append(lowerNames, name)
+		default:
+			lowerNames = append(lowerNames, name)
+		}
+		expected = append(expected, name)
+	}
+	wfs := newTestUnionWFS(t, upperNames, lowerNames)
+
+	if names := listUnionNames(t, wfs, "/upper", "", len(expected)+1); !reflect.DeepEqual(names, expected) {
+		t.Errorf("listed %d entries, expect %d", len(names), len(expected))
+	}
+
+	// resume after the last listed name, as reading a directory in several calls does
+	var names []string
+	lastName := ""
+	for {
+		page := listUnionNames(t, wfs, "/upper", lastName, 7)
+		if len(page) == 0 {
+			break
+		}
+		names = append(names, page...)
+		lastName = page[len(page)-1]
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("listed %d entries by pages, expect %d", len(names), len(expected))
+	}
+
+}
+
+func TestParseUnionDirs(t *testing.T) {
+
+	lowers, err := ParseUnionDirs("/data:/base/,/home/user:/skel")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	unionDirs := NewUnionDirs(lowers)
+	for dir, expected := range map[util.FullPath]util.FullPath{
+		"/data":          "/base",
+		"/data/a/b":      "/base/a/b",
+		"/home/user/x":   "/skel/x",
+		"/home":          "",
+		"/database/x":    "",
+		"/home/username": "",
+	} {
+		lower, found := unionDirs.Lower(dir)
+		if lower != expected || found != (expected != "") {
+			t.Errorf("lower of %s: %s %v, expect %s", dir, lower, found, expected)
+		}
+	}
+
+	for _, s := range []string{"/data", "data:/base", "/:/base", "/data:/data/base", "/data/x:/data"} {
+		if _, err := ParseUnionDirs(s); err == nil {
+			t.Errorf("parse %q: expect an error", s)
+		}
+	}
+
+}
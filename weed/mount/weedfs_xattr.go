@@ -32,17 +32,24 @@ func (wfs *WFS) GetXAttr(cancel <-chan struct{}, header *fuse.InHeader, attr str
 		return 0, fuse.EINVAL
 	}
 
-	_, _, entry, status := wfs.maybeReadEntry(header.NodeId)
-	if status != fuse.OK {
-		return 0, status
-	}
-	if entry == nil {
-		return 0, fuse.ENOENT
-	}
-	if entry.Extended == nil {
-		return 0, fuse.ENOATTR
+	fullpath, code := wfs.inodeToPath.GetPath(header.NodeId)
+	if code != fuse.OK {
+		return 0, code
+	}
+	data, found, known := wfs.listedXAttrs.Get(fullpath, attr)
+	if pathData, isPath := wfs.fullPathXAttr(fullpath, attr); isPath {
+		data, found, known = pathData, true, true
+	}
+	if !known {
+		_, _, entry, status := wfs.maybeReadEntry(header.NodeId)
+		if status != fuse.OK {
+			return 0, status
+		}
+		if entry == nil {
+			return 0, fuse.ENOENT
+		}
+		data, found = entry.Extended[XATTR_PREFIX+attr]
 	}
-	data, found := entry.Extended[XATTR_PREFIX+attr]
 	if !found {
 		return 0, fuse.ENOATTR
 	}
@@ -80,6 +87,9 @@ func (wfs *WFS) SetXAttr(cancel <-chan struct{}, input *fuse.SetXAttrIn, attr st
 	if len(attr) == 0 {
 		return fuse.EINVAL
 	}
+	if wfs.isFullPathXAttr(attr) {
+		return fuse.EPERM
+	}
 	//validate attr value
 	if len(data) > MAX_XATTR_VALUE_SIZE {
 		if runtime.GOOS == "darwin" {
@@ -93,6 +103,9 @@ func (wfs *WFS) SetXAttr(cancel <-chan struct{}, input *fuse.SetXAttrIn, attr st
 	if status != fuse.OK {
 		return status
 	}
+	if status := wfs.checkUnionWritable(path); status != fuse.OK {
+		return status
+	}
 	if entry.Extended == nil {
 		entry.Extended = make(map[string][]byte)
 	}
@@ -109,6 +122,8 @@ func (wfs *WFS) SetXAttr(cancel <-chan struct{}, input *fuse.SetXAttrIn, attr st
 		entry.Extended[XATTR_PREFIX+attr] = data
 	}
 
+	wfs.listedXAttrs.Invalidate(path)
+	wfs.readQoS.Invalidate(input.NodeId)
 	return wfs.saveEntry(path, entry)
 
 }
@@ -124,11 +139,15 @@ func (wfs *WFS) ListXAttr(cancel <-chan struct{}, header *fuse.InHeader, dest []
 	if entry == nil {
 		return 0, fuse.ENOENT
 	}
-	if entry.Extended == nil {
+	if entry.Extended == nil && !wfs.option.DirListFullPaths {
 		return 0, fuse.ENOATTR
 	}
 
 	var data []byte
+	if wfs.option.DirListFullPaths {
+		data = append(data, FullPathXAttrName...)
+		data = append(data, 0)
+	}
 	for k := range entry.Extended {
 		if strings.HasPrefix(k, XATTR_PREFIX) {
 			data = append(data, k[len(XATTR_PREFIX):]...)
@@ -149,10 +168,16 @@ func (wfs *WFS) RemoveXAttr(cancel <-chan struct{}, header *fuse.InHeader, attr
 	if len(attr) == 0 {
 		return fuse.EINVAL
 	}
+	if wfs.isFullPathXAttr(attr) {
+		return fuse.EPERM
+	}
 	path, _, entry, status := wfs.maybeReadEntry(header.NodeId)
 	if status != fuse.OK {
 		return status
 	}
+	if status := wfs.checkUnionWritable(path); status != fuse.OK {
+		return status
+	}
 	if entry.Extended == nil {
 		return fuse.ENOATTR
 	}
@@ -164,5 +189,7 @@ func (wfs *WFS) RemoveXAttr(cancel <-chan struct{}, header *fuse.InHeader, attr
 
 	delete(entry.Extended, XATTR_PREFIX+attr)
 
+	wfs.listedXAttrs.Invalidate(path)
+	wfs.readQoS.Invalidate(header.NodeId)
 	return wfs.saveEntry(path, entry)
 }
@@ -0,0 +1,120 @@
+package mount
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+const (
+	listedXAttrsTTL        = time.Second // same as the attribute validity given to the kernel
+	listedXAttrsMaxEntries = 100000
+)
+
+// ListedXAttrs keeps the allowlisted extended attributes of the entries listed in plus mode.
+// FUSE has no room for extended attributes in directory listings,
+// so they are kept here to answer the getxattr calls that often follow a listing.
+type ListedXAttrs struct {
+	sync.Mutex
+	names   map[string]struct{}
+	entries map[util.FullPath]*listedXAttrsEntry
+}
+
+type listedXAttrsEntry struct {
+	attrs    map[string][]byte
+	expireAt time.Time
+}
+
+// NewListedXAttrs returns nil if no attribute names are given, which disables keeping any.
+func NewListedXAttrs(names []string) *ListedXAttrs {
+	la := &ListedXAttrs{
+		names:   make(map[string]struct{}),
+		entries: make(map[util.FullPath]*listedXAttrsEntry),
+	}
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			la.names[name] = struct{}{}
+		}
+	}
+	if len(la.names) == 0 {
+		return nil
+	}
+	return la
+}
+
+// Add keeps the allowlisted extended attributes of a listed entry.
+func (la *ListedXAttrs) Add(entry *filer.Entry) {
+	if la == nil {
+		return
+	}
+	attrs := make(map[string][]byte)
+	for name := range la.names {
+		if data, found := entry.Extended[XATTR_PREFIX+name]; found {
+			attrs[name] = data
+		}
+	}
+
+	la.Lock()
+	defer la.Unlock()
+	if len(la.entries) >= listedXAttrsMaxEntries {
+		la.entries = make(map[util.FullPath]*listedXAttrsEntry)
+	}
+	la.entries[entry.FullPath] = &listedXAttrsEntry{
+		attrs:    attrs,
+		expireAt: time.Now().Add(listedXAttrsTTL),
+	}
+}
+
+// Get reports whether the attribute of the entry is known, and its value if it exists.
+func (la *ListedXAttrs) Get(fullpath util.FullPath, name string) (data []byte, exists bool, known bool) {
+	if la == nil {
+		return nil, false, false
+	}
+	if _, allowed := la.names[name]; !allowed {
+		return nil, false, false
+	}
+
+	la.Lock()
+	defer la.Unlock()
+	entry, found := la.entries[fullpath]
+	if !found {
+		return nil, false, false
+	}
+	if time.Now().After(entry.expireAt) {
+		delete(la.entries, fullpath)
+		return nil, false, false
+	}
+	data, exists = entry.attrs[name]
+	return data, exists, true
+}
+
+func (la *ListedXAttrs) Invalidate(fullpath util.FullPath) {
+	if la == nil {
+		return
+	}
+	la.Lock()
+	defer la.Unlock()
+	delete(la.entries, fullpath)
+}
+
+// InvalidateTree drops the attributes kept of the entry and of all entries under it, e.g. of a renamed or removed directory.
+func (la *ListedXAttrs) InvalidateTree(fullpath util.FullPath) {
+	if la == nil {
+		return
+	}
+	la.Lock()
+	defer la.Unlock()
+	delete(la.entries, fullpath)
+	prefix := string(fullpath)
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	for path := range la.entries {
+		if strings.HasPrefix(string(path), prefix) {
+			delete(la.entries, path)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package mount
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/filer"
+	"github.com/chrislusf/seaweedfs/weed/pb/filer_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+func TestReadDirPlusKeepsListedXAttrs(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	wfs.listedXAttrs = NewListedXAttrs([]string{"user.mime"})
+
+	entries := map[string]map[string][]byte{
+		"a": {XATTR_PREFIX + "user.mime": []byte("text/plain"), XATTR_PREFIX + "user.other": []byte("x")},
+		"b": nil,
+	}
+	for name, extended := range entries {
+		entry := &filer.Entry{
+			FullPath: util.NewFullPath("/", name),
+			Attr:     filer.Attr{Mode: 0644, Mtime: time.Now()},
+			Extended: extended,
+		}
+		if err := wfs.metaCache.InsertEntry(context.Background(), entry); err != nil {
+			t.Fatalf("insert %s: %v", entry.FullPath, err)
+		}
+	}
+
+	var openOut fuse.OpenOut
+	if status := wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: 1}}, &openOut); status != fuse.OK {
+		t.Fatalf("open dir: %v", status)
+	}
+	input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: 1, Length: 1024}, Fh: openOut.Fh}
+	if status := wfs.ReadDirPlus(nil, input, fuse.NewDirEntryList(make([]byte, 64*1024), 0)); status != fuse.OK {
+		t.Fatalf("read dir plus: %v", status)
+	}
+
+	// the attributes must now be served without the meta cache
+	for name := range entries {
+		if err := wfs.metaCache.DeleteEntry(context.Background(), util.NewFullPath("/", name)); err != nil {
+			t.Fatalf("delete %s: %v", name, err)
+		}
+	}
+
+	dest := make([]byte, 64)
+	inodeA := wfs.inodeToPath.Lookup("/a", false)
+	size, status := wfs.GetXAttr(nil, &fuse.InHeader{NodeId: inodeA}, "user.mime", dest)
+	if status != fuse.OK || string(dest[:size]) != "text/plain" {
+		t.Errorf("get listed xattr: %q %v", dest[:size], status)
+	}
+	inodeB := wfs.inodeToPath.Lookup("/b", false)
+	if _, status = wfs.GetXAttr(nil, &fuse.InHeader{NodeId: inodeB}, "user.mime", dest); status != fuse.ENOATTR {
+		t.Errorf("get absent listed xattr: %v, expect ENOATTR", status)
+	}
+
+	// attributes not in the allow list are not kept, and read from the deleted entry
+	if _, _, known := wfs.listedXAttrs.Get("/a", "user.other"); known {
+		t.Errorf("kept an attribute not in the allow list")
+	}
+	if _, status = wfs.GetXAttr(nil, &fuse.InHeader{NodeId: inodeA}, "user.other", dest); status != fuse.ENOENT {
+		t.Errorf("get unlisted xattr: %v, expect ENOENT", status)
+	}
+
+	wfs.listedXAttrs.Invalidate("/a")
+	if _, _, known := wfs.listedXAttrs.Get("/a", "user.mime"); known {
+		t.Errorf("kept attributes after invalidation")
+	}
+
+}
+
+func TestListedXAttrsDisabled(t *testing.T) {
+	if NewListedXAttrs(nil) != nil || NewListedXAttrs([]string{""}) != nil {
+		t.Errorf("listed xattrs enabled without attribute names")
+	}
+}
+
+func TestListedXAttrsDroppedWithChangedEntries(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	wfs.listedXAttrs = NewListedXAttrs([]string{"user.mime"})
+	for _, path := range []util.FullPath{"/dir", "/dir/a", "/dir/sub/b", "/dir2/c", "/file"} {
+		wfs.listedXAttrs.Add(&filer.Entry{FullPath: path, Extended: map[string][]byte{XATTR_PREFIX + "user.mime": []byte("text/plain")}})
+	}
+	known := func(path util.FullPath) bool {
+		_, _, known := wfs.listedXAttrs.Get(path, "user.mime")
+		return known
+	}
+
+	// changed or removed by another client
+	wfs.invalidateEntry("/file", &filer_pb.Entry{Name: "file"})
+	wfs.invalidateEntry("/dir", &filer_pb.Entry{Name: "dir", IsDirectory: true})
+	for _, path := range []util.FullPath{"/dir", "/dir/a", "/dir/sub/b", "/file"} {
+		if known(path) {
+			t.Errorf("kept the attributes of %s", path)
+		}
+	}
+	if !known("/dir2/c") {
+		t.Errorf("dropped the attributes of /dir2/c, outside the changed directory")
+	}
+
+}
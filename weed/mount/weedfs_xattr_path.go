@@ -0,0 +1,21 @@
+package mount
+
+import (
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// FullPathXAttrName is the read only extended attribute holding the path of each entry from the mount root,
+// with Option.DirListFullPaths, so that the tools re-exporting or indexing the listings need not rebuild the paths.
+const FullPathXAttrName = "user.seaweedfs.path"
+
+func (wfs *WFS) isFullPathXAttr(attr string) bool {
+	return wfs.option.DirListFullPaths && attr == FullPathXAttrName
+}
+
+// fullPathXAttr returns the FullPathXAttrName value of the entry at the path, if it is the attribute asked for.
+func (wfs *WFS) fullPathXAttr(fullpath util.FullPath, attr string) ([]byte, bool) {
+	if !wfs.isFullPathXAttr(attr) {
+		return nil, false
+	}
+	return []byte(fullpath), true
+}
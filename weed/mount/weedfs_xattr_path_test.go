@@ -0,0 +1,69 @@
+package mount
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+// direntInodes returns the inodes of the entries in a readdir buffer, by name
+func direntInodes(buf []byte) map[string]uint64 {
+	inodes := make(map[string]uint64)
+	for offset := 0; offset+direntHeaderSize <= len(buf); {
+		nameLen := int(binary.LittleEndian.Uint32(buf[offset+16:]))
+		if nameLen == 0 {
+			break
+		}
+		name := string(buf[offset+direntHeaderSize : offset+direntHeaderSize+nameLen])
+		inodes[name] = binary.LittleEndian.Uint64(buf[offset:])
+		offset += direntSize(nameLen, false)
+	}
+	return inodes
+}
+
+func TestListedEntriesFullPathXAttr(t *testing.T) {
+
+	wfs := newTestWFS(t)
+	inode := insertTestFiles(t, wfs, "/dir/sub", 3)
+	// the plain listing hands out the inodes of the entries already looked up
+	for _, name := range testNames("file%05d", 0, 3) {
+		wfs.inodeToPath.Lookup(util.FullPath("/dir/sub").Child(name), false)
+	}
+
+	readDir := func() map[string]uint64 {
+		var openOut fuse.OpenOut
+		wfs.OpenDir(nil, &fuse.OpenIn{InHeader: fuse.InHeader{NodeId: inode}}, &openOut)
+		defer wfs.ReleaseDir(&fuse.ReleaseIn{Fh: openOut.Fh})
+		buf := make([]byte, 4096)
+		input := &fuse.ReadIn{InHeader: fuse.InHeader{NodeId: inode, Length: 1024}, Fh: openOut.Fh}
+		if status := wfs.ReadDir(nil, input, fuse.NewDirEntryList(buf, 0)); status != fuse.OK {
+			t.Fatalf("read dir: %v", status)
+		}
+		return direntInodes(buf)
+	}
+
+	dest := make([]byte, 256)
+	inodes := readDir()
+	if _, status := wfs.GetXAttr(nil, &fuse.InHeader{NodeId: inodes["file00001"]}, FullPathXAttrName, dest); status == fuse.OK {
+		t.Errorf("full path given without the option")
+	}
+
+	wfs.option.DirListFullPaths = true
+	inodes = readDir()
+	for _, name := range testNames("file%05d", 0, 3) {
+		size, status := wfs.GetXAttr(nil, &fuse.InHeader{NodeId: inodes[name]}, FullPathXAttrName, dest)
+		if status != fuse.OK || string(dest[:size]) != "/dir/sub/"+name {
+			t.Errorf("full path of %s: %q %v", name, dest[:size], status)
+		}
+	}
+	size, status := wfs.ListXAttr(nil, &fuse.InHeader{NodeId: inodes["file00000"]}, dest)
+	if status != fuse.OK || string(dest[:size]) != FullPathXAttrName+"\x00" {
+		t.Errorf("listed xattrs %q %v", dest[:size], status)
+	}
+	if status := wfs.SetXAttr(nil, &fuse.SetXAttrIn{InHeader: fuse.InHeader{NodeId: inodes["file00000"]}}, FullPathXAttrName, []byte("/elsewhere")); status != fuse.EPERM {
+		t.Errorf("set the full path: %v, expect EPERM", status)
+	}
+
+}
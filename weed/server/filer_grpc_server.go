@@ -101,6 +101,11 @@ func (fs *FilerServer) LookupVolume(ctx context.Context, req *filer_pb.LookupVol
 		}
 		var locs []*filer_pb.Location
 		locations, found := fs.filer.MasterClient.GetLocations(uint32(vid))
+		if !found {
+			// erasure coded volumes are not pushed to the master client,
+			// but any volume server holding their shards can reconstruct the needles
+			locations, found = fs.lookupEcVolume(ctx, vidString)
+		}
 		if !found {
 			continue
 		}
@@ -0,0 +1,94 @@
+package weed_server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/util"
+	"github.com/chrislusf/seaweedfs/weed/wdclient"
+)
+
+// the time the servers of an erasure coded volume, or their absence, are reused without asking the master again
+const ecVolumeLocationsTTL = time.Minute
+
+// ecVolumeLocations caches the servers of the erasure coded volumes looked up from the master,
+// as they are not pushed to the master client like the other volumes.
+type ecVolumeLocations struct {
+	sync.Mutex
+	clock   util.Clock // the system clock if nil
+	entries map[string]*ecVolumeLocationsEntry
+}
+
+type ecVolumeLocationsEntry struct {
+	locations []wdclient.Location
+	expireAt  time.Time
+}
+
+type masterLookupVolumeFunc func(ctx context.Context, vid string) (*master_pb.LookupVolumeResponse, error)
+
+func (fs *FilerServer) lookupEcVolume(ctx context.Context, vid string) (locations []wdclient.Location, found bool) {
+	return fs.ecLocations.lookup(ctx, vid, func(ctx context.Context, vid string) (resp *master_pb.LookupVolumeResponse, err error) {
+		err = fs.filer.MasterClient.WithClient(false, func(client master_pb.SeaweedClient) error {
+			resp, err = client.LookupVolume(ctx, &master_pb.LookupVolumeRequest{
+				VolumeOrFileIds: []string{vid},
+			})
+			return err
+		})
+		return
+	})
+}
+
+// lookup returns the servers holding any shard of the volume, from the master if not cached or expired.
+// A failed lookup is not cached.
+func (c *ecVolumeLocations) lookup(ctx context.Context, vid string, lookupFn masterLookupVolumeFunc) (locations []wdclient.Location, found bool) {
+
+	c.Lock()
+	entry, cached := c.entries[vid]
+	c.Unlock()
+	if cached && c.now().Before(entry.expireAt) {
+		return entry.locations, len(entry.locations) > 0
+	}
+
+	resp, err := lookupFn(ctx, vid)
+	if err != nil {
+		glog.V(1).Infof("lookup ec volume %s: %v", vid, err)
+		return nil, false
+	}
+	// the master lists a server once for each shard it holds
+	seen := make(map[string]bool)
+	for _, vidLocations := range resp.VolumeIdLocations {
+		for _, loc := range vidLocations.Locations {
+			if seen[loc.Url] {
+				continue
+			}
+			seen[loc.Url] = true
+			locations = append(locations, wdclient.Location{
+				Url:       loc.Url,
+				PublicUrl: loc.PublicUrl,
+				GrpcPort:  int(loc.GrpcPort),
+			})
+		}
+	}
+
+	c.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]*ecVolumeLocationsEntry)
+	}
+	c.entries[vid] = &ecVolumeLocationsEntry{
+		locations: locations,
+		expireAt:  c.now().Add(ecVolumeLocationsTTL),
+	}
+	c.Unlock()
+
+	return locations, len(locations) > 0
+}
+
+func (c *ecVolumeLocations) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
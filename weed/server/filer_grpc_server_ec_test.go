@@ -0,0 +1,87 @@
+package weed_server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/chrislusf/seaweedfs/weed/pb/master_pb"
+	"github.com/chrislusf/seaweedfs/weed/storage/erasure_coding"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// fakeEcTopology answers the lookups like the master, listing the server of each shard in shard order
+type fakeEcTopology struct {
+	shards  map[string][]string // the server of each shard by volume id, "" for a missing shard
+	lookups int
+}
+
+func (f *fakeEcTopology) lookup(ctx context.Context, vid string) (*master_pb.LookupVolumeResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f.lookups++
+	vidLocations := &master_pb.LookupVolumeResponse_VolumeIdLocation{VolumeOrFileId: vid}
+	for _, server := range f.shards[vid] {
+		if server != "" {
+			vidLocations.Locations = append(vidLocations.Locations, &master_pb.Location{Url: server, PublicUrl: server})
+		}
+	}
+	if len(vidLocations.Locations) == 0 {
+		vidLocations.Error = fmt.Sprintf("volume id %s not found", vid)
+	}
+	return &master_pb.LookupVolumeResponse{VolumeIdLocations: []*master_pb.LookupVolumeResponse_VolumeIdLocation{vidLocations}}, nil
+}
+
+func TestLookupEcVolumeWithMissingShard(t *testing.T) {
+
+	shards := make([]string, erasure_coding.TotalShardsCount)
+	for i := range shards {
+		shards[i] = fmt.Sprintf("volume%d:8080", i%4)
+	}
+	shards[5] = ""
+	topology := &fakeEcTopology{shards: map[string][]string{"7": shards}}
+	clock := util.NewTestClock(time.Now())
+	cache := &ecVolumeLocations{clock: clock}
+
+	locations, found := cache.lookup(context.Background(), "7", topology.lookup)
+	if !found {
+		t.Fatalf("ec volume with a missing shard not found")
+	}
+	var urls []string
+	for _, loc := range locations {
+		urls = append(urls, loc.Url)
+	}
+	sort.Strings(urls)
+	if fmt.Sprint(urls) != "[volume0:8080 volume1:8080 volume2:8080 volume3:8080]" {
+		t.Errorf("locations %v, expect each server holding a shard once", urls)
+	}
+
+	// cached until the ttl, the absent volumes too
+	cache.lookup(context.Background(), "7", topology.lookup)
+	if _, found := cache.lookup(context.Background(), "8", topology.lookup); found {
+		t.Errorf("found a volume not in the topology")
+	}
+	cache.lookup(context.Background(), "8", topology.lookup)
+	if topology.lookups != 2 {
+		t.Errorf("%d lookups from the master, expect 2", topology.lookups)
+	}
+	clock.Advance(ecVolumeLocationsTTL)
+	if _, found := cache.lookup(context.Background(), "7", topology.lookup); !found || topology.lookups != 3 {
+		t.Errorf("expired locations found %v, after %d lookups from the master, expect 3", found, topology.lookups)
+	}
+
+	// the lookup is cancelled with the request, and not cached
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	clock.Advance(ecVolumeLocationsTTL)
+	if _, found := cache.lookup(ctx, "7", topology.lookup); found {
+		t.Errorf("found with a cancelled request")
+	}
+	if _, found := cache.lookup(context.Background(), "7", topology.lookup); !found || topology.lookups != 4 {
+		t.Errorf("found %v after a cancelled lookup, after %d lookups from the master, expect 4", found, topology.lookups)
+	}
+
+}
@@ -91,6 +91,8 @@ type FilerServer struct {
 
 	inFlightDataSize      int64
 	inFlightDataLimitCond *sync.Cond
+
+	ecLocations ecVolumeLocations
 }
 
 func NewFilerServer(defaultMux, readonlyMux *http.ServeMux, option *FilerOption) (fs *FilerServer, err error) {
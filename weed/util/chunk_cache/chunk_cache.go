@@ -2,6 +2,8 @@ package chunk_cache
 
 import (
 	"errors"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
@@ -10,6 +12,27 @@ import (
 
 var ErrorOutOfBounds = errors.New("attempt to read out of bounds")
 
+// NamespaceSeparator separates the namespace from the file id in a namespaced key, e.g. "tenant1/3,01637037d6".
+const NamespaceSeparator = "/"
+
+func NamespacedKey(namespace, fileId string) string {
+	return namespace + NamespaceSeparator + fileId
+}
+
+// BlockSeparator separates the block index from the file id in a block key, e.g. "3,01637037d6#2".
+const BlockSeparator = "#"
+
+// BlockKey keys the index-th fixed size block of a chunk, for the readers caching the hot parts of large chunks.
+func BlockKey(fileId string, index int64) string {
+	return fileId + BlockSeparator + strconv.FormatInt(index, 10)
+}
+
+// the on disk layers key the chunks by their needle id alone, so namespaced chunks and chunk blocks
+// are only kept in memory, if small enough for it
+func isMemoryOnlyKey(key string) bool {
+	return strings.Contains(key, NamespaceSeparator) || strings.Contains(key, BlockSeparator)
+}
+
 type ChunkCache interface {
 	GetChunk(fileId string, minSize uint64) (data []byte)
 	GetChunkSlice(fileId string, offset, length uint64) []byte
@@ -29,9 +52,14 @@ type TieredChunkCache struct {
 var _ ChunkCache = &TieredChunkCache{}
 
 func NewTieredChunkCache(maxEntries int64, dir string, diskSizeInUnit int64, unitSize int64) *TieredChunkCache {
+	return NewTieredChunkCacheWithPolicy(NewLRUPolicy(int(maxEntries)), dir, diskSizeInUnit, unitSize)
+}
+
+// NewTieredChunkCacheWithPolicy evicts the in memory chunks by the policy, e.g. an ARCPolicy for mixed scan and hot set reads.
+func NewTieredChunkCacheWithPolicy(policy EvictionPolicy, dir string, diskSizeInUnit int64, unitSize int64) *TieredChunkCache {
 
 	c := &TieredChunkCache{
-		memCache: NewChunkCacheInMemory(maxEntries),
+		memCache: NewChunkCacheInMemoryWithPolicy(policy),
 	}
 	c.diskCaches = make([]*OnDiskCacheLayer, 3)
 	c.onDiskCacheSizeLimit0 = uint64(unitSize)
@@ -64,6 +92,9 @@ func (c *TieredChunkCache) doGetChunk(fileId string, minSize uint64) (data []byt
 		}
 	}
 
+	if isMemoryOnlyKey(fileId) {
+		return nil
+	}
 	fid, err := needle.ParseFileIdFromString(fileId)
 	if err != nil {
 		glog.Errorf("failed to parse file id %s", fileId)
@@ -106,17 +137,22 @@ func (c *TieredChunkCache) GetChunkSlice(fileId string, offset, length uint64) [
 
 func (c *TieredChunkCache) doGetChunkSlice(fileId string, offset, length uint64) (data []byte) {
 
+	// the chunk must be large enough for the slice to be in the layer,
+	// while the slice itself is only length bytes
 	minSize := offset + length
 	if minSize <= c.onDiskCacheSizeLimit0 {
 		data, err := c.memCache.getChunkSlice(fileId, offset, length)
 		if err != nil {
 			glog.Errorf("failed to read from memcache: %s", err)
 		}
-		if len(data) >= int(minSize) {
+		if len(data) >= int(length) {
 			return data
 		}
 	}
 
+	if isMemoryOnlyKey(fileId) {
+		return nil
+	}
 	fid, err := needle.ParseFileIdFromString(fileId)
 	if err != nil {
 		glog.Errorf("failed to parse file id %s", fileId)
@@ -125,19 +161,19 @@ func (c *TieredChunkCache) doGetChunkSlice(fileId string, offset, length uint64)
 
 	if minSize <= c.onDiskCacheSizeLimit0 {
 		data = c.diskCaches[0].getChunkSlice(fid.Key, offset, length)
-		if len(data) >= int(minSize) {
+		if len(data) >= int(length) {
 			return data
 		}
 	}
 	if minSize <= c.onDiskCacheSizeLimit1 {
 		data = c.diskCaches[1].getChunkSlice(fid.Key, offset, length)
-		if len(data) >= int(minSize) {
+		if len(data) >= int(length) {
 			return data
 		}
 	}
 	{
 		data = c.diskCaches[2].getChunkSlice(fid.Key, offset, length)
-		if len(data) >= int(minSize) {
+		if len(data) >= int(length) {
 			return data
 		}
 	}
@@ -163,6 +199,9 @@ func (c *TieredChunkCache) doSetChunk(fileId string, data []byte) {
 		c.memCache.SetChunk(fileId, data)
 	}
 
+	if isMemoryOnlyKey(fileId) {
+		return
+	}
 	fid, err := needle.ParseFileIdFromString(fileId)
 	if err != nil {
 		glog.Errorf("failed to parse file id %s", fileId)
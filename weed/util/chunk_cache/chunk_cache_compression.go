@@ -0,0 +1,40 @@
+package chunk_cache
+
+import (
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/util"
+)
+
+// SetCompression keeps the chunks cached from now on gzipped if that saves at least a tenth of their size,
+// trading the cpu to decompress them on each read for more chunks fitting in the same bytes, e.g. for text files.
+func (c *ChunkCacheInMemory) SetCompression(enabled bool) {
+	c.Lock()
+	defer c.Unlock()
+	c.compress = enabled
+}
+
+// SetMemoryCompression compresses the chunks cached in memory. The on disk layers keep them as is.
+func (c *TieredChunkCache) SetMemoryCompression(enabled bool) {
+	if c == nil {
+		return
+	}
+	c.memCache.SetCompression(enabled)
+}
+
+// compressChunk returns the gzipped chunk, unless it does not compress well, e.g. already compressed data
+func compressChunk(data []byte) (compressed []byte, ok bool) {
+	gzipped, err := util.GzipData(data)
+	if err != nil || len(gzipped)*10 > len(data)*9 {
+		return nil, false
+	}
+	return gzipped, true
+}
+
+func decompressChunk(fileId string, data []byte) []byte {
+	uncompressed, err := util.DecompressData(data)
+	if err != nil {
+		glog.Errorf("decompress cached chunk %s: %v", fileId, err)
+		return nil
+	}
+	return uncompressed
+}
@@ -0,0 +1,118 @@
+package chunk_cache
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func textChunk(index, size int) []byte {
+	var buf bytes.Buffer
+	for line := 0; buf.Len() < size; line++ {
+		fmt.Fprintf(&buf, "chunk %d line %d: the quick brown fox jumps over the lazy dog\n", index, line)
+	}
+	return buf.Bytes()[:size]
+}
+
+func TestChunkCacheCompressionRoundTrip(t *testing.T) {
+
+	text := textChunk(0, 16*1024)
+	random := make([]byte, 16*1024)
+	rand.Read(random)
+
+	plain := NewChunkCacheInMemory(16)
+	compressed := NewChunkCacheInMemory(16)
+	compressed.SetCompression(true)
+	for _, cache := range []*ChunkCacheInMemory{plain, compressed} {
+		cache.SetChunk("text", text)
+		cache.SetChunk("random", random)
+		if !bytes.Equal(cache.GetChunk("text"), text) || !bytes.Equal(cache.GetChunk("random"), random) {
+			t.Fatalf("cached chunks differ from the original ones")
+		}
+		if slice, err := cache.getChunkSlice("text", 100, 50); err != nil || !bytes.Equal(slice, text[100:150]) {
+			t.Fatalf("chunk slice %q: %v", slice, err)
+		}
+	}
+
+	if _, found := compressed.compressed["random"]; found {
+		t.Errorf("random data should be kept uncompressed")
+	}
+	if plain.Bytes() != int64(len(text)+len(random)) {
+		t.Errorf("plain cache holds %d bytes, expect %d", plain.Bytes(), len(text)+len(random))
+	}
+	if compressed.Bytes() >= plain.Bytes()-int64(len(text))/2 {
+		t.Errorf("compressed cache holds %d bytes, plain one %d", compressed.Bytes(), plain.Bytes())
+	}
+
+	compressed.SetChunk("text", random)
+	if compressed.Bytes() != int64(2*len(random)) || !bytes.Equal(compressed.GetChunk("text"), random) {
+		t.Errorf("overwritten chunk accounted as %d bytes", compressed.Bytes())
+	}
+
+}
+
+// readCyclically reads the chunks twice in order through a cache bounded by bytes, and returns the hits
+func readCyclically(cache *ChunkCacheInMemory, chunks [][]byte) (hits int) {
+	for round := 0; round < 2; round++ {
+		for i, chunk := range chunks {
+			fileId := fmt.Sprintf("%d", i)
+			if cache.GetChunk(fileId) != nil {
+				hits++
+				continue
+			}
+			cache.SetChunk(fileId, chunk)
+		}
+	}
+	return
+}
+
+func TestChunkCacheCompressionHitRate(t *testing.T) {
+
+	var chunks [][]byte
+	for i := 0; i < 20; i++ {
+		chunks = append(chunks, textChunk(i, 16*1024))
+	}
+
+	plain := NewChunkCacheInMemory(1000)
+	plain.SetMaxBytes(128 * 1024)
+	compressed := NewChunkCacheInMemory(1000)
+	compressed.SetMaxBytes(128 * 1024)
+	compressed.SetCompression(true)
+
+	plainHits, compressedHits := readCyclically(plain, chunks), readCyclically(compressed, chunks)
+	t.Logf("hit rate plain %d/%d, compressed %d/%d", plainHits, 2*len(chunks), compressedHits, 2*len(chunks))
+	if plainHits != 0 {
+		t.Errorf("plain cache hits %d, expect the cyclic reads to miss all chunks in 8 chunks of budget", plainHits)
+	}
+	if compressedHits != len(chunks) {
+		t.Errorf("compressed cache hits %d, expect all %d chunks to fit in the budget", compressedHits, len(chunks))
+	}
+	if plain.Bytes() > 128*1024 || compressed.Bytes() > 128*1024 {
+		t.Errorf("cached %d and %d bytes over the budget", plain.Bytes(), compressed.Bytes())
+	}
+
+}
+
+func TestEvictionPolicyEvict(t *testing.T) {
+
+	for _, name := range []string{EvictionLRU, EvictionLFU, EvictionARC} {
+		policy, _ := NewEvictionPolicy(name, 8)
+		for i := 0; i < 3; i++ {
+			policy.Add(fmt.Sprintf("%d", i))
+		}
+		policy.Hit("0")
+		evicted := map[string]bool{}
+		for {
+			key, ok := policy.Evict()
+			if !ok {
+				break
+			}
+			evicted[key] = true
+		}
+		if len(evicted) != 3 {
+			t.Errorf("%s: evicted %v, expect all 3 keys", name, evicted)
+		}
+	}
+
+}
@@ -0,0 +1,323 @@
+package chunk_cache
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+)
+
+const (
+	EvictionLRU = "lru"
+	EvictionLFU = "lfu"
+	EvictionARC = "arc"
+)
+
+// EvictionPolicy decides which chunks to drop once the in memory cache is full.
+// The cache serializes the calls, so implementations need no locking.
+type EvictionPolicy interface {
+	// Hit records a read of a cached key.
+	Hit(key string)
+	// Add records a newly cached key, and returns the cached keys to drop.
+	Add(key string) (evicted []string)
+	// Evict drops the next key to evict, for caches bounded by their size in bytes too.
+	Evict() (key string, ok bool)
+	// Remove forgets a key dropped from the cache by other means, e.g. invalidated. Unknown keys are ignored.
+	Remove(key string)
+}
+
+// NewEvictionPolicy creates one of the EvictionLRU, EvictionLFU or EvictionARC policies.
+func NewEvictionPolicy(name string, maxEntries int) (EvictionPolicy, error) {
+	if maxEntries <= 0 {
+		maxEntries = 1
+	}
+	switch name {
+	case EvictionLRU, "":
+		return NewLRUPolicy(maxEntries), nil
+	case EvictionLFU:
+		return NewLFUPolicy(maxEntries), nil
+	case EvictionARC:
+		return NewARCPolicy(maxEntries), nil
+	}
+	return nil, fmt.Errorf("unknown chunk cache eviction policy %q", name)
+}
+
+// lruList keeps keys from the most to the least recently used.
+type lruList struct {
+	list     *list.List
+	elements map[string]*list.Element
+}
+
+func newLruList() *lruList {
+	return &lruList{
+		list:     list.New(),
+		elements: make(map[string]*list.Element),
+	}
+}
+
+func (l *lruList) len() int {
+	return l.list.Len()
+}
+
+func (l *lruList) has(key string) bool {
+	_, found := l.elements[key]
+	return found
+}
+
+func (l *lruList) pushFront(key string) {
+	l.elements[key] = l.list.PushFront(key)
+}
+
+func (l *lruList) moveToFront(key string) {
+	if element, found := l.elements[key]; found {
+		l.list.MoveToFront(element)
+	}
+}
+
+func (l *lruList) remove(key string) {
+	if element, found := l.elements[key]; found {
+		l.list.Remove(element)
+		delete(l.elements, key)
+	}
+}
+
+func (l *lruList) removeOldest() string {
+	element := l.list.Back()
+	l.list.Remove(element)
+	key := element.Value.(string)
+	delete(l.elements, key)
+	return key
+}
+
+// LRUPolicy drops the least recently used chunks.
+type LRUPolicy struct {
+	maxEntries int
+	keys       *lruList
+}
+
+func NewLRUPolicy(maxEntries int) *LRUPolicy {
+	return &LRUPolicy{
+		maxEntries: maxEntries,
+		keys:       newLruList(),
+	}
+}
+
+func (p *LRUPolicy) Hit(key string) {
+	p.keys.moveToFront(key)
+}
+
+func (p *LRUPolicy) Add(key string) (evicted []string) {
+	p.keys.pushFront(key)
+	for p.keys.len() > p.maxEntries {
+		evicted = append(evicted, p.keys.removeOldest())
+	}
+	return
+}
+
+func (p *LRUPolicy) Evict() (key string, ok bool) {
+	if p.keys.len() == 0 {
+		return "", false
+	}
+	return p.keys.removeOldest(), true
+}
+
+func (p *LRUPolicy) Remove(key string) {
+	p.keys.remove(key)
+}
+
+// LFUPolicy drops the least frequently used chunks, the least recently used one among equals.
+type LFUPolicy struct {
+	maxEntries int
+	tick       int64
+	items      map[string]*lfuItem
+	queue      lfuQueue
+}
+
+type lfuItem struct {
+	key        string
+	count      int64
+	lastAccess int64
+	index      int
+}
+
+type lfuQueue []*lfuItem
+
+func (q lfuQueue) Len() int { return len(q) }
+func (q lfuQueue) Less(i, j int) bool {
+	if q[i].count != q[j].count {
+		return q[i].count < q[j].count
+	}
+	return q[i].lastAccess < q[j].lastAccess
+}
+func (q lfuQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+func (q *lfuQueue) Push(x interface{}) {
+	item := x.(*lfuItem)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+func (q *lfuQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+func NewLFUPolicy(maxEntries int) *LFUPolicy {
+	return &LFUPolicy{
+		maxEntries: maxEntries,
+		items:      make(map[string]*lfuItem),
+	}
+}
+
+func (p *LFUPolicy) Hit(key string) {
+	if item, found := p.items[key]; found {
+		p.tick++
+		item.count++
+		item.lastAccess = p.tick
+		heap.Fix(&p.queue, item.index)
+	}
+}
+
+func (p *LFUPolicy) Add(key string) (evicted []string) {
+	p.tick++
+	item := &lfuItem{key: key, count: 1, lastAccess: p.tick}
+	for len(p.queue) >= p.maxEntries {
+		dropped := heap.Pop(&p.queue).(*lfuItem)
+		delete(p.items, dropped.key)
+		evicted = append(evicted, dropped.key)
+	}
+	p.items[key] = item
+	heap.Push(&p.queue, item)
+	return
+}
+
+func (p *LFUPolicy) Evict() (key string, ok bool) {
+	if len(p.queue) == 0 {
+		return "", false
+	}
+	dropped := heap.Pop(&p.queue).(*lfuItem)
+	delete(p.items, dropped.key)
+	return dropped.key, true
+}
+
+func (p *LFUPolicy) Remove(key string) {
+	if item, found := p.items[key]; found {
+		heap.Remove(&p.queue, item.index)
+		delete(p.items, key)
+	}
+}
+
+// ARCPolicy is an adaptive replacement cache policy. Chunks read only once, e.g. by a large scan,
+// stay in the recent list t1, while chunks read again move to the frequent list t2 and survive the scan.
+// The ghost lists b1 and b2 remember recently dropped keys to adapt the target size of t1.
+type ARCPolicy struct {
+	maxEntries int
+	target     int // the target size of t1
+	t1, t2     *lruList
+	b1, b2     *lruList
+}
+
+func NewARCPolicy(maxEntries int) *ARCPolicy {
+	return &ARCPolicy{
+		maxEntries: maxEntries,
+		t1:         newLruList(),
+		t2:         newLruList(),
+		b1:         newLruList(),
+		b2:         newLruList(),
+	}
+}
+
+func (p *ARCPolicy) Hit(key string) {
+	if p.t1.has(key) {
+		p.t1.remove(key)
+		p.t2.pushFront(key)
+	} else {
+		p.t2.moveToFront(key)
+	}
+}
+
+func (p *ARCPolicy) Add(key string) (evicted []string) {
+
+	if p.b1.has(key) {
+		p.target = min(p.maxEntries, p.target+max(p.b2.len()/p.b1.len(), 1))
+		evicted = p.replace(false)
+		p.b1.remove(key)
+		p.t2.pushFront(key)
+		return
+	}
+	if p.b2.has(key) {
+		p.target = max(0, p.target-max(p.b1.len()/p.b2.len(), 1))
+		evicted = p.replace(true)
+		p.b2.remove(key)
+		p.t2.pushFront(key)
+		return
+	}
+
+	if p.t1.len()+p.b1.len() >= p.maxEntries {
+		if p.t1.len() < p.maxEntries {
+			p.b1.removeOldest()
+			evicted = p.replace(false)
+		} else {
+			evicted = append(evicted, p.t1.removeOldest())
+		}
+	} else if total := p.t1.len() + p.t2.len() + p.b1.len() + p.b2.len(); total >= p.maxEntries {
+		if total >= 2*p.maxEntries {
+			p.b2.removeOldest()
+		}
+		evicted = p.replace(false)
+	}
+	p.t1.pushFront(key)
+	return
+}
+
+// replace drops one cached key if the cache is full, remembering it in a ghost list
+func (p *ARCPolicy) replace(inB2 bool) (evicted []string) {
+	if p.t1.len()+p.t2.len() < p.maxEntries {
+		return nil
+	}
+	if p.t1.len() > 0 && (p.t1.len() > p.target || (inB2 && p.t1.len() == p.target)) {
+		key := p.t1.removeOldest()
+		p.b1.pushFront(key)
+		return []string{key}
+	}
+	if p.t2.len() > 0 {
+		key := p.t2.removeOldest()
+		p.b2.pushFront(key)
+		return []string{key}
+	}
+	key := p.t1.removeOldest()
+	p.b1.pushFront(key)
+	return []string{key}
+}
+
+// Evict drops the oldest key of t1 beyond its target size, or else of t2, remembering it in a ghost list
+func (p *ARCPolicy) Evict() (key string, ok bool) {
+	if p.t1.len() > 0 && (p.t1.len() > p.target || p.t2.len() == 0) {
+		key = p.t1.removeOldest()
+		p.b1.pushFront(key)
+		return key, true
+	}
+	if p.t2.len() > 0 {
+		key = p.t2.removeOldest()
+		p.b2.pushFront(key)
+		return key, true
+	}
+	return "", false
+}
+
+// Remove forgets the key in the ghost lists too, so that caching it again does not count as a recent eviction
+func (p *ARCPolicy) Remove(key string) {
+	p.t1.remove(key)
+	p.t2.remove(key)
+	p.b1.remove(key)
+	p.b2.remove(key)
+}
+
+func max(x, y int) int {
+	if x > y {
+		return x
+	}
+	return y
+}
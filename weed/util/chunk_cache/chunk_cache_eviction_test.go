@@ -0,0 +1,114 @@
+package chunk_cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+// readHotSetDuringScan caches a hot set read a few times, then scans through many chunks read once,
+// and returns how many of the hot chunks are still cached.
+func readHotSetDuringScan(policy EvictionPolicy) (retained int) {
+	cache := NewChunkCacheInMemoryWithPolicy(policy)
+	data := []byte("chunk")
+
+	hotSet := 5
+	for i := 0; i < hotSet; i++ {
+		cache.SetChunk(fmt.Sprintf("hot%d", i), data)
+	}
+	for round := 0; round < 2; round++ {
+		for i := 0; i < hotSet; i++ {
+			cache.GetChunk(fmt.Sprintf("hot%d", i))
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		cache.SetChunk(fmt.Sprintf("cold%d", i), data)
+	}
+
+	for i := 0; i < hotSet; i++ {
+		if cache.GetChunk(fmt.Sprintf("hot%d", i)) != nil {
+			retained++
+		}
+	}
+	return
+}
+
+func TestEvictionPolicyScanResistance(t *testing.T) {
+
+	if retained := readHotSetDuringScan(NewLRUPolicy(10)); retained != 0 {
+		t.Errorf("lru: %d hot chunks survived the scan, expect 0", retained)
+	}
+	if retained := readHotSetDuringScan(NewLFUPolicy(10)); retained != 5 {
+		t.Errorf("lfu: %d hot chunks survived the scan, expect 5", retained)
+	}
+	if retained := readHotSetDuringScan(NewARCPolicy(10)); retained != 5 {
+		t.Errorf("arc: %d hot chunks survived the scan, expect 5", retained)
+	}
+
+}
+
+func TestEvictionPolicyBoundsEntries(t *testing.T) {
+
+	for _, name := range []string{EvictionLRU, EvictionLFU, EvictionARC} {
+		policy, err := NewEvictionPolicy(name, 8)
+		if err != nil {
+			t.Fatalf("new %s policy: %v", name, err)
+		}
+		cache := NewChunkCacheInMemoryWithPolicy(policy)
+		for i := 0; i < 1000; i++ {
+			fileId := fmt.Sprintf("%d", i%50)
+			if cache.GetChunk(fileId) == nil {
+				cache.SetChunk(fileId, []byte(fileId))
+			}
+			if i%3 == 0 {
+				cache.GetChunk(fmt.Sprintf("%d", i%7))
+			}
+		}
+		if len(cache.chunks) > 8 {
+			t.Errorf("%s: %d chunks cached, limit 8", name, len(cache.chunks))
+		}
+		for fileId, data := range cache.chunks {
+			if string(data) != fileId {
+				t.Errorf("%s: chunk %s has data %s", name, fileId, data)
+			}
+		}
+	}
+
+	if _, err := NewEvictionPolicy("fifo", 8); err == nil {
+		t.Errorf("expect error for an unknown policy")
+	}
+
+}
+
+func TestEvictionPolicyRemove(t *testing.T) {
+
+	for _, name := range []string{EvictionLRU, EvictionLFU, EvictionARC} {
+		policy, _ := NewEvictionPolicy(name, 2)
+		policy.Add("a")
+		for i := 0; i < 10; i++ {
+			policy.Hit("a")
+		}
+		policy.Remove("a")
+		policy.Remove("unknown")
+
+		// added again as a new key, once
+		if evicted := policy.Add("a"); len(evicted) > 0 {
+			t.Errorf("%s: re-adding a removed key evicted %v", name, evicted)
+		}
+		if evicted := policy.Add("b"); len(evicted) > 0 {
+			t.Errorf("%s: adding a second key evicted %v", name, evicted)
+		}
+		var keys []string
+		for {
+			key, ok := policy.Evict()
+			if !ok {
+				break
+			}
+			keys = append(keys, key)
+		}
+		if fmt.Sprint(keys) != "[a b]" {
+			t.Errorf("%s: evicted %v, expect each key once, oldest first", name, keys)
+		}
+	}
+
+}
@@ -0,0 +1,77 @@
+package chunk_cache
+
+import (
+	"sync"
+)
+
+// the hits on cached chunks are buffered in stripes picked by the file id, so that parallel reads
+// of different chunks do not take the cache lock each, and are passed to the eviction policy in batches
+const (
+	hitStripes   = 16
+	hitBatchSize = 64
+)
+
+type hitBuffer struct {
+	sync.Mutex
+	keys  []string
+	spare []string // the last batch passed on, reused for the next one
+}
+
+// recordHit buffers the hit, and passes the full batch of its stripe to the policy.
+// It is called without holding the cache lock.
+func (c *ChunkCacheInMemory) recordHit(fileId string) {
+	buffer := &c.hits[hitStripe(fileId)]
+	var batch []string
+	buffer.Lock()
+	buffer.keys = append(buffer.keys, fileId)
+	if len(buffer.keys) >= hitBatchSize {
+		batch, buffer.keys, buffer.spare = buffer.keys, buffer.spare, nil
+	}
+	buffer.Unlock()
+
+	if batch == nil {
+		return
+	}
+	c.Lock()
+	c.applyHits(batch)
+	c.Unlock()
+
+	for i := range batch {
+		batch[i] = ""
+	}
+	buffer.Lock()
+	buffer.spare = batch[:0]
+	buffer.Unlock()
+}
+
+// flushHits passes all buffered hits to the policy, before it picks chunks to evict.
+// It is called with the cache lock held.
+func (c *ChunkCacheInMemory) flushHits() {
+	for i := range c.hits {
+		buffer := &c.hits[i]
+		buffer.Lock()
+		keys := buffer.keys
+		buffer.keys = nil
+		buffer.Unlock()
+		c.applyHits(keys)
+	}
+}
+
+// applyHits skips the chunks dropped since their hits were buffered
+func (c *ChunkCacheInMemory) applyHits(keys []string) {
+	for _, key := range keys {
+		if _, found := c.chunks[key]; found {
+			c.policy.Hit(key)
+		}
+	}
+}
+
+// hitStripe is the 32 bit FNV-1a hash of the file id, without allocating
+func hitStripe(fileId string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(fileId); i++ {
+		h ^= uint32(fileId[i])
+		h *= 16777619
+	}
+	return int(h % hitStripes)
+}
@@ -0,0 +1,57 @@
+package chunk_cache
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+)
+
+func TestBufferedHitsReachThePolicy(t *testing.T) {
+
+	cache := NewChunkCacheInMemory(2)
+	cache.SetChunk("1,01637037d6", []byte("a"))
+	cache.SetChunk("1,02637037d6", []byte("b"))
+	// the hit is buffered, and passed to the policy before it evicts
+	cache.GetChunk("1,01637037d6")
+	cache.SetChunk("1,03637037d6", []byte("c"))
+
+	if cache.GetChunk("1,01637037d6") == nil {
+		t.Errorf("evicted the chunk read last")
+	}
+	if cache.GetChunk("1,02637037d6") != nil {
+		t.Errorf("kept the least recently used chunk")
+	}
+
+	// full batches are passed on by the reads
+	for i := 0; i < hitStripes*hitBatchSize; i++ {
+		cache.GetChunk("1,03637037d6")
+	}
+	if pending := len(cache.hits[hitStripe("1,03637037d6")].keys); pending >= hitBatchSize {
+		t.Errorf("%d hits buffered, expect less than %d", pending, hitBatchSize)
+	}
+
+}
+
+func BenchmarkChunkCacheInMemoryParallelGet(b *testing.B) {
+
+	cache := NewChunkCacheInMemory(1024)
+	fileIds := make([]string, 1024)
+	for i := range fileIds {
+		fileIds[i] = fmt.Sprintf("1,%x637037d6", i)
+		cache.SetChunk(fileIds[i], make([]byte, 4096))
+	}
+
+	var next int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := int(atomic.AddInt64(&next, 1))
+		for pb.Next() {
+			if cache.GetChunk(fileIds[i%len(fileIds)]) == nil {
+				b.Errorf("chunk %s not cached", fileIds[i%len(fileIds)])
+			}
+			i += 7
+		}
+	})
+
+}
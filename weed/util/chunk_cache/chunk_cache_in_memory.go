@@ -1,43 +1,79 @@
 package chunk_cache
 
 import (
-	"time"
-
-	"github.com/karlseguin/ccache/v2"
+	"sync"
 )
 
 // a global cache for recently accessed file chunks
 type ChunkCacheInMemory struct {
-	cache *ccache.Cache
+	sync.RWMutex
+	chunks map[string][]byte
+	policy EvictionPolicy
+	// the bytes held by the chunks, bounded by maxBytes if set
+	bytes    int64
+	maxBytes int64
+	// compress the chunks as they are cached, and keep the compressed ones
+	compress   bool
+	compressed map[string]struct{}
+	// the chunks pinned by reads, and the pinned ones the policy has evicted, to drop once unpinned
+	pins               map[string]int
+	evictedWhilePinned map[string]struct{}
+	// the hits not passed to the policy yet
+	hits [hitStripes]hitBuffer
 }
 
 func NewChunkCacheInMemory(maxEntries int64) *ChunkCacheInMemory {
-	pruneCount := maxEntries >> 3
-	if pruneCount <= 0 {
-		pruneCount = 500
-	}
+	return NewChunkCacheInMemoryWithPolicy(NewLRUPolicy(int(maxEntries)))
+}
+
+func NewChunkCacheInMemoryWithPolicy(policy EvictionPolicy) *ChunkCacheInMemory {
 	return &ChunkCacheInMemory{
-		cache: ccache.New(ccache.Configure().MaxSize(maxEntries).ItemsToPrune(uint32(pruneCount))),
+		chunks:             make(map[string][]byte),
+		policy:             policy,
+		compressed:         make(map[string]struct{}),
+		pins:               make(map[string]int),
+		evictedWhilePinned: make(map[string]struct{}),
 	}
 }
 
+// SetMaxBytes bounds the bytes held by the cached chunks, besides the entries bounded by the policy. 0 means no bound.
+func (c *ChunkCacheInMemory) SetMaxBytes(maxBytes int64) {
+	c.Lock()
+	defer c.Unlock()
+	c.maxBytes = maxBytes
+	c.flushHits()
+	c.evictOverBudget()
+}
+
+// Bytes returns the bytes held by the cached chunks, as stored.
+func (c *ChunkCacheInMemory) Bytes() int64 {
+	c.RLock()
+	defer c.RUnlock()
+	return c.bytes
+}
+
+// GetChunk only takes the read lock. The hit is passed to the policy later, along with others.
 func (c *ChunkCacheInMemory) GetChunk(fileId string) []byte {
-	item := c.cache.Get(fileId)
-	if item == nil {
+	c.RLock()
+	data, found := c.chunks[fileId]
+	_, isCompressed := c.compressed[fileId]
+	c.RUnlock()
+	if !found {
 		return nil
 	}
-	data := item.Value().([]byte)
-	item.Extend(time.Hour)
+	c.recordHit(fileId)
+
+	if isCompressed {
+		return decompressChunk(fileId, data)
+	}
 	return data
 }
 
 func (c *ChunkCacheInMemory) getChunkSlice(fileId string, offset, length uint64) ([]byte, error) {
-	item := c.cache.Get(fileId)
-	if item == nil {
+	data := c.GetChunk(fileId)
+	if data == nil {
 		return nil, nil
 	}
-	data := item.Value().([]byte)
-	item.Extend(time.Hour)
 	wanted := min(int(length), len(data)-int(offset))
 	if wanted < 0 {
 		return nil, ErrorOutOfBounds
@@ -46,7 +82,59 @@ func (c *ChunkCacheInMemory) getChunkSlice(fileId string, offset, length uint64)
 }
 
 func (c *ChunkCacheInMemory) SetChunk(fileId string, data []byte) {
-	localCopy := make([]byte, len(data))
-	copy(localCopy, data)
-	c.cache.Set(fileId, localCopy, time.Hour)
+	c.RLock()
+	compress := c.compress
+	c.RUnlock()
+
+	var localCopy []byte
+	isCompressed := false
+	if compress {
+		localCopy, isCompressed = compressChunk(data)
+	}
+	if !isCompressed {
+		localCopy = make([]byte, len(data))
+		copy(localCopy, data)
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.flushHits()
+	if _, found := c.chunks[fileId]; found {
+		c.store(fileId, localCopy, isCompressed)
+		c.policy.Hit(fileId)
+		c.evictOverBudget()
+		return
+	}
+	c.store(fileId, localCopy, isCompressed)
+	for _, evicted := range c.policy.Add(fileId) {
+		c.evict(evicted)
+	}
+	c.evictOverBudget()
+}
+
+func (c *ChunkCacheInMemory) store(fileId string, data []byte, isCompressed bool) {
+	c.remove(fileId)
+	c.chunks[fileId] = data
+	c.bytes += int64(len(data))
+	if isCompressed {
+		c.compressed[fileId] = struct{}{}
+	}
+}
+
+func (c *ChunkCacheInMemory) remove(fileId string) {
+	if data, found := c.chunks[fileId]; found {
+		c.bytes -= int64(len(data))
+		delete(c.chunks, fileId)
+		delete(c.compressed, fileId)
+	}
+}
+
+func (c *ChunkCacheInMemory) evictOverBudget() {
+	for c.maxBytes > 0 && c.bytes > c.maxBytes {
+		evicted, ok := c.policy.Evict()
+		if !ok {
+			return
+		}
+		c.evict(evicted)
+	}
 }
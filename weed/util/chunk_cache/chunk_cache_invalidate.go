@@ -0,0 +1,89 @@
+package chunk_cache
+
+import (
+	"strings"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+	"github.com/chrislusf/seaweedfs/weed/storage/types"
+)
+
+// InvalidatingChunkCache is a ChunkCache whose chunks can be dropped once the files using them
+// are deleted or overwritten, instead of waiting for them to be evicted.
+type InvalidatingChunkCache interface {
+	ChunkCache
+	InvalidateChunks(fileIds []string)
+}
+
+var _ InvalidatingChunkCache = &TieredChunkCache{}
+
+// InvalidateChunks drops the chunks of the file ids from the memory and the on disk layers,
+// along with their namespaced copies and cached blocks.
+func (c *TieredChunkCache) InvalidateChunks(fileIds []string) {
+	if c == nil || len(fileIds) == 0 {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+
+	c.memCache.Invalidate(fileIds)
+
+	for _, fileId := range fileIds {
+		fid, err := needle.ParseFileIdFromString(fileId)
+		if err != nil {
+			glog.Errorf("failed to parse file id %s", fileId)
+			continue
+		}
+		for _, diskCache := range c.diskCaches {
+			diskCache.deleteChunk(fid.Key)
+		}
+	}
+}
+
+// Invalidate drops the chunks of the file ids, pinned or not, and the keys derived from them.
+func (c *ChunkCacheInMemory) Invalidate(fileIds []string) {
+	c.Lock()
+	defer c.Unlock()
+	c.flushHits()
+
+	invalidated := make(map[string]struct{}, len(fileIds))
+	for _, fileId := range fileIds {
+		invalidated[fileId] = struct{}{}
+	}
+	for key := range c.chunks {
+		if _, found := invalidated[keyFileId(key)]; found {
+			c.remove(key)
+			c.policy.Remove(key)
+			delete(c.evictedWhilePinned, key)
+		}
+	}
+}
+
+// keyFileId returns the file id of a plain, namespaced or block key
+func keyFileId(key string) string {
+	if i := strings.LastIndex(key, NamespaceSeparator); i >= 0 {
+		key = key[i+len(NamespaceSeparator):]
+	}
+	if i := strings.Index(key, BlockSeparator); i >= 0 {
+		key = key[:i]
+	}
+	return key
+}
+
+func (c *OnDiskCacheLayer) deleteChunk(needleId types.NeedleId) {
+
+	for _, diskCache := range c.diskCaches {
+		if err := diskCache.DeleteNeedle(needleId); err != nil {
+			glog.Warningf("failed to delete from cache file %s id %d: %v", diskCache.fileName, needleId, err)
+		}
+	}
+
+}
+
+// DeleteNeedle marks the needle deleted in the index. Its data is left in place until the volume is reset.
+func (v *ChunkCacheVolume) DeleteNeedle(key types.NeedleId) error {
+	if v.nm == nil {
+		return nil
+	}
+	return v.nm.Delete(key, types.ToOffset(v.fileSize))
+}
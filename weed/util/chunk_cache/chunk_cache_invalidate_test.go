@@ -0,0 +1,78 @@
+package chunk_cache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/chrislusf/seaweedfs/weed/storage/needle"
+)
+
+func TestInvalidateChunksDropsAllTiers(t *testing.T) {
+
+	cache := NewTieredChunkCache(16, t.TempDir(), 256, 1024)
+	defer cache.Shutdown()
+
+	fileIds := make([]string, 4)
+	for i := range fileIds {
+		fileIds[i] = fmt.Sprintf("1,%daabbccdd", i+1)
+		data := make([]byte, 1024)
+		rand.Read(data)
+		cache.SetChunk(fileIds[i], data)
+	}
+	cache.SetChunk(BlockKey(fileIds[0], 3), []byte("block"))
+	cache.SetChunk(NamespacedKey("tenant1", fileIds[1]), []byte("namespaced"))
+
+	cache.InvalidateChunks(fileIds[:2])
+
+	for i, fileId := range fileIds {
+		fid, _ := needle.ParseFileIdFromString(fileId)
+		inMemory := cache.memCache.GetChunk(fileId) != nil
+		onDisk := cache.diskCaches[0].getChunk(fid.Key) != nil
+		if i < 2 && (inMemory || onDisk) {
+			t.Errorf("invalidated chunk %s kept, in memory %v, on disk %v", fileId, inMemory, onDisk)
+		}
+		if i >= 2 && (!inMemory || !onDisk) {
+			t.Errorf("chunk %s dropped, in memory %v, on disk %v", fileId, inMemory, onDisk)
+		}
+		if i < 2 && cache.GetChunk(fileId, 1024) != nil {
+			t.Errorf("invalidated chunk %s still read", fileId)
+		}
+	}
+	if cache.memCache.GetChunk(BlockKey(fileIds[0], 3)) != nil {
+		t.Errorf("block of an invalidated chunk kept")
+	}
+	if cache.memCache.GetChunk(NamespacedKey("tenant1", fileIds[1])) != nil {
+		t.Errorf("namespaced copy of an invalidated chunk kept")
+	}
+
+	// caching the chunk again serves the new data
+	cache.SetChunk(fileIds[0], []byte("rewritten"))
+	if data := cache.GetChunk(fileIds[0], 9); string(data) != "rewritten" {
+		t.Errorf("cached again as %q", data)
+	}
+
+}
+
+func TestInvalidatedChunksLeaveThePolicy(t *testing.T) {
+
+	for _, name := range []string{EvictionLRU, EvictionLFU, EvictionARC} {
+		policy, _ := NewEvictionPolicy(name, 2)
+		cache := NewChunkCacheInMemoryWithPolicy(policy)
+		cache.SetChunk("1,01637037d6", []byte("old"))
+		for i := 0; i < 10; i++ {
+			cache.GetChunk("1,01637037d6")
+		}
+		cache.Invalidate([]string{"1,01637037d6"})
+
+		cache.SetChunk("1,01637037d6", []byte("new"))
+		cache.SetChunk("1,02637037d6", []byte("other"))
+		if data := cache.GetChunk("1,01637037d6"); string(data) != "new" {
+			t.Errorf("%s: cached again as %q", name, data)
+		}
+		if cache.GetChunk("1,02637037d6") == nil {
+			t.Errorf("%s: evicted by a stale entry of the invalidated chunk", name)
+		}
+	}
+
+}
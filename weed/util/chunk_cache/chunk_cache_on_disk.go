@@ -17,6 +17,14 @@ import (
 // This implements an on disk cache
 // The entries are an FIFO with a size limit
 
+// an index entry at offset 0 is taken as deleted when the index is loaded again,
+// so the first needle starts past it, as the needles of a volume start past its super block.
+// These bytes do not count against the size limit.
+const firstNeedleOffset = types.NeedlePaddingSize
+
+// cacheClock dates the new cache volumes, which orders them in their layers. Replaced in tests.
+var cacheClock util.Clock = util.RealClock
+
 type ChunkCacheVolume struct {
 	DataBackend backend.BackendStorageFile
 	nm          storage.NeedleMapper
@@ -55,7 +63,7 @@ func LoadOrCreateChunkCacheVolume(fileName string, preallocate int64) (*ChunkCac
 		if v.DataBackend, err = backend.CreateVolumeFile(v.fileName+".dat", preallocate, 0); err != nil {
 			return nil, fmt.Errorf("cannot create cache file %s.dat: %v", v.fileName, err)
 		}
-		v.lastModTime = time.Now()
+		v.lastModTime = cacheClock.Now()
 	}
 
 	var indexFile *os.File
@@ -73,10 +81,43 @@ func LoadOrCreateChunkCacheVolume(fileName string, preallocate int64) (*ChunkCac
 		return nil, fmt.Errorf("loading leveldb %s error: %v", v.fileName+".ldb", err)
 	}
 
+	v.pruneStaleEntries()
+
 	return v, nil
 
 }
 
+// pruneStaleEntries drops the index entries whose data is not fully in the .dat file,
+// e.g. if the process stopped before the data was flushed
+func (v *ChunkCacheVolume) pruneStaleEntries() {
+	entryCount := int64(v.nm.IndexFileSize()) / types.NeedleMapEntrySize
+	for i := int64(0); i < entryCount; i++ {
+		key, _, _, err := v.nm.ReadIndexEntry(i)
+		if err != nil {
+			glog.V(0).Infof("read cache index %s.idx entry %d: %v", v.fileName, i, err)
+			return
+		}
+		nv, ok := v.nm.Get(key)
+		if !ok || nv.Size.IsDeleted() {
+			continue
+		}
+		if nv.Offset.ToActualOffset()+int64(nv.Size) > v.fileSize {
+			glog.V(1).Infof("prune stale cache entry %d in %s", key, v.fileName)
+			if err := v.nm.Delete(key, nv.Offset); err != nil {
+				glog.V(0).Infof("prune cache entry %d in %s: %v", key, v.fileName, err)
+			}
+		}
+	}
+}
+
+// dataSize is the bytes taken by the needles, without the reserved first bytes
+func (v *ChunkCacheVolume) dataSize() int64 {
+	if v.fileSize < firstNeedleOffset {
+		return 0
+	}
+	return v.fileSize - firstNeedleOffset
+}
+
 func (v *ChunkCacheVolume) Shutdown() {
 	if v.DataBackend != nil {
 		v.DataBackend.Close()
@@ -105,7 +146,7 @@ func (v *ChunkCacheVolume) Reset() (*ChunkCacheVolume, error) {
 func (v *ChunkCacheVolume) GetNeedle(key types.NeedleId) ([]byte, error) {
 
 	nv, ok := v.nm.Get(key)
-	if !ok {
+	if !ok || nv.Size.IsDeleted() {
 		return nil, storage.ErrorNotFound
 	}
 	data := make([]byte, nv.Size)
@@ -123,7 +164,7 @@ func (v *ChunkCacheVolume) GetNeedle(key types.NeedleId) ([]byte, error) {
 
 func (v *ChunkCacheVolume) getNeedleSlice(key types.NeedleId, offset, length uint64) ([]byte, error) {
 	nv, ok := v.nm.Get(key)
-	if !ok {
+	if !ok || nv.Size.IsDeleted() {
 		return nil, storage.ErrorNotFound
 	}
 	wanted := min(int(length), int(nv.Size)-int(offset))
@@ -147,6 +188,10 @@ func (v *ChunkCacheVolume) getNeedleSlice(key types.NeedleId, offset, length uin
 func (v *ChunkCacheVolume) WriteNeedle(key types.NeedleId, data []byte) error {
 
 	offset := v.fileSize
+	if offset < firstNeedleOffset {
+		offset = firstNeedleOffset
+		v.fileSize = offset
+	}
 
 	written, err := v.DataBackend.WriteAt(data, offset)
 	if err != nil {
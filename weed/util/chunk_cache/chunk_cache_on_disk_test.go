@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -92,3 +94,55 @@ func TestOnDisk(t *testing.T) {
 	cache.Shutdown()
 
 }
+
+func TestOnDiskRestart(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	cache := NewTieredChunkCache(2, tmpDir, 32, 1024)
+
+	first, second := make([]byte, 1024), make([]byte, 1024)
+	rand.Read(first)
+	rand.Read(second)
+	cache.SetChunk("1,1aabbccdd", first)
+	cache.SetChunk("1,2aabbccdd", second)
+	cache.Shutdown()
+
+	// the data of the second chunk did not make it to disk
+	dataFiles, _ := filepath.Glob(filepath.Join(tmpDir, "c0_2_*.dat"))
+	for _, dataFile := range dataFiles {
+		if stat, err := os.Stat(dataFile); err == nil && stat.Size() >= 2048 {
+			os.Truncate(dataFile, stat.Size()-1024)
+		}
+	}
+
+	cache = NewTieredChunkCache(2, tmpDir, 32, 1024)
+	defer cache.Shutdown()
+
+	if data := cache.GetChunk("1,1aabbccdd", 1024); !bytes.Equal(data, first) {
+		t.Errorf("cached chunk should be served from disk after restart")
+	}
+	if data := cache.GetChunk("1,2aabbccdd", 1024); data != nil {
+		t.Errorf("stale cache entry should have been pruned")
+	}
+}
+
+func TestNamespacedKeysStayApart(t *testing.T) {
+	cache := NewTieredChunkCache(8, t.TempDir(), 32, 1024)
+	defer cache.Shutdown()
+
+	first, second := make([]byte, 1024), make([]byte, 1024)
+	rand.Read(first)
+	rand.Read(second)
+	cache.SetChunk(NamespacedKey("tenant1", "1,1aabbccdd"), first)
+	cache.SetChunk(NamespacedKey("tenant2", "1,1aabbccdd"), second)
+
+	if data := cache.GetChunk(NamespacedKey("tenant1", "1,1aabbccdd"), 1024); !bytes.Equal(data, first) {
+		t.Errorf("tenant1 chunk mismatch")
+	}
+	if data := cache.GetChunkSlice(NamespacedKey("tenant2", "1,1aabbccdd"), 0, 1024); !bytes.Equal(data, second) {
+		t.Errorf("tenant2 chunk mismatch")
+	}
+	if data := cache.GetChunk("1,1aabbccdd", 1024); data != nil {
+		t.Errorf("namespaced chunk served without namespace")
+	}
+}
@@ -0,0 +1,57 @@
+package chunk_cache
+
+// PinningChunkCache is a ChunkCache whose chunks can be pinned by the reads using them,
+// so that caching other chunks, e.g. prefetched ones, does not evict them until unpinned.
+type PinningChunkCache interface {
+	ChunkCache
+	Pin(fileId string)
+	Unpin(fileId string)
+}
+
+var _ PinningChunkCache = &TieredChunkCache{}
+
+// Pin keeps the chunk in memory until it is unpinned as many times as pinned.
+// A pinned chunk the eviction policy picks is kept past the memory bounds, and dropped once unpinned.
+// The chunk need not be cached yet, so that it is kept once the pinning read caches it.
+func (c *ChunkCacheInMemory) Pin(fileId string) {
+	c.Lock()
+	defer c.Unlock()
+	c.pins[fileId]++
+}
+
+func (c *ChunkCacheInMemory) Unpin(fileId string) {
+	c.Lock()
+	defer c.Unlock()
+	if c.pins[fileId]--; c.pins[fileId] > 0 {
+		return
+	}
+	delete(c.pins, fileId)
+	if _, found := c.evictedWhilePinned[fileId]; found {
+		delete(c.evictedWhilePinned, fileId)
+		c.remove(fileId)
+	}
+}
+
+// evict drops the chunk picked by the eviction policy, unless pinned
+func (c *ChunkCacheInMemory) evict(fileId string) {
+	if c.pins[fileId] > 0 {
+		c.evictedWhilePinned[fileId] = struct{}{}
+		return
+	}
+	c.remove(fileId)
+}
+
+// Pin keeps the chunk in the memory layer. The on disk layers are not bounded by entries, and are left as is.
+func (c *TieredChunkCache) Pin(fileId string) {
+	if c == nil {
+		return
+	}
+	c.memCache.Pin(fileId)
+}
+
+func (c *TieredChunkCache) Unpin(fileId string) {
+	if c == nil {
+		return
+	}
+	c.memCache.Unpin(fileId)
+}
@@ -0,0 +1,35 @@
+package chunk_cache
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestChunkCachePinnedChunkIsNotEvicted(t *testing.T) {
+
+	cache := NewChunkCacheInMemory(2)
+	cache.SetChunk("active", []byte("active"))
+	cache.SetChunk("other", []byte("other"))
+
+	cache.Pin("active")
+	cache.Pin("active")
+	for i := 0; i < 10; i++ {
+		cache.SetChunk(fmt.Sprintf("prefetched%d", i), []byte("prefetched"))
+		if cache.GetChunk("active") == nil {
+			t.Fatalf("pinned chunk evicted by the %d-th prefetched chunk", i)
+		}
+	}
+
+	cache.Unpin("active")
+	if cache.GetChunk("active") == nil {
+		t.Fatalf("chunk still pinned once evicted")
+	}
+	cache.Unpin("active")
+	if cache.GetChunk("active") != nil {
+		t.Errorf("evicted chunk kept after the last unpin")
+	}
+	if cache.Bytes() != int64(2*len("prefetched")) {
+		t.Errorf("cache holds %d bytes, expect the 2 latest chunks", cache.Bytes())
+	}
+
+}
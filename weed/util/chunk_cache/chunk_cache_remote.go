@@ -0,0 +1,70 @@
+package chunk_cache
+
+import (
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// RemoteChunkStore is a key value store shared by several mount or gateway nodes,
+// e.g. a memcached or redis cluster, to fetch each chunk from the volume servers only once.
+type RemoteChunkStore interface {
+	// Get returns nil data without error if the chunk is not stored.
+	Get(fileId string) (data []byte, err error)
+	Set(fileId string, data []byte) error
+}
+
+// ChunkCacheWithRemote checks the local tiers first, and then the shared remote store.
+// Chunks found remotely are kept in the local tiers.
+type ChunkCacheWithRemote struct {
+	local  ChunkCache
+	remote RemoteChunkStore
+}
+
+var _ ChunkCache = &ChunkCacheWithRemote{}
+
+func NewChunkCacheWithRemote(local ChunkCache, remote RemoteChunkStore) *ChunkCacheWithRemote {
+	return &ChunkCacheWithRemote{
+		local:  local,
+		remote: remote,
+	}
+}
+
+func (c *ChunkCacheWithRemote) GetChunk(fileId string, minSize uint64) (data []byte) {
+	if data = c.local.GetChunk(fileId, minSize); data != nil {
+		return data
+	}
+	data = c.getRemoteChunk(fileId)
+	if uint64(len(data)) < minSize {
+		return nil
+	}
+	return data
+}
+
+func (c *ChunkCacheWithRemote) GetChunkSlice(fileId string, offset, length uint64) []byte {
+	if data := c.local.GetChunkSlice(fileId, offset, length); len(data) > 0 {
+		return data
+	}
+	data := c.getRemoteChunk(fileId)
+	if uint64(len(data)) < offset+length {
+		return nil
+	}
+	return data[offset : offset+length]
+}
+
+func (c *ChunkCacheWithRemote) SetChunk(fileId string, data []byte) {
+	c.local.SetChunk(fileId, data)
+	if err := c.remote.Set(fileId, data); err != nil {
+		glog.V(1).Infof("set remote chunk %s: %v", fileId, err)
+	}
+}
+
+func (c *ChunkCacheWithRemote) getRemoteChunk(fileId string) []byte {
+	data, err := c.remote.Get(fileId)
+	if err != nil {
+		glog.V(1).Infof("get remote chunk %s: %v", fileId, err)
+		return nil
+	}
+	if len(data) > 0 {
+		c.local.SetChunk(fileId, data)
+	}
+	return data
+}
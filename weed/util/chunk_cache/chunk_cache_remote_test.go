@@ -0,0 +1,61 @@
+package chunk_cache
+
+import (
+	"bytes"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+type fakeRemoteChunkStore struct {
+	sync.Mutex
+	chunks map[string][]byte
+	gets   int
+}
+
+func (s *fakeRemoteChunkStore) Get(fileId string) ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+	s.gets++
+	return s.chunks[fileId], nil
+}
+
+func (s *fakeRemoteChunkStore) Set(fileId string, data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	s.chunks[fileId] = append([]byte(nil), data...)
+	return nil
+}
+
+func TestChunkCacheWithRemote(t *testing.T) {
+
+	remote := &fakeRemoteChunkStore{chunks: make(map[string][]byte)}
+	node1 := NewChunkCacheWithRemote(NewTieredChunkCache(2, t.TempDir(), 32, 1024), remote)
+	node2 := NewChunkCacheWithRemote(NewTieredChunkCache(2, t.TempDir(), 32, 1024), remote)
+
+	fileId := "1,0a0b0c0d"
+	data := make([]byte, 1024)
+	rand.Read(data)
+
+	node1.SetChunk(fileId, data)
+
+	if cached := node2.GetChunk(fileId, uint64(len(data))); !bytes.Equal(cached, data) {
+		t.Fatalf("second node did not read the chunk from the remote store")
+	}
+	if remote.gets != 1 {
+		t.Errorf("%d remote reads, expect 1", remote.gets)
+	}
+
+	// kept locally afterwards
+	if slice := node2.GetChunkSlice(fileId, 100, 200); !bytes.Equal(slice, data[100:300]) {
+		t.Errorf("unexpected chunk slice")
+	}
+	if remote.gets != 1 {
+		t.Errorf("%d remote reads, expect the local tiers to serve the second read", remote.gets)
+	}
+
+	if node2.GetChunk("1,0e0f1011", 0) != nil {
+		t.Errorf("expect nil for a chunk stored nowhere")
+	}
+
+}
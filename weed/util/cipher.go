@@ -4,7 +4,7 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"errors"
+	"fmt"
 	"io"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
@@ -12,6 +12,10 @@ import (
 
 type CipherKey []byte
 
+// ErrAuthentication is returned by Decrypt for a ciphertext failing the AES-GCM authentication,
+// e.g. tampered with or corrupted, and is an ErrDecryption too.
+var ErrAuthentication = fmt.Errorf("%w: message authentication failed", ErrDecryption)
+
 func GenCipherKey() CipherKey {
 	key := make([]byte, 32)
 	if _, err := io.ReadFull(rand.Reader, key); err != nil {
@@ -50,11 +54,16 @@ func Decrypt(ciphertext []byte, key CipherKey) ([]byte, error) {
 		return nil, err
 	}
 
+	// the ciphertext is prefixed by its nonce, and ends with its authentication tag
 	nonceSize := gcm.NonceSize()
-	if len(ciphertext) < nonceSize {
-		return nil, errors.New("ciphertext too short")
+	if len(ciphertext) < nonceSize+gcm.Overhead() {
+		return nil, fmt.Errorf("%w: ciphertext of %d bytes too short", ErrAuthentication, len(ciphertext))
 	}
 
 	nonce, ciphertext := ciphertext[:nonceSize], ciphertext[nonceSize:]
-	return gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrAuthentication
+	}
+	return plaintext, nil
 }
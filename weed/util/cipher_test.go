@@ -1,7 +1,9 @@
 package util
 
 import (
+	"bytes"
 	"encoding/base64"
+	"errors"
 	"testing"
 )
 
@@ -15,3 +17,31 @@ func TestSameAsJavaImplementation(t *testing.T) {
 	}
 	println(string(plantext))
 }
+
+func TestDecryptDetectsTampering(t *testing.T) {
+	key := GenCipherKey()
+	plaintext := []byte("some chunk content")
+	ciphertext, err := Encrypt(plaintext, key)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if decrypted, err := Decrypt(ciphertext, key); err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("decrypt: %q, %v", decrypted, err)
+	}
+
+	for name, tamper := range map[string]func([]byte) []byte{
+		"nonce":      func(b []byte) []byte { b[0] ^= 0x80; return b },
+		"ciphertext": func(b []byte) []byte { b[12] ^= 0x01; return b },
+		"tag":        func(b []byte) []byte { b[len(b)-1]++; return b },
+		"truncated":  func(b []byte) []byte { return b[:len(b)-1] },
+		"no tag":     func(b []byte) []byte { return b[:12] },
+	} {
+		tampered := tamper(append([]byte(nil), ciphertext...))
+		if _, err := Decrypt(tampered, key); !errors.Is(err, ErrAuthentication) || !errors.Is(err, ErrDecryption) {
+			t.Errorf("%s tampered with: %v, expect an authentication failure", name, err)
+		}
+	}
+	if _, err := Decrypt(ciphertext, GenCipherKey()); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("decrypt with another key: %v, expect an authentication failure", err)
+	}
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and waits, so that the time dependent features can be tested with a TestClock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the system clock.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// TestClock only moves when advanced, firing the waits that are due.
+type TestClock struct {
+	sync.Mutex
+	now     time.Time
+	waiters []*testClockWaiter
+}
+
+type testClockWaiter struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func NewTestClock(now time.Time) *TestClock {
+	return &TestClock{
+		now: now,
+	}
+}
+
+func (c *TestClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *TestClock) After(d time.Duration) <-chan time.Time {
+	c.Lock()
+	defer c.Unlock()
+	waiter := &testClockWaiter{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		waiter.c <- c.now
+		return waiter.c
+	}
+	c.waiters = append(c.waiters, waiter)
+	return waiter.c
+}
+
+// Advance moves the clock forward, firing the waits due by then.
+func (c *TestClock) Advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = c.now.Add(d)
+	waiters := c.waiters[:0]
+	for _, waiter := range c.waiters {
+		if waiter.at.After(c.now) {
+			waiters = append(waiters, waiter)
+			continue
+		}
+		waiter.c <- c.now
+	}
+	c.waiters = waiters
+}
+
+// Waiters returns the number of waits not fired yet, e.g. for a test to advance
+// the clock only after the code under test started waiting.
+func (c *TestClock) Waiters() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.waiters)
+}
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTestClockFiresDueWaits(t *testing.T) {
+
+	start := time.Unix(1600000000, 0)
+	clock := NewTestClock(start)
+
+	first, second := clock.After(time.Second), clock.After(time.Minute)
+	select {
+	case <-clock.After(0):
+	default:
+		t.Errorf("a wait of 0 is not fired at once")
+	}
+
+	clock.Advance(time.Second)
+	select {
+	case now := <-first:
+		if !now.Equal(start.Add(time.Second)) {
+			t.Errorf("fired at %v, expect %v", now, start.Add(time.Second))
+		}
+	default:
+		t.Errorf("the due wait is not fired")
+	}
+	select {
+	case <-second:
+		t.Errorf("fired a wait before it is due")
+	default:
+	}
+	if clock.Waiters() != 1 {
+		t.Errorf("%d waits left, expect 1", clock.Waiters())
+	}
+
+	clock.Advance(time.Hour)
+	<-second
+	if !clock.Now().Equal(start.Add(time.Hour + time.Second)) {
+		t.Errorf("now %v", clock.Now())
+	}
+
+}
@@ -1,7 +1,9 @@
 package util
 
 import (
+	"bufio"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -22,6 +24,7 @@ func init() {
 	Transport = &http.Transport{
 		MaxIdleConns:        1024,
 		MaxIdleConnsPerHost: 1024,
+		DialContext:         dialContext,
 	}
 	client = &http.Client{
 		Transport: Transport,
@@ -286,19 +289,36 @@ func ReadUrl(fileUrl string, cipherKey []byte, isContentCompressed bool, isFullC
 	return n, err
 }
 
+// ErrDecryption and ErrDecompression are wrapped by the errors of content read but not decoded.
+var (
+	ErrDecryption    = errors.New("decryption failed")
+	ErrDecompression = errors.New("decompression failed")
+)
+
+// ErrRangeIgnored is wrapped by the errors of resumed reads answered from another offset than asked.
+var ErrRangeIgnored = errors.New("range ignored")
+
 func ReadUrlAsStream(fileUrl string, cipherKey []byte, isContentGzipped bool, isFullChunk bool, offset int64, size int, fn func(data []byte)) (retryable bool, err error) {
+	return ReadUrlAsStreamWithContext(context.Background(), fileUrl, cipherKey, isContentGzipped, isFullChunk, offset, size, fn)
+}
+
+// ReadUrlAsStreamWithContext stops reading once the context is cancelled. Encrypted content is always read to the end.
+func ReadUrlAsStreamWithContext(ctx context.Context, fileUrl string, cipherKey []byte, isContentGzipped bool, isFullChunk bool, offset int64, size int, fn func(data []byte)) (retryable bool, err error) {
 
 	if cipherKey != nil {
 		return readEncryptedUrl(fileUrl, cipherKey, isContentGzipped, isFullChunk, offset, size, fn)
 	}
 
-	req, err := http.NewRequest("GET", fileUrl, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fileUrl, nil)
 	if err != nil {
 		return false, err
 	}
 
 	if isFullChunk {
 		req.Header.Add("Accept-Encoding", "gzip")
+	} else if size < 0 {
+		// a negative size reads till the end
+		req.Header.Add("Range", fmt.Sprintf("bytes=%d-", offset))
 	} else {
 		req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+int64(size)-1))
 	}
@@ -318,11 +338,59 @@ func ReadUrlAsStream(fileUrl string, cipherKey []byte, isContentGzipped bool, is
 	switch contentEncoding {
 	case "gzip":
 		reader, err = gzip.NewReader(r.Body)
+		if err != nil {
+			return false, fmt.Errorf("gunzip %s: %w: %v", fileUrl, ErrDecompression, err)
+		}
 		defer reader.Close()
 	default:
 		reader = r.Body
+		if isContentGzipped && isFullChunk {
+			// a compressed chunk may come as stored without telling, e.g. through a proxy dropping the header
+			reader, err = gunzipIfCompressed(r.Body)
+			if err != nil {
+				return false, fmt.Errorf("gunzip %s: %w: %v", fileUrl, ErrDecompression, err)
+			}
+			defer reader.Close()
+		}
+	}
+
+	return readAsStream(reader, fn)
+
+}
+
+// ResumeUrlAsStreamWithContext reads the plain content of the url from the offset, size bytes or till the end if negative,
+// to continue an interrupted read. A response not starting at the offset, e.g. the whole content from a server
+// ignoring the range, is not passed to fn and fails with ErrRangeIgnored.
+func ResumeUrlAsStreamWithContext(ctx context.Context, fileUrl string, offset int64, size int, fn func(data []byte)) (retryable bool, err error) {
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fileUrl, nil)
+	if err != nil {
+		return false, err
+	}
+	if size < 0 {
+		req.Header.Add("Range", fmt.Sprintf("bytes=%d-", offset))
+	} else {
+		req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+int64(size)-1))
 	}
 
+	r, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer CloseResponse(r)
+	if r.StatusCode >= 400 {
+		retryable = r.StatusCode >= 500
+		return retryable, fmt.Errorf("%s: %s", fileUrl, r.Status)
+	}
+	if r.StatusCode != http.StatusPartialContent || !strings.HasPrefix(r.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+		return false, fmt.Errorf("%s: %w: %s %q", fileUrl, ErrRangeIgnored, r.Status, r.Header.Get("Content-Range"))
+	}
+
+	return readAsStream(r.Body, fn)
+
+}
+
+func readAsStream(reader io.Reader, fn func(data []byte)) (retryable bool, err error) {
 	var (
 		m int
 	)
@@ -338,7 +406,15 @@ func ReadUrlAsStream(fileUrl string, cipherKey []byte, isContentGzipped bool, is
 			return true, err
 		}
 	}
+}
 
+// gunzipIfCompressed decompresses the body if it starts as gzip data, and passes it on as is otherwise.
+func gunzipIfCompressed(body io.ReadCloser) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(body)
+	if magic, _ := buffered.Peek(2); !IsGzippedContent(magic) {
+		return io.NopCloser(buffered), nil
+	}
+	return gzip.NewReader(buffered)
 }
 
 func readEncryptedUrl(fileUrl string, cipherKey []byte, isContentCompressed bool, isFullChunk bool, offset int64, size int, fn func(data []byte)) (bool, error) {
@@ -347,13 +423,22 @@ func readEncryptedUrl(fileUrl string, cipherKey []byte, isContentCompressed bool
 		return retryable, fmt.Errorf("fetch %s: %v", fileUrl, err)
 	}
 	decryptedData, err := Decrypt(encryptedData, CipherKey(cipherKey))
+	if errors.Is(err, ErrAuthentication) {
+		// only this copy may have been tampered with
+		return true, fmt.Errorf("decrypt %s: %w", fileUrl, err)
+	}
 	if err != nil {
-		return false, fmt.Errorf("decrypt %s: %v", fileUrl, err)
+		return false, fmt.Errorf("decrypt %s: %w: %v", fileUrl, ErrDecryption, err)
 	}
 	if isContentCompressed {
-		decryptedData, err = DecompressData(decryptedData)
-		if err != nil {
-			glog.V(0).Infof("unzip decrypt %s: %v", fileUrl, err)
+		// content not compressed after all is used as is
+		if IsGzippedContent(decryptedData) {
+			decryptedData, err = DecompressData(decryptedData)
+			if err != nil {
+				return false, fmt.Errorf("unzip decrypted %s: %w: %v", fileUrl, ErrDecompression, err)
+			}
+		} else {
+			glog.V(0).Infof("unzip decrypt %s: %v", fileUrl, UnsupportedCompression)
 		}
 	}
 	if len(decryptedData) < int(offset)+size {
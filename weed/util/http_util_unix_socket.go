@@ -0,0 +1,113 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/chrislusf/seaweedfs/weed/glog"
+)
+
+// The servers on this machine also serving on a unix socket, e.g. a co-located volume server, are reached
+// over their socket instead of over tcp to localhost. The sockets are kept by the port of the servers, so the
+// same configuration can be used on all machines, each one using only the sockets of its own servers.
+var localSockets = struct {
+	sync.RWMutex
+	paths map[int]string
+}{}
+
+var (
+	localHostNames     map[string]bool
+	localHostNamesOnce sync.Once
+)
+
+// ParseLocalSockets parses comma separated <port>:<socket path> pairs, e.g. "8080:/var/run/seaweedfs/volume.sock".
+func ParseLocalSockets(s string) (map[int]string, error) {
+	sockets := make(map[int]string)
+	for _, pair := range strings.Split(s, ",") {
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("local socket %q: expecting <port>:<socket path>", pair)
+		}
+		port, err := strconv.Atoi(parts[0])
+		if err != nil || port <= 0 {
+			return nil, fmt.Errorf("local socket %q: invalid port", pair)
+		}
+		sockets[port] = parts[1]
+	}
+	return sockets, nil
+}
+
+// SetLocalSockets makes the http requests to the local servers of the ports go over their unix sockets,
+// falling back to tcp if a socket can not be connected. Nil reaches all servers over tcp again.
+func SetLocalSockets(sockets map[int]string) {
+	localSockets.Lock()
+	defer localSockets.Unlock()
+	localSockets.paths = sockets
+}
+
+func dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var dialer net.Dialer
+	if socketPath, found := localSocketOf(address); found {
+		conn, err := dialer.DialContext(ctx, "unix", socketPath)
+		if err == nil {
+			return conn, nil
+		}
+		glog.V(1).Infof("dial %s over %s: %v, falling back to tcp", address, socketPath, err)
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+func localSocketOf(address string) (socketPath string, found bool) {
+	localSockets.RLock()
+	defer localSockets.RUnlock()
+	if len(localSockets.paths) == 0 {
+		return "", false
+	}
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", false
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return "", false
+	}
+	if socketPath, found = localSockets.paths[port]; !found || !isLocalHost(host) {
+		return "", false
+	}
+	return socketPath, true
+}
+
+// isLocalHost tells whether the host is a loopback address, or a name or address of this machine
+func isLocalHost(host string) bool {
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	localHostNamesOnce.Do(func() {
+		localHostNames = map[string]bool{"localhost": true}
+		if hostname, err := os.Hostname(); err == nil {
+			localHostNames[hostname] = true
+		}
+		addrs, err := net.InterfaceAddrs()
+		if err != nil {
+			glog.V(0).Infof("list local addresses: %v", err)
+			return
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				localHostNames[ipNet.IP.String()] = true
+			}
+		}
+	})
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+	return localHostNames[host]
+}
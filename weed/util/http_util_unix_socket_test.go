@@ -0,0 +1,98 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func serveChunk(t *testing.T, listener net.Listener, over string) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("chunk over " + over))
+	}))
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	return server
+}
+
+func readChunk(fileUrl string) (string, error) {
+	var data []byte
+	_, err := ReadUrlAsStream(fileUrl, nil, false, true, 0, 0, func(received []byte) {
+		data = append(data, received...)
+	})
+	return string(data), err
+}
+
+func TestReadOverLocalSocket(t *testing.T) {
+	defer SetLocalSockets(nil)
+
+	socketPath := filepath.Join(t.TempDir(), "volume.sock")
+	unixListener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen on %s: %v", socketPath, err)
+	}
+	unixServer := serveChunk(t, unixListener, "socket")
+	defer unixServer.Close()
+
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen on tcp: %v", err)
+	}
+	tcpServer := serveChunk(t, tcpListener, "tcp")
+	defer tcpServer.Close()
+	port := tcpListener.Addr().(*net.TCPAddr).Port
+
+	SetLocalSockets(map[int]string{port: socketPath})
+	if data, err := readChunk(fmt.Sprintf("http://127.0.0.1:%d/3,01637037d6", port)); err != nil || data != "chunk over socket" {
+		t.Errorf("read from the local server: %q, %v", data, err)
+	}
+	if data, err := readChunk(fmt.Sprintf("http://localhost:%d/3,01637037d6", port)); err != nil || data != "chunk over socket" {
+		t.Errorf("read from the local server by name: %q, %v", data, err)
+	}
+
+	// the servers on other ports are reached over tcp
+	SetLocalSockets(map[int]string{port + 1: socketPath})
+	Transport.CloseIdleConnections()
+	if data, err := readChunk(fmt.Sprintf("http://127.0.0.1:%d/3,01637037d6", port)); err != nil || data != "chunk over tcp" {
+		t.Errorf("read from the server without a socket: %q, %v", data, err)
+	}
+
+	// and so are the ones whose socket can not be connected
+	SetLocalSockets(map[int]string{port: filepath.Join(t.TempDir(), "missing.sock")})
+	Transport.CloseIdleConnections()
+	if data, err := readChunk(fmt.Sprintf("http://127.0.0.1:%d/3,01637037d6", port)); err != nil || data != "chunk over tcp" {
+		t.Errorf("read from the server with a missing socket: %q, %v", data, err)
+	}
+}
+
+func TestLocalSocketsOfRemoteServers(t *testing.T) {
+	defer SetLocalSockets(nil)
+
+	SetLocalSockets(map[int]string{8080: "/var/run/volume.sock"})
+	for address, expected := range map[string]bool{
+		"127.0.0.1:8080":                  true,
+		"[::1]:8080":                      true,
+		"localhost:8080":                  true,
+		"127.0.0.1:8081":                  false,
+		"192.0.2.10:8080":                 false,
+		"volume-remote-host.invalid:8080": false,
+	} {
+		if _, found := localSocketOf(address); found != expected {
+			t.Errorf("%s over the local socket: %v, expect %v", address, found, expected)
+		}
+	}
+
+	sockets, err := ParseLocalSockets("8080:/var/run/volume.sock,18080:/tmp/a:b.sock")
+	if err != nil || sockets[8080] != "/var/run/volume.sock" || sockets[18080] != "/tmp/a:b.sock" {
+		t.Errorf("parsed %v, %v", sockets, err)
+	}
+	for _, invalid := range []string{"8080", "x:/a.sock", "8080:", "-1:/a.sock"} {
+		if _, err := ParseLocalSockets(invalid); err == nil {
+			t.Errorf("parsed invalid %q", invalid)
+		}
+	}
+}
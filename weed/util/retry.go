@@ -1,7 +1,10 @@
 package util
 
 import (
+	"fmt"
+	"math/rand"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/chrislusf/seaweedfs/weed/glog"
@@ -32,6 +35,84 @@ func Retry(name string, job func() error) (err error) {
 	return err
 }
 
+// Backoff spaces out retries exponentially, with jitter so that clients failing together
+// do not retry in lockstep, and gives up once the total wait would exceed MaxTotalWait.
+type Backoff struct {
+	InitialWait  time.Duration
+	MaxWait      time.Duration // if not 0, the cap of each wait
+	Multiplier   float64
+	Jitter       float64 // the fraction of each wait that is randomized, from 0 to 1
+	MaxTotalWait time.Duration
+
+	// if set, used for the jitter instead of the global source, e.g. seeded for reproducible waits
+	Rand     *rand.Rand
+	randLock sync.Mutex
+
+	// if set, used to wait instead of the system clock, e.g. a TestClock
+	Clock Clock
+}
+
+func NewBackoff(initialWait, maxTotalWait time.Duration) *Backoff {
+	return &Backoff{
+		InitialWait:  initialWait,
+		Multiplier:   2,
+		Jitter:       0.5,
+		MaxTotalWait: maxTotalWait,
+	}
+}
+
+// Wait returns the wait before the retry following the given number of failed attempts, starting from 1.
+func (b *Backoff) Wait(failures int) time.Duration {
+	wait := float64(b.InitialWait)
+	for i := 1; i < failures; i++ {
+		wait *= b.Multiplier
+		if b.MaxWait > 0 && wait >= float64(b.MaxWait) {
+			wait = float64(b.MaxWait)
+			break
+		}
+	}
+	var r float64
+	if b.Rand != nil {
+		b.randLock.Lock()
+		r = b.Rand.Float64()
+		b.randLock.Unlock()
+	} else {
+		r = rand.Float64()
+	}
+	return time.Duration(wait * (1 - b.Jitter*r))
+}
+
+func (b *Backoff) clock() Clock {
+	if b.Clock == nil {
+		return RealClock
+	}
+	return b.Clock
+}
+
+// RetryWithBackoff retries the job on the same transport errors as Retry, waiting as the backoff tells.
+func RetryWithBackoff(name string, backoff *Backoff, job func() error) (err error) {
+	var totalWait time.Duration
+	for failures := 1; ; failures++ {
+		err = job()
+		if err == nil {
+			if failures > 1 {
+				glog.V(0).Infof("retry %s successfully", name)
+			}
+			return nil
+		}
+		if !strings.Contains(err.Error(), "transport") {
+			return err
+		}
+		waitTime := backoff.Wait(failures)
+		if totalWait+waitTime > backoff.MaxTotalWait {
+			return fmt.Errorf("%s: giving up after %d attempts in %v: %w", name, failures, totalWait, err)
+		}
+		glog.V(0).Infof("retry %s in %v: err: %v", name, waitTime, err)
+		<-backoff.clock().After(waitTime)
+		totalWait += waitTime
+	}
+}
+
 func RetryForever(name string, job func() error, onErrFn func(err error) bool) {
 	waitTime := time.Second
 	for {